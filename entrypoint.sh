@@ -29,6 +29,14 @@ else
     exit 1
 fi
 
+# Generate the token openstack-cni uses to authenticate with the daemon
+CNI_TOKEN_FILE="$HOST_CNI_ETC_DIR/openstack-cni.token"
+if [ ! -s "$CNI_TOKEN_FILE" ]; then
+  (umask 077 && head -c 32 /dev/urandom | od -An -tx1 | tr -d ' \n' > "$CNI_TOKEN_FILE")
+  echo "Generated auth token $CNI_TOKEN_FILE"
+fi
+export CNI_AUTH_TOKEN_FILE="$CNI_TOKEN_FILE"
+
 CNI_CONF_FILE="$HOST_CNI_ETC_DIR/openstack-cni.conf"
 # Write out config that the CNI needs to run in kubelet's context
 if [ ! -f "$CNI_CONF_FILE" ]; then
@@ -45,6 +53,26 @@ if [ ! -f "$CNI_CONF_FILE" ]; then
   echo "CNI_LOG_FILENAME=$CNI_LOG_FILENAME" >> "$CNI_CONF_FILE"
   echo "CNI_LOG_LEVEL=$CNI_LOG_LEVEL" >> "$CNI_CONF_FILE"
 fi
+# the plugin runs in the host's filesystem
+if ! grep -q "^CNI_AUTH_TOKEN_FILE=" "$CNI_CONF_FILE"; then
+  echo "CNI_AUTH_TOKEN_FILE=/etc/cni/net.d/openstack-cni.token" >> "$CNI_CONF_FILE"
+fi
+
+# the plugin presents the client certificate from the host's filesystem
+if [ -n "$CNI_CLIENT_TLS_CERT_FILE" ] || [ -n "$CNI_CLIENT_TLS_CA_FILE" ]; then
+  HOST_CNI_TLS_DIR="$HOST_CNI_ETC_DIR/openstack-cni-tls"
+  (umask 077 && mkdir -p "$HOST_CNI_TLS_DIR")
+  sed -i '/^CNI_CLIENT_TLS_/d' "$CNI_CONF_FILE"
+  if [ -n "$CNI_CLIENT_TLS_CERT_FILE" ]; then
+    (umask 077 && cp -f "$CNI_CLIENT_TLS_CERT_FILE" "$HOST_CNI_TLS_DIR/tls.crt" && cp -f "$CNI_CLIENT_TLS_KEY_FILE" "$HOST_CNI_TLS_DIR/tls.key")
+    echo "CNI_CLIENT_TLS_CERT_FILE=/etc/cni/net.d/openstack-cni-tls/tls.crt" >> "$CNI_CONF_FILE"
+    echo "CNI_CLIENT_TLS_KEY_FILE=/etc/cni/net.d/openstack-cni-tls/tls.key" >> "$CNI_CONF_FILE"
+  fi
+  if [ -n "$CNI_CLIENT_TLS_CA_FILE" ]; then
+    (umask 077 && cp -f "$CNI_CLIENT_TLS_CA_FILE" "$HOST_CNI_TLS_DIR/ca.crt")
+    echo "CNI_CLIENT_TLS_CA_FILE=/etc/cni/net.d/openstack-cni-tls/ca.crt" >> "$CNI_CONF_FILE"
+  fi
+fi
 
 ## disable this after testing
 # allow the binary to be injected from the host's filesystem
@@ -13,10 +13,13 @@ require (
 	github.com/go-chi/chi/v5 v5.2.1 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
+	github.com/hashicorp/go-uuid v1.0.3 // indirect
 	github.com/klauspost/compress v1.18.0 // indirect
+	github.com/kylelemons/godebug v1.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.14 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/matttproud/golang_protobuf_extensions v1.0.4 // indirect
+	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
 	github.com/onsi/ginkgo/v2 v2.19.0 // indirect
 	github.com/onsi/gomega v1.33.1 // indirect
@@ -30,13 +33,17 @@ require (
 
 require (
 	github.com/Code-Hex/go-generics-cache v1.5.1
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/go-chi/chi v1.5.5
 	github.com/go-chi/httplog v0.3.2
 	github.com/gophercloud/gophercloud v1.14.1
+	github.com/gophercloud/utils v0.0.0-20231010081019-80377eca5d56
 	github.com/hashicorp/go-multierror v1.1.1
 	github.com/joho/godotenv v1.5.1
 	github.com/pepinns/go-hamcrest v0.0.0-20221012173254-3e7e5015d27c
 	github.com/prometheus/client_golang v1.21.0
 	github.com/rs/zerolog v1.33.0
 	github.com/vishvananda/netlink v1.3.0
+	golang.org/x/sync v0.11.0
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -2,9 +2,11 @@ package cniclient
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -36,23 +38,96 @@ func New(opts *ClientOpts) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{
-		Opts: ClientOpts{
-			BaseUrl:        util.Getenv("CNI_API_URL", "http://127.0.0.1:4242"),
-			RequestTimeout: timeout,
-			LogFileName:    util.Getenv("CNI_LOG_FILE_NAME", ""),
-		},
-	}, nil
+	clientOpts := ClientOpts{
+		BaseUrl:        util.Getenv("CNI_API_URL", "http://127.0.0.1:4242"),
+		RequestTimeout: timeout,
+		LogFileName:    util.Getenv("CNI_LOG_FILE_NAME", ""),
+	}
+	if err := clientOpts.LoadCredentials(); err != nil {
+		return nil, err
+	}
+
+	return &Client{Opts: clientOpts}, nil
+}
+
+// LoadCredentials reads the auth token and tls settings from the environment
+func (me *ClientOpts) LoadCredentials() error {
+	var err error
+	if tokenFile := util.Getenv("CNI_AUTH_TOKEN_FILE", ""); tokenFile != "" {
+		if me.AuthToken, err = util.ReadToken(tokenFile); err != nil {
+			return err
+		}
+	}
+	me.TLSConfig, err = newTLSConfig()
+	return err
+}
+
+// newTLSConfig creates the tls configuration used to verify the daemon and present a client certificate
+// nil is returned when no tls settings are configured
+func newTLSConfig() (*tls.Config, error) {
+	certFile := util.Getenv("CNI_CLIENT_TLS_CERT_FILE", "")
+	keyFile := util.Getenv("CNI_CLIENT_TLS_KEY_FILE", "")
+	caFile := util.Getenv("CNI_CLIENT_TLS_CA_FILE", "")
+	if certFile == "" && caFile == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate err=%w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pool, err := util.LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
 }
 
 type Client struct {
 	Opts ClientOpts
 }
 
+// Url returns the url for a path on the daemon, requests to a unix socket use a placeholder host
 func (me *Client) Url(path string) string {
+	if me.socketPath() != "" {
+		return fmt.Sprintf("http://unix%s", path)
+	}
 	return fmt.Sprintf("%s%s", me.Opts.BaseUrl, path)
 }
 
+func (me *Client) socketPath() string {
+	network, addr, err := util.ParseApiUrl(me.Opts.BaseUrl)
+	if err != nil || network != "unix" {
+		return ""
+	}
+	return addr
+}
+
+func (me *Client) httpClient() *http.Client {
+	socketPath := me.socketPath()
+	if socketPath == "" && me.Opts.TLSConfig == nil {
+		return http.DefaultClient
+	}
+	transport := &http.Transport{
+		DisableKeepAlives: true,
+		TLSClientConfig:   me.Opts.TLSConfig,
+	}
+	if socketPath != "" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+	}
+	return &http.Client{Transport: transport}
+}
+
 func (me *Client) CniCommand(cmd util.CniCommand) (*http.Response, error) {
 	url := me.Url("/cni")
 
@@ -94,9 +169,15 @@ func (me *Client) doRequest(url string, method string, body *[]byte) (*http.Resp
 	} else if method == http.MethodGet || method == http.MethodDelete {
 		req, err = http.NewRequestWithContext(ctx, method, url, nil)
 	}
+	if err != nil {
+		return nil, err
+	}
+	if me.Opts.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+me.Opts.AuthToken)
+	}
 
 	// send the request
-	return http.DefaultClient.Do(req)
+	return me.httpClient().Do(req)
 }
 
 func (me *Client) HandleResponse(resp *http.Response, err error) ([]byte, error) {
@@ -125,8 +206,12 @@ func (me *Client) handleResponse(resp *http.Response) ([]byte, error) {
 	return body, nil
 }
 
+// ClientOpts configures the Client
+// AuthToken is sent as a bearer token and TLSConfig is used for https BaseUrls
 type ClientOpts struct {
 	BaseUrl        string
 	RequestTimeout time.Duration
 	LogFileName    string
+	AuthToken      string
+	TLSConfig      *tls.Config
 }
@@ -46,6 +46,10 @@ func (me *App) Run() error {
 		RequestTimeout: me.config.RequestTimeout,
 		LogFileName:    me.config.LogFileName,
 	}
+	if err := clientOpts.LoadCredentials(); err != nil {
+		logging.Error("failed to load cni client credentials", err)
+		os.Exit(1)
+	}
 
 	// create a new cniclient
 	client, err := cniclient.New(clientOpts)
@@ -60,7 +64,6 @@ func (me *App) Run() error {
 		CniOpts{
 			WaitForUdev:        me.config.WaitForUdev,
 			WaitForUdevPrefix:  me.config.WaitForUdevPrefix,
-			WaitForUdevDelay:   me.config.WaitForUdevDelay,
 			WaitForUdevTimeout: me.config.WaitForUdevTimeout,
 		})
 	return cni.Invoke()
@@ -6,8 +6,9 @@ import (
 
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
-	currentcni "github.com/containernetworking/cni/pkg/types/040"
+	currentcni "github.com/containernetworking/cni/pkg/types/100"
 	cniversion "github.com/containernetworking/cni/pkg/version"
+	"github.com/hashicorp/go-multierror"
 	"github.com/jboelensns/openstack-cni/pkg/cniclient"
 	"github.com/jboelensns/openstack-cni/pkg/cniserver"
 	"github.com/jboelensns/openstack-cni/pkg/logging"
@@ -16,18 +17,16 @@ import (
 )
 
 type CniOpts struct {
-	WaitForUdev       bool
-	WaitForUdevPrefix string
-	WaitForUdevDelay  time.Duration
-	WaitForUdevTimeout  time.Duration
+	WaitForUdev        bool
+	WaitForUdevPrefix  string
+	WaitForUdevTimeout time.Duration
 }
 
 func DefaultCniOpts() CniOpts {
 	return CniOpts{
-		WaitForUdev:       true,
-		WaitForUdevPrefix: "eth",
-		WaitForUdevDelay:  100 * time.Millisecond,
-		WaitForUdevTimeout:  5000 * time.Millisecond,
+		WaitForUdev:        true,
+		WaitForUdevPrefix:  "eth",
+		WaitForUdevTimeout: 5000 * time.Millisecond,
 	}
 }
 
@@ -66,6 +65,11 @@ func (me *Cni) Add(args *skel.CmdArgs) error {
 	}
 
 	if err := me.ConfigureInterface(cmd, &result); err != nil {
+		// the daemon attached a port that we failed to configure so release it
+		logging.Log().Error().Err(err).Str("container_id", args.ContainerID).Msg("failed to configure interface, releasing port")
+		if derr := me.Del(args); derr != nil {
+			return multierror.Append(err, fmt.Errorf("failed to release port: %w", derr))
+		}
 		return err
 	}
 
@@ -77,28 +81,82 @@ func (me *Cni) Add(args *skel.CmdArgs) error {
 	return finalResult.Print()
 }
 
+// ErrCodeInterfaceMismatch is returned by CHECK when the interface inside of the container doesn't match the prevResult
+const ErrCodeInterfaceMismatch uint = 110
+
 // Check handles CHECK CNI commands
 func (me *Cni) Check(args *skel.CmdArgs) error {
+	prevResult, err := util.NewPrevResult(args.StdinData)
+	if err != nil {
+		return types.NewError(types.ErrInvalidNetworkConfig, "failed to parse prevResult", err.Error())
+	}
+
+	// ensure the port is still in the expected state
 	cmd := cniCommandFromSkelArgs(cniserver.CommandCheck, args)
-	_, err := me.client.HandleResponse(me.client.CniCommand(cmd))
-	return err
+	if _, err := me.client.HandleResponse(me.client.CniCommand(cmd)); err != nil {
+		return err
+	}
+
+	// ensure the interface inside of the container is still in the expected state
+	for i, iface := range prevResult.Interfaces {
+		if iface.Name != args.IfName {
+			continue
+		}
+		netIface := &NetworkInterface{DestName: iface.Name, Mac: iface.Mac, Addresses: interfaceAddresses(prevResult, i)}
+		if err := me.nw.Check(args.Netns, netIface); err != nil {
+			return types.NewError(ErrCodeInterfaceMismatch, "interface does not match prevResult", err.Error())
+		}
+		return nil
+	}
+	return types.NewError(ErrCodeInterfaceMismatch, "interface missing from prevResult", fmt.Sprintf("ifname=%s", args.IfName))
 }
 
 // Del handles DEL CNI commands
 func (me *Cni) Del(args *skel.CmdArgs) error {
+	// return the interface to the host before the port is detached so it isn't left configured inside of the pod
+	// the port is released regardless since the daemon can still clean it up
+	if err := me.nw.Unconfigure(args.Netns, args.IfName); err != nil {
+		logging.Error(fmt.Sprintf("failed to unconfigure interface for args=%s", args), err)
+	}
+
 	cmd := cniCommandFromSkelArgs(cniserver.CommandDel, args)
 	_, err := me.client.HandleResponse(me.client.CniCommand(cmd))
 	return err
 }
 
+// GC handles GC commands
+// the daemon deletes this host's ports for the network that aren't in the list of valid attachments
+func (me *Cni) GC(args *skel.CmdArgs) error {
+	cmd := cniCommandFromSkelArgs(cniserver.CommandGC, args)
+	_, err := me.client.HandleResponse(me.client.CniCommand(cmd))
+	return err
+}
+
+// ErrCodePluginNotAvailable is returned by STATUS when the plugin can't service ADD requests
+const ErrCodePluginNotAvailable uint = 50
+
+// Status handles STATUS commands
+// the plugin is available when the daemon is reachable and reports that it's healthy
+func (me *Cni) Status(args *skel.CmdArgs) error {
+	body, err := me.client.HandleResponse(me.client.Get(me.client.Url("/health")))
+	if err != nil {
+		var health cniserver.HealthResponse
+		if len(body) == 0 || util.FromJson(body, &health) != nil {
+			return types.NewError(ErrCodePluginNotAvailable, "daemon is unreachable", err.Error())
+		}
+		return types.NewError(ErrCodePluginNotAvailable, "daemon is unhealthy", health.String())
+	}
+	return nil
+}
+
 func argLogContext(l zerolog.Context, args *skel.CmdArgs) zerolog.Logger {
 	return l.Str("container_id", args.ContainerID).Str("ns", args.Netns).Str("iface", args.IfName).Str("args", args.Args).Str("path", args.Path).Logger()
 }
 
 // Invoke invokes the CNI plugin skeletons using its own methods
 func (me *Cni) Invoke() error {
-	err := skel.PluginMainWithError(
-		func(args *skel.CmdArgs) error {
+	err := skel.PluginMainFuncsWithError(skel.CNIFuncs{
+		Add: func(args *skel.CmdArgs) error {
 			log := argLogContext(logging.Log().With(), args)
 			log.Info().Msg("received ADD")
 			err := me.Add(args)
@@ -110,7 +168,7 @@ func (me *Cni) Invoke() error {
 
 			return err
 		},
-		func(args *skel.CmdArgs) error {
+		Check: func(args *skel.CmdArgs) error {
 			log := argLogContext(logging.Log().With(), args)
 			log.Info().Msg("received CHECK")
 			err := me.Check(args)
@@ -121,7 +179,7 @@ func (me *Cni) Invoke() error {
 			}
 			return err
 		},
-		func(args *skel.CmdArgs) error {
+		Del: func(args *skel.CmdArgs) error {
 			log := argLogContext(logging.Log().With(), args)
 			log.Info().Msg("received DEL")
 			err := me.Del(args)
@@ -132,6 +190,29 @@ func (me *Cni) Invoke() error {
 			}
 			return err
 		},
+		GC: func(args *skel.CmdArgs) error {
+			log := argLogContext(logging.Log().With(), args)
+			log.Info().Msg("received GC")
+			err := me.GC(args)
+			if err != nil {
+				logging.Error(fmt.Sprintf("error invoking CNI GC for args=%s", args), err)
+			} else {
+				log.Info().Msg("successful GC")
+			}
+			return err
+		},
+		Status: func(args *skel.CmdArgs) error {
+			log := argLogContext(logging.Log().With(), args)
+			log.Info().Msg("received STATUS")
+			err := me.Status(args)
+			if err != nil {
+				logging.Error(fmt.Sprintf("error invoking CNI STATUS for args=%s", args), err)
+			} else {
+				log.Info().Msg("successful STATUS")
+			}
+			return err
+		},
+	},
 		cniversion.All,
 		"openstack CNI plugin that plumbs neutron ports into containers")
 
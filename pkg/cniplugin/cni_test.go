@@ -1,19 +1,26 @@
 package cniplugin_test
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
 	"testing"
 	"time"
 
-	currentcni "github.com/containernetworking/cni/pkg/types/040"
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	currentcni "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/go-chi/httplog"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/jboelensns/openstack-cni/pkg/cniclient"
 	"github.com/jboelensns/openstack-cni/pkg/cniplugin"
+	"github.com/jboelensns/openstack-cni/pkg/cniserver"
 	"github.com/jboelensns/openstack-cni/pkg/fixtures"
 	. "github.com/jboelensns/openstack-cni/pkg/fixtures"
 	"github.com/jboelensns/openstack-cni/pkg/fixtures/mocks"
 	"github.com/jboelensns/openstack-cni/pkg/logging"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
 	"github.com/jboelensns/openstack-cni/pkg/util"
 	. "github.com/pepinns/go-hamcrest"
 )
@@ -35,12 +42,6 @@ func Test_Cni(t *testing.T) {
 		// if none of the interfaces had a MAC default to the first interface
 		return ifaces[0].HardwareAddr.String()
 	}
-	getLocalMacAddr := func(t *testing.T) net.HardwareAddr {
-		t.Helper()
-		mac, err := net.ParseMAC(getLocalMac(t))
-		Assert(t).That(err, IsNil())
-		return mac
-	}
 
 	t.Run("can execute an add", func(t *testing.T) {
 		cniHandler := &mocks.CommandHandlerMock{}
@@ -50,7 +51,7 @@ func Test_Cni(t *testing.T) {
 		WithServerOpts(t, sopts, func(fix *ServerFixture) {
 			cniclient := fix.CniClient()
 			// provide a meaningful result back from the http server
-			cniHandler.AddFunc = func(cmd util.CniCommand) (*currentcni.Result, error) {
+			cniHandler.AddFunc = func(ctx context.Context, cmd util.CniCommand) (*currentcni.Result, error) {
 				result := testData.CniResult()
 
 				// setup the mac as the mac of an interface on our machine so the lookup doesn't fail
@@ -59,7 +60,7 @@ func Test_Cni(t *testing.T) {
 				return result, nil
 			}
 
-			networking.GetIfaceByMacFunc = func(mac string) (*net.Interface, error) {
+			networking.WaitForIfaceFunc = func(mac string, opts cniplugin.IfaceWaitOpts) (*net.Interface, error) {
 				return &net.Interface{}, nil
 			}
 
@@ -76,51 +77,72 @@ func Test_Cni(t *testing.T) {
 
 			Assert(t).That(cniHandler.AddCalls(), HasLen(1))
 			Assert(t).That(networking.ConfigureCalls(), HasLen(1))
+			iface := networking.ConfigureCalls()[0].Iface
+			Assert(t).That(iface.Mtu, Equals(1450))
+			Assert(t).That(iface.Routes, HasLen(1))
+			Assert(t).That(iface.Routes[0].Dst.String(), Equals("192.168.1.100/32"))
 		})
 	})
 
-	t.Run("retrying GetIfaceByMacFunc works", func(t *testing.T) {
+	t.Run("add configures every address of a dual-stack result", func(t *testing.T) {
 		cniHandler := &mocks.CommandHandlerMock{}
 		networking := &mocks.NetworkingMock{}
 		sopts := &ServerOpts{CniHandler: cniHandler, Networking: networking}
 
 		WithServerOpts(t, sopts, func(fix *ServerFixture) {
-			cniclient := fix.CniClient()
-			// provide a meaningful result back from the http server
-			result := testData.CniResult()
-			// setup the mac as the mac of an interface on our machine so the lookup doesn't fail
-			result.Interfaces[0].Mac = getLocalMac(t)
-			cniHandler.AddFunc = func(cmd util.CniCommand) (*currentcni.Result, error) {
+			cniHandler.AddFunc = func(ctx context.Context, cmd util.CniCommand) (*currentcni.Result, error) {
+				result := testData.CniResult()
+				v6, _ := util.GetIpNetFromAddress("2001:db8::42/64")
+				result.IPs = append(result.IPs, &currentcni.IPConfig{
+					Interface: result.IPs[0].Interface,
+					Address:   *v6,
+					Gateway:   net.ParseIP("2001:db8::1"),
+				})
 				return result, nil
 			}
-
-			// simiulate failure to look up the mac address for some amount of time
-			count := 0
-			networking.GetIfaceByMacFunc = func(mac string) (*net.Interface, error) {
-				if count < 3 {
-					time.Sleep(time.Duration(time.Millisecond * 50))
-					count += 1
-					return nil, fmt.Errorf("interface not found")
-				}
-				return &net.Interface{
-					HardwareAddr: getLocalMacAddr(t),
-				}, nil
+			networking.WaitForIfaceFunc = func(mac string, opts cniplugin.IfaceWaitOpts) (*net.Interface, error) {
+				return &net.Interface{Name: "ens4"}, nil
 			}
-
-			// skip doing any of the netlink configuration
 			networking.ConfigureFunc = func(namespace string, iface *cniplugin.NetworkInterface) error {
 				return nil
 			}
 
-			args := testData.SkelArgs()
-
-			cniOpts := cniplugin.DefaultCniOpts()
-			cni := cniplugin.NewCni(cniclient, networking, cniOpts)
-			err := cni.Add(args)
-			Assert(t).That(err, IsNil())
+			cni := cniplugin.NewCni(fix.CniClient(), networking, cniplugin.DefaultCniOpts())
+			Assert(t).That(cni.Add(testData.SkelArgs()), IsNil())
 
-			Assert(t).That(cniHandler.AddCalls(), HasLen(1))
 			Assert(t).That(networking.ConfigureCalls(), HasLen(1))
+			Assert(t).That(networking.ConfigureCalls()[0].Iface.AddressStrings(), Equals([]string{"192.168.1.42/32", "2001:db8::42/64"}))
+		})
+	})
+
+	t.Run("add releases the port when configuring the interface fails", func(t *testing.T) {
+		cniHandler := &mocks.CommandHandlerMock{}
+		networking := &mocks.NetworkingMock{}
+		sopts := &ServerOpts{CniHandler: cniHandler, Networking: networking}
+
+		WithServerOpts(t, sopts, func(fix *ServerFixture) {
+			cniHandler.AddFunc = func(ctx context.Context, cmd util.CniCommand) (*currentcni.Result, error) {
+				return testData.CniResult(), nil
+			}
+			cniHandler.DelFunc = func(ctx context.Context, cmd util.CniCommand) error {
+				return nil
+			}
+			networking.WaitForIfaceFunc = func(mac string, opts cniplugin.IfaceWaitOpts) (*net.Interface, error) {
+				return &net.Interface{Name: "ens4"}, nil
+			}
+			networking.ConfigureFunc = func(namespace string, iface *cniplugin.NetworkInterface) error {
+				return fmt.Errorf("BOOM")
+			}
+			networking.UnconfigureFunc = func(namespace string, ifName string) error {
+				return nil
+			}
+
+			cni := cniplugin.NewCni(fix.CniClient(), networking, cniplugin.DefaultCniOpts())
+			err := cni.Add(testData.SkelArgs())
+			Assert(t).That(err, Not(IsNil()))
+			Assert(t).That(err.Error(), Contains("BOOM"))
+
+			Assert(t).That(cniHandler.DelCalls(), HasLen(1))
 		})
 	})
 
@@ -132,7 +154,10 @@ func Test_Cni(t *testing.T) {
 
 		WithServerOpts(t, sopts, func(fix *ServerFixture) {
 			cniclient := fix.CniClient()
-			cniHandler.DelFunc = func(cmd util.CniCommand) error {
+			cniHandler.DelFunc = func(ctx context.Context, cmd util.CniCommand) error {
+				return nil
+			}
+			networking.UnconfigureFunc = func(namespace string, ifName string) error {
 				return nil
 			}
 			args := testData.SkelArgs()
@@ -141,10 +166,174 @@ func Test_Cni(t *testing.T) {
 			err := cni.Del(args)
 			Assert(t).That(err, IsNil())
 
+			Assert(t).That(cniHandler.DelCalls(), HasLen(1))
+			Assert(t).That(networking.UnconfigureCalls(), HasLen(1))
+			Assert(t).That(networking.UnconfigureCalls()[0].Namespace, Equals(args.Netns))
+			Assert(t).That(networking.UnconfigureCalls()[0].IfName, Equals(args.IfName))
+		})
+	})
+
+	t.Run("delete releases the port when unconfiguring the interface fails", func(t *testing.T) {
+		cniHandler := &mocks.CommandHandlerMock{}
+		networking := &mocks.NetworkingMock{}
+		sopts := &ServerOpts{CniHandler: cniHandler, Networking: networking}
+
+		WithServerOpts(t, sopts, func(fix *ServerFixture) {
+			cniHandler.DelFunc = func(ctx context.Context, cmd util.CniCommand) error {
+				return nil
+			}
+			networking.UnconfigureFunc = func(namespace string, ifName string) error {
+				return fmt.Errorf("BOOM")
+			}
+
+			cni := cniplugin.NewCni(fix.CniClient(), networking, cniplugin.DefaultCniOpts())
+			Assert(t).That(cni.Del(testData.SkelArgs()), IsNil())
+
 			Assert(t).That(cniHandler.DelCalls(), HasLen(1))
 		})
 	})
 
+	t.Run("can execute a check", func(t *testing.T) {
+		cniHandler := &mocks.CommandHandlerMock{}
+		networking := &mocks.NetworkingMock{}
+		sopts := &ServerOpts{CniHandler: cniHandler, Networking: networking}
+
+		WithServerOpts(t, sopts, func(fix *ServerFixture) {
+			cniHandler.CheckFunc = func(ctx context.Context, cmd util.CniCommand) error {
+				return nil
+			}
+			networking.CheckFunc = func(namespace string, iface *cniplugin.NetworkInterface) error {
+				return nil
+			}
+
+			args := testData.SkelArgs()
+			result := testData.CniResult()
+			result.Interfaces[0].Name = args.IfName
+			args.StdinData = testData.StdinWithPrevResult(result)
+
+			cni := cniplugin.NewCni(fix.CniClient(), networking, cniplugin.DefaultCniOpts())
+			Assert(t).That(cni.Check(args), IsNil())
+
+			Assert(t).That(cniHandler.CheckCalls(), HasLen(1))
+			Assert(t).That(networking.CheckCalls(), HasLen(1))
+			iface := networking.CheckCalls()[0].Iface
+			Assert(t).That(iface.DestName, Equals(args.IfName))
+			Assert(t).That(iface.Mac, Equals(result.Interfaces[0].Mac))
+			Assert(t).That(iface.Addresses, HasLen(1))
+			Assert(t).That(iface.Addresses[0].String(), Equals(result.IPs[0].Address.String()))
+		})
+	})
+
+	t.Run("check returns an error when the interface does not match", func(t *testing.T) {
+		cniHandler := &mocks.CommandHandlerMock{}
+		networking := &mocks.NetworkingMock{}
+		sopts := &ServerOpts{CniHandler: cniHandler, Networking: networking}
+
+		WithServerOpts(t, sopts, func(fix *ServerFixture) {
+			cniHandler.CheckFunc = func(ctx context.Context, cmd util.CniCommand) error {
+				return nil
+			}
+			networking.CheckFunc = func(namespace string, iface *cniplugin.NetworkInterface) error {
+				return fmt.Errorf("interface mac mismatch")
+			}
+
+			args := testData.SkelArgs()
+			result := testData.CniResult()
+			result.Interfaces[0].Name = args.IfName
+			args.StdinData = testData.StdinWithPrevResult(result)
+
+			cni := cniplugin.NewCni(fix.CniClient(), networking, cniplugin.DefaultCniOpts())
+			err := cni.Check(args)
+			cniErr, ok := err.(*types.Error)
+			Assert(t).That(ok, IsTrue())
+			Assert(t).That(cniErr.Code, Equals(cniplugin.ErrCodeInterfaceMismatch))
+		})
+	})
+
+	t.Run("check returns the daemon's error code", func(t *testing.T) {
+		cniHandler := &mocks.CommandHandlerMock{}
+		networking := &mocks.NetworkingMock{}
+		sopts := &ServerOpts{CniHandler: cniHandler, Networking: networking}
+
+		WithServerOpts(t, sopts, func(fix *ServerFixture) {
+			cniHandler.CheckFunc = func(ctx context.Context, cmd util.CniCommand) error {
+				return types.NewError(cniserver.ErrCodePortNotActive, "port is not active", "")
+			}
+
+			args := testData.SkelArgs()
+			result := testData.CniResult()
+			result.Interfaces[0].Name = args.IfName
+			args.StdinData = testData.StdinWithPrevResult(result)
+
+			cni := cniplugin.NewCni(fix.CniClient(), networking, cniplugin.DefaultCniOpts())
+			err := cni.Check(args)
+			cniErr, ok := err.(*types.Error)
+			Assert(t).That(ok, IsTrue())
+			Assert(t).That(cniErr.Code, Equals(cniserver.ErrCodePortNotActive))
+			Assert(t).That(networking.CheckCalls(), HasLen(0))
+		})
+	})
+
+	t.Run("can execute a gc", func(t *testing.T) {
+		cniHandler := &mocks.CommandHandlerMock{}
+		networking := &mocks.NetworkingMock{}
+		sopts := &ServerOpts{CniHandler: cniHandler, Networking: networking}
+
+		WithServerOpts(t, sopts, func(fix *ServerFixture) {
+			cniHandler.GCFunc = func(ctx context.Context, cmd util.CniCommand) error {
+				return nil
+			}
+
+			args := &skel.CmdArgs{StdinData: testData.StdinWithValidAttachments(types.GCAttachment{ContainerID: "containerId", IfName: "eth37"})}
+			cni := cniplugin.NewCni(fix.CniClient(), networking, cniplugin.DefaultCniOpts())
+			Assert(t).That(cni.GC(args), IsNil())
+
+			Assert(t).That(cniHandler.GCCalls(), HasLen(1))
+			Assert(t).That(cniHandler.GCCalls()[0].Cmd.Command, Equals(cniserver.CommandGC))
+			Assert(t).That(cniHandler.GCCalls()[0].Cmd.StdinData, Equals(args.StdinData))
+		})
+	})
+
+	t.Run("status succeeds when the daemon is healthy", func(t *testing.T) {
+		osClient := &mocks.OpenstackClientMock{}
+		osClient.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return nil, openstack.ErrServerNotFound
+		}
+
+		WithServerOpts(t, &ServerOpts{OpenstackClient: osClient}, func(fix *ServerFixture) {
+			cni := cniplugin.NewCni(fix.CniClient(), &mocks.NetworkingMock{}, cniplugin.DefaultCniOpts())
+			Assert(t).That(cni.Status(testData.SkelArgs()), IsNil())
+		})
+	})
+
+	t.Run("status fails when the daemon is unhealthy", func(t *testing.T) {
+		osClient := &mocks.OpenstackClientMock{}
+		osClient.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return nil, fmt.Errorf("BOOM")
+		}
+
+		WithServerOpts(t, &ServerOpts{OpenstackClient: osClient}, func(fix *ServerFixture) {
+			cni := cniplugin.NewCni(fix.CniClient(), &mocks.NetworkingMock{}, cniplugin.DefaultCniOpts())
+			err := cni.Status(testData.SkelArgs())
+			cniErr, ok := err.(*types.Error)
+			Assert(t).That(ok, IsTrue())
+			Assert(t).That(cniErr.Code, Equals(cniplugin.ErrCodePluginNotAvailable))
+			Assert(t).That(cniErr.Details, Contains("BOOM"))
+		})
+	})
+
+	t.Run("status fails when the daemon is unreachable", func(t *testing.T) {
+		client, err := cniclient.New(&cniclient.ClientOpts{BaseUrl: "http://127.0.0.1:1", RequestTimeout: time.Second})
+		Assert(t).That(err, IsNil())
+
+		cni := cniplugin.NewCni(client, &mocks.NetworkingMock{}, cniplugin.DefaultCniOpts())
+		err = cni.Status(testData.SkelArgs())
+		cniErr, ok := err.(*types.Error)
+		Assert(t).That(ok, IsTrue())
+		Assert(t).That(cniErr.Code, Equals(cniplugin.ErrCodePluginNotAvailable))
+		Assert(t).That(cniErr.Msg, Equals("daemon is unreachable"))
+	})
+
 	t.Run("waitForUdev defaults to true", func(t *testing.T) {
 		cfg, err := cniplugin.LoadConfig()
 		Assert(t).That(err, IsNil())
@@ -15,7 +15,6 @@ type Config struct {
 	LogLevel           string
 	WaitForUdev        bool
 	WaitForUdevPrefix  string
-	WaitForUdevDelay   time.Duration
 	WaitForUdevTimeout time.Duration
 }
 
@@ -38,11 +37,6 @@ func LoadConfig() (Config, error) {
 		return config, err
 	}
 
-	waitForUdevDelay, err := time.ParseDuration(fmt.Sprintf("%sms", util.Getenv("CNI_WAIT_FOR_UDEV_DELAY_MS", "100")))
-	if err != nil {
-		return config, err
-	}
-
 	waitForUdevTimeout, err := time.ParseDuration(fmt.Sprintf("%sms", util.Getenv("CNI_WAIT_FOR_UDEV_TIMEOUT_MS", "5000")))
 	if err != nil {
 		return config, err
@@ -54,7 +48,6 @@ func LoadConfig() (Config, error) {
 		LogLevel:           util.Getenv("CNI_LOG_LEVEL", "info"),
 		WaitForUdev:        util.GetenvAsBool("CNI_WAIT_FOR_UDEV", DefaultCniOpts().WaitForUdev),
 		WaitForUdevPrefix:  util.Getenv("CNI_WAIT_FOR_UDEV_PREFIX", DefaultCniOpts().WaitForUdevPrefix),
-		WaitForUdevDelay:   waitForUdevDelay,
 		WaitForUdevTimeout: waitForUdevTimeout,
 	}, nil
 }
@@ -1,12 +1,17 @@
 package cniplugin
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"os"
+	"runtime"
 	"strings"
+	"syscall"
 	"time"
 
-	currentcni "github.com/containernetworking/cni/pkg/types/040"
+	"github.com/containernetworking/cni/pkg/types"
+	currentcni "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/jboelensns/openstack-cni/pkg/logging"
 	"github.com/jboelensns/openstack-cni/pkg/util"
 	"github.com/vishvananda/netlink"
@@ -15,17 +20,40 @@ import (
 
 // NetworkInterface represents a local network interface (e.g ens3)
 type NetworkInterface struct {
-	Index    int
-	DestName string
-	Address  *net.IPNet
+	Index     int
+	DestName  string
+	Addresses []*net.IPNet
+	Mac       string
+	// Mtu is left as is when zero
+	Mtu    int
+	Routes []*types.Route
+}
+
+// AddressStrings returns the interface's addresses in CIDR notation
+func (me *NetworkInterface) AddressStrings() []string {
+	addrs := make([]string, len(me.Addresses))
+	for i, addr := range me.Addresses {
+		addrs[i] = addr.String()
+	}
+	return addrs
 }
 
 //go:generate moq -pkg mocks -out ../fixtures/mocks/cniplugin_mocks.go . Networking
 
 // Networking provides the ability to manipulate a network interface
 type Networking interface {
+	Check(namespace string, iface *NetworkInterface) error
 	Configure(namespace string, iface *NetworkInterface) error
 	GetIfaceByMac(mac string) (*net.Interface, error)
+	Unconfigure(namespace string, ifName string) error
+	WaitForIface(mac string, opts IfaceWaitOpts) (*net.Interface, error)
+}
+
+// IfaceWaitOpts controls how long WaitForIface waits for an interface
+// interfaces named with Prefix haven't been renamed by udev yet
+type IfaceWaitOpts struct {
+	Prefix  string
+	Timeout time.Duration
 }
 
 type networking struct {
@@ -42,13 +70,13 @@ func (me *networking) Configure(namespace string, iface *NetworkInterface) error
 	logger := logging.Log().With().
 		Int("iface_index", iface.Index).
 		Str("namespace", namespace).Str("dest_iface", iface.DestName).
-		Str("addr", iface.Address.IP.String()).Logger()
+		Strs("addrs", iface.AddressStrings()).Logger()
 
 	// Find the link by interface name
 	logger.Info().Msg("calling netlink.LinkByIndex")
 	link, err := me.nl.LinkByIndex(iface.Index)
 	if err != nil {
-		return fmt.Errorf("failed to LinkByIndex ns=%s iface_index=%d dest_iface=%s addr=%s e=%w", namespace, iface.Index, iface.DestName, iface.Address, err)
+		return fmt.Errorf("failed to LinkByIndex ns=%s iface_index=%d dest_iface=%s addrs=%s e=%w", namespace, iface.Index, iface.DestName, iface.AddressStrings(), err)
 	}
 
 	linkAttrs := link.Attrs()
@@ -64,27 +92,31 @@ func (me *networking) Configure(namespace string, iface *NetworkInterface) error
 	logger.Info().Msg("calling netlink.GetNetNsIdByPath")
 	nsFd, err := me.nl.GetNetNsIdByPath(namespace)
 	if err != nil {
-		return fmt.Errorf("netlink failed to GetNetNsIdByPath ns=%s iface=%s iface_index=%d dest_iface=%s addr=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.Address, err)
+		return fmt.Errorf("netlink failed to GetNetNsIdByPath ns=%s iface=%s iface_index=%d dest_iface=%s addrs=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.AddressStrings(), err)
 	}
 
 	// Move the link into the desination namespace
 	logger.Info().Msg("calling netlink.LinkSetNsFd")
 	if err := me.nl.LinkSetNsFd(link, nsFd); err != nil {
-		return fmt.Errorf("netlink failed to LinkSetNsFd ns=%s iface=%s iface_index=%d dest_iface=%s addr=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.Address, err)
+		return fmt.Errorf("netlink failed to LinkSetNsFd ns=%s iface=%s iface_index=%d dest_iface=%s addrs=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.AddressStrings(), err)
 	}
 
+	// netns.Set only applies to the current thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
 	// Save our namespace so we can flip back to it once we're done
 	logger.Info().Msg("calling netlink.Get")
 	oldNs, err := netns.Get()
 	if err != nil {
-		return fmt.Errorf("netlink failed to Get namespace ns=%s iface=%s iface_index=%d dest_iface=%s addr=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.Address, err)
+		return fmt.Errorf("netlink failed to Get namespace ns=%s iface=%s iface_index=%d dest_iface=%s addrs=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.AddressStrings(), err)
 	}
 	defer oldNs.Close()
 
 	// set ourselves into the destination namespace
 	logger.Info().Msg("calling netlink.NsHandle")
 	if err := netns.Set(netns.NsHandle(nsFd)); err != nil {
-		return fmt.Errorf("netlink failed to Set namespace ns=%s iface=%s iface_index=%d dest_iface=%s addr=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.Address, err)
+		return fmt.Errorf("netlink failed to Set namespace ns=%s iface=%s iface_index=%d dest_iface=%s addrs=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.AddressStrings(), err)
 	}
 	// when we're done we need to enter our original namespace
 	defer netns.Set(oldNs)
@@ -92,27 +124,213 @@ func (me *networking) Configure(namespace string, iface *NetworkInterface) error
 	// bring the interface down before we configure it
 	logger.Info().Msg("calling netlink.LinkSetDown")
 	if err := me.nl.LinkSetDown(link); err != nil {
-		return fmt.Errorf("netlink failed to LinkSetDown ns=%s iface=%s iface_index=%d dest_iface=%s addr=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.Address, err)
+		return fmt.Errorf("netlink failed to LinkSetDown ns=%s iface=%s iface_index=%d dest_iface=%s addrs=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.AddressStrings(), err)
 	}
 
 	// set the name of the link
 	logger.Info().Msg("calling netlink.LinkSetName")
 	if err := me.nl.LinkSetName(link, iface.DestName); err != nil {
-		return fmt.Errorf("netlink failed to LinkSetName ns=%s iface=%s iface_index=%d dest_iface=%s addr=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.Address, err)
+		return fmt.Errorf("netlink failed to LinkSetName ns=%s iface=%s iface_index=%d dest_iface=%s addrs=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.AddressStrings(), err)
+	}
+
+	// set the IPs on the interface
+	for _, address := range iface.Addresses {
+		logger.Info().Str("addr", address.String()).Msg("calling netlink.AddrReplace")
+		ipAddr := &netlink.Addr{IPNet: address, Label: ""}
+		if address.IP.To4() == nil {
+			// neutron guarantees the address is unique so skip duplicate address detection
+			ipAddr.Flags = syscall.IFA_F_NODAD
+		}
+		if err := me.nl.AddrReplace(link, ipAddr); err != nil {
+			return fmt.Errorf("netlink failed to AddrReplace ns=%s iface=%s iface_index=%d dest_iface=%s addr=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, address, err)
+		}
 	}
 
-	// set the IP on the interface
-	logger.Info().Msg("calling netlink.AddrAdd")
-	ipAddr := &netlink.Addr{IPNet: iface.Address, Label: ""}
-	if err := me.nl.AddrReplace(link, ipAddr); err != nil {
-		return fmt.Errorf("netlink failed to AddrReplace ns=%s iface=%s iface_index=%d dest_iface=%s addr=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.Address, err)
+	// set the MTU of the network
+	if iface.Mtu > 0 {
+		logger.Info().Int("mtu", iface.Mtu).Msg("calling netlink.LinkSetMTU")
+		if err := me.nl.LinkSetMTU(link, iface.Mtu); err != nil {
+			return fmt.Errorf("netlink failed to LinkSetMTU ns=%s iface=%s iface_index=%d dest_iface=%s mtu=%d e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.Mtu, err)
+		}
 	}
 
 	// bring the interface up
 	logger.Info().Msg("calling netlink.LinkSetUp")
 	if err := me.nl.LinkSetUp(link); err != nil {
-		return fmt.Errorf("netlink failed to LinkSetup ns=%s iface=%s iface_index=%d dest_iface=%s addr=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.Address, err)
+		return fmt.Errorf("netlink failed to LinkSetup ns=%s iface=%s iface_index=%d dest_iface=%s addrs=%s e=%w", namespace, linkAttrs.Name, iface.Index, iface.DestName, iface.AddressStrings(), err)
+	}
+
+	// routes can only be added once the interface is up
+	if len(iface.Routes) > 0 {
+		// the index may have changed when the link moved namespaces so look it up again
+		logger.Info().Msg("calling netlink.LinkByName")
+		nsLink, err := me.nl.LinkByName(iface.DestName)
+		if err != nil {
+			return fmt.Errorf("netlink failed to LinkByName ns=%s dest_iface=%s e=%w", namespace, iface.DestName, err)
+		}
+		for _, route := range iface.Routes {
+			dst := route.Dst
+			logger.Info().Str("dst", dst.String()).Str("gw", route.GW.String()).Msg("calling netlink.RouteReplace")
+			nlRoute := &netlink.Route{LinkIndex: nsLink.Attrs().Index, Dst: &dst, Gw: route.GW}
+			if err := me.nl.RouteReplace(nlRoute); err != nil {
+				return fmt.Errorf("netlink failed to RouteReplace ns=%s dest_iface=%s dst=%s gw=%s e=%w", namespace, iface.DestName, dst.String(), route.GW, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Unconfigure moves an interface out of a network namespace and back into the host's namespace
+// the interface is brought down and its addresses are flushed first so nothing is left configured if the move fails
+// a missing namespace or interface is not an error since there is nothing left to clean up
+func (me *networking) Unconfigure(namespace string, ifName string) error {
+	logger := logging.Log().With().Str("namespace", namespace).Str("dest_iface", ifName).Logger()
+
+	if namespace == "" {
+		logger.Info().Msg("no namespace, nothing to unconfigure")
+		return nil
+	}
+
+	// Find the namespace's fd by its path
+	logger.Info().Msg("calling netlink.GetNetNsIdByPath")
+	nsFd, err := me.nl.GetNetNsIdByPath(namespace)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Info().Msg("namespace no longer exists, nothing to unconfigure")
+			return nil
+		}
+		return fmt.Errorf("netlink failed to GetNetNsIdByPath ns=%s dest_iface=%s e=%w", namespace, ifName, err)
+	}
+
+	// netns.Set only applies to the current thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// Save our namespace so we can flip back to it and move the interface into it
+	oldNs, err := netns.Get()
+	if err != nil {
+		return fmt.Errorf("netlink failed to Get namespace ns=%s dest_iface=%s e=%w", namespace, ifName, err)
+	}
+	defer oldNs.Close()
+
+	// set ourselves into the pod's namespace
+	if err := netns.Set(netns.NsHandle(nsFd)); err != nil {
+		return fmt.Errorf("netlink failed to Set namespace ns=%s dest_iface=%s e=%w", namespace, ifName, err)
+	}
+	// when we're done we need to enter our original namespace
+	defer netns.Set(oldNs)
+
+	logger.Info().Msg("calling netlink.LinkByName")
+	link, err := me.nl.LinkByName(ifName)
+	if err != nil {
+		var notFound netlink.LinkNotFoundError
+		if errors.As(err, &notFound) {
+			logger.Info().Msg("interface no longer exists, nothing to unconfigure")
+			return nil
+		}
+		return fmt.Errorf("failed to find interface ns=%s dest_iface=%s e=%w", namespace, ifName, err)
+	}
+
+	// bring the interface down so it stops passing traffic before it's detached
+	logger.Info().Msg("calling netlink.LinkSetDown")
+	if err := me.nl.LinkSetDown(link); err != nil {
+		return fmt.Errorf("netlink failed to LinkSetDown ns=%s dest_iface=%s e=%w", namespace, ifName, err)
+	}
+
+	// flush the addresses from the interface
+	logger.Info().Msg("calling netlink.AddrList")
+	addrs, err := me.nl.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("netlink failed to AddrList ns=%s dest_iface=%s e=%w", namespace, ifName, err)
+	}
+	for _, addr := range addrs {
+		logger.Info().Str("addr", addr.IPNet.String()).Msg("calling netlink.AddrDel")
+		if err := me.nl.AddrDel(link, &addr); err != nil {
+			return fmt.Errorf("netlink failed to AddrDel ns=%s dest_iface=%s addr=%s e=%w", namespace, ifName, addr.IPNet, err)
+		}
+	}
+
+	// rename the interface so it can't collide with an interface in the host's namespace
+	hostName := fmt.Sprintf("ocni%d", link.Attrs().Index)
+	logger.Info().Str("host_iface", hostName).Msg("calling netlink.LinkSetName")
+	if err := me.nl.LinkSetName(link, hostName); err != nil {
+		return fmt.Errorf("netlink failed to LinkSetName ns=%s dest_iface=%s host_iface=%s e=%w", namespace, ifName, hostName, err)
+	}
+
+	// move the interface back into the host's namespace
+	logger.Info().Msg("calling netlink.LinkSetNsFd")
+	if err := me.nl.LinkSetNsFd(link, int(oldNs)); err != nil {
+		return fmt.Errorf("netlink failed to LinkSetNsFd ns=%s dest_iface=%s host_iface=%s e=%w", namespace, ifName, hostName, err)
+	}
+	logger.Info().Msg("moved interface back into the host namespace")
+	return nil
+}
+
+// Check ensures that an interface with the expected name, MAC and IP address exists inside of the network namespace
+func (me *networking) Check(namespace string, iface *NetworkInterface) error {
+	logger := logging.Log().With().
+		Str("namespace", namespace).Str("dest_iface", iface.DestName).
+		Str("mac", iface.Mac).Strs("addrs", iface.AddressStrings()).Logger()
+
+	// Find the destination namespace's fd by its path
+	logger.Info().Msg("calling netlink.GetNetNsIdByPath")
+	nsFd, err := me.nl.GetNetNsIdByPath(namespace)
+	if err != nil {
+		return fmt.Errorf("netlink failed to GetNetNsIdByPath ns=%s dest_iface=%s e=%w", namespace, iface.DestName, err)
+	}
+
+	// netns.Set only applies to the current thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// Save our namespace so we can flip back to it once we're done
+	oldNs, err := netns.Get()
+	if err != nil {
+		return fmt.Errorf("netlink failed to Get namespace ns=%s dest_iface=%s e=%w", namespace, iface.DestName, err)
+	}
+	defer oldNs.Close()
+
+	// set ourselves into the destination namespace
+	if err := netns.Set(netns.NsHandle(nsFd)); err != nil {
+		return fmt.Errorf("netlink failed to Set namespace ns=%s dest_iface=%s e=%w", namespace, iface.DestName, err)
+	}
+	// when we're done we need to enter our original namespace
+	defer netns.Set(oldNs)
+
+	logger.Info().Msg("calling netlink.LinkByName")
+	link, err := me.nl.LinkByName(iface.DestName)
+	if err != nil {
+		return fmt.Errorf("failed to find interface ns=%s dest_iface=%s e=%w", namespace, iface.DestName, err)
+	}
+
+	linkAttrs := link.Attrs()
+	if linkAttrs == nil {
+		return fmt.Errorf("interface is missing attributes ns=%s dest_iface=%s", namespace, iface.DestName)
+	}
+	if iface.Mac != "" && !strings.EqualFold(linkAttrs.HardwareAddr.String(), iface.Mac) {
+		return fmt.Errorf("interface mac mismatch ns=%s dest_iface=%s expected=%s actual=%s", namespace, iface.DestName, iface.Mac, linkAttrs.HardwareAddr)
+	}
+
+	if len(iface.Addresses) > 0 {
+		logger.Info().Msg("calling netlink.AddrList")
+		addrs, err := me.nl.AddrList(link, netlink.FAMILY_ALL)
+		if err != nil {
+			return fmt.Errorf("netlink failed to AddrList ns=%s dest_iface=%s e=%w", namespace, iface.DestName, err)
+		}
+		for _, expected := range iface.Addresses {
+			found := false
+			for _, addr := range addrs {
+				if addr.IPNet != nil && addr.IPNet.String() == expected.String() {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("interface address mismatch ns=%s dest_iface=%s expected=%s", namespace, iface.DestName, expected)
+			}
+		}
 	}
+	logger.Info().Msg("interface is consistent")
 	return nil
 }
 
@@ -132,61 +350,137 @@ func (me *networking) GetIfaceByMac(mac string) (*net.Interface, error) {
 	return nil, fmt.Errorf("failed to find interface for %s", mac)
 }
 
-// ConfigureInterface sets up the interfaces with the correct name, network namesapce and ip address
-func (me *Cni) ConfigureInterface(cmd util.CniCommand, result *currentcni.Result) error {
-	mac := result.Interfaces[0].Mac
+// WaitForIface waits for the interface with mac to appear and be renamed by udev
+// link events are subscribed to before the existing links are listed so that no event is missed
+func (me *networking) WaitForIface(mac string, opts IfaceWaitOpts) (*net.Interface, error) {
+	logger := logging.Log().With().Str("mac", mac).Str("prefix", opts.Prefix).Logger()
 
-	// ensure that if udev rules are in use they have had time to run
-	// this accounts accounts for a race condition between nova/neutron creation/attachment and the interface showing up on the host
-	start := time.Now()
-	for {
-		logger := logging.Log().With().Str("prefix", me.Opts.WaitForUdevPrefix).Logger()
-		// return an error if we've passed our WaitUdevTimeout
-		if me.Opts.WaitForUdev {
-			if time.Now().Sub(start) >= me.Opts.WaitForUdevTimeout {
-				logger.Error().Str("timeout", me.Opts.WaitForUdevTimeout.String()).Msg("reached udev wait timeout")
-				return fmt.Errorf("reached udev wait timeout mac=%s", mac)
+	// the subscription is buffered so that it doesn't block once we've stopped reading
+	updates := make(chan netlink.LinkUpdate, 64)
+	done := make(chan struct{})
+	defer close(done)
+	if err := me.nl.LinkSubscribe(updates, done); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to link updates mac=%s e=%w", mac, err)
+	}
+
+	links, err := me.nl.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links mac=%s e=%w", mac, err)
+	}
+	lastName := ""
+	for _, link := range links {
+		if iface := matchIface(link, mac); iface != nil {
+			lastName = iface.Name
+			if !hasPrefix(iface.Name, opts.Prefix) {
+				logger.Info().Str("iface", iface.Name).Msg("found interface")
+				return iface, nil
 			}
 		}
-		iface, err := me.nw.GetIfaceByMac(mac)
-		if err != nil {
-			// If we're waiting for udev log an error and retry; otherwise return the error
-			if me.Opts.WaitForUdev {
-				logger.Error().Str("mac", mac).Err(err).Msg("failed to find mac address")
-				time.Sleep(me.Opts.WaitForUdevDelay)
+	}
+
+	timer := time.NewTimer(opts.Timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				return nil, fmt.Errorf("link subscription closed while waiting for interface mac=%s", mac)
+			}
+			if update.Header.Type == syscall.RTM_DELLINK {
 				continue
-			} else {
-				return fmt.Errorf("failed to find interface by mac %s %w", mac, err)
 			}
-		}
-		logger = logger.With().Str("iface", iface.Name).Str("mac", mac).Logger()
-
-		// test to see if we found a valid prefix
-		if me.Opts.WaitForUdev {
-			logger.Info().Msg("waiting for interface with valid prefix")
-			if strings.HasPrefix(iface.Name, me.Opts.WaitForUdevPrefix) {
-				logger.Info().Msg("found interface name matching disallowed udev prefix... waiting")
-				time.Sleep(me.Opts.WaitForUdevDelay)
+			iface := matchIface(update.Link, mac)
+			if iface == nil {
 				continue
 			}
-			logger.Info().Msg("found valid interface name")
+			lastName = iface.Name
+			if hasPrefix(iface.Name, opts.Prefix) {
+				logger.Info().Str("iface", iface.Name).Msg("found interface name matching disallowed udev prefix... waiting")
+				continue
+			}
+			logger.Info().Str("iface", iface.Name).Msg("found interface")
+			return iface, nil
+		case <-timer.C:
+			if lastName == "" {
+				return nil, fmt.Errorf("interface with mac=%s didn't appear within %s", mac, opts.Timeout)
+			}
+			return nil, fmt.Errorf("interface %s with mac=%s wasn't renamed by udev within %s", lastName, mac, opts.Timeout)
 		}
+	}
+}
 
-		// ensure that eth0 is not used
-		if iface.Name == "eth0" {
-			return fmt.Errorf("failed to configure interface. eth0 is an invalid name")
-		}
+// matchIface returns the interface of link if it has mac
+func matchIface(link netlink.Link, mac string) *net.Interface {
+	if link == nil || link.Attrs() == nil {
+		return nil
+	}
+	attrs := link.Attrs()
+	if !strings.EqualFold(attrs.HardwareAddr.String(), mac) {
+		return nil
+	}
+	return &net.Interface{
+		Index:        attrs.Index,
+		MTU:          attrs.MTU,
+		Name:         attrs.Name,
+		HardwareAddr: attrs.HardwareAddr,
+		Flags:        attrs.Flags,
+	}
+}
 
-		netIface := &NetworkInterface{
-			Index:    iface.Index,
-			DestName: result.Interfaces[0].Name,
-			Address:  &result.IPs[0].Address,
-		}
+func hasPrefix(name, prefix string) bool {
+	return prefix != "" && strings.HasPrefix(name, prefix)
+}
 
-		err = me.nw.Configure(cmd.Netns, netIface)
+// ConfigureInterface sets up the interfaces with the correct name, network namesapce, ip address, MTU and routes
+func (me *Cni) ConfigureInterface(cmd util.CniCommand, result *currentcni.Result) error {
+	mac := result.Interfaces[0].Mac
+
+	// ensure that if udev rules are in use they have had time to run
+	// this accounts accounts for a race condition between nova/neutron creation/attachment and the interface showing up on the host
+	var iface *net.Interface
+	var err error
+	if me.Opts.WaitForUdev {
+		iface, err = me.nw.WaitForIface(mac, IfaceWaitOpts{Prefix: me.Opts.WaitForUdevPrefix, Timeout: me.Opts.WaitForUdevTimeout})
 		if err != nil {
-			return fmt.Errorf("failed to configure interface %w", err)
+			logging.Log().Error().Str("mac", mac).Str("timeout", me.Opts.WaitForUdevTimeout.String()).Err(err).Msg("failed to wait for interface")
+			return fmt.Errorf("failed to wait for interface %w", err)
+		}
+	} else {
+		iface, err = me.nw.GetIfaceByMac(mac)
+		if err != nil {
+			return fmt.Errorf("failed to find interface by mac %s %w", mac, err)
+		}
+	}
+
+	// ensure that eth0 is not used
+	if iface.Name == "eth0" {
+		return fmt.Errorf("failed to configure interface. eth0 is an invalid name")
+	}
+
+	netIface := &NetworkInterface{
+		Index:     iface.Index,
+		DestName:  result.Interfaces[0].Name,
+		Addresses: interfaceAddresses(result, 0),
+		Mac:       mac,
+		Mtu:       result.Interfaces[0].Mtu,
+		Routes:    result.Routes,
+	}
+
+	err = me.nw.Configure(cmd.Netns, netIface)
+	if err != nil {
+		return fmt.Errorf("failed to configure interface %w", err)
+	}
+	return err
+}
+
+// interfaceAddresses returns all of the result's addresses that belong to the interface at index
+func interfaceAddresses(result *currentcni.Result, index int) []*net.IPNet {
+	addresses := make([]*net.IPNet, 0, len(result.IPs))
+	for _, ip := range result.IPs {
+		if ip.Interface == nil || *ip.Interface == index {
+			address := ip.Address
+			addresses = append(addresses, &address)
 		}
-		return err
 	}
+	return addresses
 }
@@ -0,0 +1,100 @@
+package cniplugin_test
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jboelensns/openstack-cni/pkg/cniplugin"
+	"github.com/jboelensns/openstack-cni/pkg/fixtures/mocks"
+	. "github.com/pepinns/go-hamcrest"
+	"github.com/vishvananda/netlink"
+)
+
+func Test_WaitForIface(t *testing.T) {
+	mac := "fa:16:3e:2e:29:6b"
+	hwAddr, _ := net.ParseMAC(mac)
+	newLink := func(index int, name string, hwAddr net.HardwareAddr) netlink.Link {
+		return &netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: index, Name: name, HardwareAddr: hwAddr}}
+	}
+	newLinkUpdate := func(msgType uint16, link netlink.Link) netlink.LinkUpdate {
+		update := netlink.LinkUpdate{Link: link}
+		update.Header.Type = msgType
+		return update
+	}
+	// newNetlink streams events to the subscriber after the existing links are listed
+	newNetlink := func(existing []netlink.Link, events ...netlink.LinkUpdate) *mocks.NetlinkWrapperMock {
+		listed := make(chan struct{})
+		nl := &mocks.NetlinkWrapperMock{}
+		nl.LinkListFunc = func() ([]netlink.Link, error) {
+			close(listed)
+			return existing, nil
+		}
+		nl.LinkSubscribeFunc = func(ch chan<- netlink.LinkUpdate, done <-chan struct{}) error {
+			go func() {
+				<-listed
+				for _, event := range events {
+					select {
+					case ch <- event:
+					case <-done:
+						return
+					}
+				}
+			}()
+			return nil
+		}
+		return nl
+	}
+	opts := cniplugin.IfaceWaitOpts{Prefix: "eth", Timeout: 200 * time.Millisecond}
+
+	t.Run("returns an existing interface without waiting", func(t *testing.T) {
+		nl := newNetlink([]netlink.Link{newLink(1, "lo", nil), newLink(4, "ens4", hwAddr)})
+
+		iface, err := cniplugin.NewNetworking(nl).WaitForIface(mac, opts)
+		Assert(t).That(err, IsNil())
+		Assert(t).That(iface.Name, Equals("ens4"))
+		Assert(t).That(iface.Index, Equals(4))
+		Assert(t).That(nl.LinkSubscribeCalls(), HasLen(1))
+	})
+
+	t.Run("waits for the interface to appear and be renamed", func(t *testing.T) {
+		nl := newNetlink(nil,
+			newLinkUpdate(syscall.RTM_NEWLINK, newLink(3, "ens3", nil)),
+			newLinkUpdate(syscall.RTM_NEWLINK, newLink(4, "eth1", hwAddr)),
+			newLinkUpdate(syscall.RTM_NEWLINK, newLink(4, "ens4", hwAddr)),
+		)
+
+		iface, err := cniplugin.NewNetworking(nl).WaitForIface(mac, opts)
+		Assert(t).That(err, IsNil())
+		Assert(t).That(iface.Name, Equals("ens4"))
+	})
+
+	t.Run("ignores removed interfaces", func(t *testing.T) {
+		nl := newNetlink(nil,
+			newLinkUpdate(syscall.RTM_DELLINK, newLink(4, "ens4", hwAddr)),
+			newLinkUpdate(syscall.RTM_NEWLINK, newLink(5, "ens5", hwAddr)),
+		)
+
+		iface, err := cniplugin.NewNetworking(nl).WaitForIface(mac, opts)
+		Assert(t).That(err, IsNil())
+		Assert(t).That(iface.Name, Equals("ens5"))
+	})
+
+	t.Run("times out when the interface isn't renamed", func(t *testing.T) {
+		nl := newNetlink([]netlink.Link{newLink(4, "eth1", hwAddr)})
+
+		_, err := cniplugin.NewNetworking(nl).WaitForIface(mac, opts)
+		Assert(t).That(err, Not(IsNil()))
+		Assert(t).That(err.Error(), Contains("eth1"))
+		Assert(t).That(err.Error(), Contains("wasn't renamed"))
+	})
+
+	t.Run("times out when the interface doesn't appear", func(t *testing.T) {
+		nl := newNetlink(nil, newLinkUpdate(syscall.RTM_NEWLINK, newLink(3, "ens3", nil)))
+
+		_, err := cniplugin.NewNetworking(nl).WaitForIface(mac, opts)
+		Assert(t).That(err, Not(IsNil()))
+		Assert(t).That(err.Error(), Contains("didn't appear"))
+	})
+}
@@ -2,31 +2,41 @@ package cniserver
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
 	"github.com/go-chi/httplog"
 	"github.com/hashicorp/go-multierror"
 	"github.com/jboelensns/openstack-cni/pkg/logging"
 	. "github.com/jboelensns/openstack-cni/pkg/logging"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
+	"github.com/jboelensns/openstack-cni/pkg/util"
 )
 
 // App represents the application running the http server
 type App struct {
-	config  Config
-	server  *http.Server
-	reaper  *PortReaper
-	metrics *Metrics
+	config        Config
+	server        *http.Server
+	reaper        *PortReaper
+	clusterReaper *ClusterReaper
+	portCounter   *PortCounter
+	reloader      *Reloader
 }
 
-// NewApp creates a new App from configuration
-func NewApp(config Config, server *http.Server, reaper *PortReaper) (*App, error) {
+// NewApp creates a new App from configuration, clusterReaper, portCounter and reloader are optional
+func NewApp(config Config, server *http.Server, reaper *PortReaper, clusterReaper *ClusterReaper, portCounter *PortCounter, reloader *Reloader) (*App, error) {
 	return &App{
-		config: config,
-		server: server,
-		reaper: reaper,
+		config:        config,
+		server:        server,
+		reaper:        reaper,
+		clusterReaper: clusterReaper,
+		portCounter:   portCounter,
+		reloader:      reloader,
 	}, nil
 }
 
@@ -34,19 +44,80 @@ func NewApp(config Config, server *http.Server, reaper *PortReaper) (*App, error
 func (me *App) Run() error {
 	Log().Info().Str("duration", me.config.ReapInterval.String()).Msg("starting port reaper")
 	me.reaper.Start()
-	Log().Info().Str("addr", me.server.Addr).Msg("starting http server")
-	if err := me.server.ListenAndServe(); err != http.ErrServerClosed {
+	if me.clusterReaper != nil {
+		Log().Info().Str("duration", me.config.ClusterReapInterval.String()).Msg("starting cluster reaper")
+		me.clusterReaper.Start()
+	}
+	if me.portCounter != nil {
+		Log().Info().Str("duration", me.config.PortCountInterval.String()).Msg("starting port counter")
+		me.portCounter.Start()
+	}
+	if me.reloader != nil {
+		Log().Info().Str("file", me.reloader.File).Msg("watching daemon config file")
+		if err := me.reloader.Start(); err != nil {
+			Error("failed to watch daemon config file", err)
+			return err
+		}
+	}
+	Log().Info().Str("network", me.config.ListenNetwork).Str("addr", me.config.ListenAddr).Msg("starting http server")
+	listener, err := Listen(me.config)
+	if err != nil {
+		Error("failed to listen", err)
+		return err
+	}
+	if me.server.TLSConfig != nil {
+		err = me.server.ServeTLS(listener, "", "")
+	} else {
+		err = me.server.Serve(listener)
+	}
+	if err != http.ErrServerClosed {
 		Error("failed to start http server", err)
 		return err
 	}
 	return nil
 }
 
+// Listen creates the listener for the http server, for a unix socket any stale socket is removed
+// and the permissions of the socket are restricted to the configured mode
+func Listen(config Config) (net.Listener, error) {
+	if config.ListenNetwork != "unix" {
+		return net.Listen("tcp", config.ListenAddr)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.ListenAddr), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(config.ListenAddr); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket %s err=%w", config.ListenAddr, err)
+	}
+	listener, err := net.Listen("unix", config.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(config.ListenAddr, config.SocketMode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
 // Shutdown signals the http server to shutdown
 func (me *App) Shutdown(ctx context.Context) error {
 	Log().Info().Msg("shutting port reaper")
 	me.reaper.Stop()
 	Log().Info().Msg("shut down port reaper")
+	if me.clusterReaper != nil {
+		me.clusterReaper.Stop()
+		Log().Info().Msg("shut down cluster reaper")
+	}
+	if me.portCounter != nil {
+		me.portCounter.Stop()
+		Log().Info().Msg("shut down port counter")
+	}
+	if me.reloader != nil {
+		me.reloader.Stop()
+		Log().Info().Msg("stopped watching daemon config file")
+	}
 	Log().Info().Msg("shutting down http server")
 	defer func() {
 		Log().Info().Msg("shut down http server")
@@ -58,13 +129,31 @@ func BuildApp() (*App, error) {
 	SetupLogging("openstack-cni-daemon", httplog.DefaultOptions, os.Stderr)
 	Log().Info().Msg("preparing http server")
 
-	config := NewConfig()
-	deps, err := NewBuilder(config).Build()
+	var config Config
+	reloader := NewReloader(util.Getenv("CNI_DAEMON_CONFIG_FILE", ""))
+	if reloader != nil {
+		if err := util.ReadConfigIntoEnv(); err != nil {
+			Error("failed to read config file", err)
+			return nil, err
+		}
+		if err := reloader.Load(); err != nil {
+			Error("failed to load daemon config file", err)
+			return nil, err
+		}
+		config = reloader.Config()
+	} else {
+		config = NewConfig()
+	}
+	serverId, err := openstack.FindInstanceId(context.Background(), config.InstanceId)
+	if err != nil {
+		Log().Warn().AnErr("err", err).Msg("failed to find the instance id, looking up the server by hostname instead")
+	}
+	deps, err := NewBuilder(config).WithServerId(serverId).WithReloader(reloader).Build()
 	if err != nil {
 		Error("failed to build dependencies", err)
 		return nil, err
 	}
-	app, err := NewApp(config, deps.RestServer(), deps.PortReaper())
+	app, err := NewApp(config, deps.RestServer(), deps.PortReaper(), deps.ClusterReaper(), deps.PortCounter(), deps.Reloader())
 	if err != nil {
 		Log().Error().Str("addr", app.config.ListenAddr).AnErr("err", err).Msg("failed to initialize server")
 		return nil, err
@@ -0,0 +1,73 @@
+package cniserver
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// AttachmentLocks serializes requests for the same attachment, the number of OpenStack operations
+// running at once is bounded by the openstack.LimitingClient instead
+type AttachmentLocks struct {
+	mu      sync.Mutex
+	keys    map[string]*keyLock
+	metrics *Metrics
+}
+
+type keyLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// NewAttachmentLocks creates AttachmentLocks
+func NewAttachmentLocks(metrics *Metrics) *AttachmentLocks {
+	return &AttachmentLocks{
+		keys:    map[string]*keyLock{},
+		metrics: metrics,
+	}
+}
+
+// Lock blocks until no other request holds key or ctx is done
+// an empty key doesn't wait; the returned func releases the lock
+func (me *AttachmentLocks) Lock(ctx context.Context, key string) (func(), error) {
+	if key == "" {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	me.metrics.cniRequestWaiting.Inc()
+	defer func() {
+		me.metrics.cniRequestWaiting.Dec()
+		me.metrics.cniRequestWaitSeconds.Observe(time.Since(start).Seconds())
+	}()
+
+	me.mu.Lock()
+	l, ok := me.keys[key]
+	if !ok {
+		l = &keyLock{ch: make(chan struct{}, 1)}
+		me.keys[key] = l
+	}
+	l.refs++
+	me.mu.Unlock()
+
+	select {
+	case l.ch <- struct{}{}:
+		return func() {
+			<-l.ch
+			me.release(key, l)
+		}, nil
+	case <-ctx.Done():
+		me.release(key, l)
+		return nil, ctx.Err()
+	}
+}
+
+// release drops a reference to the lock of key and forgets it once nobody waits for it
+func (me *AttachmentLocks) release(key string, l *keyLock) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(me.keys, key)
+	}
+}
@@ -0,0 +1,78 @@
+package cniserver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jboelensns/openstack-cni/pkg/cniserver"
+	. "github.com/jboelensns/openstack-cni/pkg/fixtures"
+	. "github.com/pepinns/go-hamcrest"
+)
+
+func Test_AttachmentLocks(t *testing.T) {
+	lockAsync := func(locks *cniserver.AttachmentLocks, key string) chan func() {
+		acquired := make(chan func(), 1)
+		go func() {
+			release, err := locks.Lock(context.Background(), key)
+			if err == nil {
+				acquired <- release
+			}
+		}()
+		return acquired
+	}
+	isBlocked := func(acquired chan func()) bool {
+		select {
+		case release := <-acquired:
+			release()
+			return false
+		case <-time.After(100 * time.Millisecond):
+			return true
+		}
+	}
+
+	t.Run("serializes requests for the same key", func(t *testing.T) {
+		locks := cniserver.NewAttachmentLocks(Metrics())
+		release, err := locks.Lock(context.Background(), "container/eth1")
+		Assert(t).That(err, IsNil())
+
+		acquired := lockAsync(locks, "container/eth1")
+		Assert(t).That(isBlocked(acquired), IsTrue())
+		release()
+		Assert(t).That(isBlocked(acquired), IsFalse())
+	})
+
+	t.Run("runs requests for different keys concurrently", func(t *testing.T) {
+		locks := cniserver.NewAttachmentLocks(Metrics())
+		release, err := locks.Lock(context.Background(), "container/eth1")
+		Assert(t).That(err, IsNil())
+		defer release()
+
+		Assert(t).That(isBlocked(lockAsync(locks, "container/eth2")), IsFalse())
+	})
+
+	t.Run("doesn't serialize requests without a key", func(t *testing.T) {
+		locks := cniserver.NewAttachmentLocks(Metrics())
+		release, err := locks.Lock(context.Background(), "")
+		Assert(t).That(err, IsNil())
+		defer release()
+
+		Assert(t).That(isBlocked(lockAsync(locks, "")), IsFalse())
+	})
+
+	t.Run("gives up when the context is done", func(t *testing.T) {
+		locks := cniserver.NewAttachmentLocks(Metrics())
+		release, err := locks.Lock(context.Background(), "container/eth1")
+		Assert(t).That(err, IsNil())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = locks.Lock(ctx, "container/eth1")
+		Assert(t).That(err, Equals(context.DeadlineExceeded))
+
+		release()
+		release, err = locks.Lock(context.Background(), "container/eth1")
+		Assert(t).That(err, IsNil())
+		release()
+	})
+}
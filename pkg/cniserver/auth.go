@@ -0,0 +1,83 @@
+package cniserver
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+
+	. "github.com/jboelensns/openstack-cni/pkg/logging"
+	"github.com/jboelensns/openstack-cni/pkg/util"
+)
+
+// AuthOpts configures how requests to the daemon are authenticated
+// when neither a token nor a client CA is configured every request is allowed
+type AuthOpts struct {
+	Token       string
+	RequireCert bool
+	PublicPaths []string
+}
+
+// Enabled returns true when requests need to be authenticated
+func (me AuthOpts) Enabled() bool {
+	return me.Token != "" || me.RequireCert
+}
+
+// IsPublic returns true when path can be requested without credentials
+func (me AuthOpts) IsPublic(path string) bool {
+	for _, p := range me.PublicPaths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAuthMiddleware returns a middleware that rejects requests without a valid bearer token or client certificate
+func NewAuthMiddleware(opts AuthOpts) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !opts.Enabled() || opts.IsPublic(r.URL.Path) || opts.isAuthenticated(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			Log().Warn().Str("path", r.URL.Path).Str("remote", r.RemoteAddr).Msg("rejected unauthenticated request")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+}
+
+func (me AuthOpts) isAuthenticated(r *http.Request) bool {
+	if me.RequireCert && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return true
+	}
+	if me.Token == "" {
+		return false
+	}
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(me.Token)) == 1
+}
+
+// NewServerTLSConfig creates the tls configuration of the daemon
+// when clientCaFile is set every connection has to present a certificate signed by it
+func NewServerTLSConfig(certFile, keyFile, clientCaFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate err=%w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCaFile != "" {
+		pool, err := util.LoadCertPool(clientCaFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
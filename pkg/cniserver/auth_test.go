@@ -0,0 +1,109 @@
+package cniserver_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jboelensns/openstack-cni/pkg/cniserver"
+	. "github.com/pepinns/go-hamcrest"
+)
+
+func Test_AuthMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	serve := func(opts cniserver.AuthOpts, r *http.Request) int {
+		w := httptest.NewRecorder()
+		cniserver.NewAuthMiddleware(opts)(next).ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("allows every request when auth isn't configured", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/cni", nil)
+		Assert(t).That(serve(cniserver.AuthOpts{}, r), Equals(http.StatusOK))
+	})
+
+	t.Run("rejects a missing or wrong token", func(t *testing.T) {
+		opts := cniserver.AuthOpts{Token: "s3cr3t"}
+		r := httptest.NewRequest(http.MethodPost, "/cni", nil)
+		Assert(t).That(serve(opts, r), Equals(http.StatusUnauthorized))
+
+		r.Header.Set("Authorization", "Bearer wrong")
+		Assert(t).That(serve(opts, r), Equals(http.StatusUnauthorized))
+	})
+
+	t.Run("accepts the bearer token", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/cni", nil)
+		r.Header.Set("Authorization", "Bearer s3cr3t")
+		Assert(t).That(serve(cniserver.AuthOpts{Token: "s3cr3t"}, r), Equals(http.StatusOK))
+	})
+
+	t.Run("allows public paths without credentials", func(t *testing.T) {
+		opts := cniserver.AuthOpts{Token: "s3cr3t", PublicPaths: []string{"/health"}}
+		Assert(t).That(serve(opts, httptest.NewRequest(http.MethodGet, "/health", nil)), Equals(http.StatusOK))
+		Assert(t).That(serve(opts, httptest.NewRequest(http.MethodGet, "/metrics", nil)), Equals(http.StatusUnauthorized))
+	})
+
+	t.Run("requires a verified client certificate", func(t *testing.T) {
+		opts := cniserver.AuthOpts{RequireCert: true}
+		r := httptest.NewRequest(http.MethodPost, "/cni", nil)
+		r.TLS = &tls.ConnectionState{}
+		Assert(t).That(serve(opts, r), Equals(http.StatusUnauthorized))
+
+		r.TLS.VerifiedChains = [][]*x509.Certificate{{&x509.Certificate{}}}
+		Assert(t).That(serve(opts, r), Equals(http.StatusOK))
+	})
+}
+
+func Test_NewServerTLSConfig(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t)
+
+	t.Run("doesn't ask for client certificates without a client CA", func(t *testing.T) {
+		config, err := cniserver.NewServerTLSConfig(certFile, keyFile, "")
+		Assert(t).That(err, IsNil())
+		Assert(t).That(config.ClientAuth, Equals(tls.NoClientCert))
+	})
+
+	t.Run("requires a verified client certificate on every connection with a client CA", func(t *testing.T) {
+		config, err := cniserver.NewServerTLSConfig(certFile, keyFile, certFile)
+		Assert(t).That(err, IsNil())
+		Assert(t).That(config.ClientAuth, Equals(tls.RequireAndVerifyClientCert))
+		Assert(t).That(config.ClientCAs, Not(IsNil()))
+	})
+}
+
+// writeSelfSignedCert writes a self-signed certificate and its key to a temporary directory
+func writeSelfSignedCert(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Assert(t).That(err, IsNil())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Assert(t).That(err, IsNil())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Assert(t).That(err, IsNil())
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	Assert(t).That(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600), IsNil())
+	Assert(t).That(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600), IsNil())
+	return certFile, keyFile
+}
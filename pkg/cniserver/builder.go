@@ -1,12 +1,17 @@
 package cniserver
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	. "github.com/jboelensns/openstack-cni/pkg/logging"
 	"github.com/jboelensns/openstack-cni/pkg/openstack"
+	"github.com/jboelensns/openstack-cni/pkg/util"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -14,11 +19,14 @@ import (
 // Deps represents dependencies for the application
 // instances of this structure are created by the Builder
 type Deps struct {
-	cniHandler CommandHandler
-	osClient   openstack.OpenstackClient
-	metrics    *Metrics
-	portReaper *PortReaper
-	restServer *http.Server
+	cniHandler    CommandHandler
+	osClient      openstack.OpenstackClient
+	metrics       *Metrics
+	portReaper    *PortReaper
+	clusterReaper *ClusterReaper
+	portCounter   *PortCounter
+	reloader      *Reloader
+	restServer    *http.Server
 }
 
 // CniHandler returns the CommandHandler
@@ -41,6 +49,21 @@ func (me *Deps) PortReaper() *PortReaper {
 	return me.portReaper
 }
 
+// ClusterReaper returns the ClusterReaper, it's nil unless cluster reaping is enabled
+func (me *Deps) ClusterReaper() *ClusterReaper {
+	return me.clusterReaper
+}
+
+// PortCounter returns the PortCounter
+func (me *Deps) PortCounter() *PortCounter {
+	return me.portCounter
+}
+
+// Reloader returns the Reloader, it's nil without a daemon config file
+func (me *Deps) Reloader() *Reloader {
+	return me.reloader
+}
+
 // RestServer returns an http.server
 func (me *Deps) RestServer() *http.Server {
 	return me.restServer
@@ -48,13 +71,16 @@ func (me *Deps) RestServer() *http.Server {
 
 // Builder provides the ability to produce Deps instances using the builder pattern
 type Builder struct {
-	config      Config
-	cniHandler  CommandHandler
-	osClient    openstack.OpenstackClient
-	metrics     *Metrics
-	restServer  *http.Server
-	portReaper  *PortReaper
-	portCounter *PortCounter
+	config        Config
+	cniHandler    CommandHandler
+	osClient      openstack.OpenstackClient
+	metrics       *Metrics
+	restServer    *http.Server
+	portReaper    *PortReaper
+	clusterReaper *ClusterReaper
+	portCounter   *PortCounter
+	reloader      *Reloader
+	serverId      string
 }
 
 // NewBuilder creates a new Builder
@@ -86,6 +112,24 @@ func (me *Builder) WithPortReaper(reaper *PortReaper) *Builder {
 	return me
 }
 
+// WithClusterReaper sets the ClusterReaper
+func (me *Builder) WithClusterReaper(reaper *ClusterReaper) *Builder {
+	me.clusterReaper = reaper
+	return me
+}
+
+// WithServerId sets the UUID of the local server, the server is looked up by hostname without it
+func (me *Builder) WithServerId(serverId string) *Builder {
+	me.serverId = serverId
+	return me
+}
+
+// WithReloader sets the Reloader applying the daemon config file, nil disables reloading
+func (me *Builder) WithReloader(reloader *Reloader) *Builder {
+	me.reloader = reloader
+	return me
+}
+
 // WithOpenstackClient sets the current to OpenstackClient to client
 func (me *Builder) WithRestServer(server *http.Server) *Builder {
 	me.restServer = server
@@ -97,47 +141,116 @@ func (me *Builder) Build() (*Deps, error) {
 	// build the default os factory if we don't have one
 	if me.osClient == nil {
 		var err error
-		me.osClient, err = openstack.NewOpenstackClient()
+		if me.reloader != nil {
+			me.osClient, err = openstack.NewOpenstackClientWithCloudOpts(me.reloader.CloudOpts())
+		} else {
+			me.osClient, err = openstack.NewOpenstackClient()
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to build openstack client err=%w", err)
 		}
 	}
 
-	me.osClient = openstack.NewCachedClient(me.osClient, getEnvDuration("CNI_CACHE_TTL", "300s"))
+	if me.metrics == nil {
+		registry := prometheus.NewRegistry()
+		me.metrics = NewMetrics(registry)
+	}
+
+	// the credentials are reloaded by the innermost client, the decorators keep working with the new ones
+	if client, ok := me.osClient.(Reloadable); ok && me.reloader != nil {
+		me.reloader.OsClient = client
+	}
+
+	me.osClient = openstack.NewMetricsClient(me.osClient, me.metrics)
+	me.osClient = openstack.NewLimitingClient(me.osClient, me.config.MaxConcurrentCalls, me.metrics)
+	me.osClient = openstack.NewRetryingClient(me.osClient, me.config.Retry, me.metrics)
+	uncachedClient := me.osClient
+	cachedClient := openstack.NewCachedClient(me.osClient, me.config.CacheTTL)
+	cachedClient.NegativeExpiration = me.config.CacheNegativeTTL
+	cachedClient.LookupTimeout = me.config.CacheLookupTimeout
+	cachedClient.Metrics = me.metrics
+	me.osClient = cachedClient
+
+	if me.serverId != "" {
+		_, err := me.osClient.GetServer(context.Background(), me.serverId)
+		if errors.Is(err, openstack.ErrServerNotFound) {
+			Log().Warn().Str("instance_id", me.serverId).Msg("instance id isn't a nova server, looking up the server by hostname instead")
+			me.serverId = ""
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to look up server %s err=%w", me.serverId, err)
+		}
+	}
 
 	// build the default cni handler if we don't have one
 	if me.cniHandler == nil {
 		pm := openstack.NewPortManager(me.osClient)
+		pm.PortWait = me.config.PortWait
+		pm.ServerId = me.serverId
 		me.cniHandler = NewCniCommandHandler(pm)
 	}
 
 	if me.portCounter == nil {
-		me.portCounter = &PortCounter{me.osClient}
-	}
-
-	if me.metrics == nil {
-		registry := prometheus.NewRegistry()
-		me.metrics = NewMetrics(registry, me.portCounter.Count)
+		me.portCounter = &PortCounter{
+			Interval: me.config.PortCountInterval,
+			ServerId: me.serverId,
+			OsClient: uncachedClient,
+			Metrics:  me.metrics,
+		}
 	}
 
 	if me.portReaper == nil {
 		me.portReaper = &PortReaper{
 			Opts: PortReaperOpts{
-				Interval:       me.config.ReapInterval,
-				MinPortAge:     me.config.MinPortAge,
-				SkipDelete:     me.config.SkipReaping,
+				Interval:   me.config.ReapInterval,
+				MinPortAge: me.config.MinPortAge,
+				SkipDelete: me.config.SkipReaping,
+				ProcPath:   me.config.ProcPath,
 			},
+			ServerId: me.serverId,
 			OsClient: me.osClient,
 			Metrics:  me.metrics,
 		}
 	}
 
+	if me.clusterReaper == nil && me.config.ClusterReaping {
+		hostname, err := util.GetHostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the hostname holding the cluster reaper lease err=%w", err)
+		}
+		me.clusterReaper = &ClusterReaper{
+			Opts: ClusterReaperOpts{
+				Interval:   me.config.ClusterReapInterval,
+				MinPortAge: me.config.MinPortAge,
+				SkipDelete: me.config.SkipReaping,
+			},
+			OsClient: uncachedClient,
+			Lease:    openstack.NewSecurityGroupLease(me.osClient, me.config.ReapLeaseName, hostname, me.config.ReapLeaseDuration),
+			Metrics:  me.metrics,
+		}
+	}
+
+	if me.reloader != nil {
+		me.reloader.Cache = cachedClient
+		me.reloader.PortReaper = me.portReaper
+		me.reloader.ClusterReaper = me.clusterReaper
+		me.reloader.Metrics = me.metrics
+	}
+
 	if me.restServer == nil {
+		authOpts, tlsConfig, err := me.buildAuth()
+		if err != nil {
+			return nil, err
+		}
 		router := chi.NewRouter()
 		router.Use(middleware.Logger)
-		router.Get("/health", (&HealthHandler{me.osClient}).HandleRequest)
+		router.Use(NewAuthMiddleware(authOpts))
+		router.Get("/health", (&HealthHandler{OsClient: me.osClient, Reloader: me.reloader}).HandleRequest)
 		router.Get("/ping", PingHandler)
-		router.Post("/cni", (&CniHandler{me.cniHandler, me.metrics}).HandleRequest)
+		router.Post("/cni", (&CniHandler{me.cniHandler, me.metrics, NewAttachmentLocks(me.metrics)}).HandleRequest)
+		reaperHandler := &ReaperHandler{me.portReaper}
+		router.Get("/reaper/plan", reaperHandler.HandlePlan)
+		router.Post("/reaper/run", reaperHandler.HandleRun)
+		router.Post("/cache/flush", (&CacheHandler{cachedClient}).HandleFlush)
 		router.Get("/metrics", promhttp.HandlerFor(me.metrics.Registry(), promhttp.HandlerOpts{Registry: me.metrics.Registry()}).ServeHTTP)
 
 		me.restServer = &http.Server{
@@ -145,14 +258,41 @@ func (me *Builder) Build() (*Deps, error) {
 			Handler:      router,
 			ReadTimeout:  me.config.ReadTimeout,
 			WriteTimeout: me.config.WriteTimeout,
+			TLSConfig:    tlsConfig,
 		}
 	}
 
 	return &Deps{
-		cniHandler: me.cniHandler,
-		osClient:   me.osClient,
-		metrics:    me.metrics,
-		portReaper: me.portReaper,
-		restServer: me.restServer,
+		cniHandler:    me.cniHandler,
+		osClient:      me.osClient,
+		metrics:       me.metrics,
+		portReaper:    me.portReaper,
+		clusterReaper: me.clusterReaper,
+		portCounter:   me.portCounter,
+		reloader:      me.reloader,
+		restServer:    me.restServer,
 	}, nil
 }
+
+func (me *Builder) buildAuth() (AuthOpts, *tls.Config, error) {
+	opts := AuthOpts{
+		RequireCert: me.config.TLSClientCAFile != "",
+		PublicPaths: me.config.PublicPaths,
+	}
+	if me.config.AuthTokenFile != "" {
+		token, err := util.ReadToken(me.config.AuthTokenFile)
+		if err != nil {
+			return opts, nil, fmt.Errorf("failed to read auth token err=%w", err)
+		}
+		opts.Token = token
+	}
+
+	if me.config.TLSCertFile == "" {
+		if opts.RequireCert {
+			return opts, nil, fmt.Errorf("CNI_SERVER_TLS_CLIENT_CA_FILE requires CNI_SERVER_TLS_CERT_FILE and CNI_SERVER_TLS_KEY_FILE")
+		}
+		return opts, nil, nil
+	}
+	tlsConfig, err := NewServerTLSConfig(me.config.TLSCertFile, me.config.TLSKeyFile, me.config.TLSClientCAFile)
+	return opts, tlsConfig, err
+}
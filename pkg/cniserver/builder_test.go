@@ -1,10 +1,15 @@
 package cniserver_test
 
 import (
+	"context"
+	"errors"
 	"testing"
 
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	"github.com/jboelensns/openstack-cni/pkg/cniserver"
 	. "github.com/jboelensns/openstack-cni/pkg/fixtures"
+	"github.com/jboelensns/openstack-cni/pkg/fixtures/mocks"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
 	. "github.com/pepinns/go-hamcrest"
 )
 
@@ -20,3 +25,26 @@ func Test_builder(t *testing.T) {
 		})
 	})
 }
+
+func Test_builderServerId(t *testing.T) {
+	t.Run("an instance id that isn't a server falls back to the hostname", func(t *testing.T) {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerFunc = func(ctx context.Context, id string) (*servers.Server, error) {
+			return nil, openstack.ErrServerNotFound
+		}
+		_, err := cniserver.NewBuilder(cniserver.NewConfig()).WithOpenstackClient(mock).WithServerId("serverId").Build()
+		Assert(t).That(err, IsNil())
+		Assert(t).That(mock.GetServerCalls(), HasLen(1))
+	})
+
+	t.Run("fails when the server can't be looked up", func(t *testing.T) {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerFunc = func(ctx context.Context, id string) (*servers.Server, error) {
+			return nil, errors.New("BOOM")
+		}
+		config := cniserver.NewConfig()
+		config.Retry.MaxAttempts = 1
+		_, err := cniserver.NewBuilder(config).WithOpenstackClient(mock).WithServerId("serverId").Build()
+		Assert(t).That(err.Error(), Contains("BOOM"))
+	})
+}
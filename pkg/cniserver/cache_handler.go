@@ -0,0 +1,20 @@
+package cniserver
+
+import (
+	"net/http"
+
+	. "github.com/jboelensns/openstack-cni/pkg/logging"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
+)
+
+// CacheHandler handles all /cache related requests
+type CacheHandler struct {
+	Cache *openstack.CachedClient
+}
+
+// HandleFlush drops every cached OpenStack lookup so the next lookups go to the API
+func (me *CacheHandler) HandleFlush(w http.ResponseWriter, r *http.Request) {
+	me.Cache.Flush()
+	Log().Info().Msg("flushed openstack cache")
+	w.WriteHeader(http.StatusNoContent)
+}
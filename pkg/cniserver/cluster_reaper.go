@@ -0,0 +1,225 @@
+package cniserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	. "github.com/jboelensns/openstack-cni/pkg/logging"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
+)
+
+// Lease makes sure only one daemon at a time does cluster-wide work
+type Lease interface {
+	TryAcquire(ctx context.Context) (bool, error)
+	Release(ctx context.Context) error
+}
+
+// ClusterReaper deletes the ports in the project whose host was deleted or replaced by another server,
+// which the PortReaper of a host can't do once the host is gone
+// OsClient shouldn't cache, otherwise a deleted server is reported as existing until its entry expires
+type ClusterReaper struct {
+	Opts     ClusterReaperOpts
+	OsClient openstack.OpenstackClient
+	Lease    Lease
+	Metrics  *Metrics
+	done     func()
+	mu       sync.Mutex
+}
+
+// ClusterReaperOpts configures the ClusterReaper
+type ClusterReaperOpts struct {
+	Interval   time.Duration
+	MinPortAge time.Duration
+	SkipDelete bool
+}
+
+func (me *ClusterReaper) Start() {
+	if me.done == nil {
+		me.done = Repeat(me.Opts.Interval, func() {
+			if _, err := me.Run(context.Background()); err != nil {
+				Log().Err(err).Msg("error reaping orphaned ports")
+			}
+		})
+	}
+}
+
+// Stop stops reaping and releases the lease so another daemon can take over
+func (me *ClusterReaper) Stop() {
+	if me.done != nil {
+		me.done()
+	}
+	if err := me.Lease.Release(context.Background()); err != nil {
+		Log().Err(err).Msg("failed to release the cluster reaper lease")
+	}
+}
+
+// Update replaces MinPortAge and SkipDelete once no run is in progress,
+// the interval only changes on a restart since the lease duration has to exceed it
+func (me *ClusterReaper) Update(opts ClusterReaperOpts) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.Opts.MinPortAge = opts.MinPortAge
+	me.Opts.SkipDelete = opts.SkipDelete
+}
+
+// Run reaps orphaned ports when this daemon holds the lease and returns the decision made for every port
+func (me *ClusterReaper) Run(ctx context.Context) ([]ReapDecision, error) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+
+	acquired, err := me.Lease.TryAcquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire the cluster reaper lease err=%w", err)
+	}
+	if !acquired {
+		Log().Info().Msg("another daemon holds the cluster reaper lease, skipping")
+		return nil, nil
+	}
+
+	decisions, err := me.Plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, decision := range decisions {
+		if !decision.Reap {
+			continue
+		}
+		log := Log().With().Str("port_id", decision.PortId).Str("host", decision.Host).Str("reason", decision.Reason).Logger()
+		if me.Opts.SkipDelete {
+			log.Info().Msg("reaping disabled, skipping orphaned port")
+			decisions[i].Reason = fmt.Sprintf("reaping disabled, %s", decision.Reason)
+			continue
+		}
+		log.Info().Msg("attempting to reap orphaned port")
+		if err := me.OsClient.DeletePort(ctx, decision.PortId); err != nil {
+			log.Err(err).Msg("failed to reap orphaned port")
+			me.Metrics.reapFailureCount.Inc()
+			decisions[i].Error = err.Error()
+			continue
+		}
+		log.Info().Msg("successfully reaped orphaned port")
+		me.Metrics.reapSuccessCount.Inc()
+	}
+	return decisions, nil
+}
+
+// Plan lists every openstack-cni port in the project, groups them by their host= tag and decides whether they're orphaned
+// attached ports are orphaned once their server is gone, detached ports once their host no longer exists as a server,
+// the detached ports of existing hosts are left to the PortReaper of the host
+// every server and host is looked up once, and only for the ports that are old enough to be reaped
+func (me *ClusterReaper) Plan(ctx context.Context) ([]ReapDecision, error) {
+	allPorts, err := me.OsClient.GetPortsByTags(ctx, []string{OPENSTACK_CNI_TAG})
+	if err != nil {
+		return nil, err
+	}
+
+	serverGone := map[string]bool{}
+	hosts := map[string]hostServer{}
+	for _, port := range allPorts {
+		if me.tooNew(port) {
+			continue
+		}
+		if port.DeviceID != "" {
+			gone, found := serverGone[port.DeviceID]
+			if !found {
+				_, err := me.OsClient.GetServer(ctx, port.DeviceID)
+				if err != nil && !errors.Is(err, openstack.ErrServerNotFound) {
+					return nil, fmt.Errorf("failed to look up server %s of port %s err=%w", port.DeviceID, port.ID, err)
+				}
+				gone = err != nil
+				serverGone[port.DeviceID] = gone
+			}
+			if !gone {
+				continue
+			}
+		} else if port.Status != "DOWN" {
+			continue
+		}
+
+		host := GetTagValue(port.Tags, "host")
+		if _, found := hosts[host]; found || host == "" {
+			continue
+		}
+		hosts[host], err = me.resolveHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	decisions := make([]ReapDecision, 0, len(allPorts))
+	for _, port := range allPorts {
+		decisions = append(decisions, me.decide(port, serverGone[port.DeviceID], hosts[GetTagValue(port.Tags, "host")]))
+	}
+	return decisions, nil
+}
+
+// hostServer is the nova server a host= tag resolves to
+// Id is empty when several servers have the host's name
+type hostServer struct {
+	Exists bool
+	Id     string
+}
+
+// resolveHost looks up the server named like host, a name shared by several servers counts as an existing host
+func (me *ClusterReaper) resolveHost(ctx context.Context, host string) (hostServer, error) {
+	server, err := me.OsClient.GetServerByName(ctx, host)
+	switch {
+	case errors.Is(err, openstack.ErrServerNotFound):
+		return hostServer{}, nil
+	case errors.Is(err, openstack.ErrAmbiguousName):
+		Log().Warn().Str("host", host).Err(err).Msg("host matches several servers, keeping its ports")
+		return hostServer{Exists: true}, nil
+	case err != nil:
+		return hostServer{}, fmt.Errorf("failed to look up the server of host %s err=%w", host, err)
+	}
+	return hostServer{Exists: true, Id: server.ID}, nil
+}
+
+func (me *ClusterReaper) tooNew(port ports.Port) bool {
+	return time.Now().Sub(port.CreatedAt) <= me.Opts.MinPortAge
+}
+
+// decide decides whether a port is orphaned, serverGone is true when nova returned 404 for the port's device
+// and host is the server its host= tag resolved to
+func (me *ClusterReaper) decide(port ports.Port, serverGone bool, host hostServer) ReapDecision {
+	decision := ReapDecision{
+		PortId:      port.ID,
+		Status:      port.Status,
+		DeviceId:    port.DeviceID,
+		ContainerId: GetTagValue(port.Tags, "containerid"),
+		Netns:       GetTagValue(port.Tags, "netns"),
+		Host:        GetTagValue(port.Tags, "host"),
+		CreatedAt:   port.CreatedAt,
+	}
+
+	switch {
+	case me.tooNew(port):
+		diff := time.Now().Sub(port.CreatedAt)
+		decision.Reason = fmt.Sprintf("too new %s <= %s", diff.Round(time.Second), me.Opts.MinPortAge)
+	case port.DeviceID != "" && !serverGone:
+		decision.Reason = fmt.Sprintf("server %s exists", port.DeviceID)
+	case port.DeviceID != "" && host.Id != "":
+		decision.Reap = true
+		decision.Reason = fmt.Sprintf("host %s was replaced by server %s, server %s no longer exists", decision.Host, host.Id, port.DeviceID)
+	case port.DeviceID != "" && decision.Host != "" && !host.Exists:
+		decision.Reap = true
+		decision.Reason = fmt.Sprintf("host %s and its server %s no longer exist", decision.Host, port.DeviceID)
+	case port.DeviceID != "":
+		decision.Reap = true
+		decision.Reason = fmt.Sprintf("server %s no longer exists", port.DeviceID)
+	case port.Status != "DOWN":
+		decision.Reason = "detached but not DOWN"
+	case decision.Host == "":
+		decision.Reason = "DOWN and detached without a host tag"
+	case host.Exists:
+		decision.Reason = fmt.Sprintf("DOWN and detached, host %s exists", decision.Host)
+	default:
+		decision.Reap = true
+		decision.Reason = fmt.Sprintf("DOWN and detached, host %s no longer exists", decision.Host)
+	}
+	return decision
+}
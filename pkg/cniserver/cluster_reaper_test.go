@@ -0,0 +1,248 @@
+package cniserver_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/jboelensns/openstack-cni/pkg/cniserver"
+	. "github.com/jboelensns/openstack-cni/pkg/fixtures"
+	"github.com/jboelensns/openstack-cni/pkg/fixtures/mocks"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
+	. "github.com/pepinns/go-hamcrest"
+)
+
+type fakeLease struct {
+	acquired bool
+	err      error
+	released int
+}
+
+func (me *fakeLease) TryAcquire(ctx context.Context) (bool, error) {
+	return me.acquired, me.err
+}
+
+func (me *fakeLease) Release(ctx context.Context) error {
+	me.released++
+	return nil
+}
+
+func Test_ClusterReaper(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	hostPort := func(id, host, deviceId, status string, createdAt time.Time) ports.Port {
+		return ports.Port{ID: id, DeviceID: deviceId, Status: status, Tags: []string{"openstack-cni=true", "host=" + host}, CreatedAt: createdAt}
+	}
+	newReaper := func(lease *fakeLease, portList ...ports.Port) (*mocks.OpenstackClientMock, *cniserver.ClusterReaper) {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetPortsByTagsFunc = func(ctx context.Context, tags []string) ([]ports.Port, error) {
+			return portList, nil
+		}
+		mock.GetServerFunc = func(ctx context.Context, id string) (*servers.Server, error) {
+			switch id {
+			case "aliveId", "otherId":
+				return &servers.Server{ID: id}, nil
+			}
+			return nil, openstack.ErrServerNotFound
+		}
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			switch name {
+			case "alive":
+				return &servers.Server{ID: "aliveId"}, nil
+			case "rebuilt":
+				return &servers.Server{ID: "rebuiltId"}, nil
+			}
+			return nil, openstack.ErrServerNotFound
+		}
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+		reaper := &cniserver.ClusterReaper{
+			Opts:     cniserver.ClusterReaperOpts{Interval: time.Minute, MinPortAge: time.Minute},
+			OsClient: mock,
+			Lease:    lease,
+			Metrics:  Metrics(),
+		}
+		return mock, reaper
+	}
+	deletedPorts := func(mock *mocks.OpenstackClientMock) []string {
+		ids := []string{}
+		for _, call := range mock.DeletePortCalls() {
+			ids = append(ids, call.PortId)
+		}
+		return ids
+	}
+
+	t.Run("reaps the ports of deleted servers and the detached ports of deleted hosts", func(t *testing.T) {
+		mock, reaper := newReaper(&fakeLease{acquired: true},
+			hostPort("deleted", "gone", "goneId", "ACTIVE", old),
+			hostPort("unbound", "gone", "", "DOWN", old),
+			hostPort("binding", "alive", "", "ACTIVE", old),
+			hostPort("attached", "alive", "aliveId", "ACTIVE", old),
+		)
+
+		decisions, err := reaper.Run(context.Background())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(decisions, HasLen(4))
+		Assert(t).That(deletedPorts(mock), Equals([]string{"deleted", "unbound"}))
+	})
+
+	t.Run("reaps the ports of a deleted host", func(t *testing.T) {
+		mock, reaper := newReaper(&fakeLease{acquired: true},
+			hostPort("attached", "gone", "goneId", "ACTIVE", old),
+			hostPort("detached", "gone", "", "DOWN", old),
+		)
+
+		decisions, err := reaper.Run(context.Background())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(decisions[0].Reason, Contains("host gone and its server goneId no longer exist"))
+		Assert(t).That(decisions[1].Reason, Contains("host gone no longer exists"))
+		Assert(t).That(deletedPorts(mock), Equals([]string{"attached", "detached"}))
+	})
+
+	t.Run("reaps the ports of a rebuilt host that are attached to its old server", func(t *testing.T) {
+		mock, reaper := newReaper(&fakeLease{acquired: true},
+			hostPort("old", "rebuilt", "oldId", "ACTIVE", old),
+			hostPort("new", "rebuilt", "rebuiltId", "ACTIVE", old),
+			hostPort("detached", "rebuilt", "", "DOWN", old),
+		)
+		mock.GetServerFunc = func(ctx context.Context, id string) (*servers.Server, error) {
+			if id == "rebuiltId" {
+				return &servers.Server{ID: id}, nil
+			}
+			return nil, openstack.ErrServerNotFound
+		}
+
+		decisions, err := reaper.Run(context.Background())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(decisions[0].Reason, Contains("host rebuilt was replaced by server rebuiltId"))
+		Assert(t).That(decisions[2].Reason, Contains("host rebuilt exists"))
+		Assert(t).That(deletedPorts(mock), Equals([]string{"old"}))
+		Assert(t).That(mock.GetServerByNameCalls(), HasLen(1))
+	})
+
+	t.Run("leaves the detached ports of existing hosts to their PortReaper", func(t *testing.T) {
+		mock, reaper := newReaper(&fakeLease{acquired: true},
+			hostPort("detached", "alive", "", "DOWN", old),
+			ports.Port{ID: "nohost", Status: "DOWN", Tags: []string{"openstack-cni=true"}, CreatedAt: old},
+		)
+
+		decisions, err := reaper.Run(context.Background())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(decisions[0].Reap, IsFalse())
+		Assert(t).That(decisions[1].Reap, IsFalse())
+		Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+	})
+
+	t.Run("keeps the ports of a host whose name matches several servers", func(t *testing.T) {
+		mock, reaper := newReaper(&fakeLease{acquired: true}, hostPort("detached", "shared", "", "DOWN", old))
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return nil, openstack.ErrAmbiguousName
+		}
+
+		decisions, err := reaper.Run(context.Background())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(decisions[0].Reap, IsFalse())
+		Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+	})
+
+	t.Run("keeps ports attached to existing servers whatever their host tag says", func(t *testing.T) {
+		mock, reaper := newReaper(&fakeLease{acquired: true},
+			hostPort("renamed", "k8s-node-name", "aliveId", "ACTIVE", old),
+			hostPort("other", "rebuilt", "otherId", "DOWN", old),
+			ports.Port{ID: "nohost", DeviceID: "aliveId", Tags: []string{"openstack-cni=true"}, CreatedAt: old},
+		)
+
+		decisions, err := reaper.Run(context.Background())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(decisions, HasLen(3))
+		Assert(t).That(decisions[0].Reason, Contains("exists"))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+		Assert(t).That(mock.GetServerByNameCalls(), HasLen(0))
+	})
+
+	t.Run("looks up every server once", func(t *testing.T) {
+		mock, reaper := newReaper(&fakeLease{acquired: true},
+			hostPort("a", "alive", "aliveId", "ACTIVE", old),
+			hostPort("b", "alive", "aliveId", "ACTIVE", old),
+			hostPort("c", "gone", "goneId", "ACTIVE", old),
+			hostPort("d", "gone", "", "DOWN", old),
+		)
+
+		_, err := reaper.Plan(context.Background())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(mock.GetServerCalls(), HasLen(2))
+		Assert(t).That(mock.GetServerByNameCalls(), HasLen(1))
+	})
+
+	t.Run("keeps new ports", func(t *testing.T) {
+		mock, reaper := newReaper(&fakeLease{acquired: true},
+			hostPort("new", "gone", "goneId", "ACTIVE", time.Now()),
+			hostPort("newDetached", "gone", "", "DOWN", time.Now()),
+		)
+
+		decisions, err := reaper.Run(context.Background())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(decisions, HasLen(2))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+		Assert(t).That(mock.GetServerCalls(), HasLen(0))
+		Assert(t).That(mock.GetServerByNameCalls(), HasLen(0))
+	})
+
+	t.Run("does nothing without the lease", func(t *testing.T) {
+		mock, reaper := newReaper(&fakeLease{acquired: false}, hostPort("deleted", "gone", "goneId", "ACTIVE", old))
+
+		decisions, err := reaper.Run(context.Background())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(decisions, HasLen(0))
+		Assert(t).That(mock.GetPortsByTagsCalls(), HasLen(0))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+	})
+
+	t.Run("fails when the lease can't be acquired", func(t *testing.T) {
+		mock, reaper := newReaper(&fakeLease{err: errors.New("BOOM")}, hostPort("deleted", "gone", "goneId", "ACTIVE", old))
+
+		_, err := reaper.Run(context.Background())
+		Assert(t).That(err.Error(), Contains("BOOM"))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+	})
+
+	t.Run("doesn't delete when reaping is disabled", func(t *testing.T) {
+		mock, reaper := newReaper(&fakeLease{acquired: true}, hostPort("deleted", "gone", "goneId", "ACTIVE", old))
+		reaper.Opts.SkipDelete = true
+
+		decisions, err := reaper.Run(context.Background())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(decisions[0].Reap, IsTrue())
+		Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+	})
+
+	t.Run("doesn't reap anything when a server lookup fails", func(t *testing.T) {
+		mock, reaper := newReaper(&fakeLease{acquired: true}, hostPort("deleted", "gone", "goneId", "ACTIVE", old))
+		mock.GetServerFunc = func(ctx context.Context, id string) (*servers.Server, error) {
+			return nil, errors.New("BOOM")
+		}
+
+		_, err := reaper.Run(context.Background())
+		Assert(t).That(err, Not(IsNil()))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+	})
+
+	t.Run("doesn't reap anything when a host lookup fails", func(t *testing.T) {
+		mock, reaper := newReaper(&fakeLease{acquired: true}, hostPort("detached", "gone", "", "DOWN", old))
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return nil, errors.New("BOOM")
+		}
+
+		_, err := reaper.Run(context.Background())
+		Assert(t).That(err.Error(), Contains("BOOM"))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+	})
+
+	t.Run("releases the lease when stopped", func(t *testing.T) {
+		lease := &fakeLease{acquired: true}
+		_, reaper := newReaper(lease)
+		reaper.Stop()
+		Assert(t).That(lease.released, Equals(1))
+	})
+}
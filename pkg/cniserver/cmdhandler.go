@@ -1,11 +1,16 @@
 package cniserver
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"slices"
+	"strings"
 
 	"github.com/containernetworking/cni/pkg/types"
-	currentcni "github.com/containernetworking/cni/pkg/types/040"
+	currentcni "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
 	. "github.com/jboelensns/openstack-cni/pkg/logging"
 	"github.com/jboelensns/openstack-cni/pkg/openstack"
 	"github.com/jboelensns/openstack-cni/pkg/util"
@@ -16,11 +21,13 @@ import (
 // CommandHandler provides the ability to handle CNI commands
 type CommandHandler interface {
 	// Add handlers ADD commands
-	Add(cmd util.CniCommand) (*currentcni.Result, error)
+	Add(ctx context.Context, cmd util.CniCommand) (*currentcni.Result, error)
 	// Check handlers DEL commands
-	Del(cmd util.CniCommand) error
-	// Check handlers CHECK commands (NOT IMPLEMENTED)
-	Check(cmd util.CniCommand) error
+	Del(ctx context.Context, cmd util.CniCommand) error
+	// Check handlers CHECK commands
+	Check(ctx context.Context, cmd util.CniCommand) error
+	// GC handles GC commands
+	GC(ctx context.Context, cmd util.CniCommand) error
 }
 
 var _ CommandHandler = &commandHandler{}
@@ -34,7 +41,7 @@ type commandHandler struct {
 	pm *openstack.PortManager
 }
 
-func (me *commandHandler) Add(cmd util.CniCommand) (*currentcni.Result, error) {
+func (me *commandHandler) Add(ctx context.Context, cmd util.CniCommand) (*currentcni.Result, error) {
 	context, err := util.NewCniContext(cmd)
 	if err != nil {
 		return nil, err
@@ -42,15 +49,16 @@ func (me *commandHandler) Add(cmd util.CniCommand) (*currentcni.Result, error) {
 
 	opts := openstack.SetupPortOptsFromContext(context)
 	opts.Tags = NewPortTags(cmd)
-	portResult, err := me.pm.SetupPort(opts)
+	opts.CreateTags = []string{NewNetConfTag(context.CniConfig.Name)}
+	portResult, err := me.pm.SetupPort(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup port %w", err)
 	}
 
-	return NewCniResult(portResult, cmd)
+	return NewCniResult(portResult, context)
 }
 
-func (me *commandHandler) Del(cmd util.CniCommand) error {
+func (me *commandHandler) Del(ctx context.Context, cmd util.CniCommand) error {
 	log := Log().With().Str("cmd", cmd.String()).Logger()
 	context, err := util.NewCniContext(cmd)
 	if err != nil {
@@ -59,28 +67,119 @@ func (me *commandHandler) Del(cmd util.CniCommand) error {
 	}
 
 	opts := openstack.TearDownPortOpts{Hostname: context.Hostname, Tags: NewPortTags(cmd)}
-	if err := me.pm.TeardownPort(opts); err != nil {
+	if err := me.pm.TeardownPort(ctx, opts); err != nil {
 		log.Error().Str("hostname", context.Hostname).Str("tags", opts.Tags.String()).AnErr("err", err).Msg("failed to teardown port")
 		return nil
 	}
 	return nil
 }
 
-func (me *commandHandler) Check(cmd util.CniCommand) error {
+// Plugin specific CNI error codes returned by CHECK
+// see https://github.com/containernetworking/cni/blob/main/SPEC.md#error
+const (
+	ErrCodePortNotActive   uint = 100
+	ErrCodePortNotAttached uint = 101
+	ErrCodePortMismatch    uint = 102
+)
+
+func (me *commandHandler) Check(ctx context.Context, cmd util.CniCommand) error {
+	context, err := util.NewCniContext(cmd)
+	if err != nil {
+		return types.NewError(types.ErrDecodingFailure, "failed to parse network configuration", err.Error())
+	}
+
+	prevResult, err := util.NewPrevResult(cmd.StdinData)
+	if err != nil {
+		return types.NewError(types.ErrInvalidNetworkConfig, "failed to parse prevResult", err.Error())
+	}
+
+	opts := openstack.CheckPortOpts{Hostname: context.Hostname, Tags: NewPortTags(cmd)}
+	for _, iface := range prevResult.Interfaces {
+		if iface.Name == cmd.IfName {
+			opts.MacAddress = iface.Mac
+			break
+		}
+	}
+	for _, ip := range prevResult.IPs {
+		opts.IPs = append(opts.IPs, ip.Address.IP)
+	}
+
+	_, err = me.pm.CheckPort(ctx, opts)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, openstack.ErrPortNotFound):
+		return types.NewError(types.ErrUnknownContainer, "failed to find port", err.Error())
+	case errors.Is(err, openstack.ErrPortNotActive):
+		return types.NewError(ErrCodePortNotActive, "port is not active", err.Error())
+	case errors.Is(err, openstack.ErrPortNotAttached):
+		return types.NewError(ErrCodePortNotAttached, "port is not attached to this server", err.Error())
+	case errors.Is(err, openstack.ErrPortMacMismatch), errors.Is(err, openstack.ErrPortIpMismatch):
+		return types.NewError(ErrCodePortMismatch, "port does not match prevResult", err.Error())
+	}
+	return types.NewError(types.ErrInternal, "failed to check port", err.Error())
+}
+
+// GC deletes the ports of this host and network configuration that aren't in the list of valid attachments
+// only ports tagged with the network configuration's name are considered so other networks' ports are left alone
+func (me *commandHandler) GC(ctx context.Context, cmd util.CniCommand) error {
+	context, err := util.NewCniContext(cmd)
+	if err != nil {
+		return types.NewError(types.ErrDecodingFailure, "failed to parse network configuration", err.Error())
+	}
+	if context.CniConfig.NetConf == nil || context.CniConfig.Name == "" {
+		return types.NewError(types.ErrInvalidNetworkConfig, "network configuration is missing a name", "")
+	}
+
+	valid := make(map[string]bool)
+	for _, attachment := range context.CniConfig.ValidAttachments {
+		valid[attachmentKey(NewContainerIdTag(attachment.ContainerID), NewIfNameTag(attachment.IfName))] = true
+	}
+
+	opts := openstack.GarbageCollectPortsOpts{
+		Hostname: context.Hostname,
+		Tags:     openstack.NewNeutronTags(append(NewPortKeyTags(), NewNetConfTag(context.CniConfig.Name))...),
+		InUse: func(port ports.Port) bool {
+			containerIdTag, ifNameTag := "", ""
+			for _, tag := range port.Tags {
+				if strings.HasPrefix(tag, "containerid=") {
+					containerIdTag = tag
+				} else if strings.HasPrefix(tag, "ifname=") {
+					ifNameTag = tag
+				}
+			}
+			// keep ports we can't identify
+			if containerIdTag == "" || ifNameTag == "" {
+				return true
+			}
+			return valid[attachmentKey(containerIdTag, ifNameTag)]
+		},
+	}
+	deleted, err := me.pm.GarbageCollectPorts(ctx, opts)
+	Log().Info().Str("netconf", context.CniConfig.Name).Int("valid_attachments", len(valid)).Strs("deleted", deleted).Msg("garbage collected ports")
+	if err != nil {
+		return types.NewError(types.ErrInternal, "failed to garbage collect ports", err.Error())
+	}
 	return nil
 }
 
+func attachmentKey(containerIdTag, ifNameTag string) string {
+	return containerIdTag + "," + ifNameTag
+}
+
 var ErrIncompletePortResult = fmt.Errorf("Incomplete port result")
 
-// NewCniResult creates a new Result from the combination of a SetupPortResult and CniCommand
-func NewCniResult(portResult *openstack.SetupPortResult, cmd util.CniCommand) (*currentcni.Result, error) {
+// NewCniResult creates a new Result from the combination of a SetupPortResult and CniContext
+func NewCniResult(portResult *openstack.SetupPortResult, context util.CniContext) (*currentcni.Result, error) {
+	cmd := context.Command
+
 	if portResult.Attachment == nil || portResult.Network == nil ||
 		portResult.Port == nil || portResult.Subnet == nil {
 
 		return nil, ErrIncompletePortResult
 	}
 
-	ipnet, err := portResult.GetIp()
+	addresses, err := portResult.GetAddresses()
 	if err != nil {
 		return nil, err
 	}
@@ -92,37 +191,79 @@ func NewCniResult(portResult *openstack.SetupPortResult, cmd util.CniCommand) (*
 			{
 				Name:    cmd.IfName,
 				Mac:     portResult.Attachment.MACAddr,
+				Mtu:     portResult.Network.MTU,
 				Sandbox: cmd.Netns,
 			},
 		},
-		IPs: []*currentcni.IPConfig{
-			{
-				Interface: &zero,
-				Address:   *ipnet,
-				Gateway:   net.ParseIP(portResult.Subnet.GatewayIP),
-				Version:   "4",
-			},
-		},
+		IPs:    make([]*currentcni.IPConfig, 0, len(addresses)),
 		Routes: make([]*types.Route, 0, 0),
 		DNS: types.DNS{
-			Nameservers: portResult.Subnet.DNSNameservers,
+			Nameservers: make([]string, 0),
 			// Domain:      "",         // NEED
 			// Search:      []string{}, //NEED
 			// Options:     []string{}, //NEED
 		},
 	}
 
-	// add host routes
-	for _, route := range portResult.Subnet.HostRoutes {
-		result.Routes = append(result.Routes, &types.Route{
-			Dst: NewIpNet(route.DestinationCIDR),
-			GW:  net.ParseIP(route.NextHop),
+	// add every fixed ip along with the gateway, host routes and nameservers of its subnet
+	seenSubnets := make(map[string]bool)
+	for _, address := range addresses {
+		result.IPs = append(result.IPs, &currentcni.IPConfig{
+			Interface: &zero,
+			Address:   *address.Address,
+			Gateway:   net.ParseIP(address.Subnet.GatewayIP),
 		})
+
+		if seenSubnets[address.Subnet.ID] {
+			continue
+		}
+		seenSubnets[address.Subnet.ID] = true
+
+		for _, route := range address.Subnet.HostRoutes {
+			result.Routes = append(result.Routes, &types.Route{
+				Dst: NewIpNet(route.DestinationCIDR),
+				GW:  net.ParseIP(route.NextHop),
+			})
+		}
+		for _, nameserver := range address.Subnet.DNSNameservers {
+			if !slices.Contains(result.DNS.Nameservers, nameserver) {
+				result.DNS.Nameservers = append(result.DNS.Nameservers, nameserver)
+			}
+		}
+	}
+
+	// optionally route all traffic through the gateway of the first subnet of each ip version
+	if context.CniConfig.DefaultRoute {
+		for _, address := range addresses {
+			gateway := net.ParseIP(address.Subnet.GatewayIP)
+			if gateway == nil || hasDefaultRoute(result.Routes, address.Version()) {
+				continue
+			}
+			result.Routes = append(result.Routes, &types.Route{Dst: defaultRouteDst(address.Version()), GW: gateway})
+		}
 	}
 
 	return result, nil
 }
 
+// defaultRouteDst returns 0.0.0.0/0 or ::/0 depending on the ip version
+func defaultRouteDst(version string) net.IPNet {
+	if version == "6" {
+		return net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
+	}
+	return net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}
+}
+
+func hasDefaultRoute(routes []*types.Route, version string) bool {
+	dst := defaultRouteDst(version)
+	for _, route := range routes {
+		if route.Dst.String() == dst.String() {
+			return true
+		}
+	}
+	return false
+}
+
 // NewIpNet creates a new IPNet from a string containing an IP and prefix (e.g. "10.1.2.3/24")
 func NewIpNet(cidr string) net.IPNet {
 	theip, ipnet, err := net.ParseCIDR(cidr)
@@ -134,20 +275,32 @@ func NewIpNet(cidr string) net.IPNet {
 
 // NewPortTags creates a NeutronTags including container, interface and namespace data
 func NewPortTags(cmd util.CniCommand) openstack.NeutronTags {
-	containerId := cmd.ContainerID
-	if len(containerId) > 12 {
-		containerId = containerId[0:12]
-	}
-
 	return openstack.NewNeutronTags(
-		fmt.Sprintf("containerid=%s", containerId),
-		fmt.Sprintf("ifname=%s", cmd.IfName),
+		NewContainerIdTag(cmd.ContainerID),
+		NewIfNameTag(cmd.IfName),
 		fmt.Sprintf("netns=%s", cmd.Netns),
 		fmt.Sprintf(OPENSTACK_CNI_TAG),
 		NewHostTag(),
 	)
 }
 
+// NewContainerIdTag returns the tag for a container id, long ids are truncated to 12 characters
+func NewContainerIdTag(containerId string) string {
+	if len(containerId) > 12 {
+		containerId = containerId[0:12]
+	}
+	return fmt.Sprintf("containerid=%s", containerId)
+}
+
+func NewIfNameTag(ifName string) string {
+	return fmt.Sprintf("ifname=%s", ifName)
+}
+
+// NewNetConfTag returns the tag for the name of a network configuration
+func NewNetConfTag(name string) string {
+	return fmt.Sprintf("netconf=%s", name)
+}
+
 func NewHostTag() string {
 	hostname, _ := util.GetHostname()
 	return fmt.Sprintf("host=%s", hostname)
@@ -1,10 +1,20 @@
 package cniserver_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
 	"github.com/jboelensns/openstack-cni/pkg/cniserver"
 	. "github.com/jboelensns/openstack-cni/pkg/fixtures"
+	"github.com/jboelensns/openstack-cni/pkg/fixtures/mocks"
 	"github.com/jboelensns/openstack-cni/pkg/openstack"
 	"github.com/jboelensns/openstack-cni/pkg/util"
 
@@ -14,14 +24,90 @@ import (
 func Test_CreateResultFromPortResult(t *testing.T) {
 	t.Run("returns an error with incomplete data", func(t *testing.T) {
 		portResult := &openstack.SetupPortResult{}
-		cmd := util.CniCommand{}
 
-		result, err := cniserver.NewCniResult(portResult, cmd)
+		result, err := cniserver.NewCniResult(portResult, util.CniContext{})
 		Assert(t).That(result, IsNil())
 		Assert(t).That(err, Equals(cniserver.ErrIncompletePortResult))
 	})
 }
 
+func Test_CreateDualStackResult(t *testing.T) {
+	t.Run("every fixed ip is included with its own version, prefix and gateway", func(t *testing.T) {
+		v4Subnet := &subnets.Subnet{ID: "v4", CIDR: "192.168.1.0/24", GatewayIP: "192.168.1.1", IPVersion: 4,
+			DNSNameservers: []string{"1.1.1.1"}}
+		v6Subnet := &subnets.Subnet{ID: "v6", CIDR: "2001:db8::/64", GatewayIP: "2001:db8::1", IPVersion: 6,
+			DNSNameservers: []string{"2606:4700:4700::1111", "1.1.1.1"},
+			HostRoutes:     []subnets.HostRoute{{DestinationCIDR: "2001:db8:1::/64", NextHop: "2001:db8::2"}}}
+		portResult := &openstack.SetupPortResult{
+			Server:     &servers.Server{ID: "serverId"},
+			Network:    &openstack.Network{Network: networks.Network{ID: "networkId"}},
+			Subnet:     v4Subnet,
+			Subnets:    map[string]*subnets.Subnet{"v4": v4Subnet, "v6": v6Subnet},
+			Attachment: &attachinterfaces.Interface{MACAddr: "02:42:d9:1f:22:9d"},
+			Port: &ports.Port{FixedIPs: []ports.IP{
+				{SubnetID: "v4", IPAddress: "192.168.1.42"},
+				{SubnetID: "v6", IPAddress: "2001:db8::42"},
+			}},
+		}
+
+		result, err := cniserver.NewCniResult(portResult, util.CniContext{Command: NewTestData().CniCommand()})
+		Assert(t).That(err, IsNil())
+		Assert(t).That(result.IPs, HasLen(2))
+		Assert(t).That(result.IPs[0].Address.String(), Equals("192.168.1.42/24"))
+		Assert(t).That(result.IPs[0].Gateway.String(), Equals("192.168.1.1"))
+		Assert(t).That(result.IPs[1].Address.String(), Equals("2001:db8::42/64"))
+		Assert(t).That(result.IPs[1].Gateway.String(), Equals("2001:db8::1"))
+		Assert(t).That(result.Routes, HasLen(1))
+		Assert(t).That(result.Routes[0].Dst.String(), Equals("2001:db8:1::/64"))
+		Assert(t).That(result.DNS.Nameservers, Equals([]string{"1.1.1.1", "2606:4700:4700::1111"}))
+	})
+}
+
+func Test_CreateResultRoutesAndMtu(t *testing.T) {
+	v4Subnet := &subnets.Subnet{ID: "v4", CIDR: "192.168.1.0/24", GatewayIP: "192.168.1.1", IPVersion: 4,
+		HostRoutes: []subnets.HostRoute{{DestinationCIDR: "10.0.0.0/8", NextHop: "192.168.1.2"}}}
+	v6Subnet := &subnets.Subnet{ID: "v6", CIDR: "2001:db8::/64", GatewayIP: "2001:db8::1", IPVersion: 6}
+	newPortResult := func() *openstack.SetupPortResult {
+		network := &openstack.Network{Network: networks.Network{ID: "networkId"}}
+		network.MTU = 1450
+		return &openstack.SetupPortResult{
+			Server:     &servers.Server{ID: "serverId"},
+			Network:    network,
+			Subnet:     v4Subnet,
+			Subnets:    map[string]*subnets.Subnet{"v4": v4Subnet, "v6": v6Subnet},
+			Attachment: &attachinterfaces.Interface{MACAddr: "02:42:d9:1f:22:9d"},
+			Port: &ports.Port{FixedIPs: []ports.IP{
+				{SubnetID: "v4", IPAddress: "192.168.1.42"},
+				{SubnetID: "v6", IPAddress: "2001:db8::42"},
+			}},
+		}
+	}
+
+	t.Run("the network's mtu is returned", func(t *testing.T) {
+		result, err := cniserver.NewCniResult(newPortResult(), util.CniContext{Command: NewTestData().CniCommand()})
+		Assert(t).That(err, IsNil())
+		Assert(t).That(result.Interfaces[0].Mtu, Equals(1450))
+	})
+
+	t.Run("only host routes are returned without default_route", func(t *testing.T) {
+		result, err := cniserver.NewCniResult(newPortResult(), util.CniContext{Command: NewTestData().CniCommand()})
+		Assert(t).That(err, IsNil())
+		Assert(t).That(result.Routes, HasLen(1))
+		Assert(t).That(result.Routes[0].Dst.String(), Equals("10.0.0.0/8"))
+	})
+
+	t.Run("default routes through each gateway are returned with default_route", func(t *testing.T) {
+		cniContext := util.CniContext{Command: NewTestData().CniCommand(), CniConfig: util.CniConfig{DefaultRoute: true}}
+		result, err := cniserver.NewCniResult(newPortResult(), cniContext)
+		Assert(t).That(err, IsNil())
+		Assert(t).That(result.Routes, HasLen(3))
+		Assert(t).That(result.Routes[1].Dst.String(), Equals("0.0.0.0/0"))
+		Assert(t).That(result.Routes[1].GW.String(), Equals("192.168.1.1"))
+		Assert(t).That(result.Routes[2].Dst.String(), Equals("::/0"))
+		Assert(t).That(result.Routes[2].GW.String(), Equals("2001:db8::1"))
+	})
+}
+
 func Test_CmdHandler(t *testing.T) {
 	t.Run("can add and delete using a handler", func(t *testing.T) {
 		WithTestConfig(t, func(cfg TestingConfig) {
@@ -29,20 +115,20 @@ func Test_CmdHandler(t *testing.T) {
 			Assert(t).That(err, IsNil())
 
 			cmd := NewTestData().CniCommand()
-			results, err := deps.CniHandler().Add(cmd)
+			results, err := deps.CniHandler().Add(context.Background(), cmd)
 			Assert(t).That(err, IsNil())
 			Assert(t).That(results, Not(IsNil()))
 
 			// ensure the port exists
-			port, err := deps.OpenstackClient().GetPortByTags(cniserver.NewPortTags(cmd).AsStringSlice())
+			port, err := deps.OpenstackClient().GetPortByTags(context.Background(), cniserver.NewPortTags(cmd).AsStringSlice())
 			Assert(t).That(err, IsNil())
 			Assert(t).That(port, Not(IsNil()))
 
 			// issue a delete
-			Assert(t).That(deps.CniHandler().Del(cmd), IsNil())
+			Assert(t).That(deps.CniHandler().Del(context.Background(), cmd), IsNil())
 
 			// ensure the port's gone
-			port, perr := deps.OpenstackClient().GetPortByTags(cniserver.NewPortTags(cmd).AsStringSlice())
+			port, perr := deps.OpenstackClient().GetPortByTags(context.Background(), cniserver.NewPortTags(cmd).AsStringSlice())
 			Assert(t).That(perr, Equals(openstack.ErrPortNotFound))
 		})
 	})
@@ -71,3 +157,158 @@ func Test_NewPortTags(t *testing.T) {
 		Assert(t).That(tags.Tags[0], Equals("containerid=123"))
 	})
 }
+
+func Test_CmdHandlerCheck(t *testing.T) {
+	newCheckCommand := func() util.CniCommand {
+		data := NewTestData()
+		result := data.CniResult()
+		cmd := data.CniCommand()
+		cmd.Command = cniserver.CommandCheck
+		result.Interfaces[0].Name = cmd.IfName
+		cmd.StdinData = data.StdinWithPrevResult(result)
+		return cmd
+	}
+	activePort := func() *ports.Port {
+		return &ports.Port{
+			ID:         "portId",
+			Status:     "ACTIVE",
+			DeviceID:   "serverId",
+			MACAddress: "02:42:d9:1f:22:9d",
+			FixedIPs:   []ports.IP{{SubnetID: "subnetId", IPAddress: "192.168.1.42"}},
+		}
+	}
+	withHandler := func(t *testing.T, port *ports.Port, callback func(handler cniserver.CommandHandler)) {
+		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
+			mock.GetPortByTagsFunc = func(ctx context.Context, tags []string) (*ports.Port, error) { return port, nil }
+			mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+				return &servers.Server{ID: "serverId"}, nil
+			}
+			callback(cniserver.NewCniCommandHandler(openstack.NewPortManager(client)))
+		})
+	}
+	assertCode := func(t *testing.T, err error, code uint) {
+		t.Helper()
+		var cniErr *types.Error
+		Assert(t).That(errors.As(err, &cniErr), IsTrue())
+		Assert(t).That(cniErr.Code, Equals(code))
+	}
+
+	t.Run("succeeds when the port matches the prevResult", func(t *testing.T) {
+		withHandler(t, activePort(), func(handler cniserver.CommandHandler) {
+			Assert(t).That(handler.Check(context.Background(), newCheckCommand()), IsNil())
+		})
+	})
+
+	t.Run("fails when the prevResult is missing", func(t *testing.T) {
+		withHandler(t, activePort(), func(handler cniserver.CommandHandler) {
+			cmd := newCheckCommand()
+			cmd.StdinData = NewTestData().Stdin()
+			assertCode(t, handler.Check(context.Background(), cmd), types.ErrInvalidNetworkConfig)
+		})
+	})
+
+	t.Run("fails when the port is not ACTIVE", func(t *testing.T) {
+		port := activePort()
+		port.Status = "DOWN"
+		withHandler(t, port, func(handler cniserver.CommandHandler) {
+			assertCode(t, handler.Check(context.Background(), newCheckCommand()), cniserver.ErrCodePortNotActive)
+		})
+	})
+
+	t.Run("fails when the port is attached to another server", func(t *testing.T) {
+		port := activePort()
+		port.DeviceID = "otherServerId"
+		withHandler(t, port, func(handler cniserver.CommandHandler) {
+			assertCode(t, handler.Check(context.Background(), newCheckCommand()), cniserver.ErrCodePortNotAttached)
+		})
+	})
+
+	t.Run("fails when the mac or ip address differ", func(t *testing.T) {
+		port := activePort()
+		port.MACAddress = "02:42:d9:1f:22:00"
+		withHandler(t, port, func(handler cniserver.CommandHandler) {
+			assertCode(t, handler.Check(context.Background(), newCheckCommand()), cniserver.ErrCodePortMismatch)
+		})
+
+		port = activePort()
+		port.FixedIPs[0].IPAddress = "192.168.1.43"
+		withHandler(t, port, func(handler cniserver.CommandHandler) {
+			assertCode(t, handler.Check(context.Background(), newCheckCommand()), cniserver.ErrCodePortMismatch)
+		})
+	})
+
+	t.Run("fails when the port does not exist", func(t *testing.T) {
+		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
+			mock.GetPortByTagsFunc = func(ctx context.Context, tags []string) (*ports.Port, error) { return nil, openstack.ErrPortNotFound }
+			handler := cniserver.NewCniCommandHandler(openstack.NewPortManager(client))
+			assertCode(t, handler.Check(context.Background(), newCheckCommand()), types.ErrUnknownContainer)
+		})
+	})
+}
+
+func Test_CmdHandlerGC(t *testing.T) {
+	validAttachment := types.GCAttachment{ContainerID: "3369ae15e741d31e8616906642c3ca309291e7776e2fff3bb8d379e642e056a8", IfName: "eth37"}
+	newPort := func(id, containerId, ifName, deviceId string) ports.Port {
+		return ports.Port{
+			ID:       id,
+			DeviceID: deviceId,
+			Tags:     []string{cniserver.NewContainerIdTag(containerId), cniserver.NewIfNameTag(ifName), cniserver.OPENSTACK_CNI_TAG},
+		}
+	}
+	newGCCommand := func() util.CniCommand {
+		data := NewTestData()
+		return util.CniCommand{Command: cniserver.CommandGC, StdinData: data.StdinWithValidAttachments(validAttachment)}
+	}
+
+	t.Run("deletes the ports that are not valid attachments", func(t *testing.T) {
+		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
+			mock.GetPortsByTagsFunc = func(ctx context.Context, tags []string) ([]ports.Port, error) {
+				return []ports.Port{
+					newPort("validPortId", validAttachment.ContainerID, validAttachment.IfName, "serverId"),
+					newPort("otherIfacePortId", validAttachment.ContainerID, "eth38", "serverId"),
+					newPort("detachedPortId", "0123456789abcdef", "eth37", ""),
+					newPort("otherServerPortId", "0123456789abcdef", "eth37", "otherServerId"),
+				}, nil
+			}
+			mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+				return &servers.Server{ID: "serverId"}, nil
+			}
+			mock.DetachPortFunc = func(ctx context.Context, portId, serverId string) error { return nil }
+			mock.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+
+			handler := cniserver.NewCniCommandHandler(openstack.NewPortManager(client))
+			Assert(t).That(handler.GC(context.Background(), newGCCommand()), IsNil())
+
+			Assert(t).That(mock.GetPortsByTagsCalls()[0].Tags, Contains(cniserver.NewNetConfTag("service-ingress")))
+			Assert(t).That(mock.DetachPortCalls(), HasLen(1))
+			Assert(t).That(mock.DetachPortCalls()[0].PortId, Equals("otherIfacePortId"))
+			Assert(t).That(mock.DeletePortCalls(), HasLen(2))
+			Assert(t).That(mock.DeletePortCalls()[0].PortId, Equals("otherIfacePortId"))
+			Assert(t).That(mock.DeletePortCalls()[1].PortId, Equals("detachedPortId"))
+		})
+	})
+
+	t.Run("reports the ports that failed to be deleted", func(t *testing.T) {
+		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
+			mock.GetPortsByTagsFunc = func(ctx context.Context, tags []string) ([]ports.Port, error) {
+				return []ports.Port{newPort("portId", "0123456789abcdef", "eth37", "")}, nil
+			}
+			mock.DeletePortFunc = func(ctx context.Context, portId string) error { return fmt.Errorf("BOOM") }
+
+			handler := cniserver.NewCniCommandHandler(openstack.NewPortManager(client))
+			err := handler.GC(context.Background(), newGCCommand())
+			Assert(t).That(err.Error(), AllOf(Contains("portId"), Contains("BOOM")))
+		})
+	})
+
+	t.Run("fails without a network name", func(t *testing.T) {
+		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
+			handler := cniserver.NewCniCommandHandler(openstack.NewPortManager(client))
+			cmd := util.CniCommand{Command: cniserver.CommandGC, StdinData: []byte(`{"cniVersion":"1.1.0"}`)}
+			var cniErr *types.Error
+			Assert(t).That(errors.As(handler.GC(context.Background(), cmd), &cniErr), IsTrue())
+			Assert(t).That(cniErr.Code, Equals(types.ErrInvalidNetworkConfig))
+			Assert(t).That(mock.GetPortsByTagsCalls(), HasLen(0))
+		})
+	})
+}
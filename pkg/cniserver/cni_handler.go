@@ -1,10 +1,13 @@
 package cniserver
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/containernetworking/cni/pkg/types"
 	. "github.com/jboelensns/openstack-cni/pkg/logging"
@@ -14,11 +17,13 @@ import (
 var CommandAdd = "ADD"
 var CommandDel = "DEL"
 var CommandCheck = "CHECK"
+var CommandGC = "GC"
 
 // CniHandler handles /cni related requests
 type CniHandler struct {
 	Cni     CommandHandler
 	Metrics *Metrics
+	Locks   *AttachmentLocks
 }
 
 // HandleRequest validates and handlers /cni related requests
@@ -37,14 +42,36 @@ func (me *CniHandler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	me.HandleCommand(w, *cmd)
+	if me.Locks != nil {
+		release, err := me.Locks.Lock(r.Context(), attachmentLockKey(*cmd))
+		if err != nil {
+			AddStrings(Log().Warn(), cmd.ForLog()).Err(err).Msg("gave up waiting to handle /cni request")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(asJson(types.NewError(types.ErrTryAgainLater, "another command for the container is still running", err.Error())))
+			return
+		}
+		defer release()
+	}
+
+	me.HandleCommand(r.Context(), w, *cmd)
 }
 
-// HandleCommand handlers ADD/DEL/CHECK CNI command requests
-func (me *CniHandler) HandleCommand(w http.ResponseWriter, cmd util.CniCommand) {
+// attachmentLockKey returns the key serializing commands for the same attachment
+// GC isn't specific to an attachment
+func attachmentLockKey(cmd util.CniCommand) string {
+	if cmd.Command == CommandGC {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", cmd.ContainerID, cmd.IfName)
+}
+
+// HandleCommand handlers ADD/DEL/CHECK/GC CNI command requests
+func (me *CniHandler) HandleCommand(ctx context.Context, w http.ResponseWriter, cmd util.CniCommand) {
+	start := time.Now()
 	switch cmd.Command {
 	case CommandAdd:
-		result, err := me.Cni.Add(cmd)
+		result, err := me.Cni.Add(ctx, cmd)
+		me.Metrics.observeCommand(cmd, start, err)
 		if err != nil {
 			me.Metrics.cniAddFailureCount.Inc()
 			AddStrings(Log().Error(), cmd.ForLog()).Err(err).Msg("failed to handle /cni ADD")
@@ -58,7 +85,9 @@ func (me *CniHandler) HandleCommand(w http.ResponseWriter, cmd util.CniCommand)
 		}
 		return
 	case CommandDel:
-		if err := me.Cni.Del(cmd); err != nil {
+		err := me.Cni.Del(ctx, cmd)
+		me.Metrics.observeCommand(cmd, start, err)
+		if err != nil {
 			me.Metrics.cniDelFailureCount.Inc()
 			AddStrings(Log().Error(), cmd.ForLog()).Err(err).Msg("failed to handle /cni DEL")
 			cerr := NewErrorResult(err, "error during DEL", fmt.Sprintf("containerid=%s ifname=%s", cmd.ContainerID, cmd.IfName))
@@ -70,7 +99,9 @@ func (me *CniHandler) HandleCommand(w http.ResponseWriter, cmd util.CniCommand)
 		w.WriteHeader(http.StatusNoContent)
 		return
 	case CommandCheck:
-		if err := me.Cni.Check(cmd); err != nil {
+		err := me.Cni.Check(ctx, cmd)
+		me.Metrics.observeCommand(cmd, start, err)
+		if err != nil {
 			me.Metrics.cniCheckFailureCount.Inc()
 			AddStrings(Log().Error(), cmd.ForLog()).Err(err).Msg("failed to handle /cni CHECK")
 			cerr := NewErrorResult(err, "error during CHECK", fmt.Sprintf("containerid=%s ifname=%s", cmd.ContainerID, cmd.IfName))
@@ -80,12 +111,34 @@ func (me *CniHandler) HandleCommand(w http.ResponseWriter, cmd util.CniCommand)
 		}
 		me.Metrics.cniCheckSuccessCount.Inc()
 		return
+	case CommandGC:
+		err := me.Cni.GC(ctx, cmd)
+		me.Metrics.observeCommand(cmd, start, err)
+		if err != nil {
+			me.Metrics.cniGcFailureCount.Inc()
+			AddStrings(Log().Error(), cmd.ForLog()).Err(err).Msg("failed to handle /cni GC")
+			cerr := NewErrorResult(err, "error during GC", "")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(cerr)
+			return
+		}
+		me.Metrics.cniGcSuccessCount.Inc()
+		w.WriteHeader(http.StatusNoContent)
+		return
 	}
 	w.WriteHeader(http.StatusBadRequest)
 }
 
 // validateCommand ensures that a command is valid
 func (me *CniHandler) validateCommand(cmd util.CniCommand) error {
+	// GC isn't specific to a container
+	if cmd.Command == CommandGC {
+		if len(cmd.StdinData) == 0 {
+			return ErrBadCommand
+		}
+		return nil
+	}
+
 	if cmd.Command == "" ||
 		cmd.ContainerID == "" ||
 		cmd.IfName == "" ||
@@ -104,7 +157,12 @@ func (me *CniHandler) validateCommand(cmd util.CniCommand) error {
 var ErrBadCommand = fmt.Errorf("bad command")
 
 // NewErrorResult creates a new error result and marshals it as json
+// CNI errors are returned as is so that their error codes are preserved
 func NewErrorResult(err error, msg, details string) []byte {
+	var cniErr *types.Error
+	if errors.As(err, &cniErr) {
+		return asJson(cniErr)
+	}
 	return asJson(types.NewError(types.ErrInternal, msg, err.Error()))
 }
 
@@ -2,55 +2,190 @@ package cniserver
 
 import (
 	"fmt"
-	"net/url"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/hashicorp/go-multierror"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
 	"github.com/jboelensns/openstack-cni/pkg/util"
 )
 
 // Config is used to configure the application
+// ListenNetwork is either tcp or unix, ListenAddr is then a host:port or the path of the socket
+// Requests to paths other than PublicPaths are authenticated with the token in AuthTokenFile
+// or a client certificate signed by TLSClientCAFile
+// MaxConcurrentCalls limits the OpenStack operations running at once, 0 disables the limit
+// Retry configures the backoff of failed OpenStack operations
+// ProcPath is where the host's /proc is mounted, the reaper uses it to find ports of deleted network namespaces
+// ClusterReaping enables the ClusterReaper, only the daemon holding the lease stored in the security group called
+// ReapLeaseName reaps the ports of deleted servers, ReapLeaseDuration has to exceed ClusterReapInterval
+// PortCountInterval is how often the ports of the host are counted for the cni_port_total metric
+// CacheTTL is how long OpenStack lookups are cached, CacheNegativeTTL how long the ones that found nothing are
+// and CacheLookupTimeout how long a lookup shared by concurrent requests may take
+// PortWait controls how long ADD waits for a port to become ACTIVE, it has to be shorter than WriteTimeout
+// InstanceId locates the UUID of the local server, without it the server is looked up by hostname
 type Config struct {
-	ListenAddr   string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
-	ReapInterval time.Duration
-	MinPortAge   time.Duration
-	SkipReaping  bool
+	ListenNetwork       string
+	ListenAddr          string
+	SocketMode          os.FileMode
+	ReadTimeout         time.Duration
+	WriteTimeout        time.Duration
+	ReapInterval        time.Duration
+	MinPortAge          time.Duration
+	SkipReaping         bool
+	ProcPath            string
+	ClusterReaping      bool
+	ClusterReapInterval time.Duration
+	ReapLeaseName       string
+	ReapLeaseDuration   time.Duration
+	PortCountInterval   time.Duration
+	CacheTTL            time.Duration
+	CacheNegativeTTL    time.Duration
+	CacheLookupTimeout  time.Duration
+	AuthTokenFile       string
+	TLSCertFile         string
+	TLSKeyFile          string
+	TLSClientCAFile     string
+	PublicPaths         []string
+	MaxConcurrentCalls  int
+	Retry               openstack.RetryOpts
+	PortWait            openstack.PortWaitOpts
+	InstanceId          openstack.InstanceIdOpts
 }
 
-// NewConfig creates a new default Config
+// NewConfig creates a new default Config, it panics when the configuration is invalid
 func NewConfig() Config {
-	listenUrl := util.Getenv("CNI_API_URL", "http://127.0.0.1:4242")
-	url, err := url.Parse(listenUrl)
+	config, err := LoadConfig()
 	if err != nil {
-		panic(fmt.Sprintf("invalid configuration CNI_API_URL=%s err=%s", listenUrl, err))
+		panic(err.Error())
 	}
+	return config
+}
+
+// LoadConfig reads the Config from the environment and reports every invalid setting
+func LoadConfig() (Config, error) {
+	return LoadConfigFrom(nil)
+}
 
-	return Config{
-		ListenAddr:   url.Host,
-		ReadTimeout:  getEnvDuration("CNI_READ_TIMEOUT", "10s"),
-		WriteTimeout: getEnvDuration("CNI_WRITE_TIMEOUT", "10s"),
-		ReapInterval: getEnvDuration("CNI_REAP_INTERVAL", "300s"),
-		MinPortAge:   getEnvDuration("CNI_MIN_PORT_AGE", "300s"),
-		SkipReaping:  getEnvBool("CNI_SKIP_REAPING", "false"),
+// LoadConfigFrom reads the Config from values, settings values doesn't have are read from the environment
+func LoadConfigFrom(values map[string]string) (Config, error) {
+	env := &envReader{values: values}
+	listenUrl := env.get("CNI_API_URL", "http://127.0.0.1:4242")
+	network, addr, err := util.ParseApiUrl(listenUrl)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid configuration CNI_API_URL=%s err=%w", listenUrl, err)
+	}
+
+	config := Config{
+		ListenNetwork:       network,
+		ListenAddr:          addr,
+		SocketMode:          env.fileMode("CNI_SOCKET_MODE", "0600"),
+		ReadTimeout:         env.duration("CNI_READ_TIMEOUT", "10s"),
+		WriteTimeout:        env.duration("CNI_WRITE_TIMEOUT", "60s"),
+		ReapInterval:        env.duration("CNI_REAP_INTERVAL", "300s"),
+		MinPortAge:          env.duration("CNI_MIN_PORT_AGE", "300s"),
+		SkipReaping:         env.bool("CNI_SKIP_REAPING", "false"),
+		ProcPath:            env.get("CNI_PROC_PATH", "/host/proc"),
+		ClusterReaping:      env.bool("CNI_CLUSTER_REAPING", "false"),
+		ClusterReapInterval: env.duration("CNI_CLUSTER_REAP_INTERVAL", "600s"),
+		ReapLeaseName:       env.get("CNI_CLUSTER_REAP_LEASE_NAME", "openstack-cni-reaper-lease"),
+		ReapLeaseDuration:   env.duration("CNI_CLUSTER_REAP_LEASE_DURATION", "1200s"),
+		PortCountInterval:   env.duration("CNI_PORT_COUNT_INTERVAL", "60s"),
+		CacheTTL:            env.duration("CNI_CACHE_TTL", "300s"),
+		CacheNegativeTTL:    env.duration("CNI_CACHE_NEGATIVE_TTL", "5s"),
+		CacheLookupTimeout:  env.duration("CNI_CACHE_LOOKUP_TIMEOUT", "30s"),
+		AuthTokenFile:       env.get("CNI_AUTH_TOKEN_FILE", ""),
+		TLSCertFile:         env.get("CNI_SERVER_TLS_CERT_FILE", ""),
+		TLSKeyFile:          env.get("CNI_SERVER_TLS_KEY_FILE", ""),
+		TLSClientCAFile:     env.get("CNI_SERVER_TLS_CLIENT_CA_FILE", ""),
+		PublicPaths:         env.list("CNI_PUBLIC_PATHS", "/health,/ping,/metrics"),
+		MaxConcurrentCalls:  env.int("CNI_MAX_CONCURRENT_CALLS", "10"),
+		Retry: openstack.RetryOpts{
+			MaxAttempts: env.int("CNI_RETRY_MAX_ATTEMPTS", "5"),
+			BaseDelay:   env.duration("CNI_RETRY_BASE_DELAY", "250ms"),
+			MaxDelay:    env.duration("CNI_RETRY_MAX_DELAY", "5s"),
+		},
+		PortWait: openstack.PortWaitOpts{
+			Timeout:  env.duration("CNI_PORT_WAIT_TIMEOUT", "30s"),
+			Interval: env.duration("CNI_PORT_WAIT_INTERVAL", "1s"),
+		},
+		InstanceId: openstack.InstanceIdOpts{
+			ConfigDrivePath: env.get("CNI_CONFIG_DRIVE_PATH", "/mnt/config"),
+			MetadataUrl:     env.get("CNI_METADATA_URL", "http://169.254.169.254"),
+			DmiPath:         env.get("CNI_DMI_PRODUCT_UUID_PATH", "/sys/class/dmi/id/product_uuid"),
+			Timeout:         env.duration("CNI_METADATA_TIMEOUT", "2s"),
+		},
+	}
+	// a lease shorter than the interval expires between runs and lets another daemon reap at the same time
+	if config.ClusterReaping && config.ReapLeaseDuration <= config.ClusterReapInterval {
+		env.err = multierror.Append(env.err, fmt.Errorf("invalid configuration CNI_CLUSTER_REAP_LEASE_DURATION=%s must exceed CNI_CLUSTER_REAP_INTERVAL=%s",
+			config.ReapLeaseDuration, config.ClusterReapInterval))
 	}
+	return config, env.err
 }
 
-func getEnvDuration(name, defVal string) time.Duration {
-	envStr := util.Getenv(name, defVal)
+// envReader parses settings from values or the environment and collects the invalid ones
+type envReader struct {
+	values map[string]string
+	err    error
+}
+
+// get returns the setting from values, the environment or defVal, in that order
+func (me *envReader) get(name, defVal string) string {
+	if value := me.values[name]; value != "" {
+		return value
+	}
+	return util.Getenv(name, defVal)
+}
+
+func (me *envReader) invalid(name, value string, err error) {
+	me.err = multierror.Append(me.err, fmt.Errorf("invalid configuration %s=%s err=%w", name, value, err))
+}
+
+func (me *envReader) duration(name, defVal string) time.Duration {
+	envStr := me.get(name, defVal)
 	duration, err := time.ParseDuration(envStr)
 	if err != nil {
-		panic(fmt.Sprintf("invalid configuration %s=%s err=%s", name, envStr, err))
+		me.invalid(name, envStr, err)
 	}
 	return duration
 }
 
-func getEnvBool(name, defVal string) bool {
-	envStr := util.Getenv(name, defVal)
+func (me *envReader) fileMode(name, defVal string) os.FileMode {
+	envStr := me.get(name, defVal)
+	mode, err := strconv.ParseUint(envStr, 8, 32)
+	if err != nil {
+		me.invalid(name, envStr, err)
+	}
+	return os.FileMode(mode)
+}
+
+func (me *envReader) int(name, defVal string) int {
+	envStr := me.get(name, defVal)
+	i, err := strconv.Atoi(envStr)
+	if err != nil {
+		me.invalid(name, envStr, err)
+	}
+	return i
+}
+
+func (me *envReader) list(name, defVal string) []string {
+	list := []string{}
+	for _, item := range strings.Split(me.get(name, defVal), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func (me *envReader) bool(name, defVal string) bool {
+	envStr := me.get(name, defVal)
 	b, err := strconv.ParseBool(envStr)
 	if err != nil {
-		panic(fmt.Sprintf("invalid configuration %s=%s err=%s", name, envStr, err))
+		me.invalid(name, envStr, err)
 	}
 	return b
 }
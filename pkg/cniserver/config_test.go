@@ -0,0 +1,42 @@
+package cniserver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jboelensns/openstack-cni/pkg/cniserver"
+	. "github.com/pepinns/go-hamcrest"
+)
+
+func Test_LoadConfig(t *testing.T) {
+	t.Run("the cluster reaping lease has to outlast the interval", func(t *testing.T) {
+		t.Setenv("CNI_CLUSTER_REAPING", "true")
+		t.Setenv("CNI_CLUSTER_REAP_INTERVAL", "10m")
+		t.Setenv("CNI_CLUSTER_REAP_LEASE_DURATION", "10m")
+
+		_, err := cniserver.LoadConfig()
+		Assert(t).That(err.Error(), Contains("CNI_CLUSTER_REAP_LEASE_DURATION=10m0s must exceed CNI_CLUSTER_REAP_INTERVAL=10m0s"))
+
+		t.Setenv("CNI_CLUSTER_REAP_LEASE_DURATION", "20m")
+		_, err = cniserver.LoadConfig()
+		Assert(t).That(err, IsNil())
+	})
+
+	t.Run("the lease isn't checked without cluster reaping", func(t *testing.T) {
+		t.Setenv("CNI_CLUSTER_REAPING", "false")
+		t.Setenv("CNI_CLUSTER_REAP_LEASE_DURATION", "1m")
+
+		_, err := cniserver.LoadConfig()
+		Assert(t).That(err, IsNil())
+	})
+
+	t.Run("values take precedence over the environment", func(t *testing.T) {
+		t.Setenv("CNI_MIN_PORT_AGE", "1m")
+		t.Setenv("CNI_REAP_INTERVAL", "2m")
+
+		config, err := cniserver.LoadConfigFrom(map[string]string{"CNI_MIN_PORT_AGE": "3m"})
+		Assert(t).That(err, IsNil())
+		Assert(t).That(config.MinPortAge, Equals(3*time.Minute))
+		Assert(t).That(config.ReapInterval, Equals(2*time.Minute))
+	})
+}
@@ -1,14 +1,19 @@
 package cniserver
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/jboelensns/openstack-cni/pkg/openstack"
 )
 
 // HealthHandler handles all /health related requests
+// Reloader is optional, the rejection of the daemon config file is reported when it's set
 type HealthHandler struct {
 	OsClient openstack.OpenstackClient
+	Reloader *Reloader
 }
 
 // HandleRequest executes health checks and returns the results
@@ -16,9 +21,12 @@ func (me *HealthHandler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 	health := HealthResponse{
 		IsHealthy: true,
 		Checks: []HealthResponseCheck{
-			me.checkOpenstack(),
+			me.checkOpenstack(r.Context()),
 		},
 	}
+	if me.Reloader != nil {
+		health.Checks = append(health.Checks, me.checkConfig())
+	}
 
 	for _, check := range health.Checks {
 		if !check.IsHealthy {
@@ -33,13 +41,13 @@ func (me *HealthHandler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 	w.Write(asJson(health))
 }
 
-func (me HealthHandler) checkOpenstack() HealthResponseCheck {
+func (me HealthHandler) checkOpenstack(ctx context.Context) HealthResponseCheck {
 	resp := HealthResponseCheck{
 		Name:      "openstack",
 		IsHealthy: true,
 		Error:     "",
 	}
-	_, err := me.OsClient.GetServerByName("serverthatdoesntexist")
+	_, err := me.OsClient.GetServerByName(ctx, "serverthatdoesntexist")
 	if err == openstack.ErrServerNotFound {
 		return resp
 	}
@@ -49,12 +57,35 @@ func (me HealthHandler) checkOpenstack() HealthResponseCheck {
 	return resp
 }
 
+func (me HealthHandler) checkConfig() HealthResponseCheck {
+	resp := HealthResponseCheck{
+		Name:      "config",
+		IsHealthy: true,
+	}
+	if err := me.Reloader.Err(); err != nil {
+		resp.IsHealthy = false
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
 // HealthResponse is returned for GET /health
 type HealthResponse struct {
 	IsHealthy bool                  `json:"is_healthy,omitempty"`
 	Checks    []HealthResponseCheck `json:"checks,omitempty"`
 }
 
+// String summarizes the failed checks
+func (me HealthResponse) String() string {
+	failed := make([]string, 0)
+	for _, check := range me.Checks {
+		if !check.IsHealthy {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, check.Error))
+		}
+	}
+	return strings.Join(failed, "; ")
+}
+
 // HealthResponse represents an invididual health check
 type HealthResponseCheck struct {
 	Name      string `json:"name,omitempty"`
@@ -1,12 +1,16 @@
 package cniserver
 
 import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jboelensns/openstack-cni/pkg/util"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type Metrics struct {
-	PortCountFunc func() float64
-	registry      *prometheus.Registry
+	registry *prometheus.Registry
 
 	cniRequestCount        prometheus.Counter
 	cniRequestInvalidCount prometheus.Counter
@@ -16,14 +20,33 @@ type Metrics struct {
 	cniDelFailureCount     prometheus.Counter
 	cniCheckSuccessCount   prometheus.Counter
 	cniCheckFailureCount   prometheus.Counter
+	cniGcSuccessCount      prometheus.Counter
+	cniGcFailureCount      prometheus.Counter
+	cniRequestWaiting      prometheus.Gauge
+	cniRequestWaitSeconds  prometheus.Histogram
+	cniCommandSeconds      *prometheus.HistogramVec
+	openstackWaiting       prometheus.Gauge
+	openstackInFlight      prometheus.Gauge
+	openstackWaitSeconds   *prometheus.HistogramVec
+	openstackRetryCount    *prometheus.CounterVec
+	openstackGiveUpCount   *prometheus.CounterVec
+	openstackSeconds       *prometheus.HistogramVec
+	openstackCacheHits     *prometheus.CounterVec
+	openstackCacheMisses   *prometheus.CounterVec
 	reapSuccessCount       prometheus.Counter
 	reapFailureCount       prometheus.Counter
-	portTotal              prometheus.GaugeFunc
+	portTotal              *prometheus.GaugeVec
+	portSnapshotAge        prometheus.GaugeFunc
+	portsCountedAt         atomic.Int64
+	portCountKeys          map[PortCountKey]bool
+	portCountsMu           sync.Mutex
+	reloadSuccessCount     prometheus.Counter
+	reloadFailureCount     prometheus.Counter
 }
 
-func NewMetrics(registry *prometheus.Registry, portCountFunc func() float64) *Metrics {
+func NewMetrics(registry *prometheus.Registry) *Metrics {
 	// Request
-	metrics := &Metrics{registry: registry, PortCountFunc: portCountFunc}
+	metrics := &Metrics{registry: registry}
 	metrics.cniRequestCount = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "cni_request_count",
@@ -90,6 +113,124 @@ func NewMetrics(registry *prometheus.Registry, portCountFunc func() float64) *Me
 	)
 	metrics.registry.MustRegister(metrics.cniCheckFailureCount)
 
+	// GC
+	metrics.cniGcSuccessCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cni_gc_success_count",
+			Help: "total count of successfully CNI GC commands",
+		},
+	)
+	metrics.registry.MustRegister(metrics.cniGcSuccessCount)
+
+	metrics.cniGcFailureCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cni_gc_failure_count",
+			Help: "total count of failed CNI GC commands",
+		},
+	)
+	metrics.registry.MustRegister(metrics.cniGcFailureCount)
+
+	// Concurrency
+	metrics.cniRequestWaiting = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cni_request_waiting",
+			Help: "number of /cni requests waiting for another command of the same container",
+		},
+	)
+	metrics.registry.MustRegister(metrics.cniRequestWaiting)
+
+	metrics.cniRequestWaitSeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "cni_request_wait_seconds",
+			Help:    "time /cni requests waited for another command of the same container",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+		},
+	)
+	metrics.registry.MustRegister(metrics.cniRequestWaitSeconds)
+
+	metrics.cniCommandSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cni_command_duration_seconds",
+			Help:    "time taken to handle CNI commands",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+		},
+		[]string{"command", "network", "outcome"},
+	)
+	metrics.registry.MustRegister(metrics.cniCommandSeconds)
+
+	// OpenStack
+	metrics.openstackWaiting = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cni_openstack_request_waiting",
+			Help: "number of OpenStack operations waiting for a free slot",
+		},
+	)
+	metrics.registry.MustRegister(metrics.openstackWaiting)
+
+	metrics.openstackInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cni_openstack_request_in_flight",
+			Help: "number of OpenStack operations being processed",
+		},
+	)
+	metrics.registry.MustRegister(metrics.openstackInFlight)
+
+	metrics.openstackWaitSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cni_openstack_request_wait_seconds",
+			Help:    "time OpenStack operations waited for a free slot",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+		},
+		[]string{"operation"},
+	)
+	metrics.registry.MustRegister(metrics.openstackWaitSeconds)
+
+	metrics.openstackRetryCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cni_openstack_retry_count",
+			Help: "total count of retried OpenStack operations",
+		},
+		[]string{"operation"},
+	)
+	metrics.registry.MustRegister(metrics.openstackRetryCount)
+
+	metrics.openstackGiveUpCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cni_openstack_retry_exhausted_count",
+			Help: "total count of OpenStack operations that failed after all retries",
+		},
+		[]string{"operation"},
+	)
+	metrics.registry.MustRegister(metrics.openstackGiveUpCount)
+
+	metrics.openstackSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cni_openstack_request_duration_seconds",
+			Help:    "time taken by OpenStack operations, every retry is observed separately",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+		},
+		[]string{"operation", "outcome"},
+	)
+	metrics.registry.MustRegister(metrics.openstackSeconds)
+
+	metrics.openstackCacheHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cni_openstack_cache_hit_count",
+			Help: "total count of OpenStack operations answered from the cache",
+		},
+		[]string{"operation"},
+	)
+	metrics.registry.MustRegister(metrics.openstackCacheHits)
+
+	metrics.openstackCacheMisses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cni_openstack_cache_miss_count",
+			Help: "total count of cacheable OpenStack operations that weren't in the cache",
+		},
+		[]string{"operation"},
+	)
+	metrics.registry.MustRegister(metrics.openstackCacheMisses)
+
 	// Reaper
 	metrics.reapSuccessCount = prometheus.NewCounter(
 		prometheus.CounterOpts{
@@ -107,18 +248,128 @@ func NewMetrics(registry *prometheus.Registry, portCountFunc func() float64) *Me
 	metrics.registry.MustRegister(metrics.reapFailureCount)
 
 	// Ports
-	metrics.portTotal = prometheus.NewGaugeFunc(
+	metrics.portTotal = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "cni_port_total",
-			Help: "number of active ports",
+			Help: "number of ports assigned to the host when they were last counted",
 		},
-		metrics.PortCountFunc,
+		[]string{"network", "status"},
 	)
 	metrics.registry.MustRegister(metrics.portTotal)
 
+	// the snapshot is as old as the daemon until the ports are counted
+	metrics.portsCountedAt.Store(time.Now().UnixNano())
+	metrics.portSnapshotAge = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "cni_port_snapshot_age_seconds",
+			Help: "seconds since the ports were last counted successfully",
+		},
+		func() float64 {
+			return time.Since(time.Unix(0, metrics.portsCountedAt.Load())).Seconds()
+		},
+	)
+	metrics.registry.MustRegister(metrics.portSnapshotAge)
+
+	// Reload
+	metrics.reloadSuccessCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cni_config_reload_success_count",
+			Help: "total count of successful reloads of the daemon config file",
+		},
+	)
+	metrics.registry.MustRegister(metrics.reloadSuccessCount)
+	metrics.reloadFailureCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cni_config_reload_failure_count",
+			Help: "total count of rejected reloads of the daemon config file",
+		},
+	)
+	metrics.registry.MustRegister(metrics.reloadFailureCount)
+
 	return metrics
 }
 
 func (me *Metrics) Registry() *prometheus.Registry {
 	return me.registry
 }
+
+// OperationWaiting changes the number of OpenStack operations waiting for a free slot by delta
+func (me *Metrics) OperationWaiting(delta int) {
+	me.openstackWaiting.Add(float64(delta))
+}
+
+// OperationInFlight changes the number of OpenStack operations being processed by delta
+func (me *Metrics) OperationInFlight(delta int) {
+	me.openstackInFlight.Add(float64(delta))
+}
+
+// OperationWaited records how long an OpenStack operation waited for a free slot
+func (me *Metrics) OperationWaited(operation string, duration time.Duration) {
+	me.openstackWaitSeconds.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// Retried records a retry of an OpenStack operation
+func (me *Metrics) Retried(operation string) {
+	me.openstackRetryCount.WithLabelValues(operation).Inc()
+}
+
+// Exhausted records an OpenStack operation that failed after all retries
+func (me *Metrics) Exhausted(operation string) {
+	me.openstackGiveUpCount.WithLabelValues(operation).Inc()
+}
+
+// ObserveOperation records the duration and outcome of an OpenStack operation
+func (me *Metrics) ObserveOperation(operation, outcome string, duration time.Duration) {
+	me.openstackSeconds.WithLabelValues(operation, outcome).Observe(duration.Seconds())
+}
+
+// CacheHit records an OpenStack operation answered from the cache
+func (me *Metrics) CacheHit(operation string) {
+	me.openstackCacheHits.WithLabelValues(operation).Inc()
+}
+
+// CacheMiss records a cacheable OpenStack operation that wasn't in the cache
+func (me *Metrics) CacheMiss(operation string) {
+	me.openstackCacheMisses.WithLabelValues(operation).Inc()
+}
+
+// observeCommand records the time taken to handle a CNI command since start
+func (me *Metrics) observeCommand(cmd util.CniCommand, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	me.cniCommandSeconds.WithLabelValues(cmd.Command, commandNetwork(cmd), outcome).Observe(time.Since(start).Seconds())
+}
+
+// commandNetwork returns the OpenStack network of the command's configuration or an empty string
+func commandNetwork(cmd util.CniCommand) string {
+	conf := struct {
+		Network   string `json:"network"`
+		NetworkId string `json:"network_id"`
+	}{}
+	util.FromJson(cmd.StdinData, &conf)
+	if conf.Network == "" {
+		return conf.NetworkId
+	}
+	return conf.Network
+}
+
+// setPortCounts replaces the snapshot of the host's ports
+func (me *Metrics) setPortCounts(counts map[PortCountKey]int) {
+	me.portCountsMu.Lock()
+	defer me.portCountsMu.Unlock()
+	// only the labels that disappeared are deleted so a scrape never sees a partial snapshot
+	keys := make(map[PortCountKey]bool, len(counts))
+	for key, count := range counts {
+		me.portTotal.WithLabelValues(key.Network, key.Status).Set(float64(count))
+		keys[key] = true
+	}
+	for key := range me.portCountKeys {
+		if !keys[key] {
+			me.portTotal.DeleteLabelValues(key.Network, key.Status)
+		}
+	}
+	me.portCountKeys = keys
+	me.portsCountedAt.Store(time.Now().UnixNano())
+}
@@ -1,43 +1,92 @@
 package cniserver
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	. "github.com/jboelensns/openstack-cni/pkg/logging"
 	"github.com/jboelensns/openstack-cni/pkg/openstack"
 	"github.com/jboelensns/openstack-cni/pkg/util"
 )
 
+// PortCounter keeps a snapshot of the openstack-cni ports assigned to the host in the cni_port_total gauge
+// the snapshot is refreshed in the background so scraping /metrics never waits on OpenStack
+// ServerId is the UUID of the local server, when it's empty the server is looked up by hostname
+// OsClient shouldn't cache, otherwise the snapshot is only as fresh as the cache
 type PortCounter struct {
+	Interval time.Duration
+	ServerId string
 	OsClient openstack.OpenstackClient
+	Metrics  *Metrics
+	done     func()
+	mu       sync.Mutex
 }
 
-// Count determines how many openstack-cni ports are currently assigned to the host
-func (me *PortCounter) Count() float64 {
+// Start refreshes the snapshot immediately and then after every interval
+func (me *PortCounter) Start() {
+	if me.done == nil {
+		refresh := func() {
+			if err := me.Refresh(context.Background()); err != nil {
+				Log().Err(err).Msg("error counting ports")
+			}
+		}
+		go refresh()
+		me.done = Repeat(me.Interval, refresh)
+	}
+}
+
+func (me *PortCounter) Stop() {
+	if me.done != nil {
+		me.done()
+	}
+}
+
+// Refresh counts the openstack-cni ports assigned to the host by network and status,
+// the previous snapshot is kept when counting fails
+func (me *PortCounter) Refresh(ctx context.Context) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+
 	hostname, _ := util.GetHostname()
 	Log().Info().Str("hostname", hostname).Msg("counting ports")
-	// lookup the server
-	server, err := me.OsClient.GetServerByName(hostname)
-	if err != nil {
-		Log().Err(err).Str("hostname", hostname).Msg("failed to GetServerByName while counting ports")
-		return 0
+	serverId := me.ServerId
+	if serverId == "" {
+		// lookup the server
+		server, err := me.OsClient.GetServerByName(ctx, hostname)
+		if err != nil {
+			Log().Err(err).Str("hostname", hostname).Msg("failed to GetServerByName while counting ports")
+			return err
+		}
+		serverId = server.ID
 	}
 
 	// list all ports for a host
-	ports, err := me.OsClient.GetPortsByDeviceId(server.ID)
+	ports, err := me.OsClient.GetPortsByDeviceId(ctx, serverId)
 	if err != nil {
-		Log().Err(err).Str("deviceId", server.ID).Msg("failed to GetPortsByDeviceId while counting ports")
-		return 0
+		Log().Err(err).Str("deviceId", serverId).Msg("failed to GetPortsByDeviceId while counting ports")
+		return err
 	}
 
-	var count float64 = 0
+	count := 0
+	counts := map[PortCountKey]int{}
 	for _, port := range ports {
 		if !HasOpenstackCniTag(port.Tags) {
 			continue
 		}
+		counts[PortCountKey{Network: port.NetworkID, Status: port.Status}]++
 		count++
 	}
+	me.Metrics.setPortCounts(counts)
+
+	Log().Info().Str("hostname", hostname).Int("count", count).Msg("found ports")
+	return nil
+}
 
-	Log().Info().Str("hostname", hostname).Float64("count", count).Msg("found ports")
-	return count
+// PortCountKey are the labels ports are counted by
+type PortCountKey struct {
+	Network string
+	Status  string
 }
 
 const OPENSTACK_CNI_TAG = "openstack-cni=true"
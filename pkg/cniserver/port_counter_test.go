@@ -0,0 +1,97 @@
+package cniserver_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/jboelensns/openstack-cni/pkg/cniserver"
+	. "github.com/jboelensns/openstack-cni/pkg/fixtures"
+	"github.com/jboelensns/openstack-cni/pkg/fixtures/mocks"
+	. "github.com/pepinns/go-hamcrest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func Test_PortCounter(t *testing.T) {
+	portList := []ports.Port{
+		{ID: "a", NetworkID: "net1", Status: "ACTIVE", Tags: NeutronTags()},
+		{ID: "b", NetworkID: "net1", Status: "ACTIVE", Tags: NeutronTags()},
+		{ID: "c", NetworkID: "net1", Status: "DOWN", Tags: NeutronTags()},
+		{ID: "d", NetworkID: "net2", Status: "ACTIVE", Tags: NeutronTags()},
+		{ID: "primary", NetworkID: "net1", Status: "ACTIVE"},
+	}
+	newCounter := func() (*mocks.OpenstackClientMock, *cniserver.PortCounter) {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return &servers.Server{ID: "serverId"}, nil
+		}
+		mock.GetPortsByDeviceIdFunc = func(ctx context.Context, deviceId string) ([]ports.Port, error) {
+			return portList, nil
+		}
+		return mock, &cniserver.PortCounter{OsClient: mock, Metrics: Metrics()}
+	}
+	expected := `
+# HELP cni_port_total number of ports assigned to the host when they were last counted
+# TYPE cni_port_total gauge
+cni_port_total{network="net1",status="ACTIVE"} 2
+cni_port_total{network="net1",status="DOWN"} 1
+cni_port_total{network="net2",status="ACTIVE"} 1
+`
+
+	t.Run("counts the host's ports by network and status", func(t *testing.T) {
+		_, counter := newCounter()
+		Assert(t).That(counter.Refresh(context.Background()), IsNil())
+		err := testutil.GatherAndCompare(counter.Metrics.Registry(), strings.NewReader(expected), "cni_port_total")
+		Assert(t).That(err, IsNil())
+	})
+
+	t.Run("keeps the previous snapshot when counting fails", func(t *testing.T) {
+		mock, counter := newCounter()
+		Assert(t).That(counter.Refresh(context.Background()), IsNil())
+		mock.GetPortsByDeviceIdFunc = func(ctx context.Context, deviceId string) ([]ports.Port, error) {
+			return nil, errors.New("BOOM")
+		}
+		Assert(t).That(counter.Refresh(context.Background()), Not(IsNil()))
+		err := testutil.GatherAndCompare(counter.Metrics.Registry(), strings.NewReader(expected), "cni_port_total")
+		Assert(t).That(err, IsNil())
+	})
+
+	t.Run("drops the labels of ports that are gone", func(t *testing.T) {
+		mock, counter := newCounter()
+		Assert(t).That(counter.Refresh(context.Background()), IsNil())
+		mock.GetPortsByDeviceIdFunc = func(ctx context.Context, deviceId string) ([]ports.Port, error) {
+			return portList[:2], nil
+		}
+		Assert(t).That(counter.Refresh(context.Background()), IsNil())
+		err := testutil.GatherAndCompare(counter.Metrics.Registry(), strings.NewReader(`
+# HELP cni_port_total number of ports assigned to the host when they were last counted
+# TYPE cni_port_total gauge
+cni_port_total{network="net1",status="ACTIVE"} 2
+`), "cni_port_total")
+		Assert(t).That(err, IsNil())
+	})
+
+	t.Run("scraping doesn't call OpenStack", func(t *testing.T) {
+		mock, counter := newCounter()
+		count, err := testutil.GatherAndCount(counter.Metrics.Registry(), "cni_port_total", "cni_port_snapshot_age_seconds")
+		Assert(t).That(err, IsNil())
+		Assert(t).That(count, Equals(1))
+		Assert(t).That(mock.GetServerByNameCalls(), HasLen(0))
+		Assert(t).That(mock.GetPortsByDeviceIdCalls(), HasLen(0))
+	})
+}
+
+func Test_PortCounterServerId(t *testing.T) {
+	mock := &mocks.OpenstackClientMock{}
+	mock.GetPortsByDeviceIdFunc = func(ctx context.Context, deviceId string) ([]ports.Port, error) {
+		return nil, nil
+	}
+	counter := &cniserver.PortCounter{ServerId: "serverId", OsClient: mock, Metrics: Metrics()}
+
+	Assert(t).That(counter.Refresh(context.Background()), IsNil())
+	Assert(t).That(mock.GetServerByNameCalls(), HasLen(0))
+	Assert(t).That(mock.GetPortsByDeviceIdCalls()[0].DeviceId, Equals("serverId"))
+}
@@ -1,8 +1,14 @@
 package cniserver
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
@@ -11,105 +17,347 @@ import (
 	"github.com/jboelensns/openstack-cni/pkg/util"
 )
 
+// PortReaper deletes the ports of this host whose pods are gone
+// ServerId is the UUID of the local server, when it's empty the server is looked up by hostname
 type PortReaper struct {
 	Opts     PortReaperOpts
+	ServerId string
 	OsClient openstack.OpenstackClient
 	Metrics  *Metrics
 	done     func()
+	mu       sync.Mutex
+	// schedule guards done, it's separate from mu so stopping doesn't wait for a run to finish
+	schedule sync.Mutex
 }
 
+// PortReaperOpts configures the PortReaper
+// ProcPath is where the host's /proc is mounted, it's used to check whether a port's network namespace still exists
 type PortReaperOpts struct {
 	Interval   time.Duration
 	MinPortAge time.Duration
 	SkipDelete bool
+	ProcPath   string
 }
 
 func (me *PortReaper) Start() {
-	hostname, _ := util.GetHostname()
+	me.schedule.Lock()
+	defer me.schedule.Unlock()
 	if me.done == nil {
-		me.done = Repeat(me.Opts.Interval, func() {
-			if err := me.Reap(hostname); err != nil {
-				Log().Err(err).Str("hostname", hostname).Msg("error reaping ports")
-			}
-		})
+		me.done = me.repeat(me.Opts.Interval)
 	}
 }
 
 func (me *PortReaper) Stop() {
+	me.schedule.Lock()
+	defer me.schedule.Unlock()
 	if me.done != nil {
 		me.done()
+		me.done = nil
+	}
+}
+
+// Update replaces the options once no run is in progress,
+// a started reaper is rescheduled when the interval changed
+func (me *PortReaper) Update(opts PortReaperOpts) {
+	me.mu.Lock()
+	interval := me.Opts.Interval
+	me.Opts = opts
+	me.mu.Unlock()
+
+	me.schedule.Lock()
+	defer me.schedule.Unlock()
+	if me.done != nil && opts.Interval != interval {
+		Log().Info().Str("duration", opts.Interval.String()).Msg("rescheduling port reaper")
+		me.done()
+		me.done = me.repeat(opts.Interval)
 	}
 }
 
-// Reap deletes any ports whose network namespaces no longer exist
-func (me *PortReaper) Reap(hostname string) error {
+func (me *PortReaper) repeat(interval time.Duration) func() {
+	hostname, _ := util.GetHostname()
+	return Repeat(interval, func() {
+		if err := me.Reap(context.Background(), hostname); err != nil {
+			Log().Err(err).Str("hostname", hostname).Msg("error reaping ports")
+		}
+	})
+}
+
+// Reap deletes this host's ports whose network namespaces no longer exist
+func (me *PortReaper) Reap(ctx context.Context, hostname string) error {
+	_, err := me.Run(ctx, hostname)
+	return err
+}
+
+// Run reaps this host's ports and returns the decision made for every port
+// errors reaping a single port are recorded in its decision and don't stop the run
+func (me *PortReaper) Run(ctx context.Context, hostname string) ([]ReapDecision, error) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+
 	log := Log().With().Str("hostname", hostname).Logger()
 	log.Info().Msg("attempting reaping ports")
 
-	// list all openstack cni ports for the host using tags
+	ports, err := me.listPorts(ctx, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	serverId := me.localServerId(ctx, hostname, ports)
+	decisions := make([]ReapDecision, 0, len(ports))
+	for _, port := range ports {
+		if me.Opts.SkipDelete {
+			log.Info().Str("port_id", port.ID).Msg("reaping disabled, skipping port")
+			decision := me.Decide(port, serverId)
+			decision.Reason = fmt.Sprintf("reaping disabled, %s", decision.Reason)
+			decisions = append(decisions, decision)
+			continue
+		}
+		decision, err := me.reapPort(ctx, port, serverId)
+		if err != nil {
+			log.Err(err).Str("port_id", port.ID).Msg("failed to reap port")
+			me.Metrics.reapFailureCount.Inc()
+			decision.Error = err.Error()
+		}
+		decisions = append(decisions, decision)
+	}
+
+	return decisions, nil
+}
+
+// Plan returns the decision ReapPort would make for every one of this host's ports without changing anything
+func (me *PortReaper) Plan(ctx context.Context, hostname string) ([]ReapDecision, error) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+
+	ports, err := me.listPorts(ctx, hostname)
+	if err != nil {
+		return nil, err
+	}
+	serverId := me.localServerId(ctx, hostname, ports)
+	decisions := make([]ReapDecision, 0, len(ports))
+	for _, port := range ports {
+		decisions = append(decisions, me.Decide(port, serverId))
+	}
+	return decisions, nil
+}
+
+// listPorts lists all openstack cni ports for the host using tags
+func (me *PortReaper) listPorts(ctx context.Context, hostname string) ([]ports.Port, error) {
+	log := Log().With().Str("hostname", hostname).Logger()
 	portTags := NewPortKeyTags()
 	log.Info().Str("tags", strings.Join(portTags, ",")).Msg("searching for reapable ports")
-	ports, err := me.OsClient.GetPortsByTags(portTags)
+	ports, err := me.OsClient.GetPortsByTags(ctx, portTags)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if len(ports) > 0 {
 		log.Info().Int("port_count", len(ports)).Msg("found repable ports")
 	} else {
 		log.Info().Msg("did not find reapable ports")
 	}
+	return ports, nil
+}
 
+// localServerId returns the UUID of the local server when one of the ports is attached,
+// an empty id is returned when it can't be looked up, which keeps every attached port
+func (me *PortReaper) localServerId(ctx context.Context, hostname string, ports []ports.Port) string {
+	if me.ServerId != "" {
+		return me.ServerId
+	}
 	for _, port := range ports {
-		if me.Opts.SkipDelete {
-			log.Info().Str("port_id", port.ID).Msg("reaping disabled, skipping port")
+		if port.DeviceID == "" {
 			continue
 		}
-		if err := me.ReapPort(port); err != nil {
-			log.Err(err).Str("port_id", port.ID).Msg("failed to reap port")
-			me.Metrics.reapFailureCount.Inc()
-			continue
+		server, err := me.OsClient.GetServerByName(ctx, hostname)
+		if err != nil {
+			Log().Err(err).Str("hostname", hostname).Msg("failed to look up the local server, keeping attached ports")
+			return ""
 		}
+		return server.ID
 	}
+	return ""
+}
 
-	return nil
+// ReapDecision describes whether a port is reaped and why
+type ReapDecision struct {
+	PortId      string    `json:"port_id"`
+	Status      string    `json:"status"`
+	DeviceId    string    `json:"device_id,omitempty"`
+	ContainerId string    `json:"container_id,omitempty"`
+	Netns       string    `json:"netns,omitempty"`
+	Host        string    `json:"host,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Reap        bool      `json:"reap"`
+	Detach      bool      `json:"detach,omitempty"`
+	Reason      string    `json:"reason"`
+	Error       string    `json:"error,omitempty"`
 }
 
-// Reap deletes any ports whose network namespaces no longer exist
-func (me *PortReaper) ReapPort(port ports.Port) error {
-	log := Log().With().Str("port_id", port.ID).Str("status", port.Status).Str("tags", strings.Join(port.Tags, ",")).Str("created_at", port.CreatedAt.String()).Logger()
-	log.Info().Msg("attempting to reap port")
+// Decide decides whether a port is reaped, serverId is the UUID of the local server
+// ports attached to another server are never reaped since their namespaces aren't on this host,
+// ports whose network namespace no longer exists are reaped, detaching them first when they're still attached
+// ports without a namespace or whose namespace can't be checked are only reaped when they're DOWN and detached
+func (me *PortReaper) Decide(port ports.Port, serverId string) ReapDecision {
+	decision := ReapDecision{
+		PortId:      port.ID,
+		Status:      port.Status,
+		DeviceId:    port.DeviceID,
+		ContainerId: GetTagValue(port.Tags, "containerid"),
+		Netns:       GetTagValue(port.Tags, "netns"),
+		CreatedAt:   port.CreatedAt,
+	}
 
 	// skip ports that aren't tagged with our special identifying tag
 	if !HasOpenstackCniTag(port.Tags) {
-		log.Info().Msg("skipping port delete.. missing openstack-cni=true tag")
-		return nil
+		decision.Reason = "missing openstack-cni=true tag"
+		return decision
 	}
 	// skip ports that were created recently
 	diff := time.Now().Sub(port.CreatedAt)
 	if diff <= me.Opts.MinPortAge {
-		log.Info().Msg(fmt.Sprintf("skipping port delete.. port is too new %s > %s", diff, me.Opts.MinPortAge))
-		return nil
+		decision.Reason = fmt.Sprintf("too new %s <= %s", diff.Round(time.Second), me.Opts.MinPortAge)
+		return decision
+	}
+	// skip ports of servers sharing our hostname
+	if port.DeviceID != "" && port.DeviceID != serverId {
+		decision.Reason = fmt.Sprintf("attached to %s which isn't the local server", port.DeviceID)
+		return decision
+	}
+
+	gone, err := me.netnsGone(decision.Netns)
+	if gone {
+		decision.Reap = true
+		decision.Detach = port.DeviceID != ""
+		decision.Reason = "netns missing"
+		return decision
+	}
+	unchecked := ""
+	if err != nil {
+		unchecked = fmt.Sprintf(", unable to check netns: %s", err)
 	}
 
 	// only delete DOWN ports
 	if port.Status != "DOWN" {
-		log.Info().Msg("skipping port delete.. port status is not DOWN")
-		return nil
+		decision.Reason = "not DOWN" + unchecked
+		return decision
 	}
-
 	// only delete detached ports
 	if port.DeviceID != "" {
-		log.Info().Str("device_id", port.DeviceID).Msg("skipping port delete.. still attached")
-		return nil
+		decision.Reason = "still attached" + unchecked
+		return decision
 	}
 
-	log.Info().Str("port_id", port.ID).Msg("attempting to reap port")
-	if err := me.OsClient.DeletePort(port.ID); err != nil {
-		return err
+	decision.Reap = true
+	decision.Reason = "DOWN and detached" + unchecked
+	return decision
+}
+
+// ReapPort deletes a port when Decide decides to reap it
+func (me *PortReaper) ReapPort(ctx context.Context, port ports.Port) error {
+	hostname, _ := util.GetHostname()
+	_, err := me.reapPort(ctx, port, me.localServerId(ctx, hostname, []ports.Port{port}))
+	return err
+}
+
+func (me *PortReaper) reapPort(ctx context.Context, port ports.Port, serverId string) (ReapDecision, error) {
+	decision := me.Decide(port, serverId)
+	log := Log().With().Str("port_id", port.ID).Str("status", port.Status).Str("tags", strings.Join(port.Tags, ",")).Str("created_at", port.CreatedAt.String()).Str("reason", decision.Reason).Logger()
+
+	if !decision.Reap {
+		log.Info().Msg("skipping port delete")
+		return decision, nil
+	}
+
+	if decision.Detach {
+		log.Info().Str("device_id", port.DeviceID).Msg("detaching port")
+		if err := me.OsClient.DetachPort(ctx, port.ID, port.DeviceID); err != nil {
+			return decision, err
+		}
 	}
-	log.Info().Str("port_id", port.ID).Msg("successfully reaped port")
+
+	log.Info().Msg("attempting to reap port")
+	if err := me.OsClient.DeletePort(ctx, port.ID); err != nil {
+		return decision, err
+	}
+	log.Info().Msg("successfully reaped port")
 	me.Metrics.reapSuccessCount.Inc()
-	return nil
+	return decision, nil
+}
+
+// netnsGone returns true when the network namespace at path certainly doesn't exist on the host
+// namespaces are looked up through the host's /proc: /proc/<pid>/ns/net paths directly,
+// pinned namespaces like /var/run/netns/<name> through the root of the host's init process
+func (me *PortReaper) netnsGone(path string) (bool, error) {
+	if path == "" || me.Opts.ProcPath == "" {
+		return false, nil
+	}
+	if _, err := os.Stat(me.Opts.ProcPath); err != nil {
+		return false, fmt.Errorf("host proc isn't available at %s err=%w", me.Opts.ProcPath, err)
+	}
+
+	var err error
+	if rest, ok := strings.CutPrefix(path, "/proc/"); ok {
+		_, err = os.Stat(filepath.Join(me.Opts.ProcPath, rest))
+	} else {
+		_, err = resolveInRoot(filepath.Join(me.Opts.ProcPath, "1", "root"), path)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	return false, err
+}
+
+// maxSymlinks bounds the symlinks followed by resolveInRoot, it matches the kernel's limit
+const maxSymlinks = 40
+
+// resolveInRoot resolves path as if root was /, one component at a time,
+// absolute symlinks like /var/run -> /run are followed inside root instead of the daemon's filesystem
+func resolveInRoot(root, path string) (string, error) {
+	current := "/"
+	parts := strings.Split(path, "/")
+	for links := 0; len(parts) > 0; {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+			continue
+		}
+
+		next := filepath.Join(current, part)
+		info, err := os.Lstat(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("too many symlinks resolving %s", path)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			current = "/"
+		}
+		parts = append(strings.Split(target, "/"), parts...)
+	}
+	return filepath.Join(root, current), nil
+}
+
+// GetTagValue returns the value of the first key=value tag with key or an empty string
+func GetTagValue(tags []string, key string) string {
+	for _, tag := range tags {
+		if value, ok := strings.CutPrefix(tag, key+"="); ok {
+			return value
+		}
+	}
+	return ""
 }
 
 // Repeat executes the fn function after each duration
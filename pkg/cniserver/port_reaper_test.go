@@ -1,7 +1,9 @@
 package cniserver_test
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -22,16 +24,16 @@ func Test_PortReaper(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			WithPortReaper(t, client, func(reaper *cniserver.PortReaper) {
 				serverId := "myId"
-				mock.GetServerByNameFunc = func(name string) (*servers.Server, error) {
+				mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
 					return &servers.Server{ID: serverId}, nil
 				}
-				mock.DeletePortFunc = func(portId string) error { return nil }
-				mock.GetPortsByTagsFunc = func(tags []string) ([]ports.Port, error) {
+				mock.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+				mock.GetPortsByTagsFunc = func(ctx context.Context, tags []string) ([]ports.Port, error) {
 					return []ports.Port{{Status: "DOWN", Tags: NeutronTags()}}, nil
 				}
 
 				WithTempDir(t, func(dir string) {
-					err = reaper.Reap(hostname)
+					err = reaper.Reap(context.Background(), hostname)
 					Assert(t).That(err, IsNil())
 					Assert(t).That(len(mock.DeletePortCalls()), Equals(1))
 				})
@@ -43,8 +45,8 @@ func Test_PortReaper(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			WithPortReaper(t, client, func(reaper *cniserver.PortReaper) {
 				port := ports.Port{CreatedAt: time.Now()}
-				mock.DeletePortFunc = func(portId string) error { return nil }
-				err = reaper.ReapPort(port)
+				mock.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+				err = reaper.ReapPort(context.Background(), port)
 				Assert(t).That(err, IsNil())
 				Assert(t).That(len(mock.DeletePortCalls()), Equals(0))
 			})
@@ -55,8 +57,8 @@ func Test_PortReaper(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			WithPortReaper(t, client, func(reaper *cniserver.PortReaper) {
 				port := ports.Port{CreatedAt: time.Now()}
-				mock.DeletePortFunc = func(portId string) error { return nil }
-				err = reaper.ReapPort(port)
+				mock.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+				err = reaper.ReapPort(context.Background(), port)
 				Assert(t).That(err, IsNil())
 				Assert(t).That(len(mock.DeletePortCalls()), Equals(0))
 			})
@@ -66,11 +68,11 @@ func Test_PortReaper(t *testing.T) {
 	t.Run("will not reap a port that is not DOWN", func(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			WithPortReaper(t, client, func(reaper *cniserver.PortReaper) {
-				mock.GetPortsByTagsFunc = func(tags []string) ([]ports.Port, error) {
+				mock.GetPortsByTagsFunc = func(ctx context.Context, tags []string) ([]ports.Port, error) {
 					return []ports.Port{{Status: "ACTIVE", Tags: NeutronTags()}}, nil
 				}
 				WithTempDir(t, func(dir string) {
-					err = reaper.Reap(hostname)
+					err = reaper.Reap(context.Background(), hostname)
 					Assert(t).That(err, IsNil())
 					Assert(t).That(len(mock.DeletePortCalls()), Equals(0))
 				})
@@ -81,11 +83,14 @@ func Test_PortReaper(t *testing.T) {
 	t.Run("will not reap an attached port", func(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			WithPortReaper(t, client, func(reaper *cniserver.PortReaper) {
-				mock.GetPortsByTagsFunc = func(tags []string) ([]ports.Port, error) {
+				mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+					return &servers.Server{ID: "SOMEID"}, nil
+				}
+				mock.GetPortsByTagsFunc = func(ctx context.Context, tags []string) ([]ports.Port, error) {
 					return []ports.Port{{DeviceID: "SOMEID", Status: "DOWN", Tags: NeutronTags()}}, nil
 				}
 				WithTempDir(t, func(dir string) {
-					err = reaper.Reap(hostname)
+					err = reaper.Reap(context.Background(), hostname)
 					Assert(t).That(err, IsNil())
 					Assert(t).That(len(mock.DeletePortCalls()), Equals(0))
 				})
@@ -97,8 +102,8 @@ func Test_PortReaper(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			WithPortReaper(t, client, func(reaper *cniserver.PortReaper) {
 				port := ports.Port{Status: "DOWN", Tags: NeutronTags(), CreatedAt: time.Now().Add(-(time.Second * 6000))}
-				mock.DeletePortFunc = func(portId string) error { return nil }
-				err = reaper.ReapPort(port)
+				mock.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+				err = reaper.ReapPort(context.Background(), port)
 				Assert(t).That(err, IsNil())
 				Assert(t).That(len(mock.DeletePortCalls()), Equals(1))
 			})
@@ -106,32 +111,178 @@ func Test_PortReaper(t *testing.T) {
 	})
 }
 
+func Test_PortReaperNetns(t *testing.T) {
+	newPort := func(netns string) ports.Port {
+		return ports.Port{
+			ID:        "portId",
+			Status:    "ACTIVE",
+			DeviceID:  "serverId",
+			Tags:      []string{"openstack-cni=true", "containerid=abc", "netns=" + netns},
+			CreatedAt: time.Now().Add(-time.Hour),
+		}
+	}
+	withReaper := func(t *testing.T, callback func(mock *mocks.OpenstackClientMock, reaper *cniserver.PortReaper, procPath string)) {
+		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
+			WithPortReaper(t, client, func(reaper *cniserver.PortReaper) {
+				WithTempDir(t, func(dir string) {
+					mock.DetachPortFunc = func(ctx context.Context, portId, serverId string) error { return nil }
+					mock.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+					reaper.Opts.ProcPath = dir
+					reaper.ServerId = "serverId"
+					callback(mock, reaper, dir)
+				})
+			})
+		})
+	}
+	createNetns := func(t *testing.T, path string) {
+		Assert(t).That(os.MkdirAll(filepath.Dir(path), 0755), IsNil())
+		Assert(t).That(os.WriteFile(path, nil, 0644), IsNil())
+	}
+
+	t.Run("detaches and deletes an attached port whose pid namespace is gone", func(t *testing.T) {
+		withReaper(t, func(mock *mocks.OpenstackClientMock, reaper *cniserver.PortReaper, procPath string) {
+			Assert(t).That(reaper.ReapPort(context.Background(), newPort("/proc/1234/ns/net")), IsNil())
+			Assert(t).That(mock.DetachPortCalls(), HasLen(1))
+			Assert(t).That(mock.DetachPortCalls()[0].ServerId, Equals("serverId"))
+			Assert(t).That(mock.DeletePortCalls(), HasLen(1))
+		})
+	})
+
+	t.Run("plans to detach and delete a port whose namespace is gone", func(t *testing.T) {
+		withReaper(t, func(mock *mocks.OpenstackClientMock, reaper *cniserver.PortReaper, procPath string) {
+			decision := reaper.Decide(newPort("/proc/1234/ns/net"), "serverId")
+			Assert(t).That(decision.Reap, IsTrue())
+			Assert(t).That(decision.Detach, IsTrue())
+			Assert(t).That(decision.Reason, Equals("netns missing"))
+			Assert(t).That(decision.ContainerId, Equals("abc"))
+		})
+	})
+
+	t.Run("deletes a port whose pinned namespace is gone", func(t *testing.T) {
+		withReaper(t, func(mock *mocks.OpenstackClientMock, reaper *cniserver.PortReaper, procPath string) {
+			createNetns(t, filepath.Join(procPath, "1/root/var/run/netns/other"))
+			Assert(t).That(reaper.ReapPort(context.Background(), newPort("/var/run/netns/cni-1234")), IsNil())
+			Assert(t).That(mock.DeletePortCalls(), HasLen(1))
+		})
+	})
+
+	t.Run("keeps a port whose namespace exists", func(t *testing.T) {
+		withReaper(t, func(mock *mocks.OpenstackClientMock, reaper *cniserver.PortReaper, procPath string) {
+			createNetns(t, filepath.Join(procPath, "1234/ns/net"))
+			createNetns(t, filepath.Join(procPath, "1/root/var/run/netns/cni-1234"))
+			Assert(t).That(reaper.ReapPort(context.Background(), newPort("/proc/1234/ns/net")), IsNil())
+			Assert(t).That(reaper.ReapPort(context.Background(), newPort("/var/run/netns/cni-1234")), IsNil())
+			Assert(t).That(mock.DetachPortCalls(), HasLen(0))
+			Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+		})
+	})
+
+	t.Run("keeps a port whose pinned namespace is reached through an absolute symlink", func(t *testing.T) {
+		withReaper(t, func(mock *mocks.OpenstackClientMock, reaper *cniserver.PortReaper, procPath string) {
+			createNetns(t, filepath.Join(procPath, "1/root/run/netns/cni-1234"))
+			Assert(t).That(os.MkdirAll(filepath.Join(procPath, "1/root/var"), 0755), IsNil())
+			Assert(t).That(os.Symlink("/run", filepath.Join(procPath, "1/root/var/run")), IsNil())
+
+			decision := reaper.Decide(newPort("/var/run/netns/cni-1234"), "serverId")
+			Assert(t).That(decision.Reap, IsFalse())
+			Assert(t).That(reaper.ReapPort(context.Background(), newPort("/var/run/netns/cni-1234")), IsNil())
+			Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+
+			decision = reaper.Decide(newPort("/var/run/netns/cni-5678"), "serverId")
+			Assert(t).That(decision.Reap, IsTrue())
+		})
+	})
+
+	t.Run("keeps a port attached to another server", func(t *testing.T) {
+		withReaper(t, func(mock *mocks.OpenstackClientMock, reaper *cniserver.PortReaper, procPath string) {
+			reaper.ServerId = "otherServerId"
+			decision := reaper.Decide(newPort("/proc/1234/ns/net"), reaper.ServerId)
+			Assert(t).That(decision.Reap, IsFalse())
+			Assert(t).That(decision.Reason, Contains("isn't the local server"))
+			Assert(t).That(reaper.ReapPort(context.Background(), newPort("/proc/1234/ns/net")), IsNil())
+			Assert(t).That(mock.DetachPortCalls(), HasLen(0))
+			Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+		})
+	})
+
+	t.Run("keeps attached ports when the local server can't be looked up", func(t *testing.T) {
+		withReaper(t, func(mock *mocks.OpenstackClientMock, reaper *cniserver.PortReaper, procPath string) {
+			reaper.ServerId = ""
+			mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+				return nil, openstack.ErrServerNotFound
+			}
+			mock.GetPortsByTagsFunc = func(ctx context.Context, tags []string) ([]ports.Port, error) {
+				return []ports.Port{newPort("/proc/1234/ns/net")}, nil
+			}
+			Assert(t).That(reaper.Reap(context.Background(), "myhost"), IsNil())
+			Assert(t).That(mock.DetachPortCalls(), HasLen(0))
+			Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+		})
+	})
+
+	t.Run("keeps a new port whose namespace is gone", func(t *testing.T) {
+		withReaper(t, func(mock *mocks.OpenstackClientMock, reaper *cniserver.PortReaper, procPath string) {
+			port := newPort("/proc/1234/ns/net")
+			port.CreatedAt = time.Now()
+			Assert(t).That(reaper.ReapPort(context.Background(), port), IsNil())
+			Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+		})
+	})
+
+	t.Run("keeps an attached port when the host's proc isn't mounted", func(t *testing.T) {
+		withReaper(t, func(mock *mocks.OpenstackClientMock, reaper *cniserver.PortReaper, procPath string) {
+			reaper.Opts.ProcPath = filepath.Join(procPath, "missing")
+			Assert(t).That(reaper.ReapPort(context.Background(), newPort("/proc/1234/ns/net")), IsNil())
+			Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+		})
+	})
+
+	t.Run("doesn't delete ports when reaping is disabled", func(t *testing.T) {
+		withReaper(t, func(mock *mocks.OpenstackClientMock, reaper *cniserver.PortReaper, procPath string) {
+			reaper.Opts.SkipDelete = true
+			mock.GetPortsByTagsFunc = func(ctx context.Context, tags []string) ([]ports.Port, error) {
+				return []ports.Port{newPort("/proc/1234/ns/net")}, nil
+			}
+			Assert(t).That(reaper.Reap(context.Background(), "myhost"), IsNil())
+			Assert(t).That(mock.DetachPortCalls(), HasLen(0))
+			Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+		})
+	})
+}
+
+func Test_GetTagValue(t *testing.T) {
+	tags := []string{"openstack-cni=true", "netns=/proc/1234/ns/net", "containerid=abc"}
+	Assert(t).That(cniserver.GetTagValue(tags, "netns"), Equals("/proc/1234/ns/net"))
+	Assert(t).That(cniserver.GetTagValue(tags, "containerid"), Equals("abc"))
+	Assert(t).That(cniserver.GetTagValue(tags, "ifname"), Equals(""))
+}
+
 func Test_PortReaperIntegration(t *testing.T) {
 	t.Run("port reaper attempts to delete ports", func(t *testing.T) {
 		WithTestConfig(t, func(cfg TestingConfig) {
 			WithOpenstackClient(t, func(client openstack.OpenstackClient) {
 				// create a port with a network namespace that doesn't exist for my machine
 				cmd := NewTestData().CniCommand()
-				context := CniContextFromConfig(t, cfg, cmd)
+				cniContext := CniContextFromConfig(t, cfg, cmd)
 				cachedClient := openstack.NewCachedClient(client, time.Second*5)
 
 				WithPortReaperWithNoMinPortAge(t, cachedClient, func(reaper *cniserver.PortReaper) {
 					pm := openstack.NewPortManager(cachedClient)
-					opts := openstack.SetupPortOptsFromContext(context)
-					opts.Tags = cniserver.NewPortTags(context.Command)
+					opts := openstack.SetupPortOptsFromContext(cniContext)
+					opts.Tags = cniserver.NewPortTags(cniContext.Command)
 					opts.SkipPortAttach = true
 
-					_, err := pm.SetupPort(opts)
+					_, err := pm.SetupPort(context.Background(), opts)
 					Assert(t).That(err, IsNil(), "failed to setup port")
 
-					_, err = cachedClient.GetPortByTags(opts.Tags.AsStringSlice())
+					_, err = cachedClient.GetPortByTags(context.Background(), opts.Tags.AsStringSlice())
 					Assert(t).That(err, IsNil(), "failed get port by tags %s", opts.Tags.String())
 
 					// run the reaper
-					Assert(t).That(reaper.Reap(cfg.Hostname), IsNil())
+					Assert(t).That(reaper.Reap(context.Background(), cfg.Hostname), IsNil())
 
 					// ensure the port is deleted
-					_, err = client.GetPortByTags(opts.Tags.AsStringSlice())
+					_, err = client.GetPortByTags(context.Background(), opts.Tags.AsStringSlice())
 					Assert(t).That(err, Equals(openstack.ErrPortNotFound))
 				})
 			})
@@ -143,34 +294,34 @@ func Test_PortReaperIntegration(t *testing.T) {
 			WithOpenstackClient(t, func(client openstack.OpenstackClient) {
 				// create a port with a network namespace that doesn't exist for my machine
 				cmd := NewTestData().CniCommand()
-				context := CniContextFromConfig(t, cfg, cmd)
+				cniContext := CniContextFromConfig(t, cfg, cmd)
 				cachedClient := openstack.NewCachedClient(client, time.Second*5)
 
 				WithPortReaperWithNoMinPortAge(t, cachedClient, func(reaper *cniserver.PortReaper) {
 					pm := openstack.NewPortManager(cachedClient)
-					opts := openstack.SetupPortOptsFromContext(context)
-					opts.Tags = cniserver.NewPortTags(context.Command)
+					opts := openstack.SetupPortOptsFromContext(cniContext)
+					opts.Tags = cniserver.NewPortTags(cniContext.Command)
 					reaper.Opts.SkipDelete = true
 
-					setupResult, err := pm.SetupPort(opts)
+					setupResult, err := pm.SetupPort(context.Background(), opts)
 					Assert(t).That(err, IsNil(), "failed to setup port")
 
-					p1, err := cachedClient.GetPortByTags(opts.Tags.AsStringSlice())
+					p1, err := cachedClient.GetPortByTags(context.Background(), opts.Tags.AsStringSlice())
 					Assert(t).That(err, IsNil(), "failed get port by tags %s", opts.Tags.String())
 
 					// run the reaper
-					Assert(t).That(reaper.Reap(cfg.Hostname), IsNil())
+					Assert(t).That(reaper.Reap(context.Background(), cfg.Hostname), IsNil())
 
 					// ensure the port is still present
-					p2, err := client.GetPortByTags(opts.Tags.AsStringSlice())
+					p2, err := client.GetPortByTags(context.Background(), opts.Tags.AsStringSlice())
 					Assert(t).That(err, IsNil())
 					Assert(t).That(p1.ID, Equals(p2.ID))
 
 					// detach the port so it will be reaped
-					cachedClient.DetachPort(setupResult.Port.ID, setupResult.Server.ID)
+					cachedClient.DetachPort(context.Background(), setupResult.Port.ID, setupResult.Server.ID)
 					// wait for the port to be detached and down
 					for {
-						p, err := client.GetPortByTags(opts.Tags.AsStringSlice())
+						p, err := client.GetPortByTags(context.Background(), opts.Tags.AsStringSlice())
 						Assert(t).That(err, Equals(nil))
 						if p.DeviceID != "" || p.Status != "DOWN" {
 							time.Sleep(1 * time.Second)
@@ -181,10 +332,10 @@ func Test_PortReaperIntegration(t *testing.T) {
 
 					// now reap it
 					reaper.Opts.SkipDelete = false
-					Assert(t).That(reaper.Reap(cfg.Hostname), IsNil())
+					Assert(t).That(reaper.Reap(context.Background(), cfg.Hostname), IsNil())
 
 					// ensure it's gone
-					p, err := client.GetPortByTags(opts.Tags.AsStringSlice())
+					p, err := client.GetPortByTags(context.Background(), opts.Tags.AsStringSlice())
 					Assert(t).That(p, Equals(nil))
 					Assert(t).That(err, Equals(openstack.ErrPortNotFound))
 				})
@@ -0,0 +1,39 @@
+package cniserver
+
+import (
+	"net/http"
+
+	. "github.com/jboelensns/openstack-cni/pkg/logging"
+	"github.com/jboelensns/openstack-cni/pkg/util"
+)
+
+// ReaperHandler handles all /reaper related requests
+type ReaperHandler struct {
+	Reaper *PortReaper
+}
+
+// HandlePlan returns the decision the reaper would make for every port of this host
+func (me *ReaperHandler) HandlePlan(w http.ResponseWriter, r *http.Request) {
+	hostname, _ := util.GetHostname()
+	decisions, err := me.Reaper.Plan(r.Context(), hostname)
+	if err != nil {
+		Log().Err(err).Msg("failed to plan reaping ports")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(asJson(decisions))
+}
+
+// HandleRun reaps this host's ports immediately and returns the decisions that were carried out
+func (me *ReaperHandler) HandleRun(w http.ResponseWriter, r *http.Request) {
+	hostname, _ := util.GetHostname()
+	decisions, err := me.Reaper.Run(r.Context(), hostname)
+	if err != nil {
+		Log().Err(err).Msg("failed to reap ports")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(asJson(decisions))
+}
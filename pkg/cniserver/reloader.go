@@ -0,0 +1,278 @@
+package cniserver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	. "github.com/jboelensns/openstack-cni/pkg/logging"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
+	"gopkg.in/yaml.v2"
+)
+
+// Reloadable authenticates with new OpenStack credentials
+type Reloadable interface {
+	Reload(cloudOpts openstack.CloudOpts) error
+}
+
+// Reloader applies the daemon config file whenever it or the clouds.yaml, secure.yaml or CA bundle it uses change
+// the file maps environment variables to values that override the environment,
+// the OpenStack credentials and the options of the reapers are reloaded from it.
+// A file that fails to load is rejected as a whole, the previous settings are kept and /health reports the failure
+type Reloader struct {
+	File          string
+	Delay         time.Duration
+	OsClient      Reloadable
+	Cache         *openstack.CachedClient
+	PortReaper    *PortReaper
+	ClusterReaper *ClusterReaper
+	Metrics       *Metrics
+	mu            sync.Mutex
+	// config and cloudOpts are what the last valid file resolved to, fingerprint identifies the credentials
+	config      Config
+	cloudOpts   openstack.CloudOpts
+	fingerprint string
+	err         error
+	watcher     *fsnotify.Watcher
+	// watched are the files whose changes trigger a reload, dirs the directories watched for them
+	watched map[string]bool
+	dirs    map[string]bool
+}
+
+// NewReloader creates a Reloader for file, nil is returned when file is empty
+func NewReloader(file string) *Reloader {
+	if file == "" {
+		return nil
+	}
+	return &Reloader{
+		File:    file,
+		Delay:   time.Second,
+		watched: map[string]bool{},
+		dirs:    map[string]bool{},
+	}
+}
+
+// Load reads the file, it's used at startup before the dependencies are built
+func (me *Reloader) Load() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+
+	config, cloudOpts, fingerprint, err := me.load()
+	me.err = err
+	if err == nil {
+		me.config, me.cloudOpts, me.fingerprint = config, cloudOpts, fingerprint
+	}
+	return err
+}
+
+// Config returns the Config the file resolved to when it was last loaded successfully
+func (me *Reloader) Config() Config {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.config
+}
+
+// CloudOpts returns the OpenStack credentials the file resolved to when it was last loaded successfully
+func (me *Reloader) CloudOpts() openstack.CloudOpts {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.cloudOpts
+}
+
+// Reload applies the file and reloads the OpenStack credentials when they changed
+func (me *Reloader) Reload() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+
+	me.err = me.reload()
+	log := Log().With().Str("file", me.File).Logger()
+	if me.err != nil {
+		log.Err(me.err).Msg("rejected the daemon config file, keeping the previous settings")
+		if me.Metrics != nil {
+			me.Metrics.reloadFailureCount.Inc()
+		}
+		return me.err
+	}
+	log.Info().Msg("reloaded the daemon config file")
+	if me.Metrics != nil {
+		me.Metrics.reloadSuccessCount.Inc()
+	}
+	return nil
+}
+
+// Err returns why the last load failed, or nil when it succeeded
+func (me *Reloader) Err() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.err
+}
+
+func (me *Reloader) reload() error {
+	config, cloudOpts, fingerprint, err := me.load()
+	if err != nil {
+		return err
+	}
+
+	if me.OsClient != nil && fingerprint != me.fingerprint {
+		if err := me.OsClient.Reload(cloudOpts); err != nil {
+			return fmt.Errorf("failed to reload openstack credentials err=%w", err)
+		}
+		// another cloud or project doesn't have the same resources
+		if me.Cache != nil {
+			me.Cache.Flush()
+		}
+	}
+	me.config, me.cloudOpts, me.fingerprint = config, cloudOpts, fingerprint
+
+	if me.PortReaper != nil {
+		me.PortReaper.Update(PortReaperOpts{
+			Interval:   config.ReapInterval,
+			MinPortAge: config.MinPortAge,
+			SkipDelete: config.SkipReaping,
+			ProcPath:   config.ProcPath,
+		})
+	}
+	if me.ClusterReaper != nil {
+		me.ClusterReaper.Update(ClusterReaperOpts{
+			MinPortAge: config.MinPortAge,
+			SkipDelete: config.SkipReaping,
+		})
+	}
+	if me.watcher != nil {
+		me.watch(cloudOpts)
+	}
+	return nil
+}
+
+// load reads the file and resolves the Config and the OpenStack credentials from it, the environment fills in the rest
+func (me *Reloader) load() (Config, openstack.CloudOpts, string, error) {
+	values, err := readConfigFile(me.File)
+	if err != nil {
+		return Config{}, openstack.CloudOpts{}, "", err
+	}
+
+	config, err := LoadConfigFrom(values)
+	if err == nil && config.ReapInterval <= 0 {
+		err = fmt.Errorf("invalid configuration CNI_REAP_INTERVAL=%s must be positive", config.ReapInterval)
+	}
+	if err != nil {
+		return Config{}, openstack.CloudOpts{}, "", err
+	}
+
+	cloudOpts, err := openstack.CloudOptsFrom(func(key string) string {
+		if value := values[key]; value != "" {
+			return value
+		}
+		return os.Getenv(key)
+	})
+	if err != nil {
+		return Config{}, openstack.CloudOpts{}, "", err
+	}
+	fingerprint, err := cloudOpts.Fingerprint()
+	if err != nil {
+		return Config{}, openstack.CloudOpts{}, "", err
+	}
+	return config, cloudOpts, fingerprint, nil
+}
+
+// Start watches the directories of the files since kubernetes updates mounted files by swapping a symlink next to them
+func (me *Reloader) Start() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.watcher = watcher
+	if err := me.watch(me.cloudOpts); err != nil {
+		watcher.Close()
+		me.watcher = nil
+		return err
+	}
+	go me.handleEvents(watcher)
+	return nil
+}
+
+func (me *Reloader) Stop() {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.watcher != nil {
+		me.watcher.Close()
+	}
+}
+
+// watch adds the file and the files of cloudOpts to the watched files, only the file is required to be watchable
+func (me *Reloader) watch(cloudOpts openstack.CloudOpts) error {
+	for i, file := range append([]string{me.File}, cloudOpts.WatchedFiles()...) {
+		file = filepath.Clean(file)
+		dir := filepath.Dir(file)
+		if !me.dirs[dir] {
+			if err := me.watcher.Add(dir); err != nil {
+				if i == 0 {
+					return fmt.Errorf("failed to watch %s err=%w", file, err)
+				}
+				Log().Err(err).Str("file", file).Msg("failed to watch a file of the openstack credentials")
+				continue
+			}
+			me.dirs[dir] = true
+		}
+		me.watched[file] = true
+	}
+	return nil
+}
+
+// watches returns whether a change to file triggers a reload
+func (me *Reloader) watches(file string) bool {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.watched[filepath.Clean(file)] || (filepath.Base(file) == "..data" && me.dirs[filepath.Dir(file)])
+}
+
+// handleEvents reloads the file once the watched files stopped changing for Delay
+func (me *Reloader) handleEvents(watcher *fsnotify.Watcher) {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod || !me.watches(event.Name) {
+				continue
+			}
+			if timer == nil {
+				timer = time.AfterFunc(me.Delay, func() { me.Reload() })
+			} else {
+				timer.Reset(me.Delay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			Log().Err(err).Str("file", me.File).Msg("error watching the daemon config file")
+		}
+	}
+}
+
+// readConfigFile reads the environment variables set by the daemon config file
+func readConfigFile(file string) (map[string]string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read daemon config file %s err=%w", file, err)
+	}
+	values := map[string]string{}
+	if err := yaml.UnmarshalStrict(b, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse daemon config file %s err=%w", file, err)
+	}
+	return values, nil
+}
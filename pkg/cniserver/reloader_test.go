@@ -0,0 +1,203 @@
+package cniserver_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/jboelensns/openstack-cni/pkg/cniserver"
+	. "github.com/jboelensns/openstack-cni/pkg/fixtures"
+	"github.com/jboelensns/openstack-cni/pkg/fixtures/mocks"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
+	. "github.com/pepinns/go-hamcrest"
+)
+
+type fakeReloadable struct {
+	err       error
+	reloads   int
+	cloudOpts openstack.CloudOpts
+}
+
+func (me *fakeReloadable) Reload(cloudOpts openstack.CloudOpts) error {
+	me.reloads++
+	if me.err != nil {
+		return me.err
+	}
+	me.cloudOpts = cloudOpts
+	return nil
+}
+
+func Test_Reloader(t *testing.T) {
+	newReloader := func(t *testing.T, content string) (*cniserver.Reloader, *fakeReloadable) {
+		for _, key := range []string{"CNI_REAP_INTERVAL", "CNI_MIN_PORT_AGE", "CNI_SKIP_REAPING", "OS_CLOUD", "OS_PASSWORD", "OS_CACERT"} {
+			t.Setenv(key, "")
+		}
+		t.Setenv("OS_AUTH_URL", "https://keystone.example.com:5000/v3")
+		t.Setenv("OS_USERNAME", "myuser")
+		t.Setenv("OS_REGION_NAME", "RegionOne")
+		reloader := cniserver.NewReloader(filepath.Join(t.TempDir(), "daemon.yaml"))
+		writeFile(t, reloader.File, content)
+		client := &fakeReloadable{}
+		reloader.OsClient = client
+		reloader.PortReaper = &cniserver.PortReaper{Opts: cniserver.PortReaperOpts{Interval: time.Hour}}
+		reloader.ClusterReaper = &cniserver.ClusterReaper{Opts: cniserver.ClusterReaperOpts{Interval: time.Hour}}
+		reloader.Metrics = Metrics()
+		return reloader, client
+	}
+
+	t.Run("the file overrides the environment without changing it", func(t *testing.T) {
+		reloader, _ := newReloader(t, "CNI_REAP_INTERVAL: 10m\nOS_REGION_NAME: RegionTwo\n")
+
+		Assert(t).That(reloader.Load(), IsNil())
+		Assert(t).That(reloader.Config().ReapInterval, Equals(10*time.Minute))
+		Assert(t).That(reloader.CloudOpts().Region, Equals("RegionTwo"))
+		Assert(t).That(reloader.CloudOpts().AuthOpts.Username, Equals("myuser"))
+		Assert(t).That(os.Getenv("CNI_REAP_INTERVAL"), Equals(""))
+		Assert(t).That(os.Getenv("OS_REGION_NAME"), Equals("RegionOne"))
+	})
+
+	t.Run("reloading updates the reapers and keeps the credentials when they didn't change", func(t *testing.T) {
+		reloader, client := newReloader(t, "OS_PASSWORD: secret\n")
+		Assert(t).That(reloader.Load(), IsNil())
+		reloader.PortReaper.Start()
+		defer reloader.PortReaper.Stop()
+
+		writeFile(t, reloader.File, "OS_PASSWORD: secret\nCNI_REAP_INTERVAL: 10m\nCNI_MIN_PORT_AGE: 1m\nCNI_SKIP_REAPING: true\n")
+		Assert(t).That(reloader.Reload(), IsNil())
+		Assert(t).That(reloader.PortReaper.Opts.Interval, Equals(10*time.Minute))
+		Assert(t).That(reloader.PortReaper.Opts.MinPortAge, Equals(time.Minute))
+		Assert(t).That(reloader.ClusterReaper.Opts.MinPortAge, Equals(time.Minute))
+		Assert(t).That(reloader.ClusterReaper.Opts.SkipDelete, IsTrue())
+		Assert(t).That(reloader.ClusterReaper.Opts.Interval, Equals(time.Hour))
+		Assert(t).That(client.reloads, Equals(0))
+	})
+
+	t.Run("changed credentials are reloaded", func(t *testing.T) {
+		reloader, client := newReloader(t, "OS_PASSWORD: secret\n")
+		Assert(t).That(reloader.Load(), IsNil())
+
+		writeFile(t, reloader.File, "OS_PASSWORD: rotated\n")
+		Assert(t).That(reloader.Reload(), IsNil())
+		Assert(t).That(client.reloads, Equals(1))
+		Assert(t).That(client.cloudOpts.AuthOpts.Password, Equals("rotated"))
+		Assert(t).That(os.Getenv("OS_PASSWORD"), Equals(""))
+	})
+
+	t.Run("a changed CA bundle reloads the credentials", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		writeFile(t, caFile, "first")
+		reloader, client := newReloader(t, "OS_CACERT: "+caFile+"\n")
+		Assert(t).That(reloader.Load(), IsNil())
+
+		Assert(t).That(reloader.Reload(), IsNil())
+		Assert(t).That(client.reloads, Equals(0))
+
+		writeFile(t, caFile, "second")
+		Assert(t).That(reloader.Reload(), IsNil())
+		Assert(t).That(client.reloads, Equals(1))
+	})
+
+	t.Run("variables removed from the file fall back to the environment", func(t *testing.T) {
+		reloader, _ := newReloader(t, "OS_REGION_NAME: RegionTwo\n")
+		Assert(t).That(reloader.Load(), IsNil())
+
+		writeFile(t, reloader.File, "CNI_REAP_INTERVAL: 10m\n")
+		Assert(t).That(reloader.Reload(), IsNil())
+		Assert(t).That(reloader.CloudOpts().Region, Equals("RegionOne"))
+	})
+
+	t.Run("an invalid file is rejected and the previous settings are kept", func(t *testing.T) {
+		reloader, _ := newReloader(t, "CNI_REAP_INTERVAL: 10m\n")
+		Assert(t).That(reloader.Load(), IsNil())
+
+		for _, content := range []string{"CNI_REAP_INTERVAL: soon\n", "CNI_REAP_INTERVAL: 0s\n", "- not a map\n", "OS_CLOUD: missing\n"} {
+			writeFile(t, reloader.File, content)
+			Assert(t).That(reloader.Reload(), Not(IsNil()))
+			Assert(t).That(reloader.Err(), Not(IsNil()))
+			Assert(t).That(reloader.Config().ReapInterval, Equals(10*time.Minute))
+			Assert(t).That(reloader.PortReaper.Opts.Interval, Equals(time.Hour))
+		}
+
+		writeFile(t, reloader.File, "CNI_REAP_INTERVAL: 20m\n")
+		Assert(t).That(reloader.Reload(), IsNil())
+		Assert(t).That(reloader.Err(), IsNil())
+	})
+
+	t.Run("credentials that fail to authenticate are rejected", func(t *testing.T) {
+		reloader, client := newReloader(t, "OS_PASSWORD: secret\n")
+		Assert(t).That(reloader.Load(), IsNil())
+		client.err = errors.New("BOOM")
+
+		writeFile(t, reloader.File, "OS_PASSWORD: wrong\nCNI_REAP_INTERVAL: 10m\n")
+		Assert(t).That(reloader.Reload().Error(), Contains("BOOM"))
+		Assert(t).That(reloader.CloudOpts().AuthOpts.Password, Equals("secret"))
+		Assert(t).That(reloader.PortReaper.Opts.Interval, Equals(time.Hour))
+
+		// the same file is retried on the next change
+		client.err = nil
+		Assert(t).That(reloader.Reload(), IsNil())
+		Assert(t).That(client.cloudOpts.AuthOpts.Password, Equals("wrong"))
+	})
+
+	t.Run("changes to the file are picked up", func(t *testing.T) {
+		reloader, _ := newReloader(t, "CNI_MIN_PORT_AGE: 1m\n")
+		reloader.Delay = 10 * time.Millisecond
+		Assert(t).That(reloader.Load(), IsNil())
+		Assert(t).That(reloader.Start(), IsNil())
+		defer reloader.Stop()
+
+		writeFile(t, reloader.File, "CNI_MIN_PORT_AGE: 2m\n")
+		for d := time.Now().Add(5 * time.Second); time.Now().Before(d) && reloader.Config().MinPortAge != 2*time.Minute; time.Sleep(10 * time.Millisecond) {
+		}
+		Assert(t).That(reloader.Config().MinPortAge, Equals(2*time.Minute))
+	})
+
+	t.Run("changes to clouds.yaml are picked up", func(t *testing.T) {
+		cloudsFile := filepath.Join(t.TempDir(), "clouds.yaml")
+		clouds := "clouds:\n  mycloud:\n    auth:\n      auth_url: https://keystone.example.com:5000/v3\n      username: myuser\n      password: %s\n"
+		writeFile(t, cloudsFile, fmt.Sprintf(clouds, "secret"))
+		reloader, _ := newReloader(t, "OS_CLOUD: mycloud\nOS_CLIENT_CONFIG_FILE: "+cloudsFile+"\n")
+		reloader.Delay = 10 * time.Millisecond
+		Assert(t).That(reloader.Load(), IsNil())
+		Assert(t).That(reloader.CloudOpts().AuthOpts.Password, Equals("secret"))
+		Assert(t).That(reloader.Start(), IsNil())
+		defer reloader.Stop()
+
+		writeFile(t, cloudsFile, fmt.Sprintf(clouds, "rotated"))
+		for d := time.Now().Add(5 * time.Second); time.Now().Before(d) && reloader.CloudOpts().AuthOpts.Password != "rotated"; time.Sleep(10 * time.Millisecond) {
+		}
+		Assert(t).That(reloader.CloudOpts().AuthOpts.Password, Equals("rotated"))
+	})
+
+	t.Run("/health reports a rejected file", func(t *testing.T) {
+		reloader, _ := newReloader(t, "CNI_REAP_INTERVAL: soon\n")
+		Assert(t).That(reloader.Load(), Not(IsNil()))
+		osClient := &mocks.OpenstackClientMock{}
+		osClient.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return nil, openstack.ErrServerNotFound
+		}
+
+		recorder := httptest.NewRecorder()
+		handler := &cniserver.HealthHandler{OsClient: osClient, Reloader: reloader}
+		handler.HandleRequest(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+		Assert(t).That(recorder.Code, Equals(http.StatusInternalServerError))
+
+		var health cniserver.HealthResponse
+		Assert(t).That(json.Unmarshal(recorder.Body.Bytes(), &health), IsNil())
+		Assert(t).That(health.String(), Contains("config: "))
+		Assert(t).That(health.String(), Contains("CNI_REAP_INTERVAL"))
+	})
+}
+
+func writeFile(t *testing.T, file, content string) {
+	t.Helper()
+	Assert(t).That(os.WriteFile(file, []byte(content), 0600), IsNil())
+}
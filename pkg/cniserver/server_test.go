@@ -1,14 +1,22 @@
 package cniserver_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/containernetworking/cni/pkg/types"
-	currentcni "github.com/containernetworking/cni/pkg/types/040"
+	currentcni "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/jboelensns/openstack-cni/pkg/cniclient"
+	"github.com/jboelensns/openstack-cni/pkg/cniserver"
 	. "github.com/jboelensns/openstack-cni/pkg/fixtures"
 	"github.com/jboelensns/openstack-cni/pkg/fixtures/mocks"
 	"github.com/jboelensns/openstack-cni/pkg/openstack"
@@ -41,7 +49,7 @@ func Test_Cni_Errors(t *testing.T) {
 func Test_Cni_Add(t *testing.T) {
 	t.Run("/cni returns 500 with an error json when add fails", func(t *testing.T) {
 		cniHandler := &mocks.CommandHandlerMock{}
-		cniHandler.AddFunc = func(cmd util.CniCommand) (*currentcni.Result, error) {
+		cniHandler.AddFunc = func(ctx context.Context, cmd util.CniCommand) (*currentcni.Result, error) {
 			return nil, fmt.Errorf("BOOM")
 		}
 
@@ -68,7 +76,7 @@ func Test_Cni_Add(t *testing.T) {
 		inResult := NewTestData().CniResult()
 
 		cniHandler := &mocks.CommandHandlerMock{}
-		cniHandler.AddFunc = func(cmd util.CniCommand) (*currentcni.Result, error) {
+		cniHandler.AddFunc = func(ctx context.Context, cmd util.CniCommand) (*currentcni.Result, error) {
 			return inResult, nil
 		}
 
@@ -87,7 +95,7 @@ func Test_Cni_Add(t *testing.T) {
 func Test_Health(t *testing.T) {
 	t.Run("/health returns 200 when healthy", func(t *testing.T) {
 		osClient := &mocks.OpenstackClientMock{}
-		osClient.GetServerByNameFunc = func(name string) (*servers.Server, error) {
+		osClient.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
 			return nil, openstack.ErrServerNotFound
 		}
 
@@ -108,7 +116,7 @@ func Test_Health(t *testing.T) {
 
 	t.Run("/health returns 500 when failing", func(t *testing.T) {
 		osClient := &mocks.OpenstackClientMock{}
-		osClient.GetServerByNameFunc = func(name string) (*servers.Server, error) {
+		osClient.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
 			return nil, errors.New("BOOM")
 		}
 
@@ -127,3 +135,183 @@ func Test_Health(t *testing.T) {
 		})
 	})
 }
+
+func Test_UnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "run", "daemon.sock")
+	cfg := cniserver.NewConfig()
+	cfg.ListenNetwork = "unix"
+	cfg.ListenAddr = socketPath
+	cfg.SkipReaping = true
+
+	deps, err := cniserver.NewBuilder(cfg).
+		WithCniHandler(&mocks.CommandHandlerMock{}).
+		WithOpenstackClient(&mocks.OpenstackClientMock{}).
+		Build()
+	Assert(t).That(err, IsNil())
+	app, err := cniserver.NewApp(cfg, deps.RestServer(), deps.PortReaper(), nil, nil, nil)
+	Assert(t).That(err, IsNil())
+	go app.Run()
+	defer app.Shutdown(context.Background())
+
+	client := &cniclient.Client{
+		Opts: cniclient.ClientOpts{
+			BaseUrl:        "unix://" + socketPath,
+			RequestTimeout: 5 * time.Second,
+		},
+	}
+	var body []byte
+	for d := time.Now().Add(5 * time.Second); time.Now().Before(d); time.Sleep(100 * time.Millisecond) {
+		body, err = client.HandleResponse(client.Get(client.Url("/ping")))
+		if err == nil {
+			break
+		}
+	}
+	Assert(t).That(err, IsNil())
+	Assert(t).That(string(body), Equals("PONG"))
+
+	info, err := os.Stat(socketPath)
+	Assert(t).That(err, IsNil())
+	Assert(t).That(info.Mode().Perm(), Equals(os.FileMode(0600)))
+}
+
+func Test_Auth(t *testing.T) {
+	cniHandler := &mocks.CommandHandlerMock{}
+	cniHandler.DelFunc = func(ctx context.Context, cmd util.CniCommand) error {
+		return nil
+	}
+	opts := &ServerOpts{CniHandler: cniHandler, AuthToken: "s3cr3t"}
+	WithServerOpts(t, opts, func(fix *ServerFixture) {
+		t.Run("/cni returns 401 without a token", func(t *testing.T) {
+			cmd := fix.TestData().CniCommand()
+			cmd.Command = "DEL"
+			body, err := util.ToJson(cmd)
+			Assert(t).That(err, IsNil())
+			resp, err := fix.Client().Post(fix.Url("/cni"), body, DefaultDoOpts())
+			Assert(t).That(err, IsNil())
+			Assert(t).That(resp.StatusCode, Equals(401))
+			Assert(t).That(len(cniHandler.DelCalls()), Equals(0))
+		})
+		t.Run("/cni accepts the client's token", func(t *testing.T) {
+			cmd := fix.TestData().CniCommand()
+			cmd.Command = "DEL"
+			resp, err := fix.CniClient().CniCommand(cmd)
+			Assert(t).That(err, IsNil())
+			Assert(t).That(resp.StatusCode, Equals(204))
+			Assert(t).That(len(cniHandler.DelCalls()), Equals(1))
+		})
+		t.Run("/ping is public", func(t *testing.T) {
+			fix.Ping(t)
+		})
+	})
+}
+
+func Test_Reaper(t *testing.T) {
+	newPorts := func() []ports.Port {
+		return []ports.Port{
+			{ID: "old", Status: "DOWN", Tags: NeutronTags(), CreatedAt: time.Now().Add(-time.Hour)},
+			{ID: "new", Status: "DOWN", Tags: NeutronTags(), CreatedAt: time.Now()},
+			{ID: "attached", Status: "DOWN", DeviceID: "serverId", Tags: NeutronTags(), CreatedAt: time.Now().Add(-time.Hour)},
+		}
+	}
+	readDecisions := func(t *testing.T, resp *http.Response) []cniserver.ReapDecision {
+		t.Helper()
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		Assert(t).That(err, IsNil())
+		decisions := []cniserver.ReapDecision{}
+		Assert(t).That(util.FromJson(body, &decisions), IsNil())
+		return decisions
+	}
+
+	osClient := &mocks.OpenstackClientMock{}
+	osClient.GetPortsByTagsFunc = func(ctx context.Context, tags []string) ([]ports.Port, error) {
+		return newPorts(), nil
+	}
+	osClient.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+	osClient.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+		return &servers.Server{ID: "serverId"}, nil
+	}
+
+	opts := &ServerOpts{OpenstackClient: osClient}
+	WithServerOpts(t, opts, func(fix *ServerFixture) {
+		t.Run("/reaper/plan returns the decision for every port without deleting any", func(t *testing.T) {
+			resp, err := fix.Client().Get(fix.Url("/reaper/plan"), nil)
+			Assert(t).That(err, IsNil())
+			Assert(t).That(resp.StatusCode, Equals(200))
+
+			decisions := readDecisions(t, resp)
+			Assert(t).That(decisions, HasLen(3))
+			Assert(t).That(decisions[0].Reap, IsTrue())
+			Assert(t).That(decisions[1].Reap, IsFalse())
+			Assert(t).That(decisions[1].Reason, Contains("too new"))
+			Assert(t).That(decisions[2].Reap, IsFalse())
+			Assert(t).That(decisions[2].Reason, Contains("still attached"))
+			Assert(t).That(osClient.DeletePortCalls(), HasLen(0))
+		})
+		t.Run("/reaper/run reaps ports immediately", func(t *testing.T) {
+			resp, err := fix.Client().Post(fix.Url("/reaper/run"), nil, DefaultDoOpts())
+			Assert(t).That(err, IsNil())
+			Assert(t).That(resp.StatusCode, Equals(200))
+
+			Assert(t).That(readDecisions(t, resp), HasLen(3))
+			Assert(t).That(osClient.DeletePortCalls(), HasLen(1))
+			Assert(t).That(osClient.DeletePortCalls()[0].PortId, Equals("old"))
+		})
+		t.Run("/reaper/plan returns 405 for POST", func(t *testing.T) {
+			resp, err := fix.Client().Post(fix.Url("/reaper/plan"), nil, DefaultDoOpts())
+			Assert(t).That(err, IsNil())
+			Assert(t).That(resp.StatusCode, Equals(405))
+		})
+	})
+}
+
+func Test_Metrics(t *testing.T) {
+	cniHandler := &mocks.CommandHandlerMock{}
+	cniHandler.AddFunc = func(ctx context.Context, cmd util.CniCommand) (*currentcni.Result, error) {
+		return NewTestData().CniResult(), nil
+	}
+	cniHandler.DelFunc = func(ctx context.Context, cmd util.CniCommand) error {
+		return errors.New("BOOM")
+	}
+	osClient := &mocks.OpenstackClientMock{}
+	osClient.GetPortsByTagsFunc = func(ctx context.Context, tags []string) ([]ports.Port, error) {
+		return nil, nil
+	}
+
+	opts := &ServerOpts{CniHandler: cniHandler, OpenstackClient: osClient}
+	WithServerOpts(t, opts, func(fix *ServerFixture) {
+		cmd := fix.TestData().CniCommand()
+		_, err := fix.CniClient().CniCommand(cmd)
+		Assert(t).That(err, IsNil())
+		cmd.Command = "DEL"
+		_, err = fix.CniClient().CniCommand(cmd)
+		Assert(t).That(err, IsNil())
+		_, err = fix.Client().Get(fix.Url("/reaper/plan"), nil)
+		Assert(t).That(err, IsNil())
+
+		resp, err := fix.Client().Get(fix.Url("/metrics"), nil)
+		Assert(t).That(err, IsNil())
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		Assert(t).That(err, IsNil())
+
+		Assert(t).That(string(body), Contains(`cni_command_duration_seconds_count{command="ADD",network="devint-dp-compute-internal",outcome="success"} 1`))
+		Assert(t).That(string(body), Contains(`cni_command_duration_seconds_count{command="DEL",network="devint-dp-compute-internal",outcome="failure"} 1`))
+		Assert(t).That(string(body), Contains(`cni_openstack_request_duration_seconds_count{operation="GetPortsByTags",outcome="success"} 1`))
+	})
+}
+
+func Test_CacheFlush(t *testing.T) {
+	WithServer(t, func(fix *ServerFixture) {
+		t.Run("/cache/flush returns 204", func(t *testing.T) {
+			resp, err := fix.Client().Post(fix.Url("/cache/flush"), nil, DefaultDoOpts())
+			Assert(t).That(err, IsNil())
+			Assert(t).That(resp.StatusCode, Equals(http.StatusNoContent))
+		})
+		t.Run("/cache/flush returns 405 for GET", func(t *testing.T) {
+			resp, err := fix.Client().Get(fix.Url("/cache/flush"), nil)
+			Assert(t).That(err, IsNil())
+			Assert(t).That(resp.StatusCode, Equals(405))
+		})
+	})
+}
@@ -7,7 +7,7 @@ import (
 	"testing"
 
 	"github.com/containernetworking/cni/pkg/types"
-	currentcni "github.com/containernetworking/cni/pkg/types/040"
+	currentcni "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/jboelensns/openstack-cni/pkg/cniserver"
 	. "github.com/pepinns/go-hamcrest"
 )
@@ -30,6 +30,7 @@ type ServerOpts struct {
 	CniHandler      cniserver.CommandHandler
 	OpenstackClient openstack.OpenstackClient
 	Networking      cniplugin.Networking
+	AuthToken       string
 }
 
 type TestingConfig struct {
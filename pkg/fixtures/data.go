@@ -1,13 +1,14 @@
 package fixtures
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"time"
 
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
-	currentcni "github.com/containernetworking/cni/pkg/types/040"
+	currentcni "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/jboelensns/openstack-cni/pkg/cniserver"
 	"github.com/jboelensns/openstack-cni/pkg/util"
 )
@@ -55,6 +56,40 @@ func (me *TestData) Stdin() []byte {
 	`)
 }
 
+// StdinWithPrevResult returns Stdin with the result embedded as the prevResult
+func (me *TestData) StdinWithPrevResult(result *currentcni.Result) []byte {
+	conf := map[string]any{}
+	if err := json.Unmarshal(me.Stdin(), &conf); err != nil {
+		panic(err)
+	}
+	// the runtime hands the prevResult over in the config's version
+	prevResult, err := result.GetAsVersion(fmt.Sprint(conf["cniVersion"]))
+	if err != nil {
+		panic(err)
+	}
+	conf["prevResult"] = prevResult
+	b, err := json.Marshal(conf)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// StdinWithValidAttachments returns Stdin with the attachments a GC should keep
+func (me *TestData) StdinWithValidAttachments(attachments ...types.GCAttachment) []byte {
+	conf := map[string]any{}
+	if err := json.Unmarshal(me.Stdin(), &conf); err != nil {
+		panic(err)
+	}
+	conf["cniVersion"] = "1.1.0"
+	conf["cni.dev/valid-attachments"] = attachments
+	b, err := json.Marshal(conf)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
 func (me *TestData) CniResult() *currentcni.Result {
 	getIpNet := func(ipWithCidr string) net.IPNet {
 		ipNet, _ := util.GetIpNetFromAddress(ipWithCidr)
@@ -63,16 +98,16 @@ func (me *TestData) CniResult() *currentcni.Result {
 	zero := 0
 
 	return &currentcni.Result{
-		CNIVersion: "0.3.1",
+		CNIVersion: currentcni.ImplementedSpecVersion,
 		Interfaces: []*currentcni.Interface{
 			{
 				Name:    "ens3",
 				Mac:     "02:42:d9:1f:22:9d",
+				Mtu:     1450,
 				Sandbox: "/proc/4237/net/ns"},
 		},
 		IPs: []*currentcni.IPConfig{
 			{
-				Version:   "4",
 				Interface: &zero,
 				Address:   getIpNet("192.168.1.42/32"),
 				Gateway:   net.ParseIP("192.168.0.1")},
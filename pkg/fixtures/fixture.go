@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -27,13 +29,16 @@ type ServerFixture struct {
 	openstack  openstack.OpenstackClient
 	networking cniplugin.Networking
 	cfg        cniserver.Config
+	authToken  string
 }
 
 func NewServerFixture(t *testing.T, opts *ServerOpts) *ServerFixture {
 	var cniHandler cniserver.CommandHandler = &mocks.CommandHandlerMock{}
 	var osClient openstack.OpenstackClient = &mocks.OpenstackClientMock{}
 	var networking cniplugin.Networking = &mocks.NetworkingMock{}
+	var authToken string
 	if opts != nil {
+		authToken = opts.AuthToken
 		if opts.CniHandler != nil {
 			cniHandler = opts.CniHandler
 		}
@@ -56,6 +61,7 @@ func NewServerFixture(t *testing.T, opts *ServerOpts) *ServerFixture {
 		cniHandler: cniHandler,
 		openstack:  osClient,
 		networking: networking,
+		authToken:  authToken,
 	}
 }
 
@@ -72,6 +78,7 @@ func (me *ServerFixture) CniClient() *cniclient.Client {
 		Opts: cniclient.ClientOpts{
 			BaseUrl:        me.Url(""),
 			RequestTimeout: time.Second * 5,
+			AuthToken:      me.authToken,
 		},
 	}
 }
@@ -97,6 +104,10 @@ func (me *ServerFixture) Start(t *testing.T) {
 
 	me.cfg = cniserver.NewConfig()
 	me.cfg.ListenAddr = me.GetListenAddr(me.GetPort())
+	if me.authToken != "" {
+		me.cfg.AuthTokenFile = filepath.Join(t.TempDir(), "openstack-cni.token")
+		Assert(t).That(os.WriteFile(me.cfg.AuthTokenFile, []byte(me.authToken+"\n"), 0600), IsNil())
+	}
 
 	deps, err := cniserver.NewBuilder(me.cfg).
 		WithCniHandler(me.cniHandler).
@@ -104,7 +115,7 @@ func (me *ServerFixture) Start(t *testing.T) {
 		Build()
 	Assert(t).That(err, IsNil())
 
-	app, err := cniserver.NewApp(me.cfg, deps.RestServer(), deps.PortReaper())
+	app, err := cniserver.NewApp(me.cfg, deps.RestServer(), deps.PortReaper(), deps.ClusterReaper(), nil, nil)
 	Assert(t).That(err, IsNil())
 	me.app = app
 	go func() {
@@ -171,7 +182,7 @@ func (me *ServerFixture) GetAvailablePort(addrs ...string) int {
 }
 
 func Metrics() *cniserver.Metrics {
-	return cniserver.NewMetrics(prometheus.NewRegistry(), func() float64 {return 0})
+	return cniserver.NewMetrics(prometheus.NewRegistry())
 }
 
 func CniContextFromConfig(t *testing.T, cfg TestingConfig, cmd util.CniCommand) util.CniContext {
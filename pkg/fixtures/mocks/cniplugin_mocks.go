@@ -19,12 +19,21 @@ var _ cniplugin.Networking = &NetworkingMock{}
 //
 //		// make and configure a mocked cniplugin.Networking
 //		mockedNetworking := &NetworkingMock{
+//			CheckFunc: func(namespace string, iface *cniplugin.NetworkInterface) error {
+//				panic("mock out the Check method")
+//			},
 //			ConfigureFunc: func(namespace string, iface *cniplugin.NetworkInterface) error {
 //				panic("mock out the Configure method")
 //			},
 //			GetIfaceByMacFunc: func(mac string) (*net.Interface, error) {
 //				panic("mock out the GetIfaceByMac method")
 //			},
+//			UnconfigureFunc: func(namespace string, ifName string) error {
+//				panic("mock out the Unconfigure method")
+//			},
+//			WaitForIfaceFunc: func(mac string, opts cniplugin.IfaceWaitOpts) (*net.Interface, error) {
+//				panic("mock out the WaitForIface method")
+//			},
 //		}
 //
 //		// use mockedNetworking in code that requires cniplugin.Networking
@@ -32,14 +41,30 @@ var _ cniplugin.Networking = &NetworkingMock{}
 //
 //	}
 type NetworkingMock struct {
+	// CheckFunc mocks the Check method.
+	CheckFunc func(namespace string, iface *cniplugin.NetworkInterface) error
+
 	// ConfigureFunc mocks the Configure method.
 	ConfigureFunc func(namespace string, iface *cniplugin.NetworkInterface) error
 
 	// GetIfaceByMacFunc mocks the GetIfaceByMac method.
 	GetIfaceByMacFunc func(mac string) (*net.Interface, error)
 
+	// UnconfigureFunc mocks the Unconfigure method.
+	UnconfigureFunc func(namespace string, ifName string) error
+
+	// WaitForIfaceFunc mocks the WaitForIface method.
+	WaitForIfaceFunc func(mac string, opts cniplugin.IfaceWaitOpts) (*net.Interface, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// Check holds details about calls to the Check method.
+		Check []struct {
+			// Namespace is the namespace argument value.
+			Namespace string
+			// Iface is the iface argument value.
+			Iface *cniplugin.NetworkInterface
+		}
 		// Configure holds details about calls to the Configure method.
 		Configure []struct {
 			// Namespace is the namespace argument value.
@@ -52,9 +77,62 @@ type NetworkingMock struct {
 			// Mac is the mac argument value.
 			Mac string
 		}
+		// Unconfigure holds details about calls to the Unconfigure method.
+		Unconfigure []struct {
+			// Namespace is the namespace argument value.
+			Namespace string
+			// IfName is the ifName argument value.
+			IfName string
+		}
+		// WaitForIface holds details about calls to the WaitForIface method.
+		WaitForIface []struct {
+			// Mac is the mac argument value.
+			Mac string
+			// Opts is the opts argument value.
+			Opts cniplugin.IfaceWaitOpts
+		}
 	}
+	lockCheck         sync.RWMutex
 	lockConfigure     sync.RWMutex
 	lockGetIfaceByMac sync.RWMutex
+	lockUnconfigure   sync.RWMutex
+	lockWaitForIface  sync.RWMutex
+}
+
+// Check calls CheckFunc.
+func (mock *NetworkingMock) Check(namespace string, iface *cniplugin.NetworkInterface) error {
+	if mock.CheckFunc == nil {
+		panic("NetworkingMock.CheckFunc: method is nil but Networking.Check was just called")
+	}
+	callInfo := struct {
+		Namespace string
+		Iface     *cniplugin.NetworkInterface
+	}{
+		Namespace: namespace,
+		Iface:     iface,
+	}
+	mock.lockCheck.Lock()
+	mock.calls.Check = append(mock.calls.Check, callInfo)
+	mock.lockCheck.Unlock()
+	return mock.CheckFunc(namespace, iface)
+}
+
+// CheckCalls gets all the calls that were made to Check.
+// Check the length with:
+//
+//	len(mockedNetworking.CheckCalls())
+func (mock *NetworkingMock) CheckCalls() []struct {
+	Namespace string
+	Iface     *cniplugin.NetworkInterface
+} {
+	var calls []struct {
+		Namespace string
+		Iface     *cniplugin.NetworkInterface
+	}
+	mock.lockCheck.RLock()
+	calls = mock.calls.Check
+	mock.lockCheck.RUnlock()
+	return calls
 }
 
 // Configure calls ConfigureFunc.
@@ -124,3 +202,75 @@ func (mock *NetworkingMock) GetIfaceByMacCalls() []struct {
 	mock.lockGetIfaceByMac.RUnlock()
 	return calls
 }
+
+// Unconfigure calls UnconfigureFunc.
+func (mock *NetworkingMock) Unconfigure(namespace string, ifName string) error {
+	if mock.UnconfigureFunc == nil {
+		panic("NetworkingMock.UnconfigureFunc: method is nil but Networking.Unconfigure was just called")
+	}
+	callInfo := struct {
+		Namespace string
+		IfName    string
+	}{
+		Namespace: namespace,
+		IfName:    ifName,
+	}
+	mock.lockUnconfigure.Lock()
+	mock.calls.Unconfigure = append(mock.calls.Unconfigure, callInfo)
+	mock.lockUnconfigure.Unlock()
+	return mock.UnconfigureFunc(namespace, ifName)
+}
+
+// UnconfigureCalls gets all the calls that were made to Unconfigure.
+// Check the length with:
+//
+//	len(mockedNetworking.UnconfigureCalls())
+func (mock *NetworkingMock) UnconfigureCalls() []struct {
+	Namespace string
+	IfName    string
+} {
+	var calls []struct {
+		Namespace string
+		IfName    string
+	}
+	mock.lockUnconfigure.RLock()
+	calls = mock.calls.Unconfigure
+	mock.lockUnconfigure.RUnlock()
+	return calls
+}
+
+// WaitForIface calls WaitForIfaceFunc.
+func (mock *NetworkingMock) WaitForIface(mac string, opts cniplugin.IfaceWaitOpts) (*net.Interface, error) {
+	if mock.WaitForIfaceFunc == nil {
+		panic("NetworkingMock.WaitForIfaceFunc: method is nil but Networking.WaitForIface was just called")
+	}
+	callInfo := struct {
+		Mac  string
+		Opts cniplugin.IfaceWaitOpts
+	}{
+		Mac:  mac,
+		Opts: opts,
+	}
+	mock.lockWaitForIface.Lock()
+	mock.calls.WaitForIface = append(mock.calls.WaitForIface, callInfo)
+	mock.lockWaitForIface.Unlock()
+	return mock.WaitForIfaceFunc(mac, opts)
+}
+
+// WaitForIfaceCalls gets all the calls that were made to WaitForIface.
+// Check the length with:
+//
+//	len(mockedNetworking.WaitForIfaceCalls())
+func (mock *NetworkingMock) WaitForIfaceCalls() []struct {
+	Mac  string
+	Opts cniplugin.IfaceWaitOpts
+} {
+	var calls []struct {
+		Mac  string
+		Opts cniplugin.IfaceWaitOpts
+	}
+	mock.lockWaitForIface.RLock()
+	calls = mock.calls.WaitForIface
+	mock.lockWaitForIface.RUnlock()
+	return calls
+}
@@ -4,7 +4,8 @@
 package mocks
 
 import (
-	currentcni "github.com/containernetworking/cni/pkg/types/040"
+	"context"
+	currentcni "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/jboelensns/openstack-cni/pkg/cniserver"
 	"github.com/jboelensns/openstack-cni/pkg/util"
 	"sync"
@@ -20,15 +21,18 @@ var _ cniserver.CommandHandler = &CommandHandlerMock{}
 //
 //		// make and configure a mocked cniserver.CommandHandler
 //		mockedCommandHandler := &CommandHandlerMock{
-//			AddFunc: func(cmd util.CniCommand) (*currentcni.Result, error) {
+//			AddFunc: func(ctx context.Context, cmd util.CniCommand) (*currentcni.Result, error) {
 //				panic("mock out the Add method")
 //			},
-//			CheckFunc: func(cmd util.CniCommand) error {
+//			CheckFunc: func(ctx context.Context, cmd util.CniCommand) error {
 //				panic("mock out the Check method")
 //			},
-//			DelFunc: func(cmd util.CniCommand) error {
+//			DelFunc: func(ctx context.Context, cmd util.CniCommand) error {
 //				panic("mock out the Del method")
 //			},
+//			GCFunc: func(ctx context.Context, cmd util.CniCommand) error {
+//				panic("mock out the GC method")
+//			},
 //		}
 //
 //		// use mockedCommandHandler in code that requires cniserver.CommandHandler
@@ -37,28 +41,44 @@ var _ cniserver.CommandHandler = &CommandHandlerMock{}
 //	}
 type CommandHandlerMock struct {
 	// AddFunc mocks the Add method.
-	AddFunc func(cmd util.CniCommand) (*currentcni.Result, error)
+	AddFunc func(ctx context.Context, cmd util.CniCommand) (*currentcni.Result, error)
 
 	// CheckFunc mocks the Check method.
-	CheckFunc func(cmd util.CniCommand) error
+	CheckFunc func(ctx context.Context, cmd util.CniCommand) error
 
 	// DelFunc mocks the Del method.
-	DelFunc func(cmd util.CniCommand) error
+	DelFunc func(ctx context.Context, cmd util.CniCommand) error
+
+	// GCFunc mocks the GC method.
+	GCFunc func(ctx context.Context, cmd util.CniCommand) error
 
 	// calls tracks calls to the methods.
 	calls struct {
 		// Add holds details about calls to the Add method.
 		Add []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Cmd is the cmd argument value.
 			Cmd util.CniCommand
 		}
 		// Check holds details about calls to the Check method.
 		Check []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Cmd is the cmd argument value.
 			Cmd util.CniCommand
 		}
 		// Del holds details about calls to the Del method.
 		Del []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Cmd is the cmd argument value.
+			Cmd util.CniCommand
+		}
+		// GC holds details about calls to the GC method.
+		GC []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Cmd is the cmd argument value.
 			Cmd util.CniCommand
 		}
@@ -66,22 +86,25 @@ type CommandHandlerMock struct {
 	lockAdd   sync.RWMutex
 	lockCheck sync.RWMutex
 	lockDel   sync.RWMutex
+	lockGC    sync.RWMutex
 }
 
 // Add calls AddFunc.
-func (mock *CommandHandlerMock) Add(cmd util.CniCommand) (*currentcni.Result, error) {
+func (mock *CommandHandlerMock) Add(ctx context.Context, cmd util.CniCommand) (*currentcni.Result, error) {
 	if mock.AddFunc == nil {
 		panic("CommandHandlerMock.AddFunc: method is nil but CommandHandler.Add was just called")
 	}
 	callInfo := struct {
+		Ctx context.Context
 		Cmd util.CniCommand
 	}{
+		Ctx: ctx,
 		Cmd: cmd,
 	}
 	mock.lockAdd.Lock()
 	mock.calls.Add = append(mock.calls.Add, callInfo)
 	mock.lockAdd.Unlock()
-	return mock.AddFunc(cmd)
+	return mock.AddFunc(ctx, cmd)
 }
 
 // AddCalls gets all the calls that were made to Add.
@@ -89,9 +112,11 @@ func (mock *CommandHandlerMock) Add(cmd util.CniCommand) (*currentcni.Result, er
 //
 //	len(mockedCommandHandler.AddCalls())
 func (mock *CommandHandlerMock) AddCalls() []struct {
+	Ctx context.Context
 	Cmd util.CniCommand
 } {
 	var calls []struct {
+		Ctx context.Context
 		Cmd util.CniCommand
 	}
 	mock.lockAdd.RLock()
@@ -101,19 +126,21 @@ func (mock *CommandHandlerMock) AddCalls() []struct {
 }
 
 // Check calls CheckFunc.
-func (mock *CommandHandlerMock) Check(cmd util.CniCommand) error {
+func (mock *CommandHandlerMock) Check(ctx context.Context, cmd util.CniCommand) error {
 	if mock.CheckFunc == nil {
 		panic("CommandHandlerMock.CheckFunc: method is nil but CommandHandler.Check was just called")
 	}
 	callInfo := struct {
+		Ctx context.Context
 		Cmd util.CniCommand
 	}{
+		Ctx: ctx,
 		Cmd: cmd,
 	}
 	mock.lockCheck.Lock()
 	mock.calls.Check = append(mock.calls.Check, callInfo)
 	mock.lockCheck.Unlock()
-	return mock.CheckFunc(cmd)
+	return mock.CheckFunc(ctx, cmd)
 }
 
 // CheckCalls gets all the calls that were made to Check.
@@ -121,9 +148,11 @@ func (mock *CommandHandlerMock) Check(cmd util.CniCommand) error {
 //
 //	len(mockedCommandHandler.CheckCalls())
 func (mock *CommandHandlerMock) CheckCalls() []struct {
+	Ctx context.Context
 	Cmd util.CniCommand
 } {
 	var calls []struct {
+		Ctx context.Context
 		Cmd util.CniCommand
 	}
 	mock.lockCheck.RLock()
@@ -133,19 +162,21 @@ func (mock *CommandHandlerMock) CheckCalls() []struct {
 }
 
 // Del calls DelFunc.
-func (mock *CommandHandlerMock) Del(cmd util.CniCommand) error {
+func (mock *CommandHandlerMock) Del(ctx context.Context, cmd util.CniCommand) error {
 	if mock.DelFunc == nil {
 		panic("CommandHandlerMock.DelFunc: method is nil but CommandHandler.Del was just called")
 	}
 	callInfo := struct {
+		Ctx context.Context
 		Cmd util.CniCommand
 	}{
+		Ctx: ctx,
 		Cmd: cmd,
 	}
 	mock.lockDel.Lock()
 	mock.calls.Del = append(mock.calls.Del, callInfo)
 	mock.lockDel.Unlock()
-	return mock.DelFunc(cmd)
+	return mock.DelFunc(ctx, cmd)
 }
 
 // DelCalls gets all the calls that were made to Del.
@@ -153,9 +184,11 @@ func (mock *CommandHandlerMock) Del(cmd util.CniCommand) error {
 //
 //	len(mockedCommandHandler.DelCalls())
 func (mock *CommandHandlerMock) DelCalls() []struct {
+	Ctx context.Context
 	Cmd util.CniCommand
 } {
 	var calls []struct {
+		Ctx context.Context
 		Cmd util.CniCommand
 	}
 	mock.lockDel.RLock()
@@ -163,3 +196,39 @@ func (mock *CommandHandlerMock) DelCalls() []struct {
 	mock.lockDel.RUnlock()
 	return calls
 }
+
+// GC calls GCFunc.
+func (mock *CommandHandlerMock) GC(ctx context.Context, cmd util.CniCommand) error {
+	if mock.GCFunc == nil {
+		panic("CommandHandlerMock.GCFunc: method is nil but CommandHandler.GC was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Cmd util.CniCommand
+	}{
+		Ctx: ctx,
+		Cmd: cmd,
+	}
+	mock.lockGC.Lock()
+	mock.calls.GC = append(mock.calls.GC, callInfo)
+	mock.lockGC.Unlock()
+	return mock.GCFunc(ctx, cmd)
+}
+
+// GCCalls gets all the calls that were made to GC.
+// Check the length with:
+//
+//	len(mockedCommandHandler.GCCalls())
+func (mock *CommandHandlerMock) GCCalls() []struct {
+	Ctx context.Context
+	Cmd util.CniCommand
+} {
+	var calls []struct {
+		Ctx context.Context
+		Cmd util.CniCommand
+	}
+	mock.lockGC.RLock()
+	calls = mock.calls.GC
+	mock.lockGC.RUnlock()
+	return calls
+}
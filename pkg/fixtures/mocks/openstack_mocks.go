@@ -4,11 +4,11 @@
 package mocks
 
 import (
+	"context"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
-	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
 	"github.com/jboelensns/openstack-cni/pkg/openstack"
@@ -25,51 +25,72 @@ var _ openstack.OpenstackClient = &OpenstackClientMock{}
 //
 //		// make and configure a mocked openstack.OpenstackClient
 //		mockedOpenstackClient := &OpenstackClientMock{
-//			AssignPortFunc: func(portId string, serverId string) (*attachinterfaces.Interface, error) {
+//			AssignPortFunc: func(ctx context.Context, portId string, serverId string) (*attachinterfaces.Interface, error) {
 //				panic("mock out the AssignPort method")
 //			},
 //			ClientsFunc: func() *openstack.ApiClients {
 //				panic("mock out the Clients method")
 //			},
-//			CreatePortFunc: func(opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) {
+//			CreatePortFunc: func(ctx context.Context, opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) {
 //				panic("mock out the CreatePort method")
 //			},
-//			DeletePortFunc: func(portId string) error {
+//			CreateSecurityGroupFunc: func(ctx context.Context, name string, description string) (*openstack.SecurityGroup, error) {
+//				panic("mock out the CreateSecurityGroup method")
+//			},
+//			DeletePortFunc: func(ctx context.Context, portId string) error {
 //				panic("mock out the DeletePort method")
 //			},
-//			DetachPortFunc: func(portId string, serverId string) error {
+//			DetachPortFunc: func(ctx context.Context, portId string, serverId string) error {
 //				panic("mock out the DetachPort method")
 //			},
-//			GetNetworkByNameFunc: func(name string) (*networks.Network, error) {
+//			GetNetworkFunc: func(ctx context.Context, id string) (*openstack.Network, error) {
+//				panic("mock out the GetNetwork method")
+//			},
+//			GetNetworkByNameFunc: func(ctx context.Context, name string) (*openstack.Network, error) {
 //				panic("mock out the GetNetworkByName method")
 //			},
-//			GetPortFunc: func(portId string) (*ports.Port, error) {
+//			GetPortFunc: func(ctx context.Context, portId string) (*ports.Port, error) {
 //				panic("mock out the GetPort method")
 //			},
-//			GetPortByTagsFunc: func(tags []string) (*ports.Port, error) {
+//			GetPortByTagsFunc: func(ctx context.Context, tags []string) (*ports.Port, error) {
 //				panic("mock out the GetPortByTags method")
 //			},
-//			GetPortsByDeviceIdFunc: func(deviceId string) ([]ports.Port, error) {
+//			GetPortWithBindingFunc: func(ctx context.Context, portId string) (*openstack.PortWithBinding, error) {
+//				panic("mock out the GetPortWithBinding method")
+//			},
+//			GetPortsByDeviceIdFunc: func(ctx context.Context, deviceId string) ([]ports.Port, error) {
 //				panic("mock out the GetPortsByDeviceId method")
 //			},
-//			GetPortsByTagsFunc: func(tags []string) ([]ports.Port, error) {
+//			GetPortsByTagsFunc: func(ctx context.Context, tags []string) ([]ports.Port, error) {
 //				panic("mock out the GetPortsByTags method")
 //			},
-//			GetProjectByNameFunc: func(name string) (*projects.Project, error) {
+//			GetProjectByNameFunc: func(ctx context.Context, name string) (*projects.Project, error) {
 //				panic("mock out the GetProjectByName method")
 //			},
-//			GetSecurityGroupByNameFunc: func(name string, projectId string) (*groups.SecGroup, error) {
+//			GetSecurityGroupByNameFunc: func(ctx context.Context, name string, projectId string) (*groups.SecGroup, error) {
 //				panic("mock out the GetSecurityGroupByName method")
 //			},
-//			GetServerByNameFunc: func(name string) (*servers.Server, error) {
+//			GetSecurityGroupsByNameFunc: func(ctx context.Context, name string) ([]openstack.SecurityGroup, error) {
+//				panic("mock out the GetSecurityGroupsByName method")
+//			},
+//			GetServerFunc: func(ctx context.Context, id string) (*servers.Server, error) {
+//				panic("mock out the GetServer method")
+//			},
+//			GetServerByNameFunc: func(ctx context.Context, name string) (*servers.Server, error) {
 //				panic("mock out the GetServerByName method")
 //			},
-//			GetSubnetFunc: func(id string) (*subnets.Subnet, error) {
+//			GetSubnetFunc: func(ctx context.Context, id string) (*subnets.Subnet, error) {
 //				panic("mock out the GetSubnet method")
 //			},
-//			GetSubnetByNameFunc: func(name string, networkId string) (*subnets.Subnet, error) {
+//			GetSubnetByNameFunc: func(ctx context.Context, name string, networkId string) (*subnets.Subnet, error) {
 //				panic("mock out the GetSubnetByName method")
 //			},
+//			SetPortTagsFunc: func(ctx context.Context, portId string, tags []string) error {
+//				panic("mock out the SetPortTags method")
+//			},
+//			UpdateSecurityGroupDescriptionFunc: func(ctx context.Context, id string, description string, revisionNumber int) error {
+//				panic("mock out the UpdateSecurityGroupDescription method")
+//			},
 //		}
 //
 //		// use mockedOpenstackClient in code that requires openstack.OpenstackClient
@@ -78,54 +99,77 @@ var _ openstack.OpenstackClient = &OpenstackClientMock{}
 //	}
 type OpenstackClientMock struct {
 	// AssignPortFunc mocks the AssignPort method.
-	AssignPortFunc func(portId string, serverId string) (*attachinterfaces.Interface, error)
+	AssignPortFunc func(ctx context.Context, portId string, serverId string) (*attachinterfaces.Interface, error)
 
 	// ClientsFunc mocks the Clients method.
 	ClientsFunc func() *openstack.ApiClients
 
 	// CreatePortFunc mocks the CreatePort method.
-	CreatePortFunc func(opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error)
+	CreatePortFunc func(ctx context.Context, opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error)
+
+	// CreateSecurityGroupFunc mocks the CreateSecurityGroup method.
+	CreateSecurityGroupFunc func(ctx context.Context, name string, description string) (*openstack.SecurityGroup, error)
 
 	// DeletePortFunc mocks the DeletePort method.
-	DeletePortFunc func(portId string) error
+	DeletePortFunc func(ctx context.Context, portId string) error
 
 	// DetachPortFunc mocks the DetachPort method.
-	DetachPortFunc func(portId string, serverId string) error
+	DetachPortFunc func(ctx context.Context, portId string, serverId string) error
+
+	// GetNetworkFunc mocks the GetNetwork method.
+	GetNetworkFunc func(ctx context.Context, id string) (*openstack.Network, error)
 
 	// GetNetworkByNameFunc mocks the GetNetworkByName method.
-	GetNetworkByNameFunc func(name string) (*networks.Network, error)
+	GetNetworkByNameFunc func(ctx context.Context, name string) (*openstack.Network, error)
 
 	// GetPortFunc mocks the GetPort method.
-	GetPortFunc func(portId string) (*ports.Port, error)
+	GetPortFunc func(ctx context.Context, portId string) (*ports.Port, error)
 
 	// GetPortByTagsFunc mocks the GetPortByTags method.
-	GetPortByTagsFunc func(tags []string) (*ports.Port, error)
+	GetPortByTagsFunc func(ctx context.Context, tags []string) (*ports.Port, error)
+
+	// GetPortWithBindingFunc mocks the GetPortWithBinding method.
+	GetPortWithBindingFunc func(ctx context.Context, portId string) (*openstack.PortWithBinding, error)
 
 	// GetPortsByDeviceIdFunc mocks the GetPortsByDeviceId method.
-	GetPortsByDeviceIdFunc func(deviceId string) ([]ports.Port, error)
+	GetPortsByDeviceIdFunc func(ctx context.Context, deviceId string) ([]ports.Port, error)
 
 	// GetPortsByTagsFunc mocks the GetPortsByTags method.
-	GetPortsByTagsFunc func(tags []string) ([]ports.Port, error)
+	GetPortsByTagsFunc func(ctx context.Context, tags []string) ([]ports.Port, error)
 
 	// GetProjectByNameFunc mocks the GetProjectByName method.
-	GetProjectByNameFunc func(name string) (*projects.Project, error)
+	GetProjectByNameFunc func(ctx context.Context, name string) (*projects.Project, error)
 
 	// GetSecurityGroupByNameFunc mocks the GetSecurityGroupByName method.
-	GetSecurityGroupByNameFunc func(name string, projectId string) (*groups.SecGroup, error)
+	GetSecurityGroupByNameFunc func(ctx context.Context, name string, projectId string) (*groups.SecGroup, error)
+
+	// GetSecurityGroupsByNameFunc mocks the GetSecurityGroupsByName method.
+	GetSecurityGroupsByNameFunc func(ctx context.Context, name string) ([]openstack.SecurityGroup, error)
+
+	// GetServerFunc mocks the GetServer method.
+	GetServerFunc func(ctx context.Context, id string) (*servers.Server, error)
 
 	// GetServerByNameFunc mocks the GetServerByName method.
-	GetServerByNameFunc func(name string) (*servers.Server, error)
+	GetServerByNameFunc func(ctx context.Context, name string) (*servers.Server, error)
 
 	// GetSubnetFunc mocks the GetSubnet method.
-	GetSubnetFunc func(id string) (*subnets.Subnet, error)
+	GetSubnetFunc func(ctx context.Context, id string) (*subnets.Subnet, error)
 
 	// GetSubnetByNameFunc mocks the GetSubnetByName method.
-	GetSubnetByNameFunc func(name string, networkId string) (*subnets.Subnet, error)
+	GetSubnetByNameFunc func(ctx context.Context, name string, networkId string) (*subnets.Subnet, error)
+
+	// SetPortTagsFunc mocks the SetPortTags method.
+	SetPortTagsFunc func(ctx context.Context, portId string, tags []string) error
+
+	// UpdateSecurityGroupDescriptionFunc mocks the UpdateSecurityGroupDescription method.
+	UpdateSecurityGroupDescriptionFunc func(ctx context.Context, id string, description string, revisionNumber int) error
 
 	// calls tracks calls to the methods.
 	calls struct {
 		// AssignPort holds details about calls to the AssignPort method.
 		AssignPort []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// PortId is the portId argument value.
 			PortId string
 			// ServerId is the serverId argument value.
@@ -136,111 +180,203 @@ type OpenstackClientMock struct {
 		}
 		// CreatePort holds details about calls to the CreatePort method.
 		CreatePort []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Opts is the opts argument value.
 			Opts ports.CreateOpts
 			// ExtraOpts is the extraOpts argument value.
 			ExtraOpts *openstack.ExtraCreatePortOpts
 		}
+		// CreateSecurityGroup holds details about calls to the CreateSecurityGroup method.
+		CreateSecurityGroup []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Name is the name argument value.
+			Name string
+			// Description is the description argument value.
+			Description string
+		}
 		// DeletePort holds details about calls to the DeletePort method.
 		DeletePort []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// PortId is the portId argument value.
 			PortId string
 		}
 		// DetachPort holds details about calls to the DetachPort method.
 		DetachPort []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// PortId is the portId argument value.
 			PortId string
 			// ServerId is the serverId argument value.
 			ServerId string
 		}
+		// GetNetwork holds details about calls to the GetNetwork method.
+		GetNetwork []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
 		// GetNetworkByName holds details about calls to the GetNetworkByName method.
 		GetNetworkByName []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Name is the name argument value.
 			Name string
 		}
 		// GetPort holds details about calls to the GetPort method.
 		GetPort []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// PortId is the portId argument value.
 			PortId string
 		}
 		// GetPortByTags holds details about calls to the GetPortByTags method.
 		GetPortByTags []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Tags is the tags argument value.
 			Tags []string
 		}
+		// GetPortWithBinding holds details about calls to the GetPortWithBinding method.
+		GetPortWithBinding []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// PortId is the portId argument value.
+			PortId string
+		}
 		// GetPortsByDeviceId holds details about calls to the GetPortsByDeviceId method.
 		GetPortsByDeviceId []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// DeviceId is the deviceId argument value.
 			DeviceId string
 		}
 		// GetPortsByTags holds details about calls to the GetPortsByTags method.
 		GetPortsByTags []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Tags is the tags argument value.
 			Tags []string
 		}
 		// GetProjectByName holds details about calls to the GetProjectByName method.
 		GetProjectByName []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Name is the name argument value.
 			Name string
 		}
 		// GetSecurityGroupByName holds details about calls to the GetSecurityGroupByName method.
 		GetSecurityGroupByName []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Name is the name argument value.
 			Name string
 			// ProjectId is the projectId argument value.
 			ProjectId string
 		}
+		// GetSecurityGroupsByName holds details about calls to the GetSecurityGroupsByName method.
+		GetSecurityGroupsByName []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Name is the name argument value.
+			Name string
+		}
+		// GetServer holds details about calls to the GetServer method.
+		GetServer []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
 		// GetServerByName holds details about calls to the GetServerByName method.
 		GetServerByName []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Name is the name argument value.
 			Name string
 		}
 		// GetSubnet holds details about calls to the GetSubnet method.
 		GetSubnet []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// ID is the id argument value.
 			ID string
 		}
 		// GetSubnetByName holds details about calls to the GetSubnetByName method.
 		GetSubnetByName []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Name is the name argument value.
 			Name string
 			// NetworkId is the networkId argument value.
 			NetworkId string
 		}
+		// SetPortTags holds details about calls to the SetPortTags method.
+		SetPortTags []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// PortId is the portId argument value.
+			PortId string
+			// Tags is the tags argument value.
+			Tags []string
+		}
+		// UpdateSecurityGroupDescription holds details about calls to the UpdateSecurityGroupDescription method.
+		UpdateSecurityGroupDescription []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Description is the description argument value.
+			Description string
+			// RevisionNumber is the revisionNumber argument value.
+			RevisionNumber int
+		}
 	}
-	lockAssignPort             sync.RWMutex
-	lockClients                sync.RWMutex
-	lockCreatePort             sync.RWMutex
-	lockDeletePort             sync.RWMutex
-	lockDetachPort             sync.RWMutex
-	lockGetNetworkByName       sync.RWMutex
-	lockGetPort                sync.RWMutex
-	lockGetPortByTags          sync.RWMutex
-	lockGetPortsByDeviceId     sync.RWMutex
-	lockGetPortsByTags         sync.RWMutex
-	lockGetProjectByName       sync.RWMutex
-	lockGetSecurityGroupByName sync.RWMutex
-	lockGetServerByName        sync.RWMutex
-	lockGetSubnet              sync.RWMutex
-	lockGetSubnetByName        sync.RWMutex
+	lockAssignPort                     sync.RWMutex
+	lockClients                        sync.RWMutex
+	lockCreatePort                     sync.RWMutex
+	lockCreateSecurityGroup            sync.RWMutex
+	lockDeletePort                     sync.RWMutex
+	lockDetachPort                     sync.RWMutex
+	lockGetNetwork                     sync.RWMutex
+	lockGetNetworkByName               sync.RWMutex
+	lockGetPort                        sync.RWMutex
+	lockGetPortByTags                  sync.RWMutex
+	lockGetPortWithBinding             sync.RWMutex
+	lockGetPortsByDeviceId             sync.RWMutex
+	lockGetPortsByTags                 sync.RWMutex
+	lockGetProjectByName               sync.RWMutex
+	lockGetSecurityGroupByName         sync.RWMutex
+	lockGetSecurityGroupsByName        sync.RWMutex
+	lockGetServer                      sync.RWMutex
+	lockGetServerByName                sync.RWMutex
+	lockGetSubnet                      sync.RWMutex
+	lockGetSubnetByName                sync.RWMutex
+	lockSetPortTags                    sync.RWMutex
+	lockUpdateSecurityGroupDescription sync.RWMutex
 }
 
 // AssignPort calls AssignPortFunc.
-func (mock *OpenstackClientMock) AssignPort(portId string, serverId string) (*attachinterfaces.Interface, error) {
+func (mock *OpenstackClientMock) AssignPort(ctx context.Context, portId string, serverId string) (*attachinterfaces.Interface, error) {
 	if mock.AssignPortFunc == nil {
 		panic("OpenstackClientMock.AssignPortFunc: method is nil but OpenstackClient.AssignPort was just called")
 	}
 	callInfo := struct {
+		Ctx      context.Context
 		PortId   string
 		ServerId string
 	}{
+		Ctx:      ctx,
 		PortId:   portId,
 		ServerId: serverId,
 	}
 	mock.lockAssignPort.Lock()
 	mock.calls.AssignPort = append(mock.calls.AssignPort, callInfo)
 	mock.lockAssignPort.Unlock()
-	return mock.AssignPortFunc(portId, serverId)
+	return mock.AssignPortFunc(ctx, portId, serverId)
 }
 
 // AssignPortCalls gets all the calls that were made to AssignPort.
@@ -248,10 +384,12 @@ func (mock *OpenstackClientMock) AssignPort(portId string, serverId string) (*at
 //
 //	len(mockedOpenstackClient.AssignPortCalls())
 func (mock *OpenstackClientMock) AssignPortCalls() []struct {
+	Ctx      context.Context
 	PortId   string
 	ServerId string
 } {
 	var calls []struct {
+		Ctx      context.Context
 		PortId   string
 		ServerId string
 	}
@@ -289,21 +427,23 @@ func (mock *OpenstackClientMock) ClientsCalls() []struct {
 }
 
 // CreatePort calls CreatePortFunc.
-func (mock *OpenstackClientMock) CreatePort(opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) {
+func (mock *OpenstackClientMock) CreatePort(ctx context.Context, opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) {
 	if mock.CreatePortFunc == nil {
 		panic("OpenstackClientMock.CreatePortFunc: method is nil but OpenstackClient.CreatePort was just called")
 	}
 	callInfo := struct {
+		Ctx       context.Context
 		Opts      ports.CreateOpts
 		ExtraOpts *openstack.ExtraCreatePortOpts
 	}{
+		Ctx:       ctx,
 		Opts:      opts,
 		ExtraOpts: extraOpts,
 	}
 	mock.lockCreatePort.Lock()
 	mock.calls.CreatePort = append(mock.calls.CreatePort, callInfo)
 	mock.lockCreatePort.Unlock()
-	return mock.CreatePortFunc(opts, extraOpts)
+	return mock.CreatePortFunc(ctx, opts, extraOpts)
 }
 
 // CreatePortCalls gets all the calls that were made to CreatePort.
@@ -311,10 +451,12 @@ func (mock *OpenstackClientMock) CreatePort(opts ports.CreateOpts, extraOpts *op
 //
 //	len(mockedOpenstackClient.CreatePortCalls())
 func (mock *OpenstackClientMock) CreatePortCalls() []struct {
+	Ctx       context.Context
 	Opts      ports.CreateOpts
 	ExtraOpts *openstack.ExtraCreatePortOpts
 } {
 	var calls []struct {
+		Ctx       context.Context
 		Opts      ports.CreateOpts
 		ExtraOpts *openstack.ExtraCreatePortOpts
 	}
@@ -324,20 +466,62 @@ func (mock *OpenstackClientMock) CreatePortCalls() []struct {
 	return calls
 }
 
+// CreateSecurityGroup calls CreateSecurityGroupFunc.
+func (mock *OpenstackClientMock) CreateSecurityGroup(ctx context.Context, name string, description string) (*openstack.SecurityGroup, error) {
+	if mock.CreateSecurityGroupFunc == nil {
+		panic("OpenstackClientMock.CreateSecurityGroupFunc: method is nil but OpenstackClient.CreateSecurityGroup was just called")
+	}
+	callInfo := struct {
+		Ctx         context.Context
+		Name        string
+		Description string
+	}{
+		Ctx:         ctx,
+		Name:        name,
+		Description: description,
+	}
+	mock.lockCreateSecurityGroup.Lock()
+	mock.calls.CreateSecurityGroup = append(mock.calls.CreateSecurityGroup, callInfo)
+	mock.lockCreateSecurityGroup.Unlock()
+	return mock.CreateSecurityGroupFunc(ctx, name, description)
+}
+
+// CreateSecurityGroupCalls gets all the calls that were made to CreateSecurityGroup.
+// Check the length with:
+//
+//	len(mockedOpenstackClient.CreateSecurityGroupCalls())
+func (mock *OpenstackClientMock) CreateSecurityGroupCalls() []struct {
+	Ctx         context.Context
+	Name        string
+	Description string
+} {
+	var calls []struct {
+		Ctx         context.Context
+		Name        string
+		Description string
+	}
+	mock.lockCreateSecurityGroup.RLock()
+	calls = mock.calls.CreateSecurityGroup
+	mock.lockCreateSecurityGroup.RUnlock()
+	return calls
+}
+
 // DeletePort calls DeletePortFunc.
-func (mock *OpenstackClientMock) DeletePort(portId string) error {
+func (mock *OpenstackClientMock) DeletePort(ctx context.Context, portId string) error {
 	if mock.DeletePortFunc == nil {
 		panic("OpenstackClientMock.DeletePortFunc: method is nil but OpenstackClient.DeletePort was just called")
 	}
 	callInfo := struct {
+		Ctx    context.Context
 		PortId string
 	}{
+		Ctx:    ctx,
 		PortId: portId,
 	}
 	mock.lockDeletePort.Lock()
 	mock.calls.DeletePort = append(mock.calls.DeletePort, callInfo)
 	mock.lockDeletePort.Unlock()
-	return mock.DeletePortFunc(portId)
+	return mock.DeletePortFunc(ctx, portId)
 }
 
 // DeletePortCalls gets all the calls that were made to DeletePort.
@@ -345,9 +529,11 @@ func (mock *OpenstackClientMock) DeletePort(portId string) error {
 //
 //	len(mockedOpenstackClient.DeletePortCalls())
 func (mock *OpenstackClientMock) DeletePortCalls() []struct {
+	Ctx    context.Context
 	PortId string
 } {
 	var calls []struct {
+		Ctx    context.Context
 		PortId string
 	}
 	mock.lockDeletePort.RLock()
@@ -357,21 +543,23 @@ func (mock *OpenstackClientMock) DeletePortCalls() []struct {
 }
 
 // DetachPort calls DetachPortFunc.
-func (mock *OpenstackClientMock) DetachPort(portId string, serverId string) error {
+func (mock *OpenstackClientMock) DetachPort(ctx context.Context, portId string, serverId string) error {
 	if mock.DetachPortFunc == nil {
 		panic("OpenstackClientMock.DetachPortFunc: method is nil but OpenstackClient.DetachPort was just called")
 	}
 	callInfo := struct {
+		Ctx      context.Context
 		PortId   string
 		ServerId string
 	}{
+		Ctx:      ctx,
 		PortId:   portId,
 		ServerId: serverId,
 	}
 	mock.lockDetachPort.Lock()
 	mock.calls.DetachPort = append(mock.calls.DetachPort, callInfo)
 	mock.lockDetachPort.Unlock()
-	return mock.DetachPortFunc(portId, serverId)
+	return mock.DetachPortFunc(ctx, portId, serverId)
 }
 
 // DetachPortCalls gets all the calls that were made to DetachPort.
@@ -379,10 +567,12 @@ func (mock *OpenstackClientMock) DetachPort(portId string, serverId string) erro
 //
 //	len(mockedOpenstackClient.DetachPortCalls())
 func (mock *OpenstackClientMock) DetachPortCalls() []struct {
+	Ctx      context.Context
 	PortId   string
 	ServerId string
 } {
 	var calls []struct {
+		Ctx      context.Context
 		PortId   string
 		ServerId string
 	}
@@ -392,20 +582,58 @@ func (mock *OpenstackClientMock) DetachPortCalls() []struct {
 	return calls
 }
 
+// GetNetwork calls GetNetworkFunc.
+func (mock *OpenstackClientMock) GetNetwork(ctx context.Context, id string) (*openstack.Network, error) {
+	if mock.GetNetworkFunc == nil {
+		panic("OpenstackClientMock.GetNetworkFunc: method is nil but OpenstackClient.GetNetwork was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockGetNetwork.Lock()
+	mock.calls.GetNetwork = append(mock.calls.GetNetwork, callInfo)
+	mock.lockGetNetwork.Unlock()
+	return mock.GetNetworkFunc(ctx, id)
+}
+
+// GetNetworkCalls gets all the calls that were made to GetNetwork.
+// Check the length with:
+//
+//	len(mockedOpenstackClient.GetNetworkCalls())
+func (mock *OpenstackClientMock) GetNetworkCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockGetNetwork.RLock()
+	calls = mock.calls.GetNetwork
+	mock.lockGetNetwork.RUnlock()
+	return calls
+}
+
 // GetNetworkByName calls GetNetworkByNameFunc.
-func (mock *OpenstackClientMock) GetNetworkByName(name string) (*networks.Network, error) {
+func (mock *OpenstackClientMock) GetNetworkByName(ctx context.Context, name string) (*openstack.Network, error) {
 	if mock.GetNetworkByNameFunc == nil {
 		panic("OpenstackClientMock.GetNetworkByNameFunc: method is nil but OpenstackClient.GetNetworkByName was just called")
 	}
 	callInfo := struct {
+		Ctx  context.Context
 		Name string
 	}{
+		Ctx:  ctx,
 		Name: name,
 	}
 	mock.lockGetNetworkByName.Lock()
 	mock.calls.GetNetworkByName = append(mock.calls.GetNetworkByName, callInfo)
 	mock.lockGetNetworkByName.Unlock()
-	return mock.GetNetworkByNameFunc(name)
+	return mock.GetNetworkByNameFunc(ctx, name)
 }
 
 // GetNetworkByNameCalls gets all the calls that were made to GetNetworkByName.
@@ -413,9 +641,11 @@ func (mock *OpenstackClientMock) GetNetworkByName(name string) (*networks.Networ
 //
 //	len(mockedOpenstackClient.GetNetworkByNameCalls())
 func (mock *OpenstackClientMock) GetNetworkByNameCalls() []struct {
+	Ctx  context.Context
 	Name string
 } {
 	var calls []struct {
+		Ctx  context.Context
 		Name string
 	}
 	mock.lockGetNetworkByName.RLock()
@@ -425,19 +655,21 @@ func (mock *OpenstackClientMock) GetNetworkByNameCalls() []struct {
 }
 
 // GetPort calls GetPortFunc.
-func (mock *OpenstackClientMock) GetPort(portId string) (*ports.Port, error) {
+func (mock *OpenstackClientMock) GetPort(ctx context.Context, portId string) (*ports.Port, error) {
 	if mock.GetPortFunc == nil {
 		panic("OpenstackClientMock.GetPortFunc: method is nil but OpenstackClient.GetPort was just called")
 	}
 	callInfo := struct {
+		Ctx    context.Context
 		PortId string
 	}{
+		Ctx:    ctx,
 		PortId: portId,
 	}
 	mock.lockGetPort.Lock()
 	mock.calls.GetPort = append(mock.calls.GetPort, callInfo)
 	mock.lockGetPort.Unlock()
-	return mock.GetPortFunc(portId)
+	return mock.GetPortFunc(ctx, portId)
 }
 
 // GetPortCalls gets all the calls that were made to GetPort.
@@ -445,9 +677,11 @@ func (mock *OpenstackClientMock) GetPort(portId string) (*ports.Port, error) {
 //
 //	len(mockedOpenstackClient.GetPortCalls())
 func (mock *OpenstackClientMock) GetPortCalls() []struct {
+	Ctx    context.Context
 	PortId string
 } {
 	var calls []struct {
+		Ctx    context.Context
 		PortId string
 	}
 	mock.lockGetPort.RLock()
@@ -457,19 +691,21 @@ func (mock *OpenstackClientMock) GetPortCalls() []struct {
 }
 
 // GetPortByTags calls GetPortByTagsFunc.
-func (mock *OpenstackClientMock) GetPortByTags(tags []string) (*ports.Port, error) {
+func (mock *OpenstackClientMock) GetPortByTags(ctx context.Context, tags []string) (*ports.Port, error) {
 	if mock.GetPortByTagsFunc == nil {
 		panic("OpenstackClientMock.GetPortByTagsFunc: method is nil but OpenstackClient.GetPortByTags was just called")
 	}
 	callInfo := struct {
+		Ctx  context.Context
 		Tags []string
 	}{
+		Ctx:  ctx,
 		Tags: tags,
 	}
 	mock.lockGetPortByTags.Lock()
 	mock.calls.GetPortByTags = append(mock.calls.GetPortByTags, callInfo)
 	mock.lockGetPortByTags.Unlock()
-	return mock.GetPortByTagsFunc(tags)
+	return mock.GetPortByTagsFunc(ctx, tags)
 }
 
 // GetPortByTagsCalls gets all the calls that were made to GetPortByTags.
@@ -477,9 +713,11 @@ func (mock *OpenstackClientMock) GetPortByTags(tags []string) (*ports.Port, erro
 //
 //	len(mockedOpenstackClient.GetPortByTagsCalls())
 func (mock *OpenstackClientMock) GetPortByTagsCalls() []struct {
+	Ctx  context.Context
 	Tags []string
 } {
 	var calls []struct {
+		Ctx  context.Context
 		Tags []string
 	}
 	mock.lockGetPortByTags.RLock()
@@ -488,20 +726,58 @@ func (mock *OpenstackClientMock) GetPortByTagsCalls() []struct {
 	return calls
 }
 
+// GetPortWithBinding calls GetPortWithBindingFunc.
+func (mock *OpenstackClientMock) GetPortWithBinding(ctx context.Context, portId string) (*openstack.PortWithBinding, error) {
+	if mock.GetPortWithBindingFunc == nil {
+		panic("OpenstackClientMock.GetPortWithBindingFunc: method is nil but OpenstackClient.GetPortWithBinding was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		PortId string
+	}{
+		Ctx:    ctx,
+		PortId: portId,
+	}
+	mock.lockGetPortWithBinding.Lock()
+	mock.calls.GetPortWithBinding = append(mock.calls.GetPortWithBinding, callInfo)
+	mock.lockGetPortWithBinding.Unlock()
+	return mock.GetPortWithBindingFunc(ctx, portId)
+}
+
+// GetPortWithBindingCalls gets all the calls that were made to GetPortWithBinding.
+// Check the length with:
+//
+//	len(mockedOpenstackClient.GetPortWithBindingCalls())
+func (mock *OpenstackClientMock) GetPortWithBindingCalls() []struct {
+	Ctx    context.Context
+	PortId string
+} {
+	var calls []struct {
+		Ctx    context.Context
+		PortId string
+	}
+	mock.lockGetPortWithBinding.RLock()
+	calls = mock.calls.GetPortWithBinding
+	mock.lockGetPortWithBinding.RUnlock()
+	return calls
+}
+
 // GetPortsByDeviceId calls GetPortsByDeviceIdFunc.
-func (mock *OpenstackClientMock) GetPortsByDeviceId(deviceId string) ([]ports.Port, error) {
+func (mock *OpenstackClientMock) GetPortsByDeviceId(ctx context.Context, deviceId string) ([]ports.Port, error) {
 	if mock.GetPortsByDeviceIdFunc == nil {
 		panic("OpenstackClientMock.GetPortsByDeviceIdFunc: method is nil but OpenstackClient.GetPortsByDeviceId was just called")
 	}
 	callInfo := struct {
+		Ctx      context.Context
 		DeviceId string
 	}{
+		Ctx:      ctx,
 		DeviceId: deviceId,
 	}
 	mock.lockGetPortsByDeviceId.Lock()
 	mock.calls.GetPortsByDeviceId = append(mock.calls.GetPortsByDeviceId, callInfo)
 	mock.lockGetPortsByDeviceId.Unlock()
-	return mock.GetPortsByDeviceIdFunc(deviceId)
+	return mock.GetPortsByDeviceIdFunc(ctx, deviceId)
 }
 
 // GetPortsByDeviceIdCalls gets all the calls that were made to GetPortsByDeviceId.
@@ -509,9 +785,11 @@ func (mock *OpenstackClientMock) GetPortsByDeviceId(deviceId string) ([]ports.Po
 //
 //	len(mockedOpenstackClient.GetPortsByDeviceIdCalls())
 func (mock *OpenstackClientMock) GetPortsByDeviceIdCalls() []struct {
+	Ctx      context.Context
 	DeviceId string
 } {
 	var calls []struct {
+		Ctx      context.Context
 		DeviceId string
 	}
 	mock.lockGetPortsByDeviceId.RLock()
@@ -521,19 +799,21 @@ func (mock *OpenstackClientMock) GetPortsByDeviceIdCalls() []struct {
 }
 
 // GetPortsByTags calls GetPortsByTagsFunc.
-func (mock *OpenstackClientMock) GetPortsByTags(tags []string) ([]ports.Port, error) {
+func (mock *OpenstackClientMock) GetPortsByTags(ctx context.Context, tags []string) ([]ports.Port, error) {
 	if mock.GetPortsByTagsFunc == nil {
 		panic("OpenstackClientMock.GetPortsByTagsFunc: method is nil but OpenstackClient.GetPortsByTags was just called")
 	}
 	callInfo := struct {
+		Ctx  context.Context
 		Tags []string
 	}{
+		Ctx:  ctx,
 		Tags: tags,
 	}
 	mock.lockGetPortsByTags.Lock()
 	mock.calls.GetPortsByTags = append(mock.calls.GetPortsByTags, callInfo)
 	mock.lockGetPortsByTags.Unlock()
-	return mock.GetPortsByTagsFunc(tags)
+	return mock.GetPortsByTagsFunc(ctx, tags)
 }
 
 // GetPortsByTagsCalls gets all the calls that were made to GetPortsByTags.
@@ -541,9 +821,11 @@ func (mock *OpenstackClientMock) GetPortsByTags(tags []string) ([]ports.Port, er
 //
 //	len(mockedOpenstackClient.GetPortsByTagsCalls())
 func (mock *OpenstackClientMock) GetPortsByTagsCalls() []struct {
+	Ctx  context.Context
 	Tags []string
 } {
 	var calls []struct {
+		Ctx  context.Context
 		Tags []string
 	}
 	mock.lockGetPortsByTags.RLock()
@@ -553,19 +835,21 @@ func (mock *OpenstackClientMock) GetPortsByTagsCalls() []struct {
 }
 
 // GetProjectByName calls GetProjectByNameFunc.
-func (mock *OpenstackClientMock) GetProjectByName(name string) (*projects.Project, error) {
+func (mock *OpenstackClientMock) GetProjectByName(ctx context.Context, name string) (*projects.Project, error) {
 	if mock.GetProjectByNameFunc == nil {
 		panic("OpenstackClientMock.GetProjectByNameFunc: method is nil but OpenstackClient.GetProjectByName was just called")
 	}
 	callInfo := struct {
+		Ctx  context.Context
 		Name string
 	}{
+		Ctx:  ctx,
 		Name: name,
 	}
 	mock.lockGetProjectByName.Lock()
 	mock.calls.GetProjectByName = append(mock.calls.GetProjectByName, callInfo)
 	mock.lockGetProjectByName.Unlock()
-	return mock.GetProjectByNameFunc(name)
+	return mock.GetProjectByNameFunc(ctx, name)
 }
 
 // GetProjectByNameCalls gets all the calls that were made to GetProjectByName.
@@ -573,9 +857,11 @@ func (mock *OpenstackClientMock) GetProjectByName(name string) (*projects.Projec
 //
 //	len(mockedOpenstackClient.GetProjectByNameCalls())
 func (mock *OpenstackClientMock) GetProjectByNameCalls() []struct {
+	Ctx  context.Context
 	Name string
 } {
 	var calls []struct {
+		Ctx  context.Context
 		Name string
 	}
 	mock.lockGetProjectByName.RLock()
@@ -585,21 +871,23 @@ func (mock *OpenstackClientMock) GetProjectByNameCalls() []struct {
 }
 
 // GetSecurityGroupByName calls GetSecurityGroupByNameFunc.
-func (mock *OpenstackClientMock) GetSecurityGroupByName(name string, projectId string) (*groups.SecGroup, error) {
+func (mock *OpenstackClientMock) GetSecurityGroupByName(ctx context.Context, name string, projectId string) (*groups.SecGroup, error) {
 	if mock.GetSecurityGroupByNameFunc == nil {
 		panic("OpenstackClientMock.GetSecurityGroupByNameFunc: method is nil but OpenstackClient.GetSecurityGroupByName was just called")
 	}
 	callInfo := struct {
+		Ctx       context.Context
 		Name      string
 		ProjectId string
 	}{
+		Ctx:       ctx,
 		Name:      name,
 		ProjectId: projectId,
 	}
 	mock.lockGetSecurityGroupByName.Lock()
 	mock.calls.GetSecurityGroupByName = append(mock.calls.GetSecurityGroupByName, callInfo)
 	mock.lockGetSecurityGroupByName.Unlock()
-	return mock.GetSecurityGroupByNameFunc(name, projectId)
+	return mock.GetSecurityGroupByNameFunc(ctx, name, projectId)
 }
 
 // GetSecurityGroupByNameCalls gets all the calls that were made to GetSecurityGroupByName.
@@ -607,10 +895,12 @@ func (mock *OpenstackClientMock) GetSecurityGroupByName(name string, projectId s
 //
 //	len(mockedOpenstackClient.GetSecurityGroupByNameCalls())
 func (mock *OpenstackClientMock) GetSecurityGroupByNameCalls() []struct {
+	Ctx       context.Context
 	Name      string
 	ProjectId string
 } {
 	var calls []struct {
+		Ctx       context.Context
 		Name      string
 		ProjectId string
 	}
@@ -620,20 +910,94 @@ func (mock *OpenstackClientMock) GetSecurityGroupByNameCalls() []struct {
 	return calls
 }
 
+// GetSecurityGroupsByName calls GetSecurityGroupsByNameFunc.
+func (mock *OpenstackClientMock) GetSecurityGroupsByName(ctx context.Context, name string) ([]openstack.SecurityGroup, error) {
+	if mock.GetSecurityGroupsByNameFunc == nil {
+		panic("OpenstackClientMock.GetSecurityGroupsByNameFunc: method is nil but OpenstackClient.GetSecurityGroupsByName was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Name string
+	}{
+		Ctx:  ctx,
+		Name: name,
+	}
+	mock.lockGetSecurityGroupsByName.Lock()
+	mock.calls.GetSecurityGroupsByName = append(mock.calls.GetSecurityGroupsByName, callInfo)
+	mock.lockGetSecurityGroupsByName.Unlock()
+	return mock.GetSecurityGroupsByNameFunc(ctx, name)
+}
+
+// GetSecurityGroupsByNameCalls gets all the calls that were made to GetSecurityGroupsByName.
+// Check the length with:
+//
+//	len(mockedOpenstackClient.GetSecurityGroupsByNameCalls())
+func (mock *OpenstackClientMock) GetSecurityGroupsByNameCalls() []struct {
+	Ctx  context.Context
+	Name string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Name string
+	}
+	mock.lockGetSecurityGroupsByName.RLock()
+	calls = mock.calls.GetSecurityGroupsByName
+	mock.lockGetSecurityGroupsByName.RUnlock()
+	return calls
+}
+
+// GetServer calls GetServerFunc.
+func (mock *OpenstackClientMock) GetServer(ctx context.Context, id string) (*servers.Server, error) {
+	if mock.GetServerFunc == nil {
+		panic("OpenstackClientMock.GetServerFunc: method is nil but OpenstackClient.GetServer was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockGetServer.Lock()
+	mock.calls.GetServer = append(mock.calls.GetServer, callInfo)
+	mock.lockGetServer.Unlock()
+	return mock.GetServerFunc(ctx, id)
+}
+
+// GetServerCalls gets all the calls that were made to GetServer.
+// Check the length with:
+//
+//	len(mockedOpenstackClient.GetServerCalls())
+func (mock *OpenstackClientMock) GetServerCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockGetServer.RLock()
+	calls = mock.calls.GetServer
+	mock.lockGetServer.RUnlock()
+	return calls
+}
+
 // GetServerByName calls GetServerByNameFunc.
-func (mock *OpenstackClientMock) GetServerByName(name string) (*servers.Server, error) {
+func (mock *OpenstackClientMock) GetServerByName(ctx context.Context, name string) (*servers.Server, error) {
 	if mock.GetServerByNameFunc == nil {
 		panic("OpenstackClientMock.GetServerByNameFunc: method is nil but OpenstackClient.GetServerByName was just called")
 	}
 	callInfo := struct {
+		Ctx  context.Context
 		Name string
 	}{
+		Ctx:  ctx,
 		Name: name,
 	}
 	mock.lockGetServerByName.Lock()
 	mock.calls.GetServerByName = append(mock.calls.GetServerByName, callInfo)
 	mock.lockGetServerByName.Unlock()
-	return mock.GetServerByNameFunc(name)
+	return mock.GetServerByNameFunc(ctx, name)
 }
 
 // GetServerByNameCalls gets all the calls that were made to GetServerByName.
@@ -641,9 +1005,11 @@ func (mock *OpenstackClientMock) GetServerByName(name string) (*servers.Server,
 //
 //	len(mockedOpenstackClient.GetServerByNameCalls())
 func (mock *OpenstackClientMock) GetServerByNameCalls() []struct {
+	Ctx  context.Context
 	Name string
 } {
 	var calls []struct {
+		Ctx  context.Context
 		Name string
 	}
 	mock.lockGetServerByName.RLock()
@@ -653,19 +1019,21 @@ func (mock *OpenstackClientMock) GetServerByNameCalls() []struct {
 }
 
 // GetSubnet calls GetSubnetFunc.
-func (mock *OpenstackClientMock) GetSubnet(id string) (*subnets.Subnet, error) {
+func (mock *OpenstackClientMock) GetSubnet(ctx context.Context, id string) (*subnets.Subnet, error) {
 	if mock.GetSubnetFunc == nil {
 		panic("OpenstackClientMock.GetSubnetFunc: method is nil but OpenstackClient.GetSubnet was just called")
 	}
 	callInfo := struct {
-		ID string
+		Ctx context.Context
+		ID  string
 	}{
-		ID: id,
+		Ctx: ctx,
+		ID:  id,
 	}
 	mock.lockGetSubnet.Lock()
 	mock.calls.GetSubnet = append(mock.calls.GetSubnet, callInfo)
 	mock.lockGetSubnet.Unlock()
-	return mock.GetSubnetFunc(id)
+	return mock.GetSubnetFunc(ctx, id)
 }
 
 // GetSubnetCalls gets all the calls that were made to GetSubnet.
@@ -673,10 +1041,12 @@ func (mock *OpenstackClientMock) GetSubnet(id string) (*subnets.Subnet, error) {
 //
 //	len(mockedOpenstackClient.GetSubnetCalls())
 func (mock *OpenstackClientMock) GetSubnetCalls() []struct {
-	ID string
+	Ctx context.Context
+	ID  string
 } {
 	var calls []struct {
-		ID string
+		Ctx context.Context
+		ID  string
 	}
 	mock.lockGetSubnet.RLock()
 	calls = mock.calls.GetSubnet
@@ -685,21 +1055,23 @@ func (mock *OpenstackClientMock) GetSubnetCalls() []struct {
 }
 
 // GetSubnetByName calls GetSubnetByNameFunc.
-func (mock *OpenstackClientMock) GetSubnetByName(name string, networkId string) (*subnets.Subnet, error) {
+func (mock *OpenstackClientMock) GetSubnetByName(ctx context.Context, name string, networkId string) (*subnets.Subnet, error) {
 	if mock.GetSubnetByNameFunc == nil {
 		panic("OpenstackClientMock.GetSubnetByNameFunc: method is nil but OpenstackClient.GetSubnetByName was just called")
 	}
 	callInfo := struct {
+		Ctx       context.Context
 		Name      string
 		NetworkId string
 	}{
+		Ctx:       ctx,
 		Name:      name,
 		NetworkId: networkId,
 	}
 	mock.lockGetSubnetByName.Lock()
 	mock.calls.GetSubnetByName = append(mock.calls.GetSubnetByName, callInfo)
 	mock.lockGetSubnetByName.Unlock()
-	return mock.GetSubnetByNameFunc(name, networkId)
+	return mock.GetSubnetByNameFunc(ctx, name, networkId)
 }
 
 // GetSubnetByNameCalls gets all the calls that were made to GetSubnetByName.
@@ -707,10 +1079,12 @@ func (mock *OpenstackClientMock) GetSubnetByName(name string, networkId string)
 //
 //	len(mockedOpenstackClient.GetSubnetByNameCalls())
 func (mock *OpenstackClientMock) GetSubnetByNameCalls() []struct {
+	Ctx       context.Context
 	Name      string
 	NetworkId string
 } {
 	var calls []struct {
+		Ctx       context.Context
 		Name      string
 		NetworkId string
 	}
@@ -719,3 +1093,87 @@ func (mock *OpenstackClientMock) GetSubnetByNameCalls() []struct {
 	mock.lockGetSubnetByName.RUnlock()
 	return calls
 }
+
+// SetPortTags calls SetPortTagsFunc.
+func (mock *OpenstackClientMock) SetPortTags(ctx context.Context, portId string, tags []string) error {
+	if mock.SetPortTagsFunc == nil {
+		panic("OpenstackClientMock.SetPortTagsFunc: method is nil but OpenstackClient.SetPortTags was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		PortId string
+		Tags   []string
+	}{
+		Ctx:    ctx,
+		PortId: portId,
+		Tags:   tags,
+	}
+	mock.lockSetPortTags.Lock()
+	mock.calls.SetPortTags = append(mock.calls.SetPortTags, callInfo)
+	mock.lockSetPortTags.Unlock()
+	return mock.SetPortTagsFunc(ctx, portId, tags)
+}
+
+// SetPortTagsCalls gets all the calls that were made to SetPortTags.
+// Check the length with:
+//
+//	len(mockedOpenstackClient.SetPortTagsCalls())
+func (mock *OpenstackClientMock) SetPortTagsCalls() []struct {
+	Ctx    context.Context
+	PortId string
+	Tags   []string
+} {
+	var calls []struct {
+		Ctx    context.Context
+		PortId string
+		Tags   []string
+	}
+	mock.lockSetPortTags.RLock()
+	calls = mock.calls.SetPortTags
+	mock.lockSetPortTags.RUnlock()
+	return calls
+}
+
+// UpdateSecurityGroupDescription calls UpdateSecurityGroupDescriptionFunc.
+func (mock *OpenstackClientMock) UpdateSecurityGroupDescription(ctx context.Context, id string, description string, revisionNumber int) error {
+	if mock.UpdateSecurityGroupDescriptionFunc == nil {
+		panic("OpenstackClientMock.UpdateSecurityGroupDescriptionFunc: method is nil but OpenstackClient.UpdateSecurityGroupDescription was just called")
+	}
+	callInfo := struct {
+		Ctx            context.Context
+		ID             string
+		Description    string
+		RevisionNumber int
+	}{
+		Ctx:            ctx,
+		ID:             id,
+		Description:    description,
+		RevisionNumber: revisionNumber,
+	}
+	mock.lockUpdateSecurityGroupDescription.Lock()
+	mock.calls.UpdateSecurityGroupDescription = append(mock.calls.UpdateSecurityGroupDescription, callInfo)
+	mock.lockUpdateSecurityGroupDescription.Unlock()
+	return mock.UpdateSecurityGroupDescriptionFunc(ctx, id, description, revisionNumber)
+}
+
+// UpdateSecurityGroupDescriptionCalls gets all the calls that were made to UpdateSecurityGroupDescription.
+// Check the length with:
+//
+//	len(mockedOpenstackClient.UpdateSecurityGroupDescriptionCalls())
+func (mock *OpenstackClientMock) UpdateSecurityGroupDescriptionCalls() []struct {
+	Ctx            context.Context
+	ID             string
+	Description    string
+	RevisionNumber int
+} {
+	var calls []struct {
+		Ctx            context.Context
+		ID             string
+		Description    string
+		RevisionNumber int
+	}
+	mock.lockUpdateSecurityGroupDescription.RLock()
+	calls = mock.calls.UpdateSecurityGroupDescription
+	mock.lockUpdateSecurityGroupDescription.RUnlock()
+	return calls
+}
@@ -22,6 +22,12 @@ var _ util.NetlinkWrapper = &NetlinkWrapperMock{}
 //			AddrAddFunc: func(link netlink.Link, addr *netlink.Addr) error {
 //				panic("mock out the AddrAdd method")
 //			},
+//			AddrDelFunc: func(link netlink.Link, addr *netlink.Addr) error {
+//				panic("mock out the AddrDel method")
+//			},
+//			AddrListFunc: func(link netlink.Link, family int) ([]netlink.Addr, error) {
+//				panic("mock out the AddrList method")
+//			},
 //			AddrReplaceFunc: func(link netlink.Link, addr *netlink.Addr) error {
 //				panic("mock out the AddrReplace method")
 //			},
@@ -37,9 +43,15 @@ var _ util.NetlinkWrapper = &NetlinkWrapperMock{}
 //			LinkByNameFunc: func(ifname string) (netlink.Link, error) {
 //				panic("mock out the LinkByName method")
 //			},
+//			LinkListFunc: func() ([]netlink.Link, error) {
+//				panic("mock out the LinkList method")
+//			},
 //			LinkSetDownFunc: func(link netlink.Link) error {
 //				panic("mock out the LinkSetDown method")
 //			},
+//			LinkSetMTUFunc: func(link netlink.Link, mtu int) error {
+//				panic("mock out the LinkSetMTU method")
+//			},
 //			LinkSetNameFunc: func(link netlink.Link, name string) error {
 //				panic("mock out the LinkSetName method")
 //			},
@@ -49,6 +61,12 @@ var _ util.NetlinkWrapper = &NetlinkWrapperMock{}
 //			LinkSetUpFunc: func(link netlink.Link) error {
 //				panic("mock out the LinkSetUp method")
 //			},
+//			LinkSubscribeFunc: func(ch chan<- netlink.LinkUpdate, done <-chan struct{}) error {
+//				panic("mock out the LinkSubscribe method")
+//			},
+//			RouteReplaceFunc: func(route *netlink.Route) error {
+//				panic("mock out the RouteReplace method")
+//			},
 //		}
 //
 //		// use mockedNetlinkWrapper in code that requires util.NetlinkWrapper
@@ -59,6 +77,12 @@ type NetlinkWrapperMock struct {
 	// AddrAddFunc mocks the AddrAdd method.
 	AddrAddFunc func(link netlink.Link, addr *netlink.Addr) error
 
+	// AddrDelFunc mocks the AddrDel method.
+	AddrDelFunc func(link netlink.Link, addr *netlink.Addr) error
+
+	// AddrListFunc mocks the AddrList method.
+	AddrListFunc func(link netlink.Link, family int) ([]netlink.Addr, error)
+
 	// AddrReplaceFunc mocks the AddrReplace method.
 	AddrReplaceFunc func(link netlink.Link, addr *netlink.Addr) error
 
@@ -74,9 +98,15 @@ type NetlinkWrapperMock struct {
 	// LinkByNameFunc mocks the LinkByName method.
 	LinkByNameFunc func(ifname string) (netlink.Link, error)
 
+	// LinkListFunc mocks the LinkList method.
+	LinkListFunc func() ([]netlink.Link, error)
+
 	// LinkSetDownFunc mocks the LinkSetDown method.
 	LinkSetDownFunc func(link netlink.Link) error
 
+	// LinkSetMTUFunc mocks the LinkSetMTU method.
+	LinkSetMTUFunc func(link netlink.Link, mtu int) error
+
 	// LinkSetNameFunc mocks the LinkSetName method.
 	LinkSetNameFunc func(link netlink.Link, name string) error
 
@@ -86,6 +116,12 @@ type NetlinkWrapperMock struct {
 	// LinkSetUpFunc mocks the LinkSetUp method.
 	LinkSetUpFunc func(link netlink.Link) error
 
+	// LinkSubscribeFunc mocks the LinkSubscribe method.
+	LinkSubscribeFunc func(ch chan<- netlink.LinkUpdate, done <-chan struct{}) error
+
+	// RouteReplaceFunc mocks the RouteReplace method.
+	RouteReplaceFunc func(route *netlink.Route) error
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// AddrAdd holds details about calls to the AddrAdd method.
@@ -95,6 +131,20 @@ type NetlinkWrapperMock struct {
 			// Addr is the addr argument value.
 			Addr *netlink.Addr
 		}
+		// AddrDel holds details about calls to the AddrDel method.
+		AddrDel []struct {
+			// Link is the link argument value.
+			Link netlink.Link
+			// Addr is the addr argument value.
+			Addr *netlink.Addr
+		}
+		// AddrList holds details about calls to the AddrList method.
+		AddrList []struct {
+			// Link is the link argument value.
+			Link netlink.Link
+			// Family is the family argument value.
+			Family int
+		}
 		// AddrReplace holds details about calls to the AddrReplace method.
 		AddrReplace []struct {
 			// Link is the link argument value.
@@ -122,11 +172,21 @@ type NetlinkWrapperMock struct {
 			// Ifname is the ifname argument value.
 			Ifname string
 		}
+		// LinkList holds details about calls to the LinkList method.
+		LinkList []struct {
+		}
 		// LinkSetDown holds details about calls to the LinkSetDown method.
 		LinkSetDown []struct {
 			// Link is the link argument value.
 			Link netlink.Link
 		}
+		// LinkSetMTU holds details about calls to the LinkSetMTU method.
+		LinkSetMTU []struct {
+			// Link is the link argument value.
+			Link netlink.Link
+			// Mtu is the mtu argument value.
+			Mtu int
+		}
 		// LinkSetName holds details about calls to the LinkSetName method.
 		LinkSetName []struct {
 			// Link is the link argument value.
@@ -146,17 +206,35 @@ type NetlinkWrapperMock struct {
 			// Link is the link argument value.
 			Link netlink.Link
 		}
+		// LinkSubscribe holds details about calls to the LinkSubscribe method.
+		LinkSubscribe []struct {
+			// Ch is the ch argument value.
+			Ch chan<- netlink.LinkUpdate
+			// Done is the done argument value.
+			Done <-chan struct{}
+		}
+		// RouteReplace holds details about calls to the RouteReplace method.
+		RouteReplace []struct {
+			// Route is the route argument value.
+			Route *netlink.Route
+		}
 	}
 	lockAddrAdd          sync.RWMutex
+	lockAddrDel          sync.RWMutex
+	lockAddrList         sync.RWMutex
 	lockAddrReplace      sync.RWMutex
 	lockGetNetNsIdByPath sync.RWMutex
 	lockGetNetNsIdByPid  sync.RWMutex
 	lockLinkByIndex      sync.RWMutex
 	lockLinkByName       sync.RWMutex
+	lockLinkList         sync.RWMutex
 	lockLinkSetDown      sync.RWMutex
+	lockLinkSetMTU       sync.RWMutex
 	lockLinkSetName      sync.RWMutex
 	lockLinkSetNsFd      sync.RWMutex
 	lockLinkSetUp        sync.RWMutex
+	lockLinkSubscribe    sync.RWMutex
+	lockRouteReplace     sync.RWMutex
 }
 
 // AddrAdd calls AddrAddFunc.
@@ -195,6 +273,78 @@ func (mock *NetlinkWrapperMock) AddrAddCalls() []struct {
 	return calls
 }
 
+// AddrDel calls AddrDelFunc.
+func (mock *NetlinkWrapperMock) AddrDel(link netlink.Link, addr *netlink.Addr) error {
+	if mock.AddrDelFunc == nil {
+		panic("NetlinkWrapperMock.AddrDelFunc: method is nil but NetlinkWrapper.AddrDel was just called")
+	}
+	callInfo := struct {
+		Link netlink.Link
+		Addr *netlink.Addr
+	}{
+		Link: link,
+		Addr: addr,
+	}
+	mock.lockAddrDel.Lock()
+	mock.calls.AddrDel = append(mock.calls.AddrDel, callInfo)
+	mock.lockAddrDel.Unlock()
+	return mock.AddrDelFunc(link, addr)
+}
+
+// AddrDelCalls gets all the calls that were made to AddrDel.
+// Check the length with:
+//
+//	len(mockedNetlinkWrapper.AddrDelCalls())
+func (mock *NetlinkWrapperMock) AddrDelCalls() []struct {
+	Link netlink.Link
+	Addr *netlink.Addr
+} {
+	var calls []struct {
+		Link netlink.Link
+		Addr *netlink.Addr
+	}
+	mock.lockAddrDel.RLock()
+	calls = mock.calls.AddrDel
+	mock.lockAddrDel.RUnlock()
+	return calls
+}
+
+// AddrList calls AddrListFunc.
+func (mock *NetlinkWrapperMock) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	if mock.AddrListFunc == nil {
+		panic("NetlinkWrapperMock.AddrListFunc: method is nil but NetlinkWrapper.AddrList was just called")
+	}
+	callInfo := struct {
+		Link   netlink.Link
+		Family int
+	}{
+		Link:   link,
+		Family: family,
+	}
+	mock.lockAddrList.Lock()
+	mock.calls.AddrList = append(mock.calls.AddrList, callInfo)
+	mock.lockAddrList.Unlock()
+	return mock.AddrListFunc(link, family)
+}
+
+// AddrListCalls gets all the calls that were made to AddrList.
+// Check the length with:
+//
+//	len(mockedNetlinkWrapper.AddrListCalls())
+func (mock *NetlinkWrapperMock) AddrListCalls() []struct {
+	Link   netlink.Link
+	Family int
+} {
+	var calls []struct {
+		Link   netlink.Link
+		Family int
+	}
+	mock.lockAddrList.RLock()
+	calls = mock.calls.AddrList
+	mock.lockAddrList.RUnlock()
+	return calls
+}
+
 // AddrReplace calls AddrReplaceFunc.
 func (mock *NetlinkWrapperMock) AddrReplace(link netlink.Link, addr *netlink.Addr) error {
 	if mock.AddrReplaceFunc == nil {
@@ -359,6 +509,33 @@ func (mock *NetlinkWrapperMock) LinkByNameCalls() []struct {
 	return calls
 }
 
+// LinkList calls LinkListFunc.
+func (mock *NetlinkWrapperMock) LinkList() ([]netlink.Link, error) {
+	if mock.LinkListFunc == nil {
+		panic("NetlinkWrapperMock.LinkListFunc: method is nil but NetlinkWrapper.LinkList was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockLinkList.Lock()
+	mock.calls.LinkList = append(mock.calls.LinkList, callInfo)
+	mock.lockLinkList.Unlock()
+	return mock.LinkListFunc()
+}
+
+// LinkListCalls gets all the calls that were made to LinkList.
+// Check the length with:
+//
+//	len(mockedNetlinkWrapper.LinkListCalls())
+func (mock *NetlinkWrapperMock) LinkListCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockLinkList.RLock()
+	calls = mock.calls.LinkList
+	mock.lockLinkList.RUnlock()
+	return calls
+}
+
 // LinkSetDown calls LinkSetDownFunc.
 func (mock *NetlinkWrapperMock) LinkSetDown(link netlink.Link) error {
 	if mock.LinkSetDownFunc == nil {
@@ -391,6 +568,42 @@ func (mock *NetlinkWrapperMock) LinkSetDownCalls() []struct {
 	return calls
 }
 
+// LinkSetMTU calls LinkSetMTUFunc.
+func (mock *NetlinkWrapperMock) LinkSetMTU(link netlink.Link, mtu int) error {
+	if mock.LinkSetMTUFunc == nil {
+		panic("NetlinkWrapperMock.LinkSetMTUFunc: method is nil but NetlinkWrapper.LinkSetMTU was just called")
+	}
+	callInfo := struct {
+		Link netlink.Link
+		Mtu  int
+	}{
+		Link: link,
+		Mtu:  mtu,
+	}
+	mock.lockLinkSetMTU.Lock()
+	mock.calls.LinkSetMTU = append(mock.calls.LinkSetMTU, callInfo)
+	mock.lockLinkSetMTU.Unlock()
+	return mock.LinkSetMTUFunc(link, mtu)
+}
+
+// LinkSetMTUCalls gets all the calls that were made to LinkSetMTU.
+// Check the length with:
+//
+//	len(mockedNetlinkWrapper.LinkSetMTUCalls())
+func (mock *NetlinkWrapperMock) LinkSetMTUCalls() []struct {
+	Link netlink.Link
+	Mtu  int
+} {
+	var calls []struct {
+		Link netlink.Link
+		Mtu  int
+	}
+	mock.lockLinkSetMTU.RLock()
+	calls = mock.calls.LinkSetMTU
+	mock.lockLinkSetMTU.RUnlock()
+	return calls
+}
+
 // LinkSetName calls LinkSetNameFunc.
 func (mock *NetlinkWrapperMock) LinkSetName(link netlink.Link, name string) error {
 	if mock.LinkSetNameFunc == nil {
@@ -494,3 +707,71 @@ func (mock *NetlinkWrapperMock) LinkSetUpCalls() []struct {
 	mock.lockLinkSetUp.RUnlock()
 	return calls
 }
+
+// LinkSubscribe calls LinkSubscribeFunc.
+func (mock *NetlinkWrapperMock) LinkSubscribe(ch chan<- netlink.LinkUpdate, done <-chan struct{}) error {
+	if mock.LinkSubscribeFunc == nil {
+		panic("NetlinkWrapperMock.LinkSubscribeFunc: method is nil but NetlinkWrapper.LinkSubscribe was just called")
+	}
+	callInfo := struct {
+		Ch   chan<- netlink.LinkUpdate
+		Done <-chan struct{}
+	}{
+		Ch:   ch,
+		Done: done,
+	}
+	mock.lockLinkSubscribe.Lock()
+	mock.calls.LinkSubscribe = append(mock.calls.LinkSubscribe, callInfo)
+	mock.lockLinkSubscribe.Unlock()
+	return mock.LinkSubscribeFunc(ch, done)
+}
+
+// LinkSubscribeCalls gets all the calls that were made to LinkSubscribe.
+// Check the length with:
+//
+//	len(mockedNetlinkWrapper.LinkSubscribeCalls())
+func (mock *NetlinkWrapperMock) LinkSubscribeCalls() []struct {
+	Ch   chan<- netlink.LinkUpdate
+	Done <-chan struct{}
+} {
+	var calls []struct {
+		Ch   chan<- netlink.LinkUpdate
+		Done <-chan struct{}
+	}
+	mock.lockLinkSubscribe.RLock()
+	calls = mock.calls.LinkSubscribe
+	mock.lockLinkSubscribe.RUnlock()
+	return calls
+}
+
+// RouteReplace calls RouteReplaceFunc.
+func (mock *NetlinkWrapperMock) RouteReplace(route *netlink.Route) error {
+	if mock.RouteReplaceFunc == nil {
+		panic("NetlinkWrapperMock.RouteReplaceFunc: method is nil but NetlinkWrapper.RouteReplace was just called")
+	}
+	callInfo := struct {
+		Route *netlink.Route
+	}{
+		Route: route,
+	}
+	mock.lockRouteReplace.Lock()
+	mock.calls.RouteReplace = append(mock.calls.RouteReplace, callInfo)
+	mock.lockRouteReplace.Unlock()
+	return mock.RouteReplaceFunc(route)
+}
+
+// RouteReplaceCalls gets all the calls that were made to RouteReplace.
+// Check the length with:
+//
+//	len(mockedNetlinkWrapper.RouteReplaceCalls())
+func (mock *NetlinkWrapperMock) RouteReplaceCalls() []struct {
+	Route *netlink.Route
+} {
+	var calls []struct {
+		Route *netlink.Route
+	}
+	mock.lockRouteReplace.RLock()
+	calls = mock.calls.RouteReplace
+	mock.lockRouteReplace.RUnlock()
+	return calls
+}
@@ -3,7 +3,8 @@ package openstack
 import (
 	"context"
 	"fmt"
-	"strings"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	cache "github.com/Code-Hex/go-generics-cache"
@@ -11,27 +12,73 @@ import (
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
-	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
+	"golang.org/x/sync/singleflight"
 )
 
 var _ OpenstackClient = &CachedClient{}
 
+// DefaultNegativeExpiration is how long NotFound errors are cached unless configured otherwise
+const DefaultNegativeExpiration = 5 * time.Second
+
+// DefaultLookupTimeout bounds a shared lookup unless configured otherwise
+const DefaultLookupTimeout = 30 * time.Second
+
+// CachedClient caches the lookups of OpenStack resources
+// every resource has its own cache keyed by all the arguments of its lookup,
+// concurrent lookups of the same key are collapsed into a single request
+// and NotFound errors are cached for NegativeExpiration, 0 disables caching them
+// a shared lookup isn't cancelled by its callers, LookupTimeout keeps a hung one from blocking its key
+// port entries are invalidated when ports are created, assigned, detached or deleted through the client
 type CachedClient struct {
-	OpenstackClient OpenstackClient
-	Expiration      time.Duration
-	cash            *cache.Cache[string, any]
-	cancelFunc      context.CancelFunc
+	OpenstackClient    OpenstackClient
+	Expiration         time.Duration
+	NegativeExpiration time.Duration
+	LookupTimeout      time.Duration
+	Metrics            CacheMetrics
+	networks           *resourceCache[string, *Network]
+	networksByName     *resourceCache[string, *Network]
+	ports              *resourceCache[string, *ports.Port]
+	portsByDevice      *resourceCache[string, []ports.Port]
+	projects           *resourceCache[string, *projects.Project]
+	servers            *resourceCache[string, *servers.Server]
+	serversByName      *resourceCache[string, *servers.Server]
+	securityGroups     *resourceCache[securityGroupKey, *groups.SecGroup]
+	subnets            *resourceCache[string, *subnets.Subnet]
+	subnetsByName      *resourceCache[subnetKey, *subnets.Subnet]
+	cancelFunc         context.CancelFunc
+}
+
+// security groups are looked up by name within a project
+type securityGroupKey struct {
+	Name      string
+	ProjectId string
 }
 
-func NewCachedClient(client OpenstackClient, expiration time.Duration) OpenstackClient {
+// subnets are looked up by name within a network
+type subnetKey struct {
+	Name      string
+	NetworkId string
+}
+
+func NewCachedClient(client OpenstackClient, expiration time.Duration) *CachedClient {
 	ctx, cancel := context.WithCancel(context.Background())
-	cash := cache.NewContext[string, any](ctx)
 	return &CachedClient{OpenstackClient: client,
-		Expiration: expiration,
-		cash:       cash,
-		cancelFunc: cancel,
+		Expiration:         expiration,
+		NegativeExpiration: DefaultNegativeExpiration,
+		LookupTimeout:      DefaultLookupTimeout,
+		networks:           newResourceCache[string, *Network](ctx, "GetNetwork"),
+		networksByName:     newResourceCache[string, *Network](ctx, "GetNetworkByName"),
+		ports:              newResourceCache[string, *ports.Port](ctx, "GetPort"),
+		portsByDevice:      newResourceCache[string, []ports.Port](ctx, "GetPortsByDeviceId"),
+		projects:           newResourceCache[string, *projects.Project](ctx, "GetProjectByName"),
+		servers:            newResourceCache[string, *servers.Server](ctx, "GetServer"),
+		serversByName:      newResourceCache[string, *servers.Server](ctx, "GetServerByName"),
+		securityGroups:     newResourceCache[securityGroupKey, *groups.SecGroup](ctx, "GetSecurityGroupByName"),
+		subnets:            newResourceCache[string, *subnets.Subnet](ctx, "GetSubnet"),
+		subnetsByName:      newResourceCache[subnetKey, *subnets.Subnet](ctx, "GetSubnetByName"),
+		cancelFunc:         cancel,
 	}
 }
 
@@ -39,9 +86,25 @@ func (me *CachedClient) Stop() {
 	me.cancelFunc()
 }
 
+// Flush drops every cached entry
+func (me *CachedClient) Flush() {
+	me.networks.flush()
+	me.networksByName.flush()
+	me.ports.flush()
+	me.portsByDevice.flush()
+	me.projects.flush()
+	me.servers.flush()
+	me.serversByName.flush()
+	me.securityGroups.flush()
+	me.subnets.flush()
+	me.subnetsByName.flush()
+}
+
 // AssignPort attaches a port to a server
-func (me *CachedClient) AssignPort(portId, serverId string) (*attachinterfaces.Interface, error) {
-	return me.OpenstackClient.AssignPort(portId, serverId)
+func (me *CachedClient) AssignPort(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error) {
+	// the attachment may have happened even when an error is returned
+	defer me.invalidatePort(portId, serverId)
+	return me.OpenstackClient.AssignPort(ctx, portId, serverId)
 }
 
 func (me *CachedClient) Clients() *ApiClients {
@@ -49,138 +112,229 @@ func (me *CachedClient) Clients() *ApiClients {
 }
 
 // CreatePort creates a neutron port inside of the specified network
-func (me *CachedClient) CreatePort(opts ports.CreateOpts, extraOpts *ExtraCreatePortOpts) (*ports.Port, error) {
-	return me.OpenstackClient.CreatePort(opts, extraOpts)
+func (me *CachedClient) CreatePort(ctx context.Context, opts ports.CreateOpts, extraOpts *ExtraCreatePortOpts) (*ports.Port, error) {
+	port, err := me.OpenstackClient.CreatePort(ctx, opts, extraOpts)
+	if err == nil {
+		me.invalidatePort(port.ID, port.DeviceID)
+	}
+	return port, err
 }
 
-// DeletePort deletes the port
-func (me *CachedClient) DeletePort(portId string) error {
-	// find all of the ports with the portId and delete them from the cache
-	//
-	// Note: this isn't he most efficient way to go about it, but it's significantly easier
-	// implement and understand
-	keys := me.cash.Keys()
-	for _, key := range keys {
-		// only look at keys having to do with Ports
-		if !strings.HasPrefix(key, "GetPort") {
-			continue
-		}
+// CreateSecurityGroup isn't cached, like the other security group calls of the lease
+func (me *CachedClient) CreateSecurityGroup(ctx context.Context, name, description string) (*SecurityGroup, error) {
+	return me.OpenstackClient.CreateSecurityGroup(ctx, name, description)
+}
 
-		// if we find a port with a matching id, delete it
-		// if we find a slice of ports, deleting the one that has a matchind id
-		val, ok := me.cash.Get(key)
-		if !ok {
-			continue
-		}
+// DeletePort deletes the port
+func (me *CachedClient) DeletePort(ctx context.Context, portId string) error {
+	defer me.invalidatePort(portId, "")
+	return me.OpenstackClient.DeletePort(ctx, portId)
+}
 
-		switch v := val.(type) {
-		case *ports.Port:
-			if v.ID == portId {
-				me.cash.Delete(key)
-			}
-		case []ports.Port:
-			for _, port := range v {
-				if port.ID == portId {
-					me.cash.Delete(key)
-				}
-			}
-		}
-	}
+// Detach port removes a port's relationship from a server
+func (me *CachedClient) DetachPort(ctx context.Context, portId, serverId string) error {
+	defer me.invalidatePort(portId, serverId)
+	return me.OpenstackClient.DetachPort(ctx, portId, serverId)
+}
 
-	return me.OpenstackClient.DeletePort(portId)
+// invalidatePort drops the port and every list of ports containing it, along with the ports of deviceId
+func (me *CachedClient) invalidatePort(portId, deviceId string) {
+	me.ports.invalidate(portId)
+	me.portsByDevice.invalidateFunc(func(key string, value []ports.Port) bool {
+		return key == deviceId || slices.ContainsFunc(value, func(port ports.Port) bool {
+			return port.ID == portId
+		})
+	})
 }
 
-// Detach port removes a port's relationship from a server
-func (me *CachedClient) DetachPort(portId, serverId string) error {
-	return me.OpenstackClient.DetachPort(portId, serverId)
+func (me *CachedClient) GetNetwork(ctx context.Context, id string) (*Network, error) {
+	return me.networks.get(ctx, me, id, func(ctx context.Context) (*Network, error) {
+		return me.OpenstackClient.GetNetwork(ctx, id)
+	})
 }
 
-func (me *CachedClient) GetNetworkByName(name string) (*networks.Network, error) {
-	return getPtrValue[networks.Network](me.cash, makeKey("GetNetworkByName", name), me.Expiration, func() (any, error) {
-		return me.OpenstackClient.GetNetworkByName(name)
+func (me *CachedClient) GetNetworkByName(ctx context.Context, name string) (*Network, error) {
+	return me.networksByName.get(ctx, me, name, func(ctx context.Context) (*Network, error) {
+		return me.OpenstackClient.GetNetworkByName(ctx, name)
 	})
 }
 
-func (me *CachedClient) GetPort(portId string) (*ports.Port, error) {
-	return getPtrValue[ports.Port](me.cash, makeKey("GetPort", portId), me.Expiration, func() (any, error) {
-		return me.OpenstackClient.GetPort(portId)
+func (me *CachedClient) GetPort(ctx context.Context, portId string) (*ports.Port, error) {
+	return me.ports.get(ctx, me, portId, func(ctx context.Context) (*ports.Port, error) {
+		return me.OpenstackClient.GetPort(ctx, portId)
 	})
 }
 
-func (me *CachedClient) GetPortsByDeviceId(deviceId string) ([]ports.Port, error) {
-	return getValue[[]ports.Port](me.cash, makeKey("GetPortsByDeviceId", deviceId), me.Expiration, func() (any, error) {
-		return me.OpenstackClient.GetPortsByDeviceId(deviceId)
+// GetPortWithBinding isn't cached since it's used to wait for the port's status to change
+func (me *CachedClient) GetPortWithBinding(ctx context.Context, portId string) (*PortWithBinding, error) {
+	return me.OpenstackClient.GetPortWithBinding(ctx, portId)
+}
+
+func (me *CachedClient) GetPortsByDeviceId(ctx context.Context, deviceId string) ([]ports.Port, error) {
+	return me.portsByDevice.get(ctx, me, deviceId, func(ctx context.Context) ([]ports.Port, error) {
+		return me.OpenstackClient.GetPortsByDeviceId(ctx, deviceId)
 	})
 }
 
-func (me *CachedClient) GetPortByTags(tags []string) (*ports.Port, error) {
-	return me.OpenstackClient.GetPortByTags(tags)
+func (me *CachedClient) GetPortByTags(ctx context.Context, tags []string) (*ports.Port, error) {
+	return me.OpenstackClient.GetPortByTags(ctx, tags)
 }
 
-func (me *CachedClient) GetPortsByTags(tags []string) ([]ports.Port, error) {
-	return me.OpenstackClient.GetPortsByTags(tags)
+func (me *CachedClient) GetPortsByTags(ctx context.Context, tags []string) ([]ports.Port, error) {
+	return me.OpenstackClient.GetPortsByTags(ctx, tags)
 }
 
-func (me *CachedClient) GetProjectByName(name string) (*projects.Project, error) {
-	return getPtrValue[projects.Project](me.cash, makeKey("GetProjectByName", name), me.Expiration, func() (any, error) {
-		return me.OpenstackClient.GetProjectByName(name)
+func (me *CachedClient) GetProjectByName(ctx context.Context, name string) (*projects.Project, error) {
+	return me.projects.get(ctx, me, name, func(ctx context.Context) (*projects.Project, error) {
+		return me.OpenstackClient.GetProjectByName(ctx, name)
 	})
 }
 
-func (me *CachedClient) GetServerByName(name string) (*servers.Server, error) {
-	return getPtrValue[servers.Server](me.cash, makeKey("GetServerByName", name), me.Expiration, func() (any, error) {
-		return me.OpenstackClient.GetServerByName(name)
+func (me *CachedClient) GetServer(ctx context.Context, id string) (*servers.Server, error) {
+	return me.servers.get(ctx, me, id, func(ctx context.Context) (*servers.Server, error) {
+		return me.OpenstackClient.GetServer(ctx, id)
 	})
 }
 
-func (me *CachedClient) GetSecurityGroupByName(name string, projectId string) (*groups.SecGroup, error) {
-	return getPtrValue[groups.SecGroup](me.cash, makeKey("GetSecurityGroupsByName", name), me.Expiration, func() (any, error) {
-		return me.OpenstackClient.GetSecurityGroupByName(name, projectId)
+func (me *CachedClient) GetServerByName(ctx context.Context, name string) (*servers.Server, error) {
+	return me.serversByName.get(ctx, me, name, func(ctx context.Context) (*servers.Server, error) {
+		return me.OpenstackClient.GetServerByName(ctx, name)
 	})
 }
 
-func (me *CachedClient) GetSubnet(id string) (*subnets.Subnet, error) {
-	return getPtrValue[subnets.Subnet](me.cash, makeKey("GetSubnet", id), me.Expiration, func() (any, error) {
-		return me.OpenstackClient.GetSubnet(id)
+func (me *CachedClient) GetSecurityGroupByName(ctx context.Context, name string, projectId string) (*groups.SecGroup, error) {
+	key := securityGroupKey{Name: name, ProjectId: projectId}
+	return me.securityGroups.get(ctx, me, key, func(ctx context.Context) (*groups.SecGroup, error) {
+		return me.OpenstackClient.GetSecurityGroupByName(ctx, name, projectId)
 	})
 }
 
-func (me *CachedClient) GetSubnetByName(name, networkId string) (*subnets.Subnet, error) {
-	return getPtrValue[subnets.Subnet](me.cash, makeKey("GetSubnetByName", fmt.Sprintf("%s--%s", name, networkId)), me.Expiration, func() (any, error) {
-		return me.OpenstackClient.GetSubnetByName(name, networkId)
+func (me *CachedClient) GetSecurityGroupsByName(ctx context.Context, name string) ([]SecurityGroup, error) {
+	return me.OpenstackClient.GetSecurityGroupsByName(ctx, name)
+}
+
+func (me *CachedClient) GetSubnet(ctx context.Context, id string) (*subnets.Subnet, error) {
+	return me.subnets.get(ctx, me, id, func(ctx context.Context) (*subnets.Subnet, error) {
+		return me.OpenstackClient.GetSubnet(ctx, id)
 	})
 }
 
-func makeKey(parts ...string) string {
-	return strings.Join(parts, "|")
+func (me *CachedClient) GetSubnetByName(ctx context.Context, name, networkId string) (*subnets.Subnet, error) {
+	key := subnetKey{Name: name, NetworkId: networkId}
+	return me.subnetsByName.get(ctx, me, key, func(ctx context.Context) (*subnets.Subnet, error) {
+		return me.OpenstackClient.GetSubnetByName(ctx, name, networkId)
+	})
 }
 
-func getPtrValue[T any](store *cache.Cache[string, any], cacheKey string, expiraton time.Duration, fn func() (any, error)) (*T, error) {
-	cachedVal, found := store.Get(cacheKey)
-	if found {
-		return cachedVal.(*T), nil
+// SetPortTags replaces the tags of a port
+func (me *CachedClient) SetPortTags(ctx context.Context, portId string, tags []string) error {
+	defer me.invalidatePort(portId, "")
+	return me.OpenstackClient.SetPortTags(ctx, portId, tags)
+}
+
+func (me *CachedClient) UpdateSecurityGroupDescription(ctx context.Context, id, description string, revisionNumber int) error {
+	return me.OpenstackClient.UpdateSecurityGroupDescription(ctx, id, description, revisionNumber)
+}
+
+func (me *CachedClient) hit(operation string) {
+	if me.Metrics != nil {
+		me.Metrics.CacheHit(operation)
 	}
+}
 
-	val, err := fn()
-	if err != nil {
-		return nil, err
+func (me *CachedClient) miss(operation string) {
+	if me.Metrics != nil {
+		me.Metrics.CacheMiss(operation)
 	}
+}
+
+// resourceCache caches the lookups of a single kind of resource
+type resourceCache[K comparable, V any] struct {
+	operation string
+	entries   *cache.Cache[K, cacheEntry[V]]
+	lookups   singleflight.Group
+	// generation changes on every invalidation so lookups started before it aren't cached
+	generation atomic.Uint64
+}
 
-	store.Set(cacheKey, val, cache.WithExpiration(expiraton))
-	return val.(*T), nil
+// cacheEntry is either a value or a NotFound error
+type cacheEntry[V any] struct {
+	value V
+	err   error
 }
 
-func getValue[T any](store *cache.Cache[string, any], cacheKey string, expiraton time.Duration, fn func() (any, error)) (T, error) {
-	cachedVal, found := store.Get(cacheKey)
-	if found {
-		return cachedVal.(T), nil
+func newResourceCache[K comparable, V any](ctx context.Context, operation string) *resourceCache[K, V] {
+	return &resourceCache[K, V]{
+		operation: operation,
+		entries:   cache.NewContext[K, cacheEntry[V]](ctx),
 	}
+}
 
-	val, err := fn()
-	if err != nil {
-		return *new(T), err
+// get returns the cached entry for key or looks it up with fn
+// the lookup isn't cancelled when the caller gives up waiting for it since other callers may be waiting too,
+// it fails once it takes longer than the client's LookupTimeout instead
+func (me *resourceCache[K, V]) get(ctx context.Context, client *CachedClient, key K, fn func(ctx context.Context) (V, error)) (V, error) {
+	if entry, found := me.entries.Get(key); found {
+		client.hit(me.operation)
+		return entry.value, entry.err
 	}
+	client.miss(me.operation)
+
+	generation := me.generation.Load()
+	lookup := me.lookups.DoChan(lookupKey(key), func() (any, error) {
+		lookupCtx := context.WithoutCancel(ctx)
+		if client.LookupTimeout > 0 {
+			var cancel context.CancelFunc
+			lookupCtx, cancel = context.WithTimeout(lookupCtx, client.LookupTimeout)
+			defer cancel()
+		}
+		value, err := fn(lookupCtx)
+		if me.generation.Load() != generation {
+			return value, err
+		}
+		switch {
+		case err == nil:
+			me.entries.Set(key, cacheEntry[V]{value: value}, cache.WithExpiration(client.Expiration))
+		case isNotFound(err) && client.NegativeExpiration > 0:
+			me.entries.Set(key, cacheEntry[V]{err: err}, cache.WithExpiration(client.NegativeExpiration))
+		}
+		return value, err
+	})
+
+	select {
+	case result := <-lookup:
+		value, _ := result.Val.(V)
+		return value, result.Err
+	case <-ctx.Done():
+		return *new(V), ctx.Err()
+	}
+}
+
+// invalidate drops the entries of keys
+func (me *resourceCache[K, V]) invalidate(keys ...K) {
+	me.generation.Add(1)
+	for _, key := range keys {
+		me.entries.Delete(key)
+		me.lookups.Forget(lookupKey(key))
+	}
+}
+
+// invalidateFunc drops every entry matching fn
+func (me *resourceCache[K, V]) invalidateFunc(fn func(key K, value V) bool) {
+	keys := []K{}
+	for _, key := range me.entries.Keys() {
+		if entry, found := me.entries.Get(key); found && fn(key, entry.value) {
+			keys = append(keys, key)
+		}
+	}
+	me.invalidate(keys...)
+}
+
+// flush drops every entry
+func (me *resourceCache[K, V]) flush() {
+	me.invalidate(me.entries.Keys()...)
+}
 
-	store.Set(cacheKey, val, cache.WithExpiration(expiraton))
-	return val.(T), nil
+func lookupKey(key any) string {
+	return fmt.Sprintf("%#v", key)
 }
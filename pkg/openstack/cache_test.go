@@ -1,10 +1,13 @@
 package openstack_test
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
@@ -24,13 +27,13 @@ func Test_Cache(t *testing.T) {
 		expiry := time.Millisecond * 25
 		WithMockClientWithExpiry(t, expiry, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			networkName := "my-network"
-			mock.GetNetworkByNameFunc = func(name string) (*networks.Network, error) {
-				return &networks.Network{Name: name}, nil
+			mock.GetNetworkByNameFunc = func(ctx context.Context, name string) (*openstack.Network, error) {
+				return &openstack.Network{Network: networks.Network{Name: name}}, nil
 			}
 
 			Assert(t).That(mock.GetNetworkByNameCalls(), HasLen(0))
 			invoke := func(calls int) {
-				network, err := client.GetNetworkByName(networkName)
+				network, err := client.GetNetworkByName(context.Background(), networkName)
 				Assert(t).That(err, IsNil())
 				Assert(t).That(network.Name, Equals(networkName))
 				Assert(t).That(mock.GetNetworkByNameCalls(), HasLen(calls))
@@ -53,13 +56,13 @@ func Test_Cache(t *testing.T) {
 	t.Run("GetPort is cached", func(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			portId := "dead"
-			mock.GetPortFunc = func(portId string) (*ports.Port, error) {
+			mock.GetPortFunc = func(ctx context.Context, portId string) (*ports.Port, error) {
 				return &ports.Port{ID: portId}, nil
 			}
 
 			Assert(t).That(mock.GetPortCalls(), HasLen(0))
 			invoke := func(calls int) {
-				port, err := client.GetPort(portId)
+				port, err := client.GetPort(context.Background(), portId)
 				Assert(t).That(err, IsNil())
 				Assert(t).That(port.ID, Equals(portId))
 				Assert(t).That(mock.GetPortCalls(), HasLen(calls))
@@ -73,7 +76,7 @@ func Test_Cache(t *testing.T) {
 	t.Run("GetPortsByDeviceId is cached", func(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			deviceId := "myId"
-			mock.GetPortsByDeviceIdFunc = func(deviceId string) ([]ports.Port, error) {
+			mock.GetPortsByDeviceIdFunc = func(ctx context.Context, deviceId string) ([]ports.Port, error) {
 				return []ports.Port{
 					{ID: deviceId},
 				}, nil
@@ -81,7 +84,7 @@ func Test_Cache(t *testing.T) {
 
 			Assert(t).That(mock.GetPortsByDeviceIdCalls(), HasLen(0))
 			invoke := func(calls int) {
-				ports, err := client.GetPortsByDeviceId(deviceId)
+				ports, err := client.GetPortsByDeviceId(context.Background(), deviceId)
 				Assert(t).That(err, IsNil())
 				Assert(t).That(ports, HasLen(1))
 				Assert(t).That(ports[0].ID, Equals(deviceId))
@@ -96,7 +99,7 @@ func Test_Cache(t *testing.T) {
 	t.Run("GetPortByTags is NOT cached", func(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			tags := []string{"foo=bar", "this=that"}
-			mock.GetPortByTagsFunc = func(tags []string) (*ports.Port, error) {
+			mock.GetPortByTagsFunc = func(ctx context.Context, tags []string) (*ports.Port, error) {
 				return &ports.Port{
 					Tags: tags,
 				}, nil
@@ -105,7 +108,7 @@ func Test_Cache(t *testing.T) {
 			Assert(t).That(mock.GetPortByTagsCalls(), HasLen(0))
 			invoke := func(calls int) {
 				t.Helper()
-				port, err := client.GetPortByTags(tags)
+				port, err := client.GetPortByTags(context.Background(), tags)
 				Assert(t).That(err, IsNil())
 				Assert(t).That(port.Tags, Equals(tags))
 				Assert(t).That(mock.GetPortByTagsCalls(), HasLen(calls))
@@ -119,13 +122,13 @@ func Test_Cache(t *testing.T) {
 	t.Run("GetPortByTags doesn't cache errors", func(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			tags := []string{"foo=bar", "this=that"}
-			mock.GetPortByTagsFunc = func(tags []string) (*ports.Port, error) {
+			mock.GetPortByTagsFunc = func(ctx context.Context, tags []string) (*ports.Port, error) {
 				return nil, fmt.Errorf("BOOM")
 			}
 
 			Assert(t).That(mock.GetPortByTagsCalls(), HasLen(0))
 			invoke := func(calls int) {
-				_, err := client.GetPortByTags(tags)
+				_, err := client.GetPortByTags(context.Background(), tags)
 				Assert(t).That(err, Not(IsNil()))
 				Assert(t).That(mock.GetPortByTagsCalls(), HasLen(calls))
 			}
@@ -140,43 +143,45 @@ func Test_Cache(t *testing.T) {
 			testPort := &ports.Port{ID: "myId", Tags: []string{"foo=bar", "this=that"}, DeviceID: "deviceId"}
 
 			// mock the creation and returning of a port
-			mock.CreatePortFunc = func(opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) { return testPort, nil }
-			mock.DeletePortFunc = func(portId string) error { return nil }
-			mock.GetPortByTagsFunc = func(tags []string) (*ports.Port, error) { return testPort, nil }
-			mock.GetPortFunc = func(portId string) (*ports.Port, error) { return testPort, nil }
-			mock.GetPortsByDeviceIdFunc = func(deviceId string) ([]ports.Port, error) { return []ports.Port{*testPort}, nil }
+			mock.CreatePortFunc = func(ctx context.Context, opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) {
+				return testPort, nil
+			}
+			mock.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+			mock.GetPortByTagsFunc = func(ctx context.Context, tags []string) (*ports.Port, error) { return testPort, nil }
+			mock.GetPortFunc = func(ctx context.Context, portId string) (*ports.Port, error) { return testPort, nil }
+			mock.GetPortsByDeviceIdFunc = func(ctx context.Context, deviceId string) ([]ports.Port, error) { return []ports.Port{*testPort}, nil }
 
 			// get the port via all possible methods in order to populate the cache
-			port, err := client.GetPortByTags(testPort.Tags)
+			port, err := client.GetPortByTags(context.Background(), testPort.Tags)
 			Assert(t).That(err, IsNil())
 			Assert(t).That(port.Tags, Equals(testPort.Tags))
 
-			port, err = client.GetPort(testPort.ID)
+			port, err = client.GetPort(context.Background(), testPort.ID)
 			Assert(t).That(err, IsNil())
 			Assert(t).That(port.Tags, Equals(testPort.Tags))
 
-			allports, err := client.GetPortsByDeviceId(testPort.DeviceID)
+			allports, err := client.GetPortsByDeviceId(context.Background(), testPort.DeviceID)
 			Assert(t).That(err, IsNil())
 			Assert(t).That(allports, HasLen(1))
 
 			// delete port which should also clear out the GetPort* related cache keys
-			Assert(t).That(client.DeletePort(testPort.ID), IsNil())
+			Assert(t).That(client.DeletePort(context.Background(), testPort.ID), IsNil())
 
 			// the port was deleted so now we mock nothing is returned
-			mock.GetPortByTagsFunc = func(tags []string) (*ports.Port, error) { return nil, openstack.ErrPortNotFound }
-			mock.GetPortFunc = func(portId string) (*ports.Port, error) { return nil, openstack.ErrPortNotFound }
-			mock.GetPortsByDeviceIdFunc = func(deviceId string) ([]ports.Port, error) { return []ports.Port{}, nil }
+			mock.GetPortByTagsFunc = func(ctx context.Context, tags []string) (*ports.Port, error) { return nil, openstack.ErrPortNotFound }
+			mock.GetPortFunc = func(ctx context.Context, portId string) (*ports.Port, error) { return nil, openstack.ErrPortNotFound }
+			mock.GetPortsByDeviceIdFunc = func(ctx context.Context, deviceId string) ([]ports.Port, error) { return []ports.Port{}, nil }
 
 			// assert that we get a port via any of the GetPort* related methods
-			port, err = client.GetPortByTags(testPort.Tags)
+			port, err = client.GetPortByTags(context.Background(), testPort.Tags)
 			Assert(t).That(err, Equals(openstack.ErrPortNotFound))
 			Assert(t).That(port, IsNil())
 
-			port, err = client.GetPort(testPort.ID)
+			port, err = client.GetPort(context.Background(), testPort.ID)
 			Assert(t).That(err, Equals(openstack.ErrPortNotFound))
 			Assert(t).That(port, IsNil())
 
-			allports, err = client.GetPortsByDeviceId(testPort.DeviceID)
+			allports, err = client.GetPortsByDeviceId(context.Background(), testPort.DeviceID)
 			Assert(t).That(err, IsNil())
 			Assert(t).That(allports, HasLen(0))
 		})
@@ -185,13 +190,13 @@ func Test_Cache(t *testing.T) {
 	t.Run("GetProjectByName is cached", func(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			name := "myname"
-			mock.GetProjectByNameFunc = func(name string) (*projects.Project, error) {
+			mock.GetProjectByNameFunc = func(ctx context.Context, name string) (*projects.Project, error) {
 				return &projects.Project{Name: name}, nil
 			}
 
 			Assert(t).That(mock.GetProjectByNameCalls(), HasLen(0))
 			invoke := func(calls int) {
-				project, err := client.GetProjectByName(name)
+				project, err := client.GetProjectByName(context.Background(), name)
 				Assert(t).That(err, IsNil())
 				Assert(t).That(project.Name, Equals(name))
 				Assert(t).That(mock.GetProjectByNameCalls(), HasLen(calls))
@@ -205,13 +210,13 @@ func Test_Cache(t *testing.T) {
 	t.Run("GetServerByName is cached", func(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			name := "myname"
-			mock.GetServerByNameFunc = func(name string) (*servers.Server, error) {
+			mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
 				return &servers.Server{Name: name}, nil
 			}
 
 			Assert(t).That(mock.GetServerByNameCalls(), HasLen(0))
 			invoke := func(calls int) {
-				server, err := client.GetServerByName(name)
+				server, err := client.GetServerByName(context.Background(), name)
 				Assert(t).That(err, IsNil())
 				Assert(t).That(server.Name, Equals(name))
 				Assert(t).That(mock.GetServerByNameCalls(), HasLen(calls))
@@ -226,13 +231,13 @@ func Test_Cache(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			name := "myname"
 			projectId := "projId"
-			mock.GetSecurityGroupByNameFunc = func(name, projectId string) (*groups.SecGroup, error) {
+			mock.GetSecurityGroupByNameFunc = func(ctx context.Context, name, projectId string) (*groups.SecGroup, error) {
 				return &groups.SecGroup{Name: name}, nil
 			}
 
 			Assert(t).That(mock.GetSecurityGroupByNameCalls(), HasLen(0))
 			invoke := func(calls int) {
-				sg, err := client.GetSecurityGroupByName(name, projectId)
+				sg, err := client.GetSecurityGroupByName(context.Background(), name, projectId)
 				Assert(t).That(err, IsNil())
 				Assert(t).That(sg.Name, Equals(name))
 				Assert(t).That(mock.GetSecurityGroupByNameCalls(), HasLen(calls))
@@ -243,16 +248,31 @@ func Test_Cache(t *testing.T) {
 		})
 	})
 
+	t.Run("GetSecurityGroupByName is cached per project", func(t *testing.T) {
+		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
+			mock.GetSecurityGroupByNameFunc = func(ctx context.Context, name, projectId string) (*groups.SecGroup, error) {
+				return &groups.SecGroup{Name: name, ProjectID: projectId}, nil
+			}
+
+			for _, projectId := range []string{"proj1", "proj2", "proj1"} {
+				sg, err := client.GetSecurityGroupByName(context.Background(), "default", projectId)
+				Assert(t).That(err, IsNil())
+				Assert(t).That(sg.ProjectID, Equals(projectId))
+			}
+			Assert(t).That(mock.GetSecurityGroupByNameCalls(), HasLen(2))
+		})
+	})
+
 	t.Run("GetSubnet is cached", func(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			id := "subnetId"
-			mock.GetSubnetFunc = func(id string) (*subnets.Subnet, error) {
+			mock.GetSubnetFunc = func(ctx context.Context, id string) (*subnets.Subnet, error) {
 				return &subnets.Subnet{ID: id}, nil
 			}
 
 			Assert(t).That(mock.GetSubnetCalls(), HasLen(0))
 			invoke := func(calls int) {
-				subnet, err := client.GetSubnet(id)
+				subnet, err := client.GetSubnet(context.Background(), id)
 				Assert(t).That(err, IsNil())
 				Assert(t).That(subnet.ID, Equals(id))
 				Assert(t).That(mock.GetSubnetCalls(), HasLen(calls))
@@ -267,13 +287,13 @@ func Test_Cache(t *testing.T) {
 		WithMockClient(t, func(mock *mocks.OpenstackClientMock, client openstack.OpenstackClient) {
 			name := "myname"
 			id := "networkid"
-			mock.GetSubnetByNameFunc = func(name, networkId string) (*subnets.Subnet, error) {
+			mock.GetSubnetByNameFunc = func(ctx context.Context, name, networkId string) (*subnets.Subnet, error) {
 				return &subnets.Subnet{Name: name}, nil
 			}
 
 			Assert(t).That(mock.GetSubnetByNameCalls(), HasLen(0))
 			invoke := func(calls int) {
-				server, err := client.GetSubnetByName(name, id)
+				server, err := client.GetSubnetByName(context.Background(), name, id)
 				Assert(t).That(err, IsNil())
 				Assert(t).That(server.Name, Equals(name))
 				Assert(t).That(mock.GetSubnetByNameCalls(), HasLen(calls))
@@ -284,3 +304,179 @@ func Test_Cache(t *testing.T) {
 		})
 	})
 }
+
+func Test_CacheLookups(t *testing.T) {
+	t.Run("NotFound errors are cached until they expire", func(t *testing.T) {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return nil, openstack.ErrServerNotFound
+		}
+		client := openstack.NewCachedClient(mock, time.Minute)
+		client.NegativeExpiration = 25 * time.Millisecond
+
+		for i := 0; i < 2; i++ {
+			_, err := client.GetServerByName(context.Background(), "gone")
+			Assert(t).That(err, Equals(openstack.ErrServerNotFound))
+		}
+		Assert(t).That(mock.GetServerByNameCalls(), HasLen(1))
+
+		time.Sleep(50 * time.Millisecond)
+		_, err := client.GetServerByName(context.Background(), "gone")
+		Assert(t).That(err, Equals(openstack.ErrServerNotFound))
+		Assert(t).That(mock.GetServerByNameCalls(), HasLen(2))
+	})
+
+	t.Run("other errors aren't cached", func(t *testing.T) {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return nil, fmt.Errorf("BOOM")
+		}
+		client := openstack.NewCachedClient(mock, time.Minute)
+
+		for i := 0; i < 2; i++ {
+			_, err := client.GetServerByName(context.Background(), "host")
+			Assert(t).That(err, Not(IsNil()))
+		}
+		Assert(t).That(mock.GetServerByNameCalls(), HasLen(2))
+	})
+
+	t.Run("concurrent lookups make a single request", func(t *testing.T) {
+		release := make(chan struct{})
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			<-release
+			return &servers.Server{ID: "serverId"}, nil
+		}
+		client := openstack.NewCachedClient(mock, time.Minute)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				server, err := client.GetServerByName(context.Background(), "host")
+				Assert(t).That(err, IsNil())
+				Assert(t).That(server.ID, Equals("serverId"))
+			}()
+		}
+		time.Sleep(25 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		Assert(t).That(mock.GetServerByNameCalls(), HasLen(1))
+	})
+
+	t.Run("a caller giving up doesn't fail the others", func(t *testing.T) {
+		release := make(chan struct{})
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			<-release
+			return &servers.Server{ID: "serverId"}, ctx.Err()
+		}
+		client := openstack.NewCachedClient(mock, time.Minute)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error)
+		go func() {
+			_, err := client.GetServerByName(ctx, "host")
+			errs <- err
+		}()
+		time.Sleep(25 * time.Millisecond)
+		cancel()
+		Assert(t).That(<-errs, Equals(context.Canceled))
+
+		close(release)
+		server, err := client.GetServerByName(context.Background(), "host")
+		Assert(t).That(err, IsNil())
+		Assert(t).That(server.ID, Equals("serverId"))
+	})
+
+	t.Run("a hung lookup times out and the next miss makes a new request", func(t *testing.T) {
+		release := make(chan struct{})
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			if len(mock.GetServerByNameCalls()) == 1 {
+				select {
+				case <-release:
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+			return &servers.Server{ID: "serverId"}, nil
+		}
+		client := openstack.NewCachedClient(mock, time.Minute)
+		client.LookupTimeout = 50 * time.Millisecond
+		defer close(release)
+
+		_, err := client.GetServerByName(context.Background(), "host")
+		Assert(t).That(err, Equals(context.DeadlineExceeded))
+
+		server, err := client.GetServerByName(context.Background(), "host")
+		Assert(t).That(err, IsNil())
+		Assert(t).That(server.ID, Equals("serverId"))
+		Assert(t).That(mock.GetServerByNameCalls(), HasLen(2))
+	})
+
+	t.Run("ports are invalidated when they are created, assigned or detached", func(t *testing.T) {
+		devicePorts := []ports.Port{}
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetPortFunc = func(ctx context.Context, portId string) (*ports.Port, error) {
+			return &ports.Port{ID: portId}, nil
+		}
+		mock.GetPortsByDeviceIdFunc = func(ctx context.Context, deviceId string) ([]ports.Port, error) {
+			return devicePorts, nil
+		}
+		mock.CreatePortFunc = func(ctx context.Context, opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) {
+			return &ports.Port{ID: "port"}, nil
+		}
+		mock.AssignPortFunc = func(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error) {
+			devicePorts = []ports.Port{{ID: portId, DeviceID: serverId}}
+			return &attachinterfaces.Interface{PortID: portId}, nil
+		}
+		mock.DetachPortFunc = func(ctx context.Context, portId, serverId string) error {
+			devicePorts = []ports.Port{}
+			return nil
+		}
+		client := openstack.NewCachedClient(mock, time.Minute)
+		devicePortCount := func() int {
+			t.Helper()
+			found, err := client.GetPortsByDeviceId(context.Background(), "server")
+			Assert(t).That(err, IsNil())
+			return len(found)
+		}
+
+		_, err := client.GetPort(context.Background(), "port")
+		Assert(t).That(err, IsNil())
+		Assert(t).That(devicePortCount(), Equals(0))
+
+		_, err = client.CreatePort(context.Background(), ports.CreateOpts{}, nil)
+		Assert(t).That(err, IsNil())
+		_, err = client.GetPort(context.Background(), "port")
+		Assert(t).That(err, IsNil())
+		Assert(t).That(mock.GetPortCalls(), HasLen(2))
+
+		_, err = client.AssignPort(context.Background(), "port", "server")
+		Assert(t).That(err, IsNil())
+		Assert(t).That(devicePortCount(), Equals(1))
+
+		Assert(t).That(client.DetachPort(context.Background(), "port", "server"), IsNil())
+		Assert(t).That(devicePortCount(), Equals(0))
+		Assert(t).That(mock.GetPortsByDeviceIdCalls(), HasLen(3))
+	})
+
+	t.Run("Flush drops every entry", func(t *testing.T) {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetProjectByNameFunc = func(ctx context.Context, name string) (*projects.Project, error) {
+			return &projects.Project{Name: name}, nil
+		}
+		client := openstack.NewCachedClient(mock, time.Minute)
+
+		for i := 0; i < 2; i++ {
+			_, err := client.GetProjectByName(context.Background(), "project")
+			Assert(t).That(err, IsNil())
+		}
+		client.Flush()
+		_, err := client.GetProjectByName(context.Background(), "project")
+		Assert(t).That(err, IsNil())
+		Assert(t).That(mock.GetProjectByNameCalls(), HasLen(2))
+	})
+}
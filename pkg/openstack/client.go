@@ -1,13 +1,18 @@
 package openstack
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
+	"sync/atomic"
 
-	gc_os "github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/mtu"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/portsbinding"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/portsecurity"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
@@ -20,26 +25,34 @@ import (
 //go:generate moq -pkg mocks -out ../fixtures/mocks/openstack_mocks.go . OpenstackClient
 
 type OpenstackClient interface {
-	AssignPort(portId, serverId string) (*attachinterfaces.Interface, error)
-	CreatePort(opts ports.CreateOpts, extraOpts *ExtraCreatePortOpts) (*ports.Port, error)
-	DeletePort(portId string) error
-	DetachPort(portId, serverId string) error
+	AssignPort(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error)
+	CreatePort(ctx context.Context, opts ports.CreateOpts, extraOpts *ExtraCreatePortOpts) (*ports.Port, error)
+	CreateSecurityGroup(ctx context.Context, name, description string) (*SecurityGroup, error)
+	DeletePort(ctx context.Context, portId string) error
+	DetachPort(ctx context.Context, portId, serverId string) error
 	Clients() *ApiClients
-	GetNetworkByName(name string) (*networks.Network, error)
-	GetPort(portId string) (*ports.Port, error)
-	GetPortsByDeviceId(deviceId string) ([]ports.Port, error)
-	GetPortByTags(tags []string) (*ports.Port, error)
-	GetPortsByTags(tags []string) ([]ports.Port, error)
-	GetProjectByName(name string) (*projects.Project, error)
-	GetServerByName(name string) (*servers.Server, error)
-	GetSecurityGroupByName(name, projectId string) (*groups.SecGroup, error)
-	GetSubnet(id string) (*subnets.Subnet, error)
-	GetSubnetByName(name, networkId string) (*subnets.Subnet, error)
+	GetNetwork(ctx context.Context, id string) (*Network, error)
+	GetNetworkByName(ctx context.Context, name string) (*Network, error)
+	GetPort(ctx context.Context, portId string) (*ports.Port, error)
+	GetPortWithBinding(ctx context.Context, portId string) (*PortWithBinding, error)
+	GetPortsByDeviceId(ctx context.Context, deviceId string) ([]ports.Port, error)
+	GetPortByTags(ctx context.Context, tags []string) (*ports.Port, error)
+	GetPortsByTags(ctx context.Context, tags []string) ([]ports.Port, error)
+	GetProjectByName(ctx context.Context, name string) (*projects.Project, error)
+	GetServer(ctx context.Context, id string) (*servers.Server, error)
+	GetServerByName(ctx context.Context, name string) (*servers.Server, error)
+	GetSecurityGroupByName(ctx context.Context, name, projectId string) (*groups.SecGroup, error)
+	GetSecurityGroupsByName(ctx context.Context, name string) ([]SecurityGroup, error)
+	GetSubnet(ctx context.Context, id string) (*subnets.Subnet, error)
+	GetSubnetByName(ctx context.Context, name, networkId string) (*subnets.Subnet, error)
+	SetPortTags(ctx context.Context, portId string, tags []string) error
+	UpdateSecurityGroupDescription(ctx context.Context, id, description string, revisionNumber int) error
 }
 
 // openstackClient exposes various Openstack API functionality in a single location
+// the ApiClients are swapped by Reload, requests in flight finish with the previous ones
 type openstackClient struct {
-	clients *ApiClients
+	clients atomic.Pointer[ApiClients]
 }
 
 var _ OpenstackClient = &openstackClient{}
@@ -50,32 +63,54 @@ func NewOpenstackClient() (*openstackClient, error) {
 		return nil, err
 	}
 
-	authOpts, err := gc_os.AuthOptionsFromEnv()
+	cloudOpts, err := CloudOptsFromEnv()
 	if err != nil {
 		return nil, err
 	}
+	return NewOpenstackClientWithCloudOpts(cloudOpts)
+}
 
-	apiClients, err := NewApiClients(authOpts)
-	if err != nil {
+// NewOpenstackClientWithCloudOpts creates a client authenticated with cloudOpts
+func NewOpenstackClientWithCloudOpts(cloudOpts CloudOpts) (*openstackClient, error) {
+	client := &openstackClient{}
+	if err := client.Reload(cloudOpts); err != nil {
 		return nil, err
 	}
+	return client, nil
+}
 
-	return &openstackClient{apiClients}, nil
+// NewOpenstackClientWithApiClients creates a client using already authenticated ApiClients
+func NewOpenstackClientWithApiClients(clients *ApiClients) *openstackClient {
+	client := &openstackClient{}
+	client.clients.Store(clients)
+	return client
+}
+
+// Reload authenticates with cloudOpts and replaces the ApiClients,
+// the previous ApiClients are kept when authenticating fails
+func (me *openstackClient) Reload(cloudOpts CloudOpts) error {
+	apiClients, err := NewApiClients(cloudOpts)
+	if err != nil {
+		return err
+	}
+
+	me.clients.Store(apiClients)
+	return nil
 }
 
 // AssignPort attaches a port to a server
-func (me *openstackClient) AssignPort(portId, serverId string) (*attachinterfaces.Interface, error) {
+func (me *openstackClient) AssignPort(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error) {
 	opts := attachinterfaces.CreateOpts{PortID: portId}
-	result := attachinterfaces.Create(me.clients.ComputeClient, serverId, opts)
+	result := attachinterfaces.Create(me.Clients().Compute(ctx), serverId, opts)
 	return result.Extract()
 }
 
 func (me *openstackClient) Clients() *ApiClients {
-	return me.clients
+	return me.clients.Load()
 }
 
 // CreatePort creates a neutron port inside of the specified network
-func (me *openstackClient) CreatePort(opts ports.CreateOpts, extraOpts *ExtraCreatePortOpts) (*ports.Port, error) {
+func (me *openstackClient) CreatePort(ctx context.Context, opts ports.CreateOpts, extraOpts *ExtraCreatePortOpts) (*ports.Port, error) {
 	// optionally include port security
 	var finalOpts ports.CreateOptsBuilder
 	finalOpts = opts
@@ -98,27 +133,36 @@ func (me *openstackClient) CreatePort(opts ports.CreateOpts, extraOpts *ExtraCre
 		}
 
 	}
-	return ports.Create(me.clients.NetworkClient, finalOpts).Extract()
+	return ports.Create(me.Clients().Network(ctx), finalOpts).Extract()
 }
 
 // DeletePort deletes the port
-func (me *openstackClient) DeletePort(portId string) error {
-	result := ports.Delete(me.clients.NetworkClient, portId)
+func (me *openstackClient) DeletePort(ctx context.Context, portId string) error {
+	result := ports.Delete(me.Clients().Network(ctx), portId)
 	return result.ExtractErr()
 }
 
 // Detach port removes a port's relationship from a server
-func (me *openstackClient) DetachPort(portId, serverId string) error {
-	result := attachinterfaces.Delete(me.clients.ComputeClient, serverId, portId)
+func (me *openstackClient) DetachPort(ctx context.Context, portId, serverId string) error {
+	result := attachinterfaces.Delete(me.Clients().Compute(ctx), serverId, portId)
 	return result.ExtractErr()
 }
 
 var ErrServerNotFound = fmt.Errorf("server not found")
 
+// GetServer returns a single server based on a server UUID
+func (me *openstackClient) GetServer(ctx context.Context, id string) (*servers.Server, error) {
+	server, err := servers.Get(me.Clients().Compute(ctx), id).Extract()
+	if statusCode(err) == http.StatusNotFound {
+		return nil, ErrServerNotFound
+	}
+	return server, err
+}
+
 // GetServer returns a single server based on a server name
-func (me *openstackClient) GetServerByName(name string) (*servers.Server, error) {
-	listOpts := servers.ListOpts{Name: regexName(name), Limit: 1}
-	allPages, err := servers.List(me.clients.ComputeClient, listOpts).AllPages()
+func (me *openstackClient) GetServerByName(ctx context.Context, name string) (*servers.Server, error) {
+	listOpts := servers.ListOpts{Name: regexName(name)}
+	allPages, err := servers.List(me.Clients().Compute(ctx), listOpts).AllPages()
 	if err != nil {
 		return nil, err
 	}
@@ -128,59 +172,87 @@ func (me *openstackClient) GetServerByName(name string) (*servers.Server, error)
 		return nil, err
 	}
 
-	if len(allServers) == 0 {
-		return nil, ErrServerNotFound
-	}
-	return &allServers[0], nil
+	return single(allServers, ErrServerNotFound, "servers", name)
 }
 
 var ErrNetworkNotFound = fmt.Errorf("network not found")
 
-// GetServer returns a single network based on a network name
-func (me *openstackClient) GetNetworkByName(name string) (*networks.Network, error) {
-	listOpts := networks.ListOpts{Name: name, Limit: 1}
-	allPages, err := networks.List(me.clients.NetworkClient, listOpts).AllPages()
+// Network is a network along with its MTU
+type Network struct {
+	networks.Network
+	mtu.NetworkMTUExt
+}
+
+// GetNetwork returns a single network based on a network UUID
+func (me *openstackClient) GetNetwork(ctx context.Context, id string) (*Network, error) {
+	var network Network
+	err := networks.Get(me.Clients().Network(ctx), id).ExtractInto(&network)
+	if statusCode(err) == http.StatusNotFound {
+		return nil, ErrNetworkNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
+	return &network, nil
+}
 
-	allNetworks, err := networks.ExtractNetworks(allPages)
+// GetServer returns a single network based on a network name
+func (me *openstackClient) GetNetworkByName(ctx context.Context, name string) (*Network, error) {
+	listOpts := networks.ListOpts{Name: name}
+	allPages, err := networks.List(me.Clients().Network(ctx), listOpts).AllPages()
 	if err != nil {
 		return nil, err
 	}
 
-	if len(allNetworks) == 0 {
-		return nil, ErrNetworkNotFound
+	var allNetworks []Network
+	if err := networks.ExtractNetworksInto(allPages, &allNetworks); err != nil {
+		return nil, err
 	}
-	return &allNetworks[0], nil
+
+	return single(allNetworks, ErrNetworkNotFound, "networks", name)
 }
 
 // GetPort returns a single port based on an ID
-func (me *openstackClient) GetPort(portId string) (*ports.Port, error) {
-	result := ports.Get(me.clients.NetworkClient, portId)
+func (me *openstackClient) GetPort(ctx context.Context, portId string) (*ports.Port, error) {
+	result := ports.Get(me.Clients().Network(ctx), portId)
 	return result.Extract()
 }
 
+// PortWithBinding is a port along with its binding details
+type PortWithBinding struct {
+	ports.Port
+	portsbinding.PortsBindingExt
+}
+
+// GetPortWithBinding returns a single port including its binding:vif_type based on an ID
+func (me *openstackClient) GetPortWithBinding(ctx context.Context, portId string) (*PortWithBinding, error) {
+	var port PortWithBinding
+	if err := ports.Get(me.Clients().Network(ctx), portId).ExtractInto(&port); err != nil {
+		return nil, err
+	}
+	return &port, nil
+}
+
 var ErrPortNotFound = fmt.Errorf("port not found")
 
 // GetPortByTags returns a single port based on matching tags
-func (me *openstackClient) GetPortByTags(tags []string) (*ports.Port, error) {
+func (me *openstackClient) GetPortByTags(ctx context.Context, tags []string) (*ports.Port, error) {
 	tagsStr := strings.Join(tags, ",")
 	listOpts := ports.ListOpts{Tags: tagsStr}
-	return me.getPort(listOpts)
+	return me.getPort(ctx, listOpts)
 }
 
 // GetPortsByTags returns a single port based on matching tags
-func (me *openstackClient) GetPortsByTags(tags []string) ([]ports.Port, error) {
+func (me *openstackClient) GetPortsByTags(ctx context.Context, tags []string) ([]ports.Port, error) {
 	tagsStr := strings.Join(tags, ",")
 	listOpts := ports.ListOpts{Tags: tagsStr}
-	return me.getPorts(listOpts)
+	return me.getPorts(ctx, listOpts)
 }
 
 // GetPortsByDeviceId returns all ports based device id (server id)
-func (me *openstackClient) GetPortsByDeviceId(deviceId string) ([]ports.Port, error) {
+func (me *openstackClient) GetPortsByDeviceId(ctx context.Context, deviceId string) ([]ports.Port, error) {
 	listOpts := ports.ListOpts{DeviceID: deviceId}
-	allPages, err := ports.List(me.clients.NetworkClient, listOpts).AllPages()
+	allPages, err := ports.List(me.Clients().Network(ctx), listOpts).AllPages()
 	if err != nil {
 		return nil, err
 	}
@@ -188,16 +260,16 @@ func (me *openstackClient) GetPortsByDeviceId(deviceId string) ([]ports.Port, er
 	return ports.ExtractPorts(allPages)
 }
 
-func (me *openstackClient) getPorts(listOpts ports.ListOpts) ([]ports.Port, error) {
-	allPages, err := ports.List(me.clients.NetworkClient, listOpts).AllPages()
+func (me *openstackClient) getPorts(ctx context.Context, listOpts ports.ListOpts) ([]ports.Port, error) {
+	allPages, err := ports.List(me.Clients().Network(ctx), listOpts).AllPages()
 	if err != nil {
 		return nil, err
 	}
 	return ports.ExtractPorts(allPages)
 }
 
-func (me *openstackClient) getPort(listOpts ports.ListOpts) (*ports.Port, error) {
-	ports, err := me.getPorts(listOpts)
+func (me *openstackClient) getPort(ctx context.Context, listOpts ports.ListOpts) (*ports.Port, error) {
+	ports, err := me.getPorts(ctx, listOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -211,10 +283,10 @@ func (me *openstackClient) getPort(listOpts ports.ListOpts) (*ports.Port, error)
 var ErrProjectNotFound = fmt.Errorf("project not found")
 
 // GetProjectByName returns a project based on name
-func (me *openstackClient) GetProjectByName(name string) (*projects.Project, error) {
+func (me *openstackClient) GetProjectByName(ctx context.Context, name string) (*projects.Project, error) {
 	listOpts := projects.ListOpts{Name: name}
 
-	allPages, err := projects.List(me.clients.IdentityClient, listOpts).AllPages()
+	allPages, err := projects.List(me.Clients().Identity(ctx), listOpts).AllPages()
 	if err != nil {
 		return nil, err
 	}
@@ -224,19 +296,16 @@ func (me *openstackClient) GetProjectByName(name string) (*projects.Project, err
 		return nil, err
 	}
 
-	if len(allProjects) == 0 {
-		return nil, ErrProjectNotFound
-	}
-	return &allProjects[0], nil
+	return single(allProjects, ErrProjectNotFound, "projects", name)
 }
 
 var ErrSecurityGroupNotFound = fmt.Errorf("security group not found")
 
 // GetSecurityGroupByName returns a single port based on an IpAddress
-func (me *openstackClient) GetSecurityGroupByName(name, projectId string) (*groups.SecGroup, error) {
+func (me *openstackClient) GetSecurityGroupByName(ctx context.Context, name, projectId string) (*groups.SecGroup, error) {
 	listOpts := groups.ListOpts{Name: name, ProjectID: projectId}
 
-	allPages, err := groups.List(me.clients.NetworkClient, listOpts).AllPages()
+	allPages, err := groups.List(me.Clients().Network(ctx), listOpts).AllPages()
 	if err != nil {
 		return nil, err
 	}
@@ -246,25 +315,77 @@ func (me *openstackClient) GetSecurityGroupByName(name, projectId string) (*grou
 		return nil, err
 	}
 
-	if len(allGroups) == 0 {
-		return nil, ErrSecurityGroupNotFound
+	return single(allGroups, ErrSecurityGroupNotFound, "security groups", name)
+}
+
+// SecurityGroup is the part of a security group needed to make updates conditional on its revision number
+type SecurityGroup struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	RevisionNumber int    `json:"revision_number"`
+}
+
+// CreateSecurityGroup creates a security group in the current project
+func (me *openstackClient) CreateSecurityGroup(ctx context.Context, name, description string) (*SecurityGroup, error) {
+	client := me.Clients().Network(ctx)
+	request := map[string]any{"security_group": map[string]string{"name": name, "description": description}}
+	var response struct {
+		SecurityGroup SecurityGroup `json:"security_group"`
+	}
+	opts := &gophercloud.RequestOpts{OkCodes: []int{201}}
+	_, _, err := gophercloud.ParseResponse(client.Post(client.ServiceURL("security-groups"), request, &response, opts))
+	if err != nil {
+		return nil, err
+	}
+	return &response.SecurityGroup, nil
+}
+
+// GetSecurityGroupsByName returns every security group named name in the current project
+func (me *openstackClient) GetSecurityGroupsByName(ctx context.Context, name string) ([]SecurityGroup, error) {
+	client := me.Clients().Network(ctx)
+	var response struct {
+		SecurityGroups []SecurityGroup `json:"security_groups"`
 	}
-	return &allGroups[0], nil
+	listUrl := client.ServiceURL("security-groups") + "?name=" + url.QueryEscape(name)
+	_, _, err := gophercloud.ParseResponse(client.Get(listUrl, &response, nil))
+	if err != nil {
+		return nil, err
+	}
+	return response.SecurityGroups, nil
+}
+
+// UpdateSecurityGroupDescription replaces the description of a security group,
+// the update fails with 412 when the group's revision number is no longer revisionNumber
+func (me *openstackClient) UpdateSecurityGroupDescription(ctx context.Context, id, description string, revisionNumber int) error {
+	client := me.Clients().Network(ctx)
+	request := map[string]any{"security_group": map[string]string{"description": description}}
+	opts := &gophercloud.RequestOpts{
+		OkCodes:     []int{200},
+		MoreHeaders: map[string]string{"If-Match": fmt.Sprintf("revision_number=%d", revisionNumber)},
+	}
+	_, _, err := gophercloud.ParseResponse(client.Put(client.ServiceURL("security-groups", id), request, nil, opts))
+	return err
+}
+
+// SetPortTags replaces the tags of a port
+func (me *openstackClient) SetPortTags(ctx context.Context, portId string, tags []string) error {
+	return NewNeutronTagger(me.Clients().Network(ctx), Ports).SetAll(portId, NewNeutronTags(tags...))
 }
 
 // GetSubnet return a single subnet based on a subnet UUID
-func (me *openstackClient) GetSubnet(id string) (*subnets.Subnet, error) {
-	result := subnets.Get(me.clients.NetworkClient, id)
+func (me *openstackClient) GetSubnet(ctx context.Context, id string) (*subnets.Subnet, error) {
+	result := subnets.Get(me.Clients().Network(ctx), id)
 	return result.Extract()
 }
 
 var ErrSubnetNotFound = fmt.Errorf("subnet not found")
 
 // GetSubnetByName returns a project based on name
-func (me *openstackClient) GetSubnetByName(name, networkId string) (*subnets.Subnet, error) {
+func (me *openstackClient) GetSubnetByName(ctx context.Context, name, networkId string) (*subnets.Subnet, error) {
 	listOpts := subnets.ListOpts{Name: name, NetworkID: networkId}
 
-	allPages, err := subnets.List(me.clients.NetworkClient, listOpts).AllPages()
+	allPages, err := subnets.List(me.Clients().Network(ctx), listOpts).AllPages()
 	if err != nil {
 		return nil, err
 	}
@@ -274,10 +395,21 @@ func (me *openstackClient) GetSubnetByName(name, networkId string) (*subnets.Sub
 		return nil, err
 	}
 
-	if len(all) == 0 {
-		return nil, ErrSubnetNotFound
+	return single(all, ErrSubnetNotFound, "subnets", name)
+}
+
+var ErrAmbiguousName = fmt.Errorf("name matches more than one resource")
+
+// single returns the only resource found by name, notFound when there's none
+// and ErrAmbiguousName when there are several since picking one could pick the wrong one
+func single[T any](all []T, notFound error, kind, name string) (*T, error) {
+	switch len(all) {
+	case 0:
+		return nil, notFound
+	case 1:
+		return &all[0], nil
 	}
-	return &all[0], nil
+	return nil, fmt.Errorf("%w: %d %s named %s, reference it by ID instead", ErrAmbiguousName, len(all), kind, name)
 }
 
 type FixedIP struct {
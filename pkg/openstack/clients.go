@@ -1,7 +1,7 @@
 package openstack
 
 import (
-	"os"
+	"context"
 
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
@@ -17,22 +17,27 @@ type ApiClients struct {
 	ProviderClient *gophercloud.ProviderClient
 }
 
-// NewApiClients creates a new ApiClients based on AuthOptions
-func NewApiClients(opts gophercloud.AuthOptions) (*ApiClients, error) {
-	region := os.Getenv("OS_REGION_NAME")
-	if region == "" {
-		region = "RegionOne"
-	}
-
-	opts.AllowReauth = true
+// NewApiClients creates a new ApiClients based on CloudOpts
+func NewApiClients(cloud CloudOpts) (*ApiClients, error) {
+	region := cloud.Region
+	opts := cloud.AuthOpts
+	// a token can't be exchanged for a new one once it expires
+	opts.AllowReauth = opts.TokenID == ""
 	clients := &ApiClients{authOpts: opts}
 	var err error
 
 	// setup the provider client
-	clients.ProviderClient, err = openstack.AuthenticatedClient(opts)
+	clients.ProviderClient, err = openstack.NewClient(opts.IdentityEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	clients.ProviderClient.HTTPClient, err = cloud.httpClient()
 	if err != nil {
 		return nil, err
 	}
+	if err := openstack.Authenticate(clients.ProviderClient, opts); err != nil {
+		return nil, err
+	}
 
 	// setup the compute / nova client
 	clients.ComputeClient, err = openstack.NewComputeV2(clients.ProviderClient, gophercloud.EndpointOpts{
@@ -66,3 +71,50 @@ func NewApiClients(opts gophercloud.AuthOptions) (*ApiClients, error) {
 
 	return clients, nil
 }
+
+// Compute returns the compute client bound to ctx
+func (me *ApiClients) Compute(ctx context.Context) *gophercloud.ServiceClient {
+	return withContext(ctx, me.ComputeClient)
+}
+
+// Identity returns the identity client bound to ctx
+func (me *ApiClients) Identity(ctx context.Context) *gophercloud.ServiceClient {
+	return withContext(ctx, me.IdentityClient)
+}
+
+// Network returns the network client bound to ctx
+func (me *ApiClients) Network(ctx context.Context) *gophercloud.ServiceClient {
+	return withContext(ctx, me.NetworkClient)
+}
+
+// withContext returns a copy of client whose requests are cancelled with ctx
+// the copy gets its own token, reauthenticating it refreshes the original's token and copies it back
+func withContext(ctx context.Context, client *gophercloud.ServiceClient) *gophercloud.ServiceClient {
+	original := client.ProviderClient
+	provider := &gophercloud.ProviderClient{
+		IdentityBase:      original.IdentityBase,
+		IdentityEndpoint:  original.IdentityEndpoint,
+		EndpointLocator:   original.EndpointLocator,
+		HTTPClient:        original.HTTPClient,
+		UserAgent:         original.UserAgent,
+		Throwaway:         original.Throwaway,
+		Context:           ctx,
+		RetryBackoffFunc:  original.RetryBackoffFunc,
+		MaxBackoffRetries: original.MaxBackoffRetries,
+		RetryFunc:         original.RetryFunc,
+	}
+	provider.UseTokenLock()
+	provider.CopyTokenFrom(original)
+	if original.ReauthFunc != nil {
+		provider.ReauthFunc = func() error {
+			if err := original.Reauthenticate(provider.Token()); err != nil {
+				return err
+			}
+			provider.CopyTokenFrom(original)
+			return nil
+		}
+	}
+	service := *client
+	service.ProviderClient = provider
+	return &service
+}
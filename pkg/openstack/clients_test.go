@@ -0,0 +1,61 @@
+package openstack_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
+	. "github.com/pepinns/go-hamcrest"
+)
+
+func Test_ApiClientsWithContext(t *testing.T) {
+	newClients := func() (*openstack.ApiClients, *gophercloud.ProviderClient) {
+		provider := &gophercloud.ProviderClient{}
+		provider.UseTokenLock()
+		provider.SetToken("oldToken")
+		provider.ReauthFunc = func() error {
+			provider.SetToken("newToken")
+			return nil
+		}
+		clients := &openstack.ApiClients{NetworkClient: &gophercloud.ServiceClient{ProviderClient: provider}}
+		return clients, provider
+	}
+
+	t.Run("the copy is bound to the context and starts with the provider's token", func(t *testing.T) {
+		clients, provider := newClients()
+		ctx := context.Background()
+
+		network := clients.Network(ctx)
+		Assert(t).That(network.ProviderClient == provider, IsFalse())
+		Assert(t).That(network.ProviderClient.Context, Equals(ctx))
+		Assert(t).That(network.ProviderClient.Token(), Equals("oldToken"))
+	})
+
+	t.Run("reauthenticating a copy refreshes the provider and the copy", func(t *testing.T) {
+		clients, provider := newClients()
+
+		network := clients.Network(context.Background())
+		err := network.ProviderClient.Reauthenticate("oldToken")
+		Assert(t).That(err, IsNil())
+		Assert(t).That(network.ProviderClient.Token(), Equals("newToken"))
+		Assert(t).That(provider.Token(), Equals("newToken"))
+		Assert(t).That(clients.Network(context.Background()).ProviderClient.Token(), Equals("newToken"))
+	})
+
+	t.Run("a copy with a stale token picks up the token another copy refreshed", func(t *testing.T) {
+		clients, provider := newClients()
+		reauths := 0
+		provider.ReauthFunc = func() error {
+			reauths++
+			provider.SetToken("newToken")
+			return nil
+		}
+
+		first, second := clients.Network(context.Background()), clients.Network(context.Background())
+		Assert(t).That(first.ProviderClient.Reauthenticate("oldToken"), IsNil())
+		Assert(t).That(second.ProviderClient.Reauthenticate("oldToken"), IsNil())
+		Assert(t).That(second.ProviderClient.Token(), Equals("newToken"))
+		Assert(t).That(reauths, Equals(1))
+	})
+}
@@ -0,0 +1,253 @@
+package openstack
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/utils/openstack/clientconfig"
+	"github.com/jboelensns/openstack-cni/pkg/util"
+	"gopkg.in/yaml.v2"
+)
+
+// CloudOpts is how to authenticate with Openstack and connect to its endpoints
+type CloudOpts struct {
+	AuthOpts gophercloud.AuthOptions
+	Region   string
+	// CACertFile is a PEM bundle of the CAs trusted instead of the system's
+	CACertFile string
+	// Insecure skips verifying the certificates of the endpoints
+	Insecure bool
+	// Files are the clouds.yaml and secure.yaml the options were read from, see WatchedFiles
+	Files []string
+}
+
+// CloudOptsFromEnv reads the CloudOpts from the environment, see CloudOptsFrom
+func CloudOptsFromEnv() (CloudOpts, error) {
+	return CloudOptsFrom(os.Getenv)
+}
+
+// ignoredEnvPrefix points clientconfig at variables nobody sets, it would read the environment itself otherwise
+const ignoredEnvPrefix = "OPENSTACK_CNI_IGNORED_"
+
+// resolvedCloudName is the name the resolved cloud is handed to clientconfig under
+const resolvedCloudName = "openstack-cni"
+
+// CloudOptsFrom reads the cloud named by OS_CLOUD from clouds.yaml and secure.yaml,
+// the OS_* variables returned by getenv fill in whatever the cloud leaves out.
+// Without OS_CLOUD everything is read from getenv.
+// Passwords, application credentials and tokens are supported.
+func CloudOptsFrom(getenv func(key string) string) (CloudOpts, error) {
+	get := func(key, defVal string) string {
+		if value := getenv(key); value != "" {
+			return value
+		}
+		return defVal
+	}
+	insecure, _ := strconv.ParseBool(get("OS_INSECURE", "false"))
+	opts := CloudOpts{
+		Region:     get("OS_REGION_NAME", "RegionOne"),
+		CACertFile: get("OS_CACERT", ""),
+		Insecure:   insecure,
+	}
+
+	cloud := &clientconfig.Cloud{}
+	if name := getenv("OS_CLOUD"); name != "" {
+		yamlOpts := &cloudsYAML{clientConfigFile: getenv("OS_CLIENT_CONFIG_FILE")}
+		var err error
+		cloud, err = clientconfig.GetCloudFromYAML(&clientconfig.ClientOpts{Cloud: name, EnvPrefix: ignoredEnvPrefix, YAMLOpts: yamlOpts})
+		if err != nil {
+			return opts, err
+		}
+		opts.Files = yamlOpts.files
+		if cloud.RegionName != "" {
+			opts.Region = cloud.RegionName
+		}
+		if cloud.CACertFile != "" {
+			opts.CACertFile = cloud.CACertFile
+		}
+		if cloud.Verify != nil {
+			opts.Insecure = !*cloud.Verify
+		}
+		// the profile was merged in already
+		cloud.Cloud, cloud.Profile = "", ""
+	}
+	if cloud.AuthInfo == nil {
+		cloud.AuthInfo = &clientconfig.AuthInfo{}
+	}
+	fillAuthInfo(cloud.AuthInfo, getenv)
+	if cloud.IdentityAPIVersion == "" {
+		cloud.IdentityAPIVersion = getenv("OS_IDENTITY_API_VERSION")
+	}
+
+	authOpts, err := clientconfig.AuthOptions(&clientconfig.ClientOpts{
+		Cloud:     resolvedCloudName,
+		EnvPrefix: ignoredEnvPrefix,
+		YAMLOpts:  resolvedCloud{cloud: *cloud},
+	})
+	if err != nil {
+		return opts, fmt.Errorf("failed to read openstack auth options err=%w", err)
+	}
+	opts.AuthOpts = *authOpts
+	return opts, nil
+}
+
+// fillAuthInfo sets the fields of info that are still empty from the OS_* variables
+func fillAuthInfo(info *clientconfig.AuthInfo, getenv func(key string) string) {
+	fields := []struct {
+		value *string
+		keys  []string
+	}{
+		{&info.AuthURL, []string{"OS_AUTH_URL"}},
+		{&info.Token, []string{"OS_AUTH_TOKEN", "OS_TOKEN"}},
+		{&info.Username, []string{"OS_USERNAME"}},
+		{&info.UserID, []string{"OS_USER_ID"}},
+		{&info.Password, []string{"OS_PASSWORD"}},
+		{&info.ProjectID, []string{"OS_PROJECT_ID", "OS_TENANT_ID"}},
+		{&info.ProjectName, []string{"OS_PROJECT_NAME", "OS_TENANT_NAME"}},
+		{&info.DomainID, []string{"OS_DOMAIN_ID"}},
+		{&info.DomainName, []string{"OS_DOMAIN_NAME"}},
+		{&info.DefaultDomain, []string{"OS_DEFAULT_DOMAIN"}},
+		{&info.ProjectDomainID, []string{"OS_PROJECT_DOMAIN_ID"}},
+		{&info.ProjectDomainName, []string{"OS_PROJECT_DOMAIN_NAME"}},
+		{&info.UserDomainID, []string{"OS_USER_DOMAIN_ID"}},
+		{&info.UserDomainName, []string{"OS_USER_DOMAIN_NAME"}},
+		{&info.ApplicationCredentialID, []string{"OS_APPLICATION_CREDENTIAL_ID"}},
+		{&info.ApplicationCredentialName, []string{"OS_APPLICATION_CREDENTIAL_NAME"}},
+		{&info.ApplicationCredentialSecret, []string{"OS_APPLICATION_CREDENTIAL_SECRET"}},
+		{&info.SystemScope, []string{"OS_SYSTEM_SCOPE"}},
+	}
+	for _, field := range fields {
+		for _, key := range field.keys {
+			if value := getenv(key); *field.value == "" && value != "" {
+				*field.value = value
+			}
+		}
+	}
+}
+
+// cloudsYAML loads clouds.yaml from OS_CLIENT_CONFIG_FILE or the usual locations and remembers the files it read
+type cloudsYAML struct {
+	clientConfigFile string
+	files            []string
+}
+
+func (me *cloudsYAML) LoadCloudsYAML() (map[string]clientconfig.Cloud, error) {
+	file := me.clientConfigFile
+	if exists, _ := util.FileExists(file); file == "" || !exists {
+		var err error
+		if file, err = findYAML("clouds.yaml", "clouds.yml"); err != nil {
+			return nil, err
+		}
+	}
+	return me.read(file)
+}
+
+func (me *cloudsYAML) LoadSecureCloudsYAML() (map[string]clientconfig.Cloud, error) {
+	file, err := findYAML("secure.yaml", "secure.yml")
+	// secure.yaml is optional
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return me.read(file)
+}
+
+func (me *cloudsYAML) LoadPublicCloudsYAML() (map[string]clientconfig.Cloud, error) {
+	return clientconfig.LoadPublicCloudsYAML()
+}
+
+func (me *cloudsYAML) read(file string) (map[string]clientconfig.Cloud, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	me.files = append(me.files, file)
+	var clouds clientconfig.Clouds
+	if err := yaml.Unmarshal(content, &clouds); err != nil {
+		return nil, fmt.Errorf("failed to parse %s err=%w", file, err)
+	}
+	return clouds.Clouds, nil
+}
+
+// findYAML returns the first of names found in the current directory, ~/.config/openstack or /etc/openstack
+func findYAML(names ...string) (string, error) {
+	var err error
+	for _, name := range names {
+		var file string
+		if file, _, err = clientconfig.FindAndReadYAML(name); file != "" {
+			return file, err
+		}
+	}
+	return "", err
+}
+
+// resolvedCloud hands a cloud that was already merged with secure.yaml and the environment to clientconfig
+type resolvedCloud struct {
+	cloud clientconfig.Cloud
+}
+
+func (me resolvedCloud) LoadCloudsYAML() (map[string]clientconfig.Cloud, error) {
+	return map[string]clientconfig.Cloud{resolvedCloudName: me.cloud}, nil
+}
+
+func (me resolvedCloud) LoadSecureCloudsYAML() (map[string]clientconfig.Cloud, error) {
+	return nil, nil
+}
+
+func (me resolvedCloud) LoadPublicCloudsYAML() (map[string]clientconfig.Cloud, error) {
+	return nil, nil
+}
+
+// Fingerprint changes whenever the options or the content of their files change
+func (me CloudOpts) Fingerprint() (string, error) {
+	hash := sha256.New()
+	authOpts := me.AuthOpts
+	authOpts.Scope = nil
+	fmt.Fprintf(hash, "%#v\n", authOpts)
+	if me.AuthOpts.Scope != nil {
+		fmt.Fprintf(hash, "%#v\n", *me.AuthOpts.Scope)
+	}
+	fmt.Fprintf(hash, "%s %s %t\n", me.Region, me.CACertFile, me.Insecure)
+	for _, file := range me.WatchedFiles() {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s err=%w", file, err)
+		}
+		fmt.Fprintf(hash, "%s %x\n", file, sha256.Sum256(content))
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// WatchedFiles are the files whose changes change the options
+func (me CloudOpts) WatchedFiles() []string {
+	files := slices.Clone(me.Files)
+	if me.CACertFile != "" {
+		files = append(files, me.CACertFile)
+	}
+	return files
+}
+
+// httpClient creates the client used for every request to Openstack
+func (me CloudOpts) httpClient() (http.Client, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: me.Insecure}
+	if me.CACertFile != "" {
+		pool, err := util.LoadCertPool(me.CACertFile)
+		if err != nil {
+			return http.Client{}, err
+		}
+		config.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,191 @@
+package openstack_test
+
+import (
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
+	. "github.com/pepinns/go-hamcrest"
+)
+
+const cloudsYaml = `
+clouds:
+  appcred:
+    auth_type: v3applicationcredential
+    auth:
+      auth_url: https://keystone.example.com:5000/v3
+      application_credential_id: appCredId
+      application_credential_secret: appCredSecret
+    region_name: RegionTwo
+    cacert: /etc/openstack/ca.pem
+  insecure:
+    auth:
+      auth_url: https://keystone.example.com:5000/v3
+      username: myuser
+      password: mypassword
+      project_name: myproject
+      user_domain_name: default
+      project_domain_name: default
+    verify: false
+`
+
+func Test_CloudOptsFromEnv(t *testing.T) {
+	withEnv := func(t *testing.T, env map[string]string) {
+		for _, key := range []string{
+			"OS_CLOUD", "OS_AUTH_URL", "OS_USERNAME", "OS_PASSWORD", "OS_PROJECT_NAME", "OS_DOMAIN_NAME", "OS_TOKEN",
+			"OS_APPLICATION_CREDENTIAL_ID", "OS_APPLICATION_CREDENTIAL_SECRET", "OS_REGION_NAME", "OS_CACERT", "OS_INSECURE",
+		} {
+			t.Setenv(key, "")
+		}
+		file := filepath.Join(t.TempDir(), "clouds.yaml")
+		Assert(t).That(os.WriteFile(file, []byte(cloudsYaml), 0600), IsNil())
+		t.Setenv("OS_CLIENT_CONFIG_FILE", file)
+		for key, value := range env {
+			t.Setenv(key, value)
+		}
+	}
+
+	t.Run("reads an application credential from clouds.yaml", func(t *testing.T) {
+		withEnv(t, map[string]string{"OS_CLOUD": "appcred", "OS_REGION_NAME": "RegionOne"})
+
+		opts, err := openstack.CloudOptsFromEnv()
+		Assert(t).That(err, IsNil())
+		Assert(t).That(opts.AuthOpts.ApplicationCredentialID, Equals("appCredId"))
+		Assert(t).That(opts.AuthOpts.ApplicationCredentialSecret, Equals("appCredSecret"))
+		Assert(t).That(opts.AuthOpts.Password, Equals(""))
+		Assert(t).That(opts.Region, Equals("RegionTwo"))
+		Assert(t).That(opts.CACertFile, Equals("/etc/openstack/ca.pem"))
+		Assert(t).That(opts.Insecure, IsFalse())
+	})
+
+	t.Run("a cloud that doesn't verify certificates is insecure", func(t *testing.T) {
+		withEnv(t, map[string]string{"OS_CLOUD": "insecure"})
+
+		opts, err := openstack.CloudOptsFromEnv()
+		Assert(t).That(err, IsNil())
+		Assert(t).That(opts.AuthOpts.Username, Equals("myuser"))
+		Assert(t).That(opts.Region, Equals("RegionOne"))
+		Assert(t).That(opts.Insecure, IsTrue())
+	})
+
+	t.Run("fails when the cloud doesn't exist", func(t *testing.T) {
+		withEnv(t, map[string]string{"OS_CLOUD": "missing"})
+
+		_, err := openstack.CloudOptsFromEnv()
+		Assert(t).That(err.Error(), Contains("missing"))
+	})
+
+	t.Run("reads the environment without OS_CLOUD", func(t *testing.T) {
+		withEnv(t, map[string]string{
+			"OS_AUTH_URL":                      "https://keystone.example.com:5000/v3",
+			"OS_APPLICATION_CREDENTIAL_ID":     "appCredId",
+			"OS_APPLICATION_CREDENTIAL_SECRET": "appCredSecret",
+			"OS_CACERT":                        "/etc/ssl/ca.pem",
+			"OS_INSECURE":                      "true",
+		})
+
+		opts, err := openstack.CloudOptsFromEnv()
+		Assert(t).That(err, IsNil())
+		Assert(t).That(opts.AuthOpts.ApplicationCredentialID, Equals("appCredId"))
+		Assert(t).That(opts.CACertFile, Equals("/etc/ssl/ca.pem"))
+		Assert(t).That(opts.Insecure, IsTrue())
+	})
+
+	t.Run("reads a token from the environment", func(t *testing.T) {
+		withEnv(t, map[string]string{
+			"OS_AUTH_URL":     "https://keystone.example.com:5000/v3",
+			"OS_TOKEN":        "myToken",
+			"OS_USERNAME":     "myuser",
+			"OS_PROJECT_NAME": "myproject",
+			"OS_DOMAIN_NAME":  "default",
+		})
+
+		opts, err := openstack.CloudOptsFromEnv()
+		Assert(t).That(err, IsNil())
+		Assert(t).That(opts.AuthOpts.TokenID, Equals("myToken"))
+		Assert(t).That(opts.AuthOpts.Username, Equals(""))
+	})
+}
+
+func Test_CloudOptsFrom(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "clouds.yaml")
+	Assert(t).That(os.WriteFile(file, []byte(cloudsYaml), 0600), IsNil())
+	t.Setenv("OS_PASSWORD", "envPassword")
+	t.Setenv("OS_CLOUD", "")
+
+	t.Run("reads only the values it's given", func(t *testing.T) {
+		values := map[string]string{"OS_AUTH_URL": "https://keystone.example.com:5000/v3", "OS_USERNAME": "myuser", "OS_PASSWORD": "mypassword"}
+
+		opts, err := openstack.CloudOptsFrom(func(key string) string { return values[key] })
+		Assert(t).That(err, IsNil())
+		Assert(t).That(opts.AuthOpts.Password, Equals("mypassword"))
+		Assert(t).That(opts.Files, HasLen(0))
+	})
+
+	t.Run("records the clouds.yaml it read", func(t *testing.T) {
+		values := map[string]string{"OS_CLOUD": "insecure", "OS_CLIENT_CONFIG_FILE": file, "OS_CACERT": "/etc/ssl/ca.pem"}
+
+		opts, err := openstack.CloudOptsFrom(func(key string) string { return values[key] })
+		Assert(t).That(err, IsNil())
+		Assert(t).That(opts.AuthOpts.Password, Equals("mypassword"))
+		Assert(t).That(opts.Files, Equals([]string{file}))
+		Assert(t).That(opts.WatchedFiles(), Equals([]string{file, "/etc/ssl/ca.pem"}))
+	})
+
+	t.Run("the fingerprint changes with the content of the files", func(t *testing.T) {
+		values := map[string]string{"OS_CLOUD": "insecure", "OS_CLIENT_CONFIG_FILE": file}
+		opts, err := openstack.CloudOptsFrom(func(key string) string { return values[key] })
+		Assert(t).That(err, IsNil())
+		before, err := opts.Fingerprint()
+		Assert(t).That(err, IsNil())
+
+		same, _ := opts.Fingerprint()
+		Assert(t).That(same, Equals(before))
+		Assert(t).That(os.WriteFile(file, []byte(cloudsYaml+"\n"), 0600), IsNil())
+		after, _ := opts.Fingerprint()
+		Assert(t).That(after, Not(Equals(before)))
+	})
+}
+
+func Test_CloudOptsTLS(t *testing.T) {
+	keystone := httptest.NewUnstartedServer(http.NotFoundHandler())
+	keystone.Config.ErrorLog = log.New(io.Discard, "", 0)
+	keystone.StartTLS()
+	t.Cleanup(keystone.Close)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: keystone.Certificate().Raw})
+	Assert(t).That(os.WriteFile(caFile, ca, 0600), IsNil())
+
+	newClients := func(opts openstack.CloudOpts) error {
+		opts.AuthOpts = gophercloud.AuthOptions{IdentityEndpoint: keystone.URL + "/v3/", Username: "myuser", Password: "mypassword", DomainName: "default"}
+		_, err := openstack.NewApiClients(opts)
+		return err
+	}
+
+	t.Run("the endpoint's certificate is verified", func(t *testing.T) {
+		err := newClients(openstack.CloudOpts{})
+		Assert(t).That(err.Error(), Contains("certificate"))
+	})
+
+	t.Run("trusts the CA bundle", func(t *testing.T) {
+		err := newClients(openstack.CloudOpts{CACertFile: caFile})
+		Assert(t).That(err.Error(), Not(Contains("certificate")))
+	})
+
+	t.Run("skips verification when insecure", func(t *testing.T) {
+		err := newClients(openstack.CloudOpts{Insecure: true})
+		Assert(t).That(err.Error(), Not(Contains("certificate")))
+	})
+
+	t.Run("fails when the CA bundle can't be read", func(t *testing.T) {
+		err := newClients(openstack.CloudOpts{CACertFile: filepath.Join(t.TempDir(), "missing.pem")})
+		Assert(t).That(err.Error(), Contains("missing.pem"))
+	})
+}
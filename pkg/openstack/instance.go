@@ -0,0 +1,132 @@
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	. "github.com/jboelensns/openstack-cni/pkg/logging"
+)
+
+var ErrInstanceIdNotFound = fmt.Errorf("instance id not found")
+
+// metaDataPath is where the config drive and the metadata service keep the instance's metadata
+const metaDataPath = "openstack/latest/meta_data.json"
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// InstanceIdOpts controls where FindInstanceId looks for the UUID of the local server
+// sources with an empty location are skipped
+type InstanceIdOpts struct {
+	// ConfigDrivePath is where the config drive is mounted
+	ConfigDrivePath string
+	// MetadataUrl is the base url of the metadata service
+	MetadataUrl string
+	// DmiPath is the file holding the DMI product UUID, which nova sets to the instance UUID
+	DmiPath string
+	// Timeout bounds the request to the metadata service
+	Timeout time.Duration
+}
+
+// FindInstanceId returns the UUID of the server this is running on
+// the config drive, the metadata service and DMI are tried in that order,
+// ErrInstanceIdNotFound is returned along with why every source failed when none has a UUID
+func FindInstanceId(ctx context.Context, opts InstanceIdOpts) (string, error) {
+	sources := []struct {
+		name     string
+		location string
+		find     func(ctx context.Context, location string) (string, error)
+	}{
+		{"config drive", opts.ConfigDrivePath, instanceIdFromConfigDrive},
+		{"metadata service", opts.MetadataUrl, func(ctx context.Context, location string) (string, error) {
+			return instanceIdFromMetadataService(ctx, location, opts.Timeout)
+		}},
+		{"dmi", opts.DmiPath, instanceIdFromDmi},
+	}
+
+	var errs error
+	for _, source := range sources {
+		if source.location == "" {
+			continue
+		}
+		id, err := source.find(ctx, source.location)
+		if err == nil {
+			err = validateInstanceId(id)
+		}
+		if err != nil {
+			Log().Debug().Str("source", source.name).AnErr("err", err).Msg("no instance id")
+			errs = multierror.Append(errs, fmt.Errorf("%s %s err=%w", source.name, source.location, err))
+			continue
+		}
+		Log().Info().Str("source", source.name).Str("instance_id", id).Msg("found instance id")
+		return id, nil
+	}
+	return "", fmt.Errorf("%w: %w", ErrInstanceIdNotFound, errs)
+}
+
+func instanceIdFromConfigDrive(ctx context.Context, path string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(path, metaDataPath))
+	if err != nil {
+		return "", err
+	}
+	return instanceIdFromMetaData(data)
+}
+
+func instanceIdFromMetadataService(ctx context.Context, url string, timeout time.Duration) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(url, "/")+"/"+metaDataPath, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return instanceIdFromMetaData(data)
+}
+
+func instanceIdFromDmi(ctx context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	// some hypervisors report the UUID in upper case
+	return strings.ToLower(strings.TrimSpace(string(data))), nil
+}
+
+func instanceIdFromMetaData(data []byte) (string, error) {
+	metaData := struct {
+		Uuid string `json:"uuid"`
+	}{}
+	if err := json.Unmarshal(data, &metaData); err != nil {
+		return "", err
+	}
+	return metaData.Uuid, nil
+}
+
+func validateInstanceId(id string) error {
+	if !uuidPattern.MatchString(id) {
+		return fmt.Errorf("invalid instance id %q", id)
+	}
+	return nil
+}
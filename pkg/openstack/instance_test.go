@@ -0,0 +1,87 @@
+package openstack_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
+	. "github.com/pepinns/go-hamcrest"
+)
+
+func Test_FindInstanceId(t *testing.T) {
+	const uuid = "4be2ed0a-23c4-4c5b-91b3-eedce17b3de2"
+	metaData := `{"uuid": "` + uuid + `", "name": "myhost"}`
+
+	configDrive := func(t *testing.T, metaData string) string {
+		dir := t.TempDir()
+		Assert(t).That(os.MkdirAll(filepath.Join(dir, "openstack", "latest"), 0755), IsNil())
+		Assert(t).That(os.WriteFile(filepath.Join(dir, "openstack", "latest", "meta_data.json"), []byte(metaData), 0644), IsNil())
+		return dir
+	}
+	metadataService := func(t *testing.T, status int, metaData string) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/openstack/latest/meta_data.json" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(metaData))
+		}))
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+	dmi := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "product_uuid")
+		Assert(t).That(os.WriteFile(path, []byte(content), 0444), IsNil())
+		return path
+	}
+	missing := func(t *testing.T) string {
+		return filepath.Join(t.TempDir(), "missing")
+	}
+
+	t.Run("reads the config drive", func(t *testing.T) {
+		opts := openstack.InstanceIdOpts{ConfigDrivePath: configDrive(t, metaData), MetadataUrl: metadataService(t, http.StatusInternalServerError, "")}
+		id, err := openstack.FindInstanceId(t.Context(), opts)
+		Assert(t).That(err, IsNil())
+		Assert(t).That(id, Equals(uuid))
+	})
+
+	t.Run("falls back to the metadata service", func(t *testing.T) {
+		opts := openstack.InstanceIdOpts{ConfigDrivePath: missing(t), MetadataUrl: metadataService(t, http.StatusOK, metaData), Timeout: time.Second}
+		id, err := openstack.FindInstanceId(t.Context(), opts)
+		Assert(t).That(err, IsNil())
+		Assert(t).That(id, Equals(uuid))
+	})
+
+	t.Run("falls back to the DMI product UUID", func(t *testing.T) {
+		opts := openstack.InstanceIdOpts{
+			ConfigDrivePath: missing(t),
+			MetadataUrl:     metadataService(t, http.StatusNotFound, ""),
+			DmiPath:         dmi(t, "4BE2ED0A-23C4-4C5B-91B3-EEDCE17B3DE2\n"),
+		}
+		id, err := openstack.FindInstanceId(t.Context(), opts)
+		Assert(t).That(err, IsNil())
+		Assert(t).That(id, Equals(uuid))
+	})
+
+	t.Run("fails when no source has a valid UUID", func(t *testing.T) {
+		opts := openstack.InstanceIdOpts{
+			ConfigDrivePath: configDrive(t, `{"name": "myhost"}`),
+			MetadataUrl:     metadataService(t, http.StatusOK, "NOT JSON"),
+			DmiPath:         dmi(t, "Not Settable\n"),
+		}
+		_, err := openstack.FindInstanceId(t.Context(), opts)
+		Assert(t).That(errors.Is(err, openstack.ErrInstanceIdNotFound), IsTrue())
+		Assert(t).That(err.Error(), Contains("not settable"))
+	})
+
+	t.Run("skips sources without a location", func(t *testing.T) {
+		_, err := openstack.FindInstanceId(t.Context(), openstack.InstanceIdOpts{})
+		Assert(t).That(errors.Is(err, openstack.ErrInstanceIdNotFound), IsTrue())
+	})
+}
@@ -0,0 +1,137 @@
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/jboelensns/openstack-cni/pkg/logging"
+)
+
+// SecurityGroupLease is a lease held by a single holder at a time
+// the lease is stored in the description of a neutron security group that is never attached to a port,
+// updates are conditional on the group's revision number so two holders can't take the lease at once
+type SecurityGroupLease struct {
+	Name     string
+	Holder   string
+	Duration time.Duration
+	client   OpenstackClient
+}
+
+// NewSecurityGroupLease creates a lease stored in the security group called name
+func NewSecurityGroupLease(client OpenstackClient, name, holder string, duration time.Duration) *SecurityGroupLease {
+	return &SecurityGroupLease{
+		Name:     name,
+		Holder:   holder,
+		Duration: duration,
+		client:   client,
+	}
+}
+
+// leaseRecord is the holder and expiry stored in the group's description
+type leaseRecord struct {
+	Holder  string
+	Expires time.Time
+}
+
+func (me leaseRecord) String() string {
+	return fmt.Sprintf("holder=%s expires=%s", me.Holder, me.Expires.UTC().Format(time.RFC3339))
+}
+
+func parseLeaseRecord(description string) leaseRecord {
+	record := leaseRecord{}
+	for _, field := range strings.Fields(description) {
+		if holder, ok := strings.CutPrefix(field, "holder="); ok {
+			record.Holder = holder
+		} else if expires, ok := strings.CutPrefix(field, "expires="); ok {
+			record.Expires, _ = time.Parse(time.RFC3339, expires)
+		}
+	}
+	return record
+}
+
+// TryAcquire takes or renews the lease, it returns false when somebody else holds it
+func (me *SecurityGroupLease) TryAcquire(ctx context.Context) (bool, error) {
+	group, err := me.group(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	record := parseLeaseRecord(group.Description)
+	if record.Holder != me.Holder && record.Holder != "" && now.Before(record.Expires) {
+		return false, nil
+	}
+
+	err = me.update(ctx, group, leaseRecord{Holder: me.Holder, Expires: now.Add(me.Duration)})
+	if statusCode(err) == http.StatusPreconditionFailed {
+		Log().Info().Str("lease", me.Name).Msg("lost the race for the lease")
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Release gives up the lease when it's held by this holder
+func (me *SecurityGroupLease) Release(ctx context.Context) error {
+	group, err := me.group(ctx)
+	if err != nil {
+		return err
+	}
+	if parseLeaseRecord(group.Description).Holder != me.Holder {
+		return nil
+	}
+	err = me.update(ctx, group, leaseRecord{})
+	if statusCode(err) == http.StatusPreconditionFailed {
+		return nil
+	}
+	return err
+}
+
+// group returns the security group storing the lease, creating it when it doesn't exist
+// when concurrent creates leave several groups every holder uses the one with the lowest ID
+func (me *SecurityGroupLease) group(ctx context.Context) (*SecurityGroup, error) {
+	groups, err := me.listGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) > 0 {
+		return &groups[0], nil
+	}
+
+	Log().Info().Str("lease", me.Name).Msg("creating lease security group")
+	if _, err := me.client.CreateSecurityGroup(ctx, me.Name, ""); err != nil {
+		return nil, fmt.Errorf("failed to create lease security group %s err=%w", me.Name, err)
+	}
+
+	groups, err = me.listGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("lease security group %s disappeared after creating it", me.Name)
+	}
+	return &groups[0], nil
+}
+
+func (me *SecurityGroupLease) listGroups(ctx context.Context) ([]SecurityGroup, error) {
+	groups, err := me.client.GetSecurityGroupsByName(ctx, me.Name)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].ID < groups[j].ID
+	})
+	return groups, nil
+}
+
+// update replaces the lease record unless the group changed since it was read
+func (me *SecurityGroupLease) update(ctx context.Context, group *SecurityGroup, record leaseRecord) error {
+	description := record.String()
+	if record.Holder == "" {
+		description = ""
+	}
+	return me.client.UpdateSecurityGroupDescription(ctx, group.ID, description, group.RevisionNumber)
+}
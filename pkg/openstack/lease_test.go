@@ -0,0 +1,156 @@
+package openstack_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
+	. "github.com/pepinns/go-hamcrest"
+)
+
+type fakeSecurityGroup struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	RevisionNumber int    `json:"revision_number"`
+}
+
+// fakeNeutron serves the security group calls of the lease and honors If-Match revision constraints
+type fakeNeutron struct {
+	mu     sync.Mutex
+	groups []*fakeSecurityGroup
+}
+
+func (me *fakeNeutron) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+
+	var body struct {
+		SecurityGroup fakeSecurityGroup `json:"security_group"`
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/security-groups":
+		groups := []*fakeSecurityGroup{}
+		for _, group := range me.groups {
+			if group.Name == r.URL.Query().Get("name") {
+				groups = append(groups, group)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"security_groups": groups})
+	case r.Method == http.MethodPost && r.URL.Path == "/security-groups":
+		json.NewDecoder(r.Body).Decode(&body)
+		group := &fakeSecurityGroup{ID: fmt.Sprintf("sg-%d", len(me.groups)), Name: body.SecurityGroup.Name, RevisionNumber: 1}
+		me.groups = append(me.groups, group)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"security_group": group})
+	case r.Method == http.MethodPut:
+		id := strings.TrimPrefix(r.URL.Path, "/security-groups/")
+		for _, group := range me.groups {
+			if group.ID != id {
+				continue
+			}
+			if r.Header.Get("If-Match") != fmt.Sprintf("revision_number=%d", group.RevisionNumber) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			group.Description = body.SecurityGroup.Description
+			group.RevisionNumber++
+			json.NewEncoder(w).Encode(map[string]any{"security_group": group})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func Test_SecurityGroupLease(t *testing.T) {
+	newLease := func(neutron *fakeNeutron, holder string) *openstack.SecurityGroupLease {
+		server := httptest.NewServer(neutron)
+		t.Cleanup(server.Close)
+		clients := &openstack.ApiClients{NetworkClient: &gophercloud.ServiceClient{
+			ProviderClient: &gophercloud.ProviderClient{},
+			Endpoint:       server.URL + "/",
+		}}
+		return openstack.NewSecurityGroupLease(openstack.NewOpenstackClientWithApiClients(clients), "openstack-cni-reaper-lease", holder, time.Minute)
+	}
+
+	t.Run("the first holder creates the group and takes the lease", func(t *testing.T) {
+		neutron := &fakeNeutron{}
+		acquired, err := newLease(neutron, "node1").TryAcquire(t.Context())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(acquired, IsTrue())
+		Assert(t).That(neutron.groups, HasLen(1))
+		Assert(t).That(neutron.groups[0].Description, Contains("holder=node1"))
+	})
+
+	t.Run("only one holder gets the lease until it expires or is released", func(t *testing.T) {
+		neutron := &fakeNeutron{}
+		node1 := newLease(neutron, "node1")
+		node2 := newLease(neutron, "node2")
+
+		acquired, err := node1.TryAcquire(t.Context())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(acquired, IsTrue())
+
+		acquired, err = node2.TryAcquire(t.Context())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(acquired, IsFalse())
+
+		acquired, err = node1.TryAcquire(t.Context())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(acquired, IsTrue())
+
+		Assert(t).That(node1.Release(t.Context()), IsNil())
+		acquired, err = node2.TryAcquire(t.Context())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(acquired, IsTrue())
+	})
+
+	t.Run("an expired lease is taken over", func(t *testing.T) {
+		neutron := &fakeNeutron{}
+		node1 := newLease(neutron, "node1")
+		node1.Duration = -time.Second
+		acquired, err := node1.TryAcquire(t.Context())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(acquired, IsTrue())
+
+		acquired, err = newLease(neutron, "node2").TryAcquire(t.Context())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(acquired, IsTrue())
+	})
+
+	t.Run("concurrent holders never both get the lease", func(t *testing.T) {
+		neutron := &fakeNeutron{groups: []*fakeSecurityGroup{{ID: "sg", Name: "openstack-cni-reaper-lease", RevisionNumber: 1}}}
+		results := make(chan bool, 10)
+		var wg sync.WaitGroup
+		for i := 0; i < cap(results); i++ {
+			lease := newLease(neutron, fmt.Sprintf("node%d", i))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				acquired, err := lease.TryAcquire(t.Context())
+				Assert(t).That(err, IsNil())
+				results <- acquired
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		holders := 0
+		for acquired := range results {
+			if acquired {
+				holders++
+			}
+		}
+		Assert(t).That(holders, Equals(1))
+	})
+}
@@ -0,0 +1,220 @@
+package openstack
+
+import (
+	"context"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
+)
+
+var _ OpenstackClient = &LimitingClient{}
+
+// LimiterMetrics records how many OpenStack operations wait for a slot, how many run and how long they waited
+type LimiterMetrics interface {
+	OperationWaiting(delta int)
+	OperationInFlight(delta int)
+	OperationWaited(operation string, duration time.Duration)
+}
+
+// LimitingClient bounds the number of OpenstackClient operations running at once,
+// so that a burst of pods doesn't overwhelm the OpenStack APIs
+// every attempt of a retried operation takes its own slot, backoffs don't hold one
+type LimitingClient struct {
+	OpenstackClient OpenstackClient
+	Metrics         LimiterMetrics
+	slots           chan struct{}
+}
+
+// NewLimitingClient creates a LimitingClient running at most maxConcurrent operations at a time
+// a maxConcurrent below 1 doesn't limit concurrency
+func NewLimitingClient(client OpenstackClient, maxConcurrent int, metrics LimiterMetrics) *LimitingClient {
+	limiter := &LimitingClient{OpenstackClient: client, Metrics: metrics}
+	if maxConcurrent > 0 {
+		limiter.slots = make(chan struct{}, maxConcurrent)
+	}
+	return limiter
+}
+
+// acquire blocks until a slot is available or ctx is done, the returned func releases the slot
+func (me *LimitingClient) acquire(ctx context.Context, operation string) (func(), error) {
+	start := time.Now()
+	me.Metrics.OperationWaiting(1)
+	defer func() {
+		me.Metrics.OperationWaiting(-1)
+		me.Metrics.OperationWaited(operation, time.Since(start))
+	}()
+
+	if me.slots != nil {
+		select {
+		case me.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	me.Metrics.OperationInFlight(1)
+	return func() {
+		me.Metrics.OperationInFlight(-1)
+		if me.slots != nil {
+			<-me.slots
+		}
+	}, nil
+}
+
+// limit calls fn once a slot is available
+func limit[T any](ctx context.Context, me *LimitingClient, operation string, fn func() (T, error)) (T, error) {
+	release, err := me.acquire(ctx, operation)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer release()
+	return fn()
+}
+
+func limitErr(ctx context.Context, me *LimitingClient, operation string, fn func() error) error {
+	_, err := limit(ctx, me, operation, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// AssignPort attaches a port to a server
+func (me *LimitingClient) AssignPort(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error) {
+	return limit(ctx, me, "AssignPort", func() (*attachinterfaces.Interface, error) {
+		return me.OpenstackClient.AssignPort(ctx, portId, serverId)
+	})
+}
+
+func (me *LimitingClient) Clients() *ApiClients {
+	return me.OpenstackClient.Clients()
+}
+
+// CreatePort creates a neutron port inside of the specified network
+func (me *LimitingClient) CreatePort(ctx context.Context, opts ports.CreateOpts, extraOpts *ExtraCreatePortOpts) (*ports.Port, error) {
+	return limit(ctx, me, "CreatePort", func() (*ports.Port, error) {
+		return me.OpenstackClient.CreatePort(ctx, opts, extraOpts)
+	})
+}
+
+func (me *LimitingClient) CreateSecurityGroup(ctx context.Context, name, description string) (*SecurityGroup, error) {
+	return limit(ctx, me, "CreateSecurityGroup", func() (*SecurityGroup, error) {
+		return me.OpenstackClient.CreateSecurityGroup(ctx, name, description)
+	})
+}
+
+// DeletePort deletes the port
+func (me *LimitingClient) DeletePort(ctx context.Context, portId string) error {
+	return limitErr(ctx, me, "DeletePort", func() error {
+		return me.OpenstackClient.DeletePort(ctx, portId)
+	})
+}
+
+// Detach port removes a port's relationship from a server
+func (me *LimitingClient) DetachPort(ctx context.Context, portId, serverId string) error {
+	return limitErr(ctx, me, "DetachPort", func() error {
+		return me.OpenstackClient.DetachPort(ctx, portId, serverId)
+	})
+}
+
+func (me *LimitingClient) GetNetwork(ctx context.Context, id string) (*Network, error) {
+	return limit(ctx, me, "GetNetwork", func() (*Network, error) {
+		return me.OpenstackClient.GetNetwork(ctx, id)
+	})
+}
+
+func (me *LimitingClient) GetNetworkByName(ctx context.Context, name string) (*Network, error) {
+	return limit(ctx, me, "GetNetworkByName", func() (*Network, error) {
+		return me.OpenstackClient.GetNetworkByName(ctx, name)
+	})
+}
+
+func (me *LimitingClient) GetPort(ctx context.Context, portId string) (*ports.Port, error) {
+	return limit(ctx, me, "GetPort", func() (*ports.Port, error) {
+		return me.OpenstackClient.GetPort(ctx, portId)
+	})
+}
+
+func (me *LimitingClient) GetPortWithBinding(ctx context.Context, portId string) (*PortWithBinding, error) {
+	return limit(ctx, me, "GetPortWithBinding", func() (*PortWithBinding, error) {
+		return me.OpenstackClient.GetPortWithBinding(ctx, portId)
+	})
+}
+
+func (me *LimitingClient) GetPortsByDeviceId(ctx context.Context, deviceId string) ([]ports.Port, error) {
+	return limit(ctx, me, "GetPortsByDeviceId", func() ([]ports.Port, error) {
+		return me.OpenstackClient.GetPortsByDeviceId(ctx, deviceId)
+	})
+}
+
+func (me *LimitingClient) GetPortByTags(ctx context.Context, tags []string) (*ports.Port, error) {
+	return limit(ctx, me, "GetPortByTags", func() (*ports.Port, error) {
+		return me.OpenstackClient.GetPortByTags(ctx, tags)
+	})
+}
+
+func (me *LimitingClient) GetPortsByTags(ctx context.Context, tags []string) ([]ports.Port, error) {
+	return limit(ctx, me, "GetPortsByTags", func() ([]ports.Port, error) {
+		return me.OpenstackClient.GetPortsByTags(ctx, tags)
+	})
+}
+
+func (me *LimitingClient) GetProjectByName(ctx context.Context, name string) (*projects.Project, error) {
+	return limit(ctx, me, "GetProjectByName", func() (*projects.Project, error) {
+		return me.OpenstackClient.GetProjectByName(ctx, name)
+	})
+}
+
+func (me *LimitingClient) GetServer(ctx context.Context, id string) (*servers.Server, error) {
+	return limit(ctx, me, "GetServer", func() (*servers.Server, error) {
+		return me.OpenstackClient.GetServer(ctx, id)
+	})
+}
+
+func (me *LimitingClient) GetServerByName(ctx context.Context, name string) (*servers.Server, error) {
+	return limit(ctx, me, "GetServerByName", func() (*servers.Server, error) {
+		return me.OpenstackClient.GetServerByName(ctx, name)
+	})
+}
+
+func (me *LimitingClient) GetSecurityGroupByName(ctx context.Context, name string, projectId string) (*groups.SecGroup, error) {
+	return limit(ctx, me, "GetSecurityGroupByName", func() (*groups.SecGroup, error) {
+		return me.OpenstackClient.GetSecurityGroupByName(ctx, name, projectId)
+	})
+}
+
+func (me *LimitingClient) GetSecurityGroupsByName(ctx context.Context, name string) ([]SecurityGroup, error) {
+	return limit(ctx, me, "GetSecurityGroupsByName", func() ([]SecurityGroup, error) {
+		return me.OpenstackClient.GetSecurityGroupsByName(ctx, name)
+	})
+}
+
+func (me *LimitingClient) GetSubnet(ctx context.Context, id string) (*subnets.Subnet, error) {
+	return limit(ctx, me, "GetSubnet", func() (*subnets.Subnet, error) {
+		return me.OpenstackClient.GetSubnet(ctx, id)
+	})
+}
+
+func (me *LimitingClient) GetSubnetByName(ctx context.Context, name, networkId string) (*subnets.Subnet, error) {
+	return limit(ctx, me, "GetSubnetByName", func() (*subnets.Subnet, error) {
+		return me.OpenstackClient.GetSubnetByName(ctx, name, networkId)
+	})
+}
+
+// SetPortTags replaces the tags of a port
+func (me *LimitingClient) SetPortTags(ctx context.Context, portId string, tags []string) error {
+	return limitErr(ctx, me, "SetPortTags", func() error {
+		return me.OpenstackClient.SetPortTags(ctx, portId, tags)
+	})
+}
+
+func (me *LimitingClient) UpdateSecurityGroupDescription(ctx context.Context, id, description string, revisionNumber int) error {
+	return limitErr(ctx, me, "UpdateSecurityGroupDescription", func() error {
+		return me.OpenstackClient.UpdateSecurityGroupDescription(ctx, id, description, revisionNumber)
+	})
+}
@@ -0,0 +1,107 @@
+package openstack_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/jboelensns/openstack-cni/pkg/fixtures/mocks"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
+	. "github.com/pepinns/go-hamcrest"
+)
+
+type limiterRecorder struct {
+	mu       sync.Mutex
+	waiting  int
+	inFlight int
+	waited   map[string]int
+}
+
+func (me *limiterRecorder) OperationWaiting(delta int) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.waiting += delta
+}
+
+func (me *limiterRecorder) OperationInFlight(delta int) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.inFlight += delta
+}
+
+func (me *limiterRecorder) OperationWaited(operation string, duration time.Duration) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.waited[operation]++
+}
+
+func Test_LimitingClient(t *testing.T) {
+	newClient := func(maxConcurrent int) (*mocks.OpenstackClientMock, *limiterRecorder, chan struct{}, openstack.OpenstackClient) {
+		unblock := make(chan struct{})
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetPortFunc = func(ctx context.Context, portId string) (*ports.Port, error) {
+			<-unblock
+			return &ports.Port{ID: portId}, nil
+		}
+		recorder := &limiterRecorder{waited: map[string]int{}}
+		return mock, recorder, unblock, openstack.NewLimitingClient(mock, maxConcurrent, recorder)
+	}
+	getPortAsync := func(client openstack.OpenstackClient, ctx context.Context) chan error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := client.GetPort(ctx, "port")
+			done <- err
+		}()
+		return done
+	}
+	isBlocked := func(done chan error) bool {
+		select {
+		case <-done:
+			return false
+		case <-time.After(100 * time.Millisecond):
+			return true
+		}
+	}
+
+	t.Run("bounds the number of concurrent operations", func(t *testing.T) {
+		mock, recorder, unblock, client := newClient(1)
+		first := getPortAsync(client, context.Background())
+		Assert(t).That(isBlocked(first), IsTrue())
+
+		second := getPortAsync(client, context.Background())
+		Assert(t).That(isBlocked(second), IsTrue())
+		Assert(t).That(len(mock.GetPortCalls()), Equals(1))
+
+		close(unblock)
+		Assert(t).That(isBlocked(first), IsFalse())
+		Assert(t).That(isBlocked(second), IsFalse())
+		Assert(t).That(len(mock.GetPortCalls()), Equals(2))
+		Assert(t).That(recorder.waiting, Equals(0))
+		Assert(t).That(recorder.inFlight, Equals(0))
+		Assert(t).That(recorder.waited["GetPort"], Equals(2))
+	})
+
+	t.Run("doesn't limit concurrency without a maximum", func(t *testing.T) {
+		mock, _, unblock, client := newClient(0)
+		defer close(unblock)
+		getPortAsync(client, context.Background())
+		getPortAsync(client, context.Background())
+
+		time.Sleep(100 * time.Millisecond)
+		Assert(t).That(len(mock.GetPortCalls()), Equals(2))
+	})
+
+	t.Run("gives up waiting when the context is done", func(t *testing.T) {
+		mock, _, unblock, client := newClient(1)
+		defer close(unblock)
+		Assert(t).That(isBlocked(getPortAsync(client, context.Background())), IsTrue())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := client.GetPort(ctx, "port")
+		Assert(t).That(err, Equals(context.DeadlineExceeded))
+		Assert(t).That(len(mock.GetPortCalls()), Equals(1))
+	})
+}
@@ -0,0 +1,215 @@
+package openstack
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
+)
+
+var _ OpenstackClient = &MetricsClient{}
+
+// ClientMetrics records how long OpenStack operations take and how they end
+type ClientMetrics interface {
+	ObserveOperation(operation, outcome string, duration time.Duration)
+}
+
+// CacheMetrics records whether cached OpenStack operations were answered from the cache
+type CacheMetrics interface {
+	CacheHit(operation string)
+	CacheMiss(operation string)
+}
+
+// MetricsClient records the duration and outcome of every OpenstackClient operation
+type MetricsClient struct {
+	OpenstackClient OpenstackClient
+	Metrics         ClientMetrics
+}
+
+// NewMetricsClient creates a MetricsClient
+func NewMetricsClient(client OpenstackClient, metrics ClientMetrics) *MetricsClient {
+	return &MetricsClient{OpenstackClient: client, Metrics: metrics}
+}
+
+// Outcome classifies the result of an operation as success, not_found, cancelled or error
+func Outcome(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "cancelled"
+	case isNotFound(err):
+		return "not_found"
+	}
+	return "error"
+}
+
+func isNotFound(err error) bool {
+	for _, notFound := range []error{ErrServerNotFound, ErrNetworkNotFound, ErrPortNotFound, ErrProjectNotFound, ErrSecurityGroupNotFound, ErrSubnetNotFound} {
+		if errors.Is(err, notFound) {
+			return true
+		}
+	}
+	return statusCode(err) == http.StatusNotFound
+}
+
+func observe[T any](ctx context.Context, me *MetricsClient, operation string, fn func() (T, error)) (T, error) {
+	start := time.Now()
+	val, err := fn()
+	outcome := Outcome(err)
+	if err != nil && ctx.Err() != nil {
+		// gophercloud doesn't wrap the context's error
+		outcome = Outcome(ctx.Err())
+	}
+	me.Metrics.ObserveOperation(operation, outcome, time.Since(start))
+	return val, err
+}
+
+func observeErr(ctx context.Context, me *MetricsClient, operation string, fn func() error) error {
+	_, err := observe(ctx, me, operation, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// AssignPort attaches a port to a server
+func (me *MetricsClient) AssignPort(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error) {
+	return observe(ctx, me, "AssignPort", func() (*attachinterfaces.Interface, error) {
+		return me.OpenstackClient.AssignPort(ctx, portId, serverId)
+	})
+}
+
+func (me *MetricsClient) Clients() *ApiClients {
+	return me.OpenstackClient.Clients()
+}
+
+// CreatePort creates a neutron port inside of the specified network
+func (me *MetricsClient) CreatePort(ctx context.Context, opts ports.CreateOpts, extraOpts *ExtraCreatePortOpts) (*ports.Port, error) {
+	return observe(ctx, me, "CreatePort", func() (*ports.Port, error) {
+		return me.OpenstackClient.CreatePort(ctx, opts, extraOpts)
+	})
+}
+
+func (me *MetricsClient) CreateSecurityGroup(ctx context.Context, name, description string) (*SecurityGroup, error) {
+	return observe(ctx, me, "CreateSecurityGroup", func() (*SecurityGroup, error) {
+		return me.OpenstackClient.CreateSecurityGroup(ctx, name, description)
+	})
+}
+
+// DeletePort deletes the port
+func (me *MetricsClient) DeletePort(ctx context.Context, portId string) error {
+	return observeErr(ctx, me, "DeletePort", func() error {
+		return me.OpenstackClient.DeletePort(ctx, portId)
+	})
+}
+
+// Detach port removes a port's relationship from a server
+func (me *MetricsClient) DetachPort(ctx context.Context, portId, serverId string) error {
+	return observeErr(ctx, me, "DetachPort", func() error {
+		return me.OpenstackClient.DetachPort(ctx, portId, serverId)
+	})
+}
+
+func (me *MetricsClient) GetNetwork(ctx context.Context, id string) (*Network, error) {
+	return observe(ctx, me, "GetNetwork", func() (*Network, error) {
+		return me.OpenstackClient.GetNetwork(ctx, id)
+	})
+}
+
+func (me *MetricsClient) GetNetworkByName(ctx context.Context, name string) (*Network, error) {
+	return observe(ctx, me, "GetNetworkByName", func() (*Network, error) {
+		return me.OpenstackClient.GetNetworkByName(ctx, name)
+	})
+}
+
+func (me *MetricsClient) GetPort(ctx context.Context, portId string) (*ports.Port, error) {
+	return observe(ctx, me, "GetPort", func() (*ports.Port, error) {
+		return me.OpenstackClient.GetPort(ctx, portId)
+	})
+}
+
+func (me *MetricsClient) GetPortWithBinding(ctx context.Context, portId string) (*PortWithBinding, error) {
+	return observe(ctx, me, "GetPortWithBinding", func() (*PortWithBinding, error) {
+		return me.OpenstackClient.GetPortWithBinding(ctx, portId)
+	})
+}
+
+func (me *MetricsClient) GetPortsByDeviceId(ctx context.Context, deviceId string) ([]ports.Port, error) {
+	return observe(ctx, me, "GetPortsByDeviceId", func() ([]ports.Port, error) {
+		return me.OpenstackClient.GetPortsByDeviceId(ctx, deviceId)
+	})
+}
+
+func (me *MetricsClient) GetPortByTags(ctx context.Context, tags []string) (*ports.Port, error) {
+	return observe(ctx, me, "GetPortByTags", func() (*ports.Port, error) {
+		return me.OpenstackClient.GetPortByTags(ctx, tags)
+	})
+}
+
+func (me *MetricsClient) GetPortsByTags(ctx context.Context, tags []string) ([]ports.Port, error) {
+	return observe(ctx, me, "GetPortsByTags", func() ([]ports.Port, error) {
+		return me.OpenstackClient.GetPortsByTags(ctx, tags)
+	})
+}
+
+func (me *MetricsClient) GetProjectByName(ctx context.Context, name string) (*projects.Project, error) {
+	return observe(ctx, me, "GetProjectByName", func() (*projects.Project, error) {
+		return me.OpenstackClient.GetProjectByName(ctx, name)
+	})
+}
+
+func (me *MetricsClient) GetServer(ctx context.Context, id string) (*servers.Server, error) {
+	return observe(ctx, me, "GetServer", func() (*servers.Server, error) {
+		return me.OpenstackClient.GetServer(ctx, id)
+	})
+}
+
+func (me *MetricsClient) GetServerByName(ctx context.Context, name string) (*servers.Server, error) {
+	return observe(ctx, me, "GetServerByName", func() (*servers.Server, error) {
+		return me.OpenstackClient.GetServerByName(ctx, name)
+	})
+}
+
+func (me *MetricsClient) GetSecurityGroupByName(ctx context.Context, name string, projectId string) (*groups.SecGroup, error) {
+	return observe(ctx, me, "GetSecurityGroupByName", func() (*groups.SecGroup, error) {
+		return me.OpenstackClient.GetSecurityGroupByName(ctx, name, projectId)
+	})
+}
+
+func (me *MetricsClient) GetSecurityGroupsByName(ctx context.Context, name string) ([]SecurityGroup, error) {
+	return observe(ctx, me, "GetSecurityGroupsByName", func() ([]SecurityGroup, error) {
+		return me.OpenstackClient.GetSecurityGroupsByName(ctx, name)
+	})
+}
+
+func (me *MetricsClient) GetSubnet(ctx context.Context, id string) (*subnets.Subnet, error) {
+	return observe(ctx, me, "GetSubnet", func() (*subnets.Subnet, error) {
+		return me.OpenstackClient.GetSubnet(ctx, id)
+	})
+}
+
+func (me *MetricsClient) GetSubnetByName(ctx context.Context, name, networkId string) (*subnets.Subnet, error) {
+	return observe(ctx, me, "GetSubnetByName", func() (*subnets.Subnet, error) {
+		return me.OpenstackClient.GetSubnetByName(ctx, name, networkId)
+	})
+}
+
+// SetPortTags replaces the tags of a port
+func (me *MetricsClient) SetPortTags(ctx context.Context, portId string, tags []string) error {
+	return observeErr(ctx, me, "SetPortTags", func() error {
+		return me.OpenstackClient.SetPortTags(ctx, portId, tags)
+	})
+}
+
+func (me *MetricsClient) UpdateSecurityGroupDescription(ctx context.Context, id, description string, revisionNumber int) error {
+	return observeErr(ctx, me, "UpdateSecurityGroupDescription", func() error {
+		return me.OpenstackClient.UpdateSecurityGroupDescription(ctx, id, description, revisionNumber)
+	})
+}
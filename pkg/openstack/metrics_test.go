@@ -0,0 +1,115 @@
+package openstack_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/jboelensns/openstack-cni/pkg/fixtures/mocks"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
+	. "github.com/pepinns/go-hamcrest"
+)
+
+type metricsRecorder struct {
+	operations map[string]int
+	hits       map[string]int
+	misses     map[string]int
+}
+
+func newMetricsRecorder() *metricsRecorder {
+	return &metricsRecorder{operations: map[string]int{}, hits: map[string]int{}, misses: map[string]int{}}
+}
+
+func (me *metricsRecorder) ObserveOperation(operation, outcome string, duration time.Duration) {
+	me.operations[operation+"/"+outcome]++
+}
+
+func (me *metricsRecorder) CacheHit(operation string) {
+	me.hits[operation]++
+}
+
+func (me *metricsRecorder) CacheMiss(operation string) {
+	me.misses[operation]++
+}
+
+func Test_MetricsClient(t *testing.T) {
+	t.Run("records every operation with its outcome", func(t *testing.T) {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetPortFunc = func(ctx context.Context, portId string) (*ports.Port, error) {
+			if portId == "missing" {
+				return nil, openstack.ErrPortNotFound
+			}
+			return &ports.Port{ID: portId}, nil
+		}
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error {
+			return errors.New("BOOM")
+		}
+		recorder := newMetricsRecorder()
+		client := openstack.NewMetricsClient(mock, recorder)
+
+		port, err := client.GetPort(context.Background(), "port")
+		Assert(t).That(err, IsNil())
+		Assert(t).That(port.ID, Equals("port"))
+		_, err = client.GetPort(context.Background(), "missing")
+		Assert(t).That(err, Equals(openstack.ErrPortNotFound))
+		Assert(t).That(client.DeletePort(context.Background(), "port").Error(), Equals("BOOM"))
+
+		Assert(t).That(recorder.operations, Equals(map[string]int{
+			"GetPort/success":   1,
+			"GetPort/not_found": 1,
+			"DeletePort/error":  1,
+		}))
+	})
+
+	t.Run("records cancelled operations", func(t *testing.T) {
+		mock := &mocks.OpenstackClientMock{}
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error {
+			return errors.New("request aborted")
+		}
+		recorder := newMetricsRecorder()
+		client := openstack.NewMetricsClient(mock, recorder)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Assert(t).That(client.DeletePort(ctx, "port"), Not(IsNil()))
+		Assert(t).That(recorder.operations["DeletePort/cancelled"], Equals(1))
+	})
+}
+
+func Test_Outcome(t *testing.T) {
+	tests := []struct {
+		err     error
+		outcome string
+	}{
+		{nil, "success"},
+		{context.Canceled, "cancelled"},
+		{fmt.Errorf("wrapped err=%w", context.DeadlineExceeded), "cancelled"},
+		{openstack.ErrServerNotFound, "not_found"},
+		{statusError(404), "not_found"},
+		{statusError(500), "error"},
+		{errors.New("BOOM"), "error"},
+	}
+	for _, test := range tests {
+		Assert(t).That(openstack.Outcome(test.err), Equals(test.outcome))
+	}
+}
+
+func Test_CacheMetrics(t *testing.T) {
+	mock := &mocks.OpenstackClientMock{}
+	mock.GetPortFunc = func(ctx context.Context, portId string) (*ports.Port, error) {
+		return &ports.Port{ID: portId}, nil
+	}
+	recorder := newMetricsRecorder()
+	client := openstack.NewCachedClient(mock, time.Minute)
+	client.Metrics = recorder
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetPort(context.Background(), "port")
+		Assert(t).That(err, IsNil())
+	}
+	Assert(t).That(recorder.misses["GetPort"], Equals(1))
+	Assert(t).That(recorder.hits["GetPort"], Equals(2))
+}
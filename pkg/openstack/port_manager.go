@@ -1,36 +1,70 @@
 package openstack
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
-	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
+	"github.com/hashicorp/go-multierror"
 	. "github.com/jboelensns/openstack-cni/pkg/logging"
 	"github.com/jboelensns/openstack-cni/pkg/util"
+	"github.com/rs/zerolog"
 )
 
 func NewPortManager(client OpenstackClient) *PortManager {
-	return &PortManager{client}
+	return &PortManager{client: client}
 }
 
 // PortManager provides the ability to execute various compound port actions
+// PortWait controls how long SetupPort waits for an attached port to become usable
+// ServerId is the UUID of the local server, when it's empty the server is looked up by hostname
 type PortManager struct {
-	client OpenstackClient
+	client   OpenstackClient
+	PortWait PortWaitOpts
+	ServerId string
+}
+
+// server returns the local server
+// looking it up by ID is preferred since several servers can share a name
+func (me *PortManager) server(ctx context.Context, hostname string) (*servers.Server, error) {
+	if me.ServerId != "" {
+		return me.client.GetServer(ctx, me.ServerId)
+	}
+	return me.client.GetServerByName(ctx, hostname)
+}
+
+// PortWaitOpts controls how long and how often a port is polled, a Timeout of 0 doesn't wait
+type PortWaitOpts struct {
+	Timeout  time.Duration
+	Interval time.Duration
 }
 
 // SetupPort creates a new port and assigns it to a server
-func (me *PortManager) SetupPort(opts SetupPortOpts) (*SetupPortResult, error) {
+// if any step fails the completed steps are rolled back in reverse order
+func (me *PortManager) SetupPort(ctx context.Context, opts SetupPortOpts) (*SetupPortResult, error) {
+	undo := &rollback{}
+	result, err := me.setupPort(ctx, opts, undo)
+	if err != nil {
+		return nil, undo.Run(ctx, err)
+	}
+	return result, nil
+}
+
+func (me *PortManager) setupPort(ctx context.Context, opts SetupPortOpts, undo *rollback) (*SetupPortResult, error) {
 	log := Log().With().Str("command", "ADD").Str("hostname", opts.Hostname).Str("networkName", opts.NetworkName).Str("projectName", opts.ProjectName).Str("portName", opts.PortName).Logger()
 	result := &SetupPortResult{}
 	var err error
 
 	// look up the server
 	log.Info().Msg("looking up server")
-	result.Server, err = me.client.GetServerByName(opts.Hostname)
+	result.Server, err = me.server(ctx, opts.Hostname)
 	if err != nil {
 		return result, err
 	}
@@ -39,9 +73,16 @@ func (me *PortManager) SetupPort(opts SetupPortOpts) (*SetupPortResult, error) {
 	}
 	log.Info().Msg("found server")
 
-	// find the network by name
-	log.Info().Msg("looking up network")
-	result.Network, err = me.client.GetNetworkByName(opts.NetworkName)
+	// find the network by ID or name
+	if err := cancelled(ctx, "looking up the network"); err != nil {
+		return result, err
+	}
+	log.Info().Str("networkId", opts.NetworkId).Msg("looking up network")
+	if opts.NetworkId != "" {
+		result.Network, err = me.client.GetNetwork(ctx, opts.NetworkId)
+	} else {
+		result.Network, err = me.client.GetNetworkByName(ctx, opts.NetworkName)
+	}
 	if err != nil {
 		return result, err
 	}
@@ -50,17 +91,188 @@ func (me *PortManager) SetupPort(opts SetupPortOpts) (*SetupPortResult, error) {
 	}
 	log.Info().Msg("found network")
 
-	if opts.SecurityGroups != nil && len(*opts.SecurityGroups) > 0 {
-		projectId := ""
+	// reuse an existing port for the same container/interface
+	// this prevents retried ADDs from creating additional ports
+	result.Port, err = me.findExistingPort(ctx, opts)
+	if err != nil {
+		return result, err
+	}
+	reused := result.Port != nil
+	if reused {
+		log.Info().Str("portId", result.Port.ID).Msg("found existing port, reusing it")
+		// the port belongs to this attachment and is rolled back like a created one,
+		// unless it's attached to another device which fails below
+		if port := result.Port; port.DeviceID == "" || port.DeviceID == result.Server.ID {
+			undo.Add("reuse port", func(ctx context.Context) error { return me.client.DeletePort(ctx, port.ID) })
+			if port.DeviceID != "" {
+				undo.Add("reuse attached port", func(ctx context.Context) error { return me.client.DetachPort(ctx, port.ID, port.DeviceID) })
+			}
+		}
+	} else {
+		if err := cancelled(ctx, "creating the port"); err != nil {
+			return result, err
+		}
+		port, err := me.createPort(ctx, opts, result)
+		if port != nil {
+			undo.Add("create port", func(ctx context.Context) error { return me.client.DeletePort(ctx, port.ID) })
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Port = port
+	}
+
+	// lookup the subnets that the port's fixed ips came from
+	if err := cancelled(ctx, "looking up the subnets"); err != nil {
+		return result, err
+	}
+	if len(result.Port.FixedIPs) == 0 {
+		return result, fmt.Errorf("port %s has no fixed ips", result.Port.ID)
+	}
+	result.Subnets = make(map[string]*subnets.Subnet)
+	for _, fixedIp := range result.Port.FixedIPs {
+		if _, found := result.Subnets[fixedIp.SubnetID]; found {
+			continue
+		}
+		log.Info().Str("subnetId", fixedIp.SubnetID).Msg("looking up subnet by id")
+		subnet, err := me.client.GetSubnet(ctx, fixedIp.SubnetID)
+		if err != nil {
+			return result, err
+		}
+		if subnet == nil {
+			return result, fmt.Errorf("failed to find subnet with ID %s", fixedIp.SubnetID)
+		}
+		log.Info().Str("subnetId", fixedIp.SubnetID).Msg("found subnet by id")
+		result.Subnets[fixedIp.SubnetID] = subnet
+	}
+	result.Subnet = result.Subnets[result.Port.FixedIPs[0].SubnetID]
+
+	if !opts.SkipPortAttach {
+		if reused && result.Port.DeviceID == result.Server.ID {
+			// a previous ADD already attached the port
+			log.Info().Str("portId", result.Port.ID).Str("serverId", result.Server.ID).Msg("port is already assigned to server")
+			result.Attachment = attachmentFromPort(result.Port)
+			return result, me.waitForPort(ctx, result, log)
+		}
+		if reused && result.Port.DeviceID != "" {
+			return result, fmt.Errorf("existing port %s is attached to another device %s", result.Port.ID, result.Port.DeviceID)
+		}
+
+		// assign the port to the VM
+		if err := cancelled(ctx, "assigning the port"); err != nil {
+			return result, err
+		}
+		log.Info().Str("portId", result.Port.ID).Str("serverId", result.Server.ID).Msg("assigning port to server")
+		result.Attachment, err = me.client.AssignPort(ctx, result.Port.ID, result.Server.ID)
+		if err != nil {
+			return result, err
+		}
+		portId, serverId := result.Port.ID, result.Server.ID
+		undo.Add("assign port", func(ctx context.Context) error { return me.client.DetachPort(ctx, portId, serverId) })
+		log.Info().Str("portId", result.Port.ID).Str("serverId", result.Server.ID).Msg("assigned port to server")
+
+		if err := me.waitForPort(ctx, result, log); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// cancelled returns ctx's error annotated with the step that was about to start
+func cancelled(ctx context.Context, step string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("cancelled before %s: %w", step, err)
+	}
+	return nil
+}
+
+var ErrPortWaitTimeout = fmt.Errorf("timed out waiting for port")
+var ErrPortBindingFailed = fmt.Errorf("port binding failed")
+
+// waitForPort polls the port until nova attached it to the server, neutron bound it and it is ACTIVE
+// the result's port is updated with the last state that was seen
+func (me *PortManager) waitForPort(ctx context.Context, result *SetupPortResult, log zerolog.Logger) error {
+	if me.PortWait.Timeout <= 0 {
+		return nil
+	}
+
+	portId, serverId := result.Port.ID, result.Server.ID
+	log.Info().Str("portId", portId).Str("timeout", me.PortWait.Timeout.String()).Msg("waiting for port to become ACTIVE")
+	deadline := time.Now().Add(me.PortWait.Timeout)
+	for {
+		port, err := me.client.GetPortWithBinding(ctx, portId)
+		if err != nil {
+			return err
+		}
+		result.Port = &port.Port
+
+		if port.VIFType == "binding_failed" {
+			return fmt.Errorf("%w port=%s host=%s", ErrPortBindingFailed, portId, port.HostID)
+		}
+		stage := portWaitStage(port, serverId)
+		if stage == "" {
+			log.Info().Str("portId", portId).Str("vifType", port.VIFType).Msg("port is ACTIVE")
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("%w port=%s %s after %s (status=%s device_id=%s vif_type=%s)",
+				ErrPortWaitTimeout, portId, stage, me.PortWait.Timeout, port.Status, port.DeviceID, port.VIFType)
+		}
+		log.Debug().Str("portId", portId).Str("stage", stage).Msg("port isn't ready yet")
+		select {
+		case <-time.After(min(me.PortWait.Interval, remaining)):
+		case <-ctx.Done():
+			return fmt.Errorf("cancelled while waiting for port=%s %s: %w", portId, stage, ctx.Err())
+		}
+	}
+}
+
+// portWaitStage describes the stage a port is stuck in, an empty string means the port is usable
+func portWaitStage(port *PortWithBinding, serverId string) string {
+	switch {
+	case port.DeviceID != serverId:
+		return "not attached to the server by nova"
+	case port.VIFType == "" || port.VIFType == "unbound":
+		return "not bound by neutron"
+	case port.Status != "ACTIVE":
+		return fmt.Sprintf("stuck in %s", port.Status)
+	}
+	return ""
+}
+
+// findExistingPort returns the port tagged for the same container/interface or nil when no such port exists
+func (me *PortManager) findExistingPort(ctx context.Context, opts SetupPortOpts) (*ports.Port, error) {
+	if len(opts.Tags.Tags) == 0 {
+		return nil, nil
+	}
+	port, err := me.client.GetPortByTags(ctx, opts.Tags.AsStringSlice())
+	if err == ErrPortNotFound {
+		return nil, nil
+	}
+	return port, err
+}
+
+// createPort creates and tags a new port
+func (me *PortManager) createPort(ctx context.Context, opts SetupPortOpts, result *SetupPortResult) (*ports.Port, error) {
+	log := Log().With().Str("command", "ADD").Str("hostname", opts.Hostname).Str("networkName", opts.NetworkName).Str("projectName", opts.ProjectName).Str("portName", opts.PortName).Logger()
+
+	if len(opts.SecurityGroupIds) > 0 {
+		sgIds := slices.Clone(opts.SecurityGroupIds)
+		opts.SecurityGroups = &sgIds
+	} else if opts.SecurityGroups != nil && len(*opts.SecurityGroups) > 0 {
+		projectId := opts.ProjectId
 		// we need the projectId in order to look up the security groups
-		if len(opts.ProjectName) > 0 {
+		if projectId == "" && len(opts.ProjectName) > 0 {
 			log.Info().Msg("looking up project")
-			project, err := me.client.GetProjectByName(opts.ProjectName)
+			project, err := me.client.GetProjectByName(ctx, opts.ProjectName)
 			if err != nil {
 				return nil, err
 			}
 			if project == nil {
-				return result, fmt.Errorf("failed to find project named %s", opts.ProjectName)
+				return nil, fmt.Errorf("failed to find project named %s", opts.ProjectName)
 			}
 			log.Info().Msg("found project")
 			projectId = project.ID
@@ -70,12 +282,12 @@ func (me *PortManager) SetupPort(opts SetupPortOpts) (*SetupPortResult, error) {
 		sgIds := make([]string, len(*opts.SecurityGroups), len(*opts.SecurityGroups))
 		for i, sgName := range *opts.SecurityGroups {
 			log.Info().Str("sgName", sgName).Msg("looking up security group")
-			sg, err := me.client.GetSecurityGroupByName(sgName, projectId)
+			sg, err := me.client.GetSecurityGroupByName(ctx, sgName, projectId)
 			if err != nil {
-				return nil, fmt.Errorf("failed to lookup security group named %s", sgName)
+				return nil, fmt.Errorf("failed to lookup security group named %s err=%w", sgName, err)
 			}
 			if sg == nil {
-				return result, fmt.Errorf("failed to find security group named %s", sgName)
+				return nil, fmt.Errorf("failed to find security group named %s", sgName)
 			}
 			log.Info().Str("sgName", sgName).Msg("found security group")
 			sgIds[i] = sg.ID
@@ -86,61 +298,66 @@ func (me *PortManager) SetupPort(opts SetupPortOpts) (*SetupPortResult, error) {
 	// create a port
 	portOpts := me.setupPortOpts(opts, result)
 
-	// optionally include the subnet when creating the port
-	if opts.SubnetName != "" {
-		log.Info().Str("subnetName", opts.SubnetName).Str("networkId", portOpts.NetworkID).Msg("looking up subnet")
-		subnet, err := me.client.GetSubnetByName(opts.SubnetName, portOpts.NetworkID)
-		if err != nil {
-			return result, err
-		}
-		if subnet == nil {
-			return result, fmt.Errorf("failed to find subnet named %s in network %s", opts.SubnetName, portOpts.NetworkID)
-		}
-		log.Info().Str("subnetName", opts.SubnetName).Str("networkId", portOpts.NetworkID).Msg("found subnet")
-		portOpts.FixedIPs = []FixedIP{{SubnetID: subnet.ID}}
+	// optionally request specific subnets and addresses when creating the port
+	fixedIps, err := me.resolveFixedIPs(ctx, opts.FixedIPs, portOpts.NetworkID)
+	if err != nil {
+		return nil, err
+	}
+	if len(fixedIps) > 0 {
+		portOpts.FixedIPs = fixedIps
 	}
 
 	log.Info().Msg("creating port")
 	// account for non-default port create options
 	extraCreateOpts := opts.CreateExtraPortOpts()
-	result.Port, err = me.client.CreatePort(portOpts, &extraCreateOpts)
+	port, err := me.client.CreatePort(ctx, portOpts, &extraCreateOpts)
 	if err != nil {
-		return result, err
+		return nil, err
 	}
 	log.Info().Msg("created port")
 
 	// add tags to the port
 	log.Info().Msg("adding tags to port")
-	if len(opts.Tags.Tags) > 0 {
-		tagger := NewNeutronTagger(me.client.Clients().NetworkClient, Ports)
-		if err := tagger.SetAll(result.Port.ID, opts.Tags); err != nil {
-			return result, err
+	tags := append(opts.Tags.AsStringSlice(), opts.CreateTags...)
+	if len(tags) > 0 {
+		if err := me.client.SetPortTags(ctx, port.ID, tags); err != nil {
+			return port, err
 		}
 		log.Info().Msg("added tags to port")
 	}
 
-	// lookup the subnet that the port came from
-	log.Info().Str("subnetId", result.Port.FixedIPs[0].SubnetID).Msg("looking up subnet by id")
-	result.Subnet, err = me.client.GetSubnet(result.Port.FixedIPs[0].SubnetID)
-	if err != nil {
-		return result, err
-	}
-	if result.Subnet == nil {
-		return result, fmt.Errorf("failed to find subnet with ID %s", result.Port.FixedIPs[0].SubnetID)
-	}
-	log.Info().Msg("found subnet by id")
+	return port, nil
+}
 
-	if !opts.SkipPortAttach {
-		// assign the port to the VM
-		log.Info().Str("portId", result.Port.ID).Str("serverId", result.Server.ID).Msg("assigning port to server")
-		result.Attachment, err = me.client.AssignPort(result.Port.ID, result.Server.ID)
-		if err != nil {
-			return result, err
+// resolveFixedIPs turns the requested fixed ips into the fixed ips of a port create request
+// subnets referenced by name are looked up in the port's network
+func (me *PortManager) resolveFixedIPs(ctx context.Context, requested []util.FixedIP, networkId string) ([]FixedIP, error) {
+	log := Log().With().Str("networkId", networkId).Logger()
+
+	fixedIps := make([]FixedIP, 0, len(requested))
+	for _, req := range requested {
+		if req.IpAddress != "" && net.ParseIP(req.IpAddress) == nil {
+			return nil, fmt.Errorf("invalid fixed ip address %q", req.IpAddress)
 		}
-		log.Info().Str("portId", result.Port.ID).Str("serverId", result.Server.ID).Msg("assigned port to server")
+		fixedIp := FixedIP{SubnetID: req.SubnetID, IPAddress: req.IpAddress}
+		if fixedIp.SubnetID == "" && req.SubnetName != "" {
+			log.Info().Str("subnetName", req.SubnetName).Msg("looking up subnet")
+			subnet, err := me.client.GetSubnetByName(ctx, req.SubnetName, networkId)
+			if err != nil {
+				return nil, err
+			}
+			if subnet == nil {
+				return nil, fmt.Errorf("failed to find subnet named %s in network %s", req.SubnetName, networkId)
+			}
+			log.Info().Str("subnetName", req.SubnetName).Msg("found subnet")
+			fixedIp.SubnetID = subnet.ID
+		}
+		if fixedIp.SubnetID == "" && fixedIp.IPAddress == "" {
+			return nil, fmt.Errorf("fixed ip requires a subnet or an ip address")
+		}
+		fixedIps = append(fixedIps, fixedIp)
 	}
-
-	return result, nil
+	return fixedIps, nil
 }
 
 func (me *PortManager) setupPortOpts(opts SetupPortOpts, result *SetupPortResult) ports.CreateOpts {
@@ -173,12 +390,12 @@ func (me *PortManager) setupPortOpts(opts SetupPortOpts, result *SetupPortResult
 	return portOpts
 }
 
-func (me *PortManager) TeardownPort(opts TearDownPortOpts) error {
+func (me *PortManager) TeardownPort(ctx context.Context, opts TearDownPortOpts) error {
 	log := Log().With().Str("command", "DEL").Str("hostname", opts.Hostname).Str("tags", opts.Tags.String()).Logger()
 
 	// lookup port by tags
 	log.Info().Msg("looking up port by tags")
-	port, err := me.client.GetPortByTags(opts.Tags.AsStringSlice())
+	port, err := me.client.GetPortByTags(ctx, opts.Tags.AsStringSlice())
 	if err != nil {
 		return err
 	}
@@ -190,7 +407,7 @@ func (me *PortManager) TeardownPort(opts TearDownPortOpts) error {
 	if !opts.SkipPortDetach {
 		// look up the server
 		log.Info().Msg("looking up server")
-		server, err := me.client.GetServerByName(opts.Hostname)
+		server, err := me.server(ctx, opts.Hostname)
 		if err != nil {
 			return err
 		}
@@ -199,22 +416,171 @@ func (me *PortManager) TeardownPort(opts TearDownPortOpts) error {
 		}
 		log.Info().Msg("found server")
 
+		if err := cancelled(ctx, "detaching the port"); err != nil {
+			return err
+		}
 		log.Info().Str("portId", port.ID).Str("serverId", server.ID).Msg("detaching port")
-		err = me.client.DetachPort(port.ID, server.ID)
+		err = me.client.DetachPort(ctx, port.ID, server.ID)
 		if err != nil {
 			return err
 		}
 		log.Info().Str("portId", port.ID).Str("serverId", server.ID).Msg("detached port")
 	}
 
+	if err := cancelled(ctx, "deleting the port"); err != nil {
+		return err
+	}
 	log.Info().Str("portId", port.ID).Msg("deleting port")
-	if err := me.client.DeletePort(port.ID); err != nil {
+	if err := me.client.DeletePort(ctx, port.ID); err != nil {
 		return err
 	}
 	log.Info().Str("portId", port.ID).Msg("deleted port")
 	return nil
 }
 
+// GarbageCollectPortsOpts selects the ports to garbage collect
+type GarbageCollectPortsOpts struct {
+	Hostname string
+	// Tags selects the candidate ports
+	Tags NeutronTags
+	// InUse reports whether a candidate port is still in use and must be kept
+	InUse func(port ports.Port) bool
+}
+
+// GarbageCollectPorts detaches and deletes every port matching the tags that is no longer in use
+// ports attached to a different server are left alone
+// the IDs of the deleted ports are returned along with any errors encountered along the way
+func (me *PortManager) GarbageCollectPorts(ctx context.Context, opts GarbageCollectPortsOpts) ([]string, error) {
+	log := Log().With().Str("command", "GC").Str("hostname", opts.Hostname).Str("tags", opts.Tags.String()).Logger()
+
+	// without tags every port in the project would be a candidate
+	if len(opts.Tags.Tags) == 0 {
+		return nil, fmt.Errorf("refusing to garbage collect ports without tags")
+	}
+
+	log.Info().Msg("looking up ports by tags")
+	candidates, err := me.client.GetPortsByTags(ctx, opts.Tags.AsStringSlice())
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Int("port_count", len(candidates)).Msg("found ports by tags")
+
+	var server *servers.Server
+	var errs error
+	deleted := make([]string, 0)
+	for _, port := range candidates {
+		if opts.InUse(port) {
+			continue
+		}
+		log := log.With().Str("portId", port.ID).Logger()
+
+		if port.DeviceID != "" {
+			// only look up the server once there's a port to detach from it
+			if server == nil {
+				log.Info().Msg("looking up server")
+				server, err = me.server(ctx, opts.Hostname)
+				if err != nil {
+					return deleted, multierror.Append(errs, err).ErrorOrNil()
+				}
+				if server == nil {
+					return deleted, multierror.Append(errs, fmt.Errorf("failed to find server by name %s", opts.Hostname)).ErrorOrNil()
+				}
+				log.Info().Msg("found server")
+			}
+			if port.DeviceID != server.ID {
+				log.Info().Str("deviceId", port.DeviceID).Msg("skipping port attached to another server")
+				continue
+			}
+
+			log.Info().Str("serverId", server.ID).Msg("detaching port")
+			if err := me.client.DetachPort(ctx, port.ID, server.ID); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("failed to detach port %s: %w", port.ID, err))
+				continue
+			}
+			log.Info().Str("serverId", server.ID).Msg("detached port")
+		}
+
+		log.Info().Msg("deleting port")
+		if err := me.client.DeletePort(ctx, port.ID); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to delete port %s: %w", port.ID, err))
+			continue
+		}
+		log.Info().Msg("deleted port")
+		deleted = append(deleted, port.ID)
+	}
+	return deleted, errs
+}
+
+var ErrPortNotActive = fmt.Errorf("port is not ACTIVE")
+var ErrPortNotAttached = fmt.Errorf("port is not attached to server")
+var ErrPortMacMismatch = fmt.Errorf("port mac address mismatch")
+var ErrPortIpMismatch = fmt.Errorf("port ip address mismatch")
+
+// CheckPort ensures that the port matching the tags is ACTIVE, attached to the server and has the expected MAC and IPs
+func (me *PortManager) CheckPort(ctx context.Context, opts CheckPortOpts) (*ports.Port, error) {
+	log := Log().With().Str("command", "CHECK").Str("hostname", opts.Hostname).Str("tags", opts.Tags.String()).Logger()
+
+	// lookup port by tags
+	log.Info().Msg("looking up port by tags")
+	port, err := me.client.GetPortByTags(ctx, opts.Tags.AsStringSlice())
+	if err != nil {
+		return nil, err
+	}
+	if port == nil {
+		return nil, ErrPortNotFound
+	}
+	log = log.With().Str("portId", port.ID).Logger()
+	log.Info().Msg("found port by tags")
+
+	if port.Status != "ACTIVE" {
+		return port, fmt.Errorf("%w port=%s status=%s", ErrPortNotActive, port.ID, port.Status)
+	}
+
+	// look up the server
+	log.Info().Msg("looking up server")
+	server, err := me.server(ctx, opts.Hostname)
+	if err != nil {
+		return port, err
+	}
+	if server == nil {
+		return port, fmt.Errorf("failed to find server by name %s", opts.Hostname)
+	}
+	log.Info().Str("serverId", server.ID).Msg("found server")
+
+	if port.DeviceID != server.ID {
+		return port, fmt.Errorf("%w port=%s device_id=%s server=%s", ErrPortNotAttached, port.ID, port.DeviceID, server.ID)
+	}
+
+	if opts.MacAddress != "" && !strings.EqualFold(port.MACAddress, opts.MacAddress) {
+		return port, fmt.Errorf("%w port=%s expected=%s actual=%s", ErrPortMacMismatch, port.ID, opts.MacAddress, port.MACAddress)
+	}
+
+	for _, ip := range opts.IPs {
+		found := false
+		for _, fixedIp := range port.FixedIPs {
+			if ip.Equal(net.ParseIP(fixedIp.IPAddress)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return port, fmt.Errorf("%w port=%s expected=%s", ErrPortIpMismatch, port.ID, ip)
+		}
+	}
+	log.Info().Msg("port is consistent")
+	return port, nil
+}
+
+type CheckPortOpts struct {
+	Hostname   string
+	Tags       NeutronTags
+	MacAddress string
+	IPs        []net.IP
+}
+
+// SetupPortOpts controls how a port is set up
+// Tags are used to find an existing port and are added to a newly created port
+// CreateTags are only added to a newly created port, they aren't used to find it
 type SetupPortOpts struct {
 	AdminStateUp        *bool
 	AllowedAddressPairs []util.AddressPair
@@ -223,13 +589,17 @@ type SetupPortOpts struct {
 	Hostname            string
 	MacAddress          string
 	NetworkName         string
+	NetworkId           string
 	PortDescription     string
 	PortName            string
 	ProjectName         string
+	ProjectId           string
 	SecurityGroups      *[]string
-	SubnetName          string
+	SecurityGroupIds    []string
+	FixedIPs            []util.FixedIP
 	SkipPortAttach      bool
 	Tags                NeutronTags
+	CreateTags          []string
 	TenantId            string
 	ValueSpecs          *map[string]string
 	// extra options
@@ -257,17 +627,20 @@ func SetupPortOptsFromContext(context util.CniContext) SetupPortOpts {
 		Hostname:            context.Hostname,
 		MacAddress:          context.CniConfig.MacAddress,
 		NetworkName:         context.CniConfig.Network,
+		NetworkId:           context.CniConfig.NetworkId,
 		PortDescription:     context.CniConfig.PortDescription,
 		PortName:            context.CniConfig.PortName,
 		ProjectName:         context.CniConfig.ProjectName,
+		ProjectId:           context.CniConfig.ProjectId,
 		SecurityGroups:      context.CniConfig.SecurityGroups,
-		SubnetName:          context.CniConfig.SubnetName,
+		SecurityGroupIds:    context.CniConfig.SecurityGroupIds,
+		FixedIPs:            context.FixedIPs(),
 		TenantId:            context.CniConfig.TenantId,
 		ValueSpecs:          context.CniConfig.ValueSpecs,
 		PortSecurityEnabled: context.CniConfig.PortSecurityEnabled,
-		HostID: context.CniConfig.HostID,
-		VNICType: context.CniConfig.VNICType,
-		Profile: context.CniConfig.Profile,
+		HostID:              context.CniConfig.HostID,
+		VNICType:            context.CniConfig.VNICType,
+		Profile:             context.CniConfig.Profile,
 	}
 }
 
@@ -279,19 +652,84 @@ type TearDownPortOpts struct {
 
 // SetupPortResult contains information gathered while setting up a port
 type SetupPortResult struct {
-	Server     *servers.Server
-	Network    *networks.Network
-	Subnet     *subnets.Subnet
+	Server  *servers.Server
+	Network *Network
+	// Subnet is the subnet of the port's first fixed ip
+	Subnet *subnets.Subnet
+	// Subnets contains the subnets of all of the port's fixed ips keyed by subnet ID
+	Subnets    map[string]*subnets.Subnet
 	Port       *ports.Port
 	Attachment *attachinterfaces.Interface
 }
 
-// GetIp returns an IPNet created from teh first FixedIP
+// attachmentFromPort creates an Interface describing an already attached port
+func attachmentFromPort(port *ports.Port) *attachinterfaces.Interface {
+	attachment := &attachinterfaces.Interface{
+		PortState: port.Status,
+		PortID:    port.ID,
+		NetID:     port.NetworkID,
+		MACAddr:   port.MACAddress,
+	}
+	for _, ip := range port.FixedIPs {
+		attachment.FixedIPs = append(attachment.FixedIPs, attachinterfaces.FixedIP{SubnetID: ip.SubnetID, IPAddress: ip.IPAddress})
+	}
+	return attachment
+}
+
+// GetIp returns an IPNet created from the first FixedIP
 func (me *SetupPortResult) GetIp() (*net.IPNet, error) {
-	ip := net.ParseIP(me.Port.FixedIPs[0].IPAddress)
-	_, cidr, err := net.ParseCIDR(me.Subnet.CIDR)
+	addresses, err := me.GetAddresses()
 	if err != nil {
 		return nil, err
 	}
-	return &net.IPNet{IP: ip, Mask: cidr.Mask}, nil
+	return addresses[0].Address, nil
+}
+
+// PortAddress represents one of a port's fixed ips along with the subnet it belongs to
+type PortAddress struct {
+	Address *net.IPNet
+	Subnet  *subnets.Subnet
+}
+
+// Version returns the IP version ("4" or "6") of the address
+func (me PortAddress) Version() string {
+	if me.Address.IP.To4() != nil {
+		return "4"
+	}
+	return "6"
+}
+
+// GetAddresses returns an IPNet for every FixedIP using the prefix length of the FixedIP's subnet
+func (me *SetupPortResult) GetAddresses() ([]PortAddress, error) {
+	if len(me.Port.FixedIPs) == 0 {
+		return nil, fmt.Errorf("port %s has no fixed ips", me.Port.ID)
+	}
+
+	addresses := make([]PortAddress, 0, len(me.Port.FixedIPs))
+	for _, fixedIp := range me.Port.FixedIPs {
+		subnet := me.getSubnet(fixedIp.SubnetID)
+		if subnet == nil {
+			return nil, fmt.Errorf("missing subnet %s for ip %s", fixedIp.SubnetID, fixedIp.IPAddress)
+		}
+		ip := net.ParseIP(fixedIp.IPAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip address %s", fixedIp.IPAddress)
+		}
+		_, cidr, err := net.ParseCIDR(subnet.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, PortAddress{Address: &net.IPNet{IP: ip, Mask: cidr.Mask}, Subnet: subnet})
+	}
+	return addresses, nil
+}
+
+func (me *SetupPortResult) getSubnet(id string) *subnets.Subnet {
+	if subnet, found := me.Subnets[id]; found {
+		return subnet
+	}
+	if me.Subnet != nil && (me.Subnet.ID == id || len(me.Subnets) == 0) {
+		return me.Subnet
+	}
+	return nil
 }
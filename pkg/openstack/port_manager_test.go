@@ -1,11 +1,21 @@
 package openstack_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
 	"github.com/jboelensns/openstack-cni/pkg/cniserver"
 	. "github.com/jboelensns/openstack-cni/pkg/fixtures"
+	"github.com/jboelensns/openstack-cni/pkg/fixtures/mocks"
 	"github.com/jboelensns/openstack-cni/pkg/openstack"
 	"github.com/jboelensns/openstack-cni/pkg/util"
 
@@ -22,86 +32,545 @@ func Test_PortManager(t *testing.T) {
 		client := openstack.NewCachedClient(realClient, time.Second*5)
 
 		t.Run("can setup a port and tear it down", func(t *testing.T) {
-			context := CniContextFromConfig(t, cfg, cmd)
-			SetupAndTeardownPort(t, context, client)
+			cniContext := CniContextFromConfig(t, cfg, cmd)
+			SetupAndTeardownPort(t, cniContext, client)
 		})
 
 		t.Run("can setup a port with all options and tear it down", func(t *testing.T) {
-			context := CniContextFromConfig(t, cfg, cmd)
-			context.CniConfig.SubnetName = cfg.SubnetName
-			context.CniConfig.PortDescription = "description"
+			cniContext := CniContextFromConfig(t, cfg, cmd)
+			cniContext.CniConfig.SubnetName = cfg.SubnetName
+			cniContext.CniConfig.PortDescription = "description"
 			f := false
-			context.CniConfig.AdminStateUp = &f
-			context.CniConfig.MacAddress = "52:54:00:28:ea:16"
+			cniContext.CniConfig.AdminStateUp = &f
+			cniContext.CniConfig.MacAddress = "52:54:00:28:ea:16"
 			// This cannot be tested without a sepcific device id
-			// context.CniConfig.DeviceId = "4be2ed0a-23c4-4c5b-91b3-eedce17b3de2"
-			context.CniConfig.DeviceOwner = "compute:nova"
-			context.CniConfig.TenantId = "67f06cc9d851455f94fc0380233ab86c"
-			context.CniConfig.AllowedAddressPairs = []util.AddressPair{{IpAddress: "1.1.1.1", MacAddress: "52:54:00:28:ea:16"}}
+			// cniContext.CniConfig.DeviceId = "4be2ed0a-23c4-4c5b-91b3-eedce17b3de2"
+			cniContext.CniConfig.DeviceOwner = "compute:nova"
+			cniContext.CniConfig.TenantId = "67f06cc9d851455f94fc0380233ab86c"
+			cniContext.CniConfig.AllowedAddressPairs = []util.AddressPair{{IpAddress: "1.1.1.1", MacAddress: "52:54:00:28:ea:16"}}
 			// This cannot be tested unless openstack is setup to accept specific value spec pairs
-			// context.CniConfig.ValueSpecs = &map[string]string{
+			// cniContext.CniConfig.ValueSpecs = &map[string]string{
 			// 	"foo": "bar",
 			// }
 
-			SetupAndTeardownPort(t, context, client)
+			SetupAndTeardownPort(t, cniContext, client)
 		})
 
 		t.Run("can setup a port with port security enabled", func(t *testing.T) {
-			context := CniContextFromConfig(t, cfg, cmd)
+			cniContext := CniContextFromConfig(t, cfg, cmd)
 			enabled := true
-			context.CniConfig.PortSecurityEnabled = &enabled
-			SetupAndTeardownPort(t, context, client)
+			cniContext.CniConfig.PortSecurityEnabled = &enabled
+			SetupAndTeardownPort(t, cniContext, client)
 		})
 
 		t.Run("can setup a port with port security disabled", func(t *testing.T) {
-			context := CniContextFromConfig(t, cfg, cmd)
+			cniContext := CniContextFromConfig(t, cfg, cmd)
 			enabled := false
-			context.CniConfig.PortSecurityEnabled = &enabled
+			cniContext.CniConfig.PortSecurityEnabled = &enabled
 			// Port security cannot be disabled if security groups are provided
-			context.CniConfig.SecurityGroups = nil
-			SetupAndTeardownPort(t, context, client)
+			cniContext.CniConfig.SecurityGroups = nil
+			SetupAndTeardownPort(t, cniContext, client)
 		})
 	})
 }
 
-func SetupAndTeardownPort(t *testing.T, context util.CniContext, client openstack.OpenstackClient) {
+func SetupAndTeardownPort(t *testing.T, cniContext util.CniContext, client openstack.OpenstackClient) {
 	t.Helper()
 	pm := openstack.NewPortManager(client)
-	opts := openstack.SetupPortOptsFromContext(context)
-	opts.Tags = cniserver.NewPortTags(context.Command)
+	opts := openstack.SetupPortOptsFromContext(cniContext)
+	opts.Tags = cniserver.NewPortTags(cniContext.Command)
 
-	results, err := pm.SetupPort(opts)
+	results, err := pm.SetupPort(context.Background(), opts)
 	Assert(t).That(err, IsNil(), "failed to setup port")
 
-	if len(context.CniConfig.AllowedAddressPairs) > 0 {
+	if len(cniContext.CniConfig.AllowedAddressPairs) > 0 {
 		Assert(t).That(results.Port.AllowedAddressPairs, HasLen(1))
 		Assert(t).That(results.Port.AllowedAddressPairs[0].IPAddress, Equals("1.1.1.1"))
 	}
 
-	_, err = client.GetPortByTags(opts.Tags.AsStringSlice())
+	_, err = client.GetPortByTags(context.Background(), opts.Tags.AsStringSlice())
 	Assert(t).That(err, IsNil(), "failed get port by tags %s", opts.Tags.String())
 
-	tdOpts := openstack.TearDownPortOpts{Hostname: context.Hostname, Tags: cniserver.NewPortTags(context.Command)}
-	err = pm.TeardownPort(tdOpts)
+	tdOpts := openstack.TearDownPortOpts{Hostname: cniContext.Hostname, Tags: cniserver.NewPortTags(cniContext.Command)}
+	err = pm.TeardownPort(context.Background(), tdOpts)
 	Assert(t).That(err, IsNil(), "failed teardown port")
 
-	_, err = client.GetPort(results.Port.ID)
+	_, err = client.GetPort(context.Background(), results.Port.ID)
 	if err == nil {
 		t.Errorf("expected port to be gone with tags %s", tdOpts.Tags.String())
 	}
 
-	results, err = pm.SetupPort(opts)
+	results, err = pm.SetupPort(context.Background(), opts)
 	Assert(t).That(err, IsNil(), "failed to setup port")
 
-	_, err = client.GetPortByTags(opts.Tags.AsStringSlice())
+	_, err = client.GetPortByTags(context.Background(), opts.Tags.AsStringSlice())
 	Assert(t).That(err, IsNil(), "failed get port by tags %s", opts.Tags.String())
 
-	tdOpts = openstack.TearDownPortOpts{Hostname: context.Hostname, Tags: cniserver.NewPortTags(context.Command)}
-	err = pm.TeardownPort(tdOpts)
+	tdOpts = openstack.TearDownPortOpts{Hostname: cniContext.Hostname, Tags: cniserver.NewPortTags(cniContext.Command)}
+	err = pm.TeardownPort(context.Background(), tdOpts)
 	Assert(t).That(err, IsNil(), "failed teardown port")
 
-	_, err = client.GetPort(results.Port.ID)
+	_, err = client.GetPort(context.Background(), results.Port.ID)
 	if err == nil {
 		t.Errorf("expected port to be gone with tags %s", tdOpts.Tags.String())
 	}
 }
+
+func Test_PortManagerReusesExistingPorts(t *testing.T) {
+	newMock := func(port *ports.Port) *mocks.OpenstackClientMock {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return &servers.Server{ID: "serverId"}, nil
+		}
+		mock.GetNetworkByNameFunc = func(ctx context.Context, name string) (*openstack.Network, error) {
+			return &openstack.Network{Network: networks.Network{ID: "networkId"}}, nil
+		}
+		mock.GetPortByTagsFunc = func(ctx context.Context, tags []string) (*ports.Port, error) { return port, nil }
+		mock.GetSubnetFunc = func(ctx context.Context, id string) (*subnets.Subnet, error) {
+			return &subnets.Subnet{ID: id, CIDR: "192.168.1.0/24"}, nil
+		}
+		mock.AssignPortFunc = func(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error) {
+			return &attachinterfaces.Interface{PortID: portId, MACAddr: port.MACAddress}, nil
+		}
+		return mock
+	}
+	newOpts := func() openstack.SetupPortOpts {
+		cmd := NewTestData().CniCommand()
+		return openstack.SetupPortOpts{Hostname: "myhost", NetworkName: "mynetwork", Tags: cniserver.NewPortTags(cmd)}
+	}
+	newPort := func() *ports.Port {
+		return &ports.Port{
+			ID:         "portId",
+			MACAddress: "02:42:d9:1f:22:9d",
+			FixedIPs:   []ports.IP{{SubnetID: "subnetId", IPAddress: "192.168.1.42"}},
+		}
+	}
+
+	t.Run("an attached port is reused without creating or assigning a port", func(t *testing.T) {
+		port := newPort()
+		port.DeviceID = "serverId"
+		mock := newMock(port)
+
+		result, err := openstack.NewPortManager(mock).SetupPort(context.Background(), newOpts())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(result.Port.ID, Equals(port.ID))
+		Assert(t).That(result.Attachment.MACAddr, Equals(port.MACAddress))
+		Assert(t).That(mock.CreatePortCalls(), HasLen(0))
+		Assert(t).That(mock.AssignPortCalls(), HasLen(0))
+	})
+
+	t.Run("a detached port is reused and assigned", func(t *testing.T) {
+		port := newPort()
+		mock := newMock(port)
+
+		result, err := openstack.NewPortManager(mock).SetupPort(context.Background(), newOpts())
+		Assert(t).That(err, IsNil())
+		Assert(t).That(result.Port.ID, Equals(port.ID))
+		Assert(t).That(mock.CreatePortCalls(), HasLen(0))
+		Assert(t).That(mock.AssignPortCalls(), HasLen(1))
+	})
+
+	t.Run("a port attached to another device is not reused or deleted", func(t *testing.T) {
+		port := newPort()
+		port.DeviceID = "otherServerId"
+		mock := newMock(port)
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), newOpts())
+		Assert(t).That(err, Not(IsNil()))
+		Assert(t).That(mock.CreatePortCalls(), HasLen(0))
+		Assert(t).That(mock.AssignPortCalls(), HasLen(0))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+	})
+
+	t.Run("a reused port is deleted when assigning it fails", func(t *testing.T) {
+		port := newPort()
+		mock := newMock(port)
+		mock.AssignPortFunc = func(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error) {
+			return nil, fmt.Errorf("BOOM")
+		}
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), newOpts())
+		Assert(t).That(err.Error(), Contains("BOOM"))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(1))
+		Assert(t).That(mock.DeletePortCalls()[0].PortId, Equals(port.ID))
+	})
+
+	t.Run("a reused attached port is detached and deleted when a later step fails", func(t *testing.T) {
+		port := newPort()
+		port.DeviceID = "serverId"
+		mock := newMock(port)
+		mock.GetSubnetFunc = func(ctx context.Context, id string) (*subnets.Subnet, error) { return nil, fmt.Errorf("BOOM") }
+		mock.DetachPortFunc = func(ctx context.Context, portId, serverId string) error { return nil }
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), newOpts())
+		Assert(t).That(err.Error(), Contains("BOOM"))
+		Assert(t).That(mock.DetachPortCalls(), HasLen(1))
+		Assert(t).That(mock.DetachPortCalls()[0].ServerId, Equals("serverId"))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(1))
+	})
+
+	t.Run("a request cancelled while looking for an existing port doesn't create one", func(t *testing.T) {
+		mock := newMock(nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		mock.GetPortByTagsFunc = func(ctx context.Context, tags []string) (*ports.Port, error) {
+			cancel()
+			return nil, openstack.ErrPortNotFound
+		}
+
+		_, err := openstack.NewPortManager(mock).SetupPort(ctx, newOpts())
+		Assert(t).That(errors.Is(err, context.Canceled), IsTrue())
+		Assert(t).That(mock.GetPortByTagsCalls(), HasLen(1))
+		Assert(t).That(mock.CreatePortCalls(), HasLen(0))
+	})
+}
+
+func Test_PortManagerRollback(t *testing.T) {
+	newPort := func() *ports.Port {
+		return &ports.Port{
+			ID:         "portId",
+			MACAddress: "02:42:d9:1f:22:9d",
+			FixedIPs:   []ports.IP{{SubnetID: "subnetId", IPAddress: "192.168.1.42"}},
+		}
+	}
+	newMock := func() *mocks.OpenstackClientMock {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return &servers.Server{ID: "serverId"}, nil
+		}
+		mock.GetNetworkByNameFunc = func(ctx context.Context, name string) (*openstack.Network, error) {
+			return &openstack.Network{Network: networks.Network{ID: "networkId"}}, nil
+		}
+		mock.CreatePortFunc = func(ctx context.Context, opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) {
+			return newPort(), nil
+		}
+		mock.GetSubnetFunc = func(ctx context.Context, id string) (*subnets.Subnet, error) {
+			return &subnets.Subnet{ID: id, CIDR: "192.168.1.0/24"}, nil
+		}
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+		mock.DetachPortFunc = func(ctx context.Context, portId, serverId string) error { return nil }
+		return mock
+	}
+	opts := openstack.SetupPortOpts{Hostname: "myhost", NetworkName: "mynetwork"}
+
+	t.Run("the port is deleted when looking up its subnet fails", func(t *testing.T) {
+		mock := newMock()
+		mock.GetSubnetFunc = func(ctx context.Context, id string) (*subnets.Subnet, error) { return nil, fmt.Errorf("BOOM") }
+
+		result, err := openstack.NewPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(result, IsNil())
+		Assert(t).That(err.Error(), Contains("BOOM"))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(1))
+		Assert(t).That(mock.DeletePortCalls()[0].PortId, Equals("portId"))
+		Assert(t).That(mock.DetachPortCalls(), HasLen(0))
+	})
+
+	t.Run("the port is deleted when assigning it fails", func(t *testing.T) {
+		mock := newMock()
+		mock.AssignPortFunc = func(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error) {
+			return nil, fmt.Errorf("BOOM")
+		}
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(err.Error(), Contains("BOOM"))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(1))
+	})
+
+	t.Run("the port is deleted when tagging it fails", func(t *testing.T) {
+		mock := newMock()
+		mock.SetPortTagsFunc = func(ctx context.Context, portId string, tags []string) error { return fmt.Errorf("BOOM") }
+		opts := openstack.SetupPortOpts{Hostname: "myhost", NetworkName: "mynetwork", CreateTags: []string{"openstack-cni=true"}}
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(err.Error(), Contains("BOOM"))
+		Assert(t).That(mock.SetPortTagsCalls(), HasLen(1))
+		Assert(t).That(mock.SetPortTagsCalls()[0].Tags, Equals([]string{"openstack-cni=true"}))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(1))
+	})
+
+	t.Run("rollback errors are reported alongside the original error", func(t *testing.T) {
+		mock := newMock()
+		mock.AssignPortFunc = func(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error) {
+			return nil, fmt.Errorf("BOOM")
+		}
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error { return fmt.Errorf("BANG") }
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(err.Error(), AllOf(Contains("BOOM"), Contains("BANG")))
+	})
+
+	t.Run("nothing is rolled back when the server lookup fails", func(t *testing.T) {
+		mock := newMock()
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return nil, openstack.ErrServerNotFound
+		}
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(err, Equals(openstack.ErrServerNotFound))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+	})
+
+	t.Run("cancelling after the port was created stops before assigning and deletes the port", func(t *testing.T) {
+		mock := newMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		mock.CreatePortFunc = func(ctx context.Context, opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) {
+			cancel()
+			return newPort(), nil
+		}
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error { return ctx.Err() }
+
+		_, err := openstack.NewPortManager(mock).SetupPort(ctx, opts)
+		Assert(t).That(errors.Is(err, context.Canceled), IsTrue())
+		Assert(t).That(mock.AssignPortCalls(), HasLen(0))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(1))
+	})
+
+	t.Run("a cancelled request does nothing", func(t *testing.T) {
+		mock := newMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := openstack.NewPortManager(mock).SetupPort(ctx, opts)
+		Assert(t).That(errors.Is(err, context.Canceled), IsTrue())
+		Assert(t).That(mock.CreatePortCalls(), HasLen(0))
+		Assert(t).That(mock.DeletePortCalls(), HasLen(0))
+	})
+}
+
+func Test_PortManagerFixedIPs(t *testing.T) {
+	newMock := func() *mocks.OpenstackClientMock {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return &servers.Server{ID: "serverId"}, nil
+		}
+		mock.GetNetworkByNameFunc = func(ctx context.Context, name string) (*openstack.Network, error) {
+			return &openstack.Network{Network: networks.Network{ID: "networkId"}}, nil
+		}
+		mock.GetSubnetByNameFunc = func(ctx context.Context, name, networkId string) (*subnets.Subnet, error) {
+			return &subnets.Subnet{ID: name + "Id"}, nil
+		}
+		mock.CreatePortFunc = func(ctx context.Context, opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) {
+			return nil, fmt.Errorf("BOOM")
+		}
+		return mock
+	}
+
+	t.Run("the requested fixed ips are passed to the port", func(t *testing.T) {
+		mock := newMock()
+		opts := openstack.SetupPortOpts{Hostname: "myhost", NetworkName: "mynetwork", FixedIPs: []util.FixedIP{
+			{SubnetName: "mysubnet", IpAddress: "10.0.0.5"},
+			{SubnetID: "otherSubnetId"},
+			{IpAddress: "2001:db8::5"},
+		}}
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(err.Error(), Contains("BOOM"))
+		Assert(t).That(mock.GetSubnetByNameCalls(), HasLen(1))
+		Assert(t).That(mock.GetSubnetByNameCalls()[0].NetworkId, Equals("networkId"))
+		Assert(t).That(mock.CreatePortCalls()[0].Opts.FixedIPs, Equals([]openstack.FixedIP{
+			{SubnetID: "mysubnetId", IPAddress: "10.0.0.5"},
+			{SubnetID: "otherSubnetId"},
+			{IPAddress: "2001:db8::5"},
+		}))
+	})
+
+	t.Run("no fixed ips are sent when none were requested", func(t *testing.T) {
+		mock := newMock()
+		opts := openstack.SetupPortOpts{Hostname: "myhost", NetworkName: "mynetwork"}
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(err.Error(), Contains("BOOM"))
+		Assert(t).That(mock.CreatePortCalls()[0].Opts.FixedIPs, IsNil())
+	})
+
+	t.Run("an invalid address fails before creating the port", func(t *testing.T) {
+		mock := newMock()
+		opts := openstack.SetupPortOpts{Hostname: "myhost", NetworkName: "mynetwork", FixedIPs: []util.FixedIP{{IpAddress: "10.0.0"}}}
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(err.Error(), Contains("invalid fixed ip address"))
+		Assert(t).That(mock.CreatePortCalls(), HasLen(0))
+	})
+}
+
+func Test_PortManagerWaitsForPort(t *testing.T) {
+	newMock := func(states ...openstack.PortWithBinding) *mocks.OpenstackClientMock {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return &servers.Server{ID: "serverId"}, nil
+		}
+		mock.GetNetworkByNameFunc = func(ctx context.Context, name string) (*openstack.Network, error) {
+			return &openstack.Network{Network: networks.Network{ID: "networkId"}}, nil
+		}
+		mock.CreatePortFunc = func(ctx context.Context, opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) {
+			return &ports.Port{ID: "portId", FixedIPs: []ports.IP{{SubnetID: "subnetId", IPAddress: "192.168.1.42"}}}, nil
+		}
+		mock.GetSubnetFunc = func(ctx context.Context, id string) (*subnets.Subnet, error) {
+			return &subnets.Subnet{ID: id, CIDR: "192.168.1.0/24"}, nil
+		}
+		mock.AssignPortFunc = func(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error) {
+			return &attachinterfaces.Interface{PortID: portId}, nil
+		}
+		mock.GetPortWithBindingFunc = func(ctx context.Context, portId string) (*openstack.PortWithBinding, error) {
+			state := states[min(len(mock.GetPortWithBindingCalls()), len(states))-1]
+			state.ID = portId
+			return &state, nil
+		}
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+		mock.DetachPortFunc = func(ctx context.Context, portId, serverId string) error { return nil }
+		return mock
+	}
+	portState := func(status, deviceId, vifType string) openstack.PortWithBinding {
+		state := openstack.PortWithBinding{}
+		state.Status = status
+		state.DeviceID = deviceId
+		state.VIFType = vifType
+		return state
+	}
+	newPortManager := func(mock *mocks.OpenstackClientMock) *openstack.PortManager {
+		pm := openstack.NewPortManager(mock)
+		pm.PortWait = openstack.PortWaitOpts{Timeout: 50 * time.Millisecond, Interval: time.Millisecond}
+		return pm
+	}
+	opts := openstack.SetupPortOpts{Hostname: "myhost", NetworkName: "mynetwork"}
+
+	t.Run("returns once the port is attached, bound and ACTIVE", func(t *testing.T) {
+		mock := newMock(
+			portState("DOWN", "", ""),
+			portState("BUILD", "serverId", "unbound"),
+			portState("BUILD", "serverId", "ovs"),
+			portState("ACTIVE", "serverId", "ovs"),
+		)
+
+		result, err := newPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(err, IsNil())
+		Assert(t).That(result.Port.Status, Equals("ACTIVE"))
+		Assert(t).That(mock.GetPortWithBindingCalls(), HasLen(4))
+	})
+
+	t.Run("names the stage the port is stuck in", func(t *testing.T) {
+		for _, test := range []struct {
+			state    openstack.PortWithBinding
+			expected string
+		}{
+			{portState("DOWN", "", ""), "not attached to the server by nova"},
+			{portState("DOWN", "serverId", "unbound"), "not bound by neutron"},
+			{portState("BUILD", "serverId", "ovs"), "stuck in BUILD"},
+		} {
+			mock := newMock(test.state)
+			_, err := newPortManager(mock).SetupPort(context.Background(), opts)
+			Assert(t).That(err.Error(), Contains(openstack.ErrPortWaitTimeout.Error()))
+			Assert(t).That(err.Error(), Contains(test.expected))
+			Assert(t).That(mock.DetachPortCalls(), HasLen(1))
+			Assert(t).That(mock.DeletePortCalls(), HasLen(1))
+		}
+	})
+
+	t.Run("fails immediately when binding failed", func(t *testing.T) {
+		mock := newMock(portState("DOWN", "serverId", "binding_failed"))
+
+		_, err := newPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(err.Error(), Contains(openstack.ErrPortBindingFailed.Error()))
+		Assert(t).That(mock.GetPortWithBindingCalls(), HasLen(1))
+	})
+
+	t.Run("doesn't wait without a timeout", func(t *testing.T) {
+		mock := newMock(portState("DOWN", "", ""))
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(err, IsNil())
+		Assert(t).That(mock.GetPortWithBindingCalls(), HasLen(0))
+	})
+}
+
+func Test_PortManagerServerId(t *testing.T) {
+	newMock := func() *mocks.OpenstackClientMock {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerFunc = func(ctx context.Context, id string) (*servers.Server, error) {
+			return &servers.Server{ID: id}, nil
+		}
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return &servers.Server{ID: "namesakeId"}, nil
+		}
+		mock.GetPortByTagsFunc = func(ctx context.Context, tags []string) (*ports.Port, error) {
+			return &ports.Port{ID: "portId", DeviceID: "serverId"}, nil
+		}
+		mock.DetachPortFunc = func(ctx context.Context, portId, serverId string) error { return nil }
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error { return nil }
+		return mock
+	}
+	opts := openstack.TearDownPortOpts{Hostname: "myhost", Tags: cniserver.NewPortTags(NewTestData().CniCommand())}
+
+	t.Run("the server is looked up by ID when it's known", func(t *testing.T) {
+		mock := newMock()
+		pm := openstack.NewPortManager(mock)
+		pm.ServerId = "serverId"
+
+		Assert(t).That(pm.TeardownPort(context.Background(), opts), IsNil())
+		Assert(t).That(mock.GetServerByNameCalls(), HasLen(0))
+		Assert(t).That(mock.DetachPortCalls()[0].ServerId, Equals("serverId"))
+	})
+
+	t.Run("the server is looked up by name without an ID", func(t *testing.T) {
+		mock := newMock()
+
+		Assert(t).That(openstack.NewPortManager(mock).TeardownPort(context.Background(), opts), IsNil())
+		Assert(t).That(mock.GetServerCalls(), HasLen(0))
+		Assert(t).That(mock.DetachPortCalls()[0].ServerId, Equals("namesakeId"))
+	})
+}
+
+func Test_PortManagerResourceIds(t *testing.T) {
+	newMock := func() *mocks.OpenstackClientMock {
+		mock := &mocks.OpenstackClientMock{}
+		mock.GetServerByNameFunc = func(ctx context.Context, name string) (*servers.Server, error) {
+			return &servers.Server{ID: "serverId"}, nil
+		}
+		mock.GetNetworkFunc = func(ctx context.Context, id string) (*openstack.Network, error) {
+			return &openstack.Network{Network: networks.Network{ID: id}}, nil
+		}
+		mock.GetNetworkByNameFunc = func(ctx context.Context, name string) (*openstack.Network, error) {
+			return nil, fmt.Errorf("%w: 2 networks named %s", openstack.ErrAmbiguousName, name)
+		}
+		mock.GetSecurityGroupByNameFunc = func(ctx context.Context, name, projectId string) (*groups.SecGroup, error) {
+			return &groups.SecGroup{ID: name + "Id"}, nil
+		}
+		mock.CreatePortFunc = func(ctx context.Context, opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) {
+			return nil, fmt.Errorf("BOOM")
+		}
+		return mock
+	}
+
+	t.Run("resources referenced by ID aren't looked up by name", func(t *testing.T) {
+		mock := newMock()
+		opts := openstack.SetupPortOpts{Hostname: "myhost", NetworkId: "networkId", SecurityGroupIds: []string{"sgId"}}
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(err.Error(), Contains("BOOM"))
+		Assert(t).That(mock.GetNetworkByNameCalls(), HasLen(0))
+		Assert(t).That(mock.GetSecurityGroupByNameCalls(), HasLen(0))
+		Assert(t).That(mock.CreatePortCalls()[0].Opts.NetworkID, Equals("networkId"))
+		Assert(t).That(*mock.CreatePortCalls()[0].Opts.SecurityGroups, Equals([]string{"sgId"}))
+	})
+
+	t.Run("security groups are looked up in the project given by ID", func(t *testing.T) {
+		mock := newMock()
+		opts := openstack.SetupPortOpts{Hostname: "myhost", NetworkId: "networkId", ProjectId: "projectId", SecurityGroups: &[]string{"default"}}
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(err.Error(), Contains("BOOM"))
+		Assert(t).That(mock.GetProjectByNameCalls(), HasLen(0))
+		Assert(t).That(mock.GetSecurityGroupByNameCalls()[0].ProjectId, Equals("projectId"))
+		Assert(t).That(*mock.CreatePortCalls()[0].Opts.SecurityGroups, Equals([]string{"defaultId"}))
+	})
+
+	t.Run("an ambiguous name fails before creating the port", func(t *testing.T) {
+		mock := newMock()
+		opts := openstack.SetupPortOpts{Hostname: "myhost", NetworkName: "mynetwork"}
+
+		_, err := openstack.NewPortManager(mock).SetupPort(context.Background(), opts)
+		Assert(t).That(errors.Is(err, openstack.ErrAmbiguousName), IsTrue())
+		Assert(t).That(mock.CreatePortCalls(), HasLen(0))
+	})
+}
@@ -0,0 +1,319 @@
+package openstack
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
+	. "github.com/jboelensns/openstack-cni/pkg/logging"
+)
+
+var _ OpenstackClient = &RetryingClient{}
+
+// RetryOpts configures how often and how fast operations are retried
+type RetryOpts struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// RetryPolicy decides which errors of an operation are retried
+type RetryPolicy struct {
+	RetryOpts
+	Retryable func(err error) bool
+}
+
+// RetryMetrics records retries of OpenStack operations
+type RetryMetrics interface {
+	Retried(operation string)
+	Exhausted(operation string)
+}
+
+// RetryingClient retries failed OpenstackClient operations with exponential backoff and jitter
+// operations that create, attach or delete resources are only retried when the request wasn't processed
+type RetryingClient struct {
+	OpenstackClient OpenstackClient
+	Policies        map[string]RetryPolicy
+	Default         RetryPolicy
+	Metrics         RetryMetrics
+}
+
+// NewRetryingClient creates a RetryingClient with the default policies
+func NewRetryingClient(client OpenstackClient, opts RetryOpts, metrics RetryMetrics) *RetryingClient {
+	return &RetryingClient{
+		OpenstackClient: client,
+		Default:         RetryPolicy{RetryOpts: opts, Retryable: IsRetryableError},
+		Policies: map[string]RetryPolicy{
+			"AssignPort":                     {RetryOpts: opts, Retryable: IsRejectedError},
+			"CreatePort":                     {RetryOpts: opts, Retryable: IsUnprocessedError},
+			"CreateSecurityGroup":            {RetryOpts: opts, Retryable: IsUnprocessedError},
+			"DeletePort":                     {RetryOpts: opts, Retryable: IsUnprocessedError},
+			"UpdateSecurityGroupDescription": {RetryOpts: opts, Retryable: IsUnprocessedError},
+		},
+		Metrics: metrics,
+	}
+}
+
+// IsRetryableError returns true for errors that are likely to go away: conflicts, throttling,
+// server side errors, expired tokens and network errors
+func IsRetryableError(err error) bool {
+	switch statusCode(err) {
+	case http.StatusUnauthorized, http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case 0:
+		var netErr net.Error
+		return errors.As(err, &netErr)
+	}
+	return false
+}
+
+// IsUnprocessedError returns true for retryable errors where the request certainly wasn't processed
+// making it safe to retry requests that aren't idempotent
+func IsUnprocessedError(err error) bool {
+	switch statusCode(err) {
+	case http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case 0:
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+	return false
+}
+
+// IsRejectedError returns true for unprocessed errors and conflicts, nova answers an attach with 409
+// while the instance is busy with another task and rejects the request without processing it
+func IsRejectedError(err error) bool {
+	return statusCode(err) == http.StatusConflict || IsUnprocessedError(err)
+}
+
+// statusCode returns the http status code of a gophercloud error or 0
+func statusCode(err error) int {
+	var reauthErr gophercloud.ErrErrorAfterReauthentication
+	if errors.As(err, &reauthErr) && reauthErr.ErrOriginal != nil {
+		err = reauthErr.ErrOriginal
+	}
+	var codeErr gophercloud.StatusCodeError
+	if errors.As(err, &codeErr) {
+		return codeErr.GetStatusCode()
+	}
+	return 0
+}
+
+// Delay returns the backoff before the next attempt, attempt starts at 1
+// the delay doubles every attempt up to MaxDelay and is jittered between half and the full delay
+func (me RetryOpts) Delay(attempt int) time.Duration {
+	delay := me.BaseDelay
+	for i := 1; i < attempt && delay < me.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > me.MaxDelay {
+		delay = me.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+func (me *RetryingClient) policy(operation string) RetryPolicy {
+	if policy, ok := me.Policies[operation]; ok {
+		return policy
+	}
+	return me.Default
+}
+
+// retry calls fn until it succeeds, fails with an error that isn't retryable, runs out of attempts or ctx is done
+func retry[T any](ctx context.Context, me *RetryingClient, operation string, fn func() (T, error)) (T, error) {
+	policy := me.policy(operation)
+	for attempt := 1; ; attempt++ {
+		val, err := fn()
+		if err == nil || policy.Retryable == nil || !policy.Retryable(err) || ctx.Err() != nil {
+			return val, err
+		}
+		if attempt >= policy.MaxAttempts {
+			if me.Metrics != nil {
+				me.Metrics.Exhausted(operation)
+			}
+			return val, err
+		}
+
+		delay := policy.Delay(attempt)
+		Log().Warn().Str("operation", operation).Int("attempt", attempt).Str("delay", delay.String()).Err(err).Msg("retrying openstack operation")
+		if me.Metrics != nil {
+			me.Metrics.Retried(operation)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return val, err
+		}
+	}
+}
+
+func retryErr(ctx context.Context, me *RetryingClient, operation string, fn func() error) error {
+	_, err := retry(ctx, me, operation, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// retryDelete retries a delete like retryErr, a 404 after the first attempt means an earlier attempt
+// deleted the resource even though its response was lost, so it counts as success
+func retryDelete(ctx context.Context, me *RetryingClient, operation string, fn func() error) error {
+	attempt := 0
+	return retryErr(ctx, me, operation, func() error {
+		attempt++
+		err := fn()
+		if attempt > 1 && statusCode(err) == http.StatusNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// AssignPort attaches a port to a server
+func (me *RetryingClient) AssignPort(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error) {
+	return retry(ctx, me, "AssignPort", func() (*attachinterfaces.Interface, error) {
+		return me.OpenstackClient.AssignPort(ctx, portId, serverId)
+	})
+}
+
+func (me *RetryingClient) Clients() *ApiClients {
+	return me.OpenstackClient.Clients()
+}
+
+// CreatePort creates a neutron port inside of the specified network
+func (me *RetryingClient) CreatePort(ctx context.Context, opts ports.CreateOpts, extraOpts *ExtraCreatePortOpts) (*ports.Port, error) {
+	return retry(ctx, me, "CreatePort", func() (*ports.Port, error) {
+		return me.OpenstackClient.CreatePort(ctx, opts, extraOpts)
+	})
+}
+
+func (me *RetryingClient) CreateSecurityGroup(ctx context.Context, name, description string) (*SecurityGroup, error) {
+	return retry(ctx, me, "CreateSecurityGroup", func() (*SecurityGroup, error) {
+		return me.OpenstackClient.CreateSecurityGroup(ctx, name, description)
+	})
+}
+
+// DeletePort deletes the port
+func (me *RetryingClient) DeletePort(ctx context.Context, portId string) error {
+	return retryDelete(ctx, me, "DeletePort", func() error {
+		return me.OpenstackClient.DeletePort(ctx, portId)
+	})
+}
+
+// Detach port removes a port's relationship from a server
+func (me *RetryingClient) DetachPort(ctx context.Context, portId, serverId string) error {
+	return retryDelete(ctx, me, "DetachPort", func() error {
+		return me.OpenstackClient.DetachPort(ctx, portId, serverId)
+	})
+}
+
+func (me *RetryingClient) GetNetwork(ctx context.Context, id string) (*Network, error) {
+	return retry(ctx, me, "GetNetwork", func() (*Network, error) {
+		return me.OpenstackClient.GetNetwork(ctx, id)
+	})
+}
+
+func (me *RetryingClient) GetNetworkByName(ctx context.Context, name string) (*Network, error) {
+	return retry(ctx, me, "GetNetworkByName", func() (*Network, error) {
+		return me.OpenstackClient.GetNetworkByName(ctx, name)
+	})
+}
+
+func (me *RetryingClient) GetPort(ctx context.Context, portId string) (*ports.Port, error) {
+	return retry(ctx, me, "GetPort", func() (*ports.Port, error) {
+		return me.OpenstackClient.GetPort(ctx, portId)
+	})
+}
+
+func (me *RetryingClient) GetPortWithBinding(ctx context.Context, portId string) (*PortWithBinding, error) {
+	return retry(ctx, me, "GetPortWithBinding", func() (*PortWithBinding, error) {
+		return me.OpenstackClient.GetPortWithBinding(ctx, portId)
+	})
+}
+
+func (me *RetryingClient) GetPortsByDeviceId(ctx context.Context, deviceId string) ([]ports.Port, error) {
+	return retry(ctx, me, "GetPortsByDeviceId", func() ([]ports.Port, error) {
+		return me.OpenstackClient.GetPortsByDeviceId(ctx, deviceId)
+	})
+}
+
+func (me *RetryingClient) GetPortByTags(ctx context.Context, tags []string) (*ports.Port, error) {
+	return retry(ctx, me, "GetPortByTags", func() (*ports.Port, error) {
+		return me.OpenstackClient.GetPortByTags(ctx, tags)
+	})
+}
+
+func (me *RetryingClient) GetPortsByTags(ctx context.Context, tags []string) ([]ports.Port, error) {
+	return retry(ctx, me, "GetPortsByTags", func() ([]ports.Port, error) {
+		return me.OpenstackClient.GetPortsByTags(ctx, tags)
+	})
+}
+
+func (me *RetryingClient) GetProjectByName(ctx context.Context, name string) (*projects.Project, error) {
+	return retry(ctx, me, "GetProjectByName", func() (*projects.Project, error) {
+		return me.OpenstackClient.GetProjectByName(ctx, name)
+	})
+}
+
+func (me *RetryingClient) GetServer(ctx context.Context, id string) (*servers.Server, error) {
+	return retry(ctx, me, "GetServer", func() (*servers.Server, error) {
+		return me.OpenstackClient.GetServer(ctx, id)
+	})
+}
+
+func (me *RetryingClient) GetServerByName(ctx context.Context, name string) (*servers.Server, error) {
+	return retry(ctx, me, "GetServerByName", func() (*servers.Server, error) {
+		return me.OpenstackClient.GetServerByName(ctx, name)
+	})
+}
+
+func (me *RetryingClient) GetSecurityGroupByName(ctx context.Context, name string, projectId string) (*groups.SecGroup, error) {
+	return retry(ctx, me, "GetSecurityGroupByName", func() (*groups.SecGroup, error) {
+		return me.OpenstackClient.GetSecurityGroupByName(ctx, name, projectId)
+	})
+}
+
+func (me *RetryingClient) GetSecurityGroupsByName(ctx context.Context, name string) ([]SecurityGroup, error) {
+	return retry(ctx, me, "GetSecurityGroupsByName", func() ([]SecurityGroup, error) {
+		return me.OpenstackClient.GetSecurityGroupsByName(ctx, name)
+	})
+}
+
+func (me *RetryingClient) GetSubnet(ctx context.Context, id string) (*subnets.Subnet, error) {
+	return retry(ctx, me, "GetSubnet", func() (*subnets.Subnet, error) {
+		return me.OpenstackClient.GetSubnet(ctx, id)
+	})
+}
+
+func (me *RetryingClient) GetSubnetByName(ctx context.Context, name, networkId string) (*subnets.Subnet, error) {
+	return retry(ctx, me, "GetSubnetByName", func() (*subnets.Subnet, error) {
+		return me.OpenstackClient.GetSubnetByName(ctx, name, networkId)
+	})
+}
+
+// SetPortTags replaces the tags of a port
+func (me *RetryingClient) SetPortTags(ctx context.Context, portId string, tags []string) error {
+	return retryErr(ctx, me, "SetPortTags", func() error {
+		return me.OpenstackClient.SetPortTags(ctx, portId, tags)
+	})
+}
+
+func (me *RetryingClient) UpdateSecurityGroupDescription(ctx context.Context, id, description string, revisionNumber int) error {
+	return retryErr(ctx, me, "UpdateSecurityGroupDescription", func() error {
+		return me.OpenstackClient.UpdateSecurityGroupDescription(ctx, id, description, revisionNumber)
+	})
+}
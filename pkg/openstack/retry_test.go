@@ -0,0 +1,210 @@
+package openstack_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/jboelensns/openstack-cni/pkg/fixtures/mocks"
+	"github.com/jboelensns/openstack-cni/pkg/openstack"
+	. "github.com/pepinns/go-hamcrest"
+)
+
+type retryCounter struct {
+	retried   map[string]int
+	exhausted map[string]int
+}
+
+func (me *retryCounter) Retried(operation string) {
+	me.retried[operation]++
+}
+
+func (me *retryCounter) Exhausted(operation string) {
+	me.exhausted[operation]++
+}
+
+func statusError(code int) error {
+	return gophercloud.ErrUnexpectedResponseCode{Actual: code}
+}
+
+func Test_RetryingClient(t *testing.T) {
+	opts := openstack.RetryOpts{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+	newClient := func() (*mocks.OpenstackClientMock, *retryCounter, openstack.OpenstackClient) {
+		mock := &mocks.OpenstackClientMock{}
+		counter := &retryCounter{retried: map[string]int{}, exhausted: map[string]int{}}
+		return mock, counter, openstack.NewRetryingClient(mock, opts, counter)
+	}
+
+	t.Run("retries transient errors until the operation succeeds", func(t *testing.T) {
+		mock, counter, client := newClient()
+		mock.AssignPortFunc = func(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error) {
+			if len(mock.AssignPortCalls()) < 3 {
+				return nil, gophercloud.ErrDefault409{ErrUnexpectedResponseCode: gophercloud.ErrUnexpectedResponseCode{Actual: 409}}
+			}
+			return &attachinterfaces.Interface{PortID: portId}, nil
+		}
+
+		iface, err := client.AssignPort(context.Background(), "port", "server")
+		Assert(t).That(err, IsNil())
+		Assert(t).That(iface.PortID, Equals("port"))
+		Assert(t).That(len(mock.AssignPortCalls()), Equals(3))
+		Assert(t).That(counter.retried["AssignPort"], Equals(2))
+		Assert(t).That(counter.exhausted["AssignPort"], Equals(0))
+	})
+
+	t.Run("gives up after the maximum attempts", func(t *testing.T) {
+		mock, counter, client := newClient()
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error {
+			return statusError(503)
+		}
+
+		err := client.DeletePort(context.Background(), "port")
+		Assert(t).That(err, Not(IsNil()))
+		Assert(t).That(len(mock.DeletePortCalls()), Equals(3))
+		Assert(t).That(counter.exhausted["DeletePort"], Equals(1))
+	})
+
+	t.Run("stops retrying when the context is cancelled", func(t *testing.T) {
+		mock, _, client := newClient()
+		ctx, cancel := context.WithCancel(context.Background())
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error {
+			cancel()
+			return statusError(503)
+		}
+
+		err := client.DeletePort(ctx, "port")
+		Assert(t).That(err, Not(IsNil()))
+		Assert(t).That(len(mock.DeletePortCalls()), Equals(1))
+	})
+
+	t.Run("doesn't retry fatal errors", func(t *testing.T) {
+		mock, counter, client := newClient()
+		mock.GetPortFunc = func(ctx context.Context, portId string) (*ports.Port, error) {
+			return nil, statusError(404)
+		}
+
+		_, err := client.GetPort(context.Background(), "port")
+		Assert(t).That(err, Not(IsNil()))
+		Assert(t).That(len(mock.GetPortCalls()), Equals(1))
+		Assert(t).That(counter.retried["GetPort"], Equals(0))
+	})
+
+	t.Run("only retries port creation when the request wasn't processed", func(t *testing.T) {
+		mock, _, client := newClient()
+		mock.CreatePortFunc = func(ctx context.Context, opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) {
+			return nil, statusError(500)
+		}
+		_, err := client.CreatePort(context.Background(), ports.CreateOpts{}, nil)
+		Assert(t).That(err, Not(IsNil()))
+		Assert(t).That(len(mock.CreatePortCalls()), Equals(1))
+
+		mock.CreatePortFunc = func(ctx context.Context, opts ports.CreateOpts, extraOpts *openstack.ExtraCreatePortOpts) (*ports.Port, error) {
+			return nil, statusError(503)
+		}
+		_, err = client.CreatePort(context.Background(), ports.CreateOpts{}, nil)
+		Assert(t).That(err, Not(IsNil()))
+		Assert(t).That(len(mock.CreatePortCalls()), Equals(4))
+	})
+
+	t.Run("only retries attaching ports when the request was rejected or wasn't processed", func(t *testing.T) {
+		mock, _, client := newClient()
+		mock.AssignPortFunc = func(ctx context.Context, portId, serverId string) (*attachinterfaces.Interface, error) {
+			return nil, statusError(500)
+		}
+
+		_, err := client.AssignPort(context.Background(), "port", "server")
+		Assert(t).That(err, Not(IsNil()))
+		Assert(t).That(len(mock.AssignPortCalls()), Equals(1))
+	})
+
+	t.Run("only retries deleting ports when the request wasn't processed", func(t *testing.T) {
+		mock, _, client := newClient()
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error {
+			return statusError(500)
+		}
+
+		err := client.DeletePort(context.Background(), "port")
+		Assert(t).That(err, Not(IsNil()))
+		Assert(t).That(len(mock.DeletePortCalls()), Equals(1))
+	})
+
+	t.Run("a 404 on a retried delete or detach is a success", func(t *testing.T) {
+		mock, _, client := newClient()
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error {
+			if len(mock.DeletePortCalls()) == 1 {
+				return statusError(503)
+			}
+			return statusError(404)
+		}
+		mock.DetachPortFunc = func(ctx context.Context, portId, serverId string) error {
+			if len(mock.DetachPortCalls()) == 1 {
+				return statusError(504)
+			}
+			return statusError(404)
+		}
+
+		Assert(t).That(client.DeletePort(context.Background(), "port"), IsNil())
+		Assert(t).That(len(mock.DeletePortCalls()), Equals(2))
+		Assert(t).That(client.DetachPort(context.Background(), "port", "server"), IsNil())
+		Assert(t).That(len(mock.DetachPortCalls()), Equals(2))
+	})
+
+	t.Run("a 404 on the first delete is still an error", func(t *testing.T) {
+		mock, _, client := newClient()
+		mock.DeletePortFunc = func(ctx context.Context, portId string) error {
+			return statusError(404)
+		}
+
+		err := client.DeletePort(context.Background(), "port")
+		Assert(t).That(err, Not(IsNil()))
+		Assert(t).That(len(mock.DeletePortCalls()), Equals(1))
+	})
+}
+
+func Test_RetryClassification(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset")}
+	reauthErr := gophercloud.ErrErrorAfterReauthentication{ErrOriginal: statusError(503)}
+
+	tests := []struct {
+		name        string
+		err         error
+		retryable   bool
+		unprocessed bool
+		rejected    bool
+	}{
+		{"400", statusError(400), false, false, false},
+		{"401", statusError(401), true, true, true},
+		{"404", statusError(404), false, false, false},
+		{"409", statusError(409), true, false, true},
+		{"429", statusError(429), true, true, true},
+		{"500", statusError(500), true, false, false},
+		{"503", statusError(503), true, true, true},
+		{"503 after reauthentication", reauthErr, true, true, true},
+		{"wrapped 503", fmt.Errorf("failed err=%w", statusError(503)), true, true, true},
+		{"dial error", dialErr, true, true, true},
+		{"read error", readErr, true, false, false},
+		{"other error", errors.New("BOOM"), false, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			Assert(t).That(openstack.IsRetryableError(test.err), Equals(test.retryable))
+			Assert(t).That(openstack.IsUnprocessedError(test.err), Equals(test.unprocessed))
+			Assert(t).That(openstack.IsRejectedError(test.err), Equals(test.rejected))
+		})
+	}
+}
+
+func Test_RetryDelay(t *testing.T) {
+	opts := openstack.RetryOpts{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 10: time.Second} {
+		delay := opts.Delay(attempt)
+		Assert(t).That(delay >= max/2 && delay <= max, IsTrue(), fmt.Sprintf("attempt=%d delay=%s", attempt, delay))
+	}
+}
@@ -0,0 +1,51 @@
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	. "github.com/jboelensns/openstack-cni/pkg/logging"
+)
+
+// rollbackTimeout bounds the compensating actions, they don't stop when the request is cancelled
+const rollbackTimeout = 60 * time.Second
+
+// rollback records compensating actions for completed steps so that they can be undone when a later step fails
+type rollback struct {
+	steps []rollbackStep
+}
+
+type rollbackStep struct {
+	name string
+	undo func(ctx context.Context) error
+}
+
+// Add records the compensating action for a completed step
+func (me *rollback) Add(name string, undo func(ctx context.Context) error) {
+	me.steps = append(me.steps, rollbackStep{name: name, undo: undo})
+}
+
+// Run executes the compensating actions in reverse order
+// the returned error contains the original error along with any errors encountered while rolling back
+// the actions still run when ctx is cancelled since a cancellation is a common reason to roll back
+func (me *rollback) Run(ctx context.Context, err error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+
+	errs := multierror.Append(nil, err)
+	for i := len(me.steps) - 1; i >= 0; i-- {
+		step := me.steps[i]
+		Log().Info().Str("step", step.name).AnErr("cause", err).Msg("rolling back")
+		if rerr := step.undo(ctx); rerr != nil {
+			Log().Error().Str("step", step.name).AnErr("err", rerr).Msg("failed to roll back")
+			errs = multierror.Append(errs, fmt.Errorf("failed to roll back %s: %w", step.name, rerr))
+		}
+	}
+	me.steps = nil
+	if len(errs.Errors) == 1 {
+		return err
+	}
+	return errs
+}
@@ -1,6 +1,7 @@
 package openstack_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
@@ -101,13 +102,13 @@ func purgeOldTags(t *testing.T, tagger *openstack.NeutronTagger, id string) {
 }
 
 func WithPort(t *testing.T, cfg TestingConfig, client openstack.OpenstackClient, callback func(*ports.Port)) {
-	network, err := client.GetNetworkByName(cfg.NetworkName)
+	network, err := client.GetNetworkByName(context.Background(), cfg.NetworkName)
 	Assert(t).That(err, IsNil())
 
-	project, err := client.GetProjectByName(cfg.ProjectName)
+	project, err := client.GetProjectByName(context.Background(), cfg.ProjectName)
 	Assert(t).That(err, IsNil())
 
-	port, err := client.CreatePort(ports.CreateOpts{
+	port, err := client.CreatePort(context.Background(), ports.CreateOpts{
 		NetworkID:  network.ID,
 		Name:       "openstack-cni-unit-test",
 		ProjectID:  project.ID,
@@ -116,7 +117,7 @@ func WithPort(t *testing.T, cfg TestingConfig, client openstack.OpenstackClient,
 	Assert(t).That(err, IsNil())
 
 	defer func() {
-		Assert(t).That(client.DeletePort(port.ID), IsNil())
+		Assert(t).That(client.DeletePort(context.Background(), port.ID), IsNil())
 	}()
 	callback(port)
 }
@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/containernetworking/cni/pkg/types"
+	currentcni "github.com/containernetworking/cni/pkg/types/100"
+	cniversion "github.com/containernetworking/cni/pkg/version"
 	"github.com/jboelensns/openstack-cni/pkg/logging"
 	"github.com/joho/godotenv"
 )
@@ -49,6 +51,57 @@ func (me *CniContext) GetArg(name string) string {
 	return val
 }
 
+// RequestedIPs returns the addresses requested for this pod
+// the runtimeConfig `ips` (multus) take precedence over the `IP` CNI arg
+// the `IP` arg is a comma separated list; a prefix length on an address is ignored
+func (me *CniContext) RequestedIPs() []string {
+	requested := me.CniConfig.RuntimeConfig.IPs
+	if len(requested) == 0 && me.GetArg("IP") != "" {
+		requested = strings.Split(me.GetArg("IP"), ",")
+	}
+
+	ips := make([]string, 0, len(requested))
+	for _, ip := range requested {
+		ip, _, _ = strings.Cut(strings.TrimSpace(ip), "/")
+		if ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// FixedIPs returns the fixed ips that should be requested when creating the port
+// addresses requested for the pod override the configured fixed_ips, a requested address keeps the subnet
+// of the fixed_ips entry with the same address or else subnet_name/subnet_id, without either neutron
+// picks the subnet of the network containing the address
+func (me *CniContext) FixedIPs() []FixedIP {
+	if requested := me.RequestedIPs(); len(requested) > 0 {
+		fixedIps := make([]FixedIP, len(requested))
+		for i, ip := range requested {
+			fixedIps[i] = me.subnetOf(ip)
+			fixedIps[i].IpAddress = ip
+		}
+		return fixedIps
+	}
+	if len(me.CniConfig.FixedIPs) > 0 {
+		return me.CniConfig.FixedIPs
+	}
+	if me.CniConfig.SubnetName != "" || me.CniConfig.SubnetId != "" {
+		return []FixedIP{{SubnetName: me.CniConfig.SubnetName, SubnetID: me.CniConfig.SubnetId}}
+	}
+	return nil
+}
+
+// subnetOf returns the subnet a requested address is allocated from
+func (me *CniContext) subnetOf(ip string) FixedIP {
+	for _, fixedIp := range me.CniConfig.FixedIPs {
+		if fixedIp.IpAddress == ip {
+			return FixedIP{SubnetName: fixedIp.SubnetName, SubnetID: fixedIp.SubnetID}
+		}
+	}
+	return FixedIP{SubnetName: me.CniConfig.SubnetName, SubnetID: me.CniConfig.SubnetId}
+}
+
 // CniCommand contains all of the data required for a CNI command
 type CniCommand struct {
 	Command     string `json:"command,omitempty"`
@@ -87,25 +140,31 @@ func (me CniCommand) ForLog() [][]string {
       "name": "service-ingress",
       "network": "compute-internal",
       "security_groups": ["dp_default", "default"]
-      "enable_port_security": true
+      "enable_port_security": true,
+      "default_route": true
     }'
 */
 type CniConfig struct {
 	*types.NetConf
-	AllowedAddressPairs []AddressPair `json:"allowed_address_pairs,omitempty"`
-	AdminStateUp        *bool         `json:"admin_state_up,omitempty"`
-	DeviceId            string        `json:"device_id,omitempty"`
-	DeviceOwner         string        `json:"device_owner,omitempty"`
-	// FixedIPs string `json:"fixed_ips,omitempty"`
-	MacAddress      string             `json:"mac_address,omitempty"`
-	Network         string             `json:"network,omitempty"`
-	PortDescription string             `json:"port_description,omitempty"`
-	PortName        string             `json:"port_name,omitempty"`
-	ProjectName     string             `json:"project_name,omitempty"`
-	SecurityGroups  *[]string          `json:"security_groups,omitempty"`
-	SubnetName      string             `json:"subnet_name,omitempty"`
-	TenantId        string             `json:"tenant_id,omitempty"`
-	ValueSpecs      *map[string]string `json:"value_specs,omitempty"`
+	AllowedAddressPairs []AddressPair      `json:"allowed_address_pairs,omitempty"`
+	AdminStateUp        *bool              `json:"admin_state_up,omitempty"`
+	DeviceId            string             `json:"device_id,omitempty"`
+	DefaultRoute        bool               `json:"default_route,omitempty"`
+	DeviceOwner         string             `json:"device_owner,omitempty"`
+	FixedIPs            []FixedIP          `json:"fixed_ips,omitempty"`
+	MacAddress          string             `json:"mac_address,omitempty"`
+	Network             string             `json:"network,omitempty"`
+	NetworkId           string             `json:"network_id,omitempty"`
+	PortDescription     string             `json:"port_description,omitempty"`
+	PortName            string             `json:"port_name,omitempty"`
+	ProjectName         string             `json:"project_name,omitempty"`
+	ProjectId           string             `json:"project_id,omitempty"`
+	SecurityGroups      *[]string          `json:"security_groups,omitempty"`
+	SecurityGroupIds    []string           `json:"security_group_ids,omitempty"`
+	SubnetName          string             `json:"subnet_name,omitempty"`
+	SubnetId            string             `json:"subnet_id,omitempty"`
+	TenantId            string             `json:"tenant_id,omitempty"`
+	ValueSpecs          *map[string]string `json:"value_specs,omitempty"`
 	// PortSecurityEnabled toggles port security on a port.
 	PortSecurityEnabled *bool `json:"port_security_enabled,omitempty"`
 	// The ID of the host where the port is allocated.
@@ -117,6 +176,22 @@ type CniConfig struct {
 	// host to pass and receive virtual network interface (VIF) port-specific
 	// information to the plug-in.
 	Profile map[string]interface{} `json:"binding:profile,omitempty"`
+	// RuntimeConfig is injected by the runtime for the capabilities enabled in the config
+	RuntimeConfig RuntimeConfig `json:"runtimeConfig,omitempty"`
+}
+
+// FixedIP requests an address from a subnet
+// the subnet can be referenced by name or ID and the address is optional
+type FixedIP struct {
+	SubnetName string `json:"subnet_name,omitempty"`
+	SubnetID   string `json:"subnet_id,omitempty"`
+	IpAddress  string `json:"ip_address,omitempty"`
+}
+
+// RuntimeConfig contains the runtime supplied capability arguments
+// multus fills in ips from the pod's network selection annotation when the `ips` capability is enabled
+type RuntimeConfig struct {
+	IPs []string `json:"ips,omitempty"`
 }
 
 func NewCniConfig(bytes []byte) (CniConfig, error) {
@@ -127,7 +202,7 @@ func NewCniConfig(bytes []byte) (CniConfig, error) {
 	if conf.PortName == "" {
 		conf.PortName = Getenv("OS_PORT_NAME", "openstack-cni")
 	}
-	if conf.ProjectName == "" {
+	if conf.ProjectName == "" && conf.ProjectId == "" {
 		conf.ProjectName = Getenv("OS_PROJECT_NAME", "")
 	}
 	if conf.DeviceOwner == "" {
@@ -139,7 +214,55 @@ func NewCniConfig(bytes []byte) (CniConfig, error) {
 		conf.PortSecurityEnabled = &t
 	}
 
-	return *conf, nil
+	return *conf, conf.Validate()
+}
+
+// Validate rejects configurations referencing a resource both by name and by ID
+// since it's unclear which one should win when they don't match, the same goes for
+// a subnet next to fixed_ips
+func (me *CniConfig) Validate() error {
+	conflicts := []struct {
+		name, id string
+		hasName  bool
+		hasId    bool
+	}{
+		{"network", "network_id", me.Network != "", me.NetworkId != ""},
+		{"subnet_name", "subnet_id", me.SubnetName != "", me.SubnetId != ""},
+		{"project_name", "project_id", me.ProjectName != "", me.ProjectId != ""},
+		{"security_groups", "security_group_ids", me.SecurityGroups != nil && len(*me.SecurityGroups) > 0, len(me.SecurityGroupIds) > 0},
+	}
+	for _, conflict := range conflicts {
+		if conflict.hasName && conflict.hasId {
+			return fmt.Errorf("%s and %s are mutually exclusive", conflict.name, conflict.id)
+		}
+	}
+	if len(me.FixedIPs) > 0 && (me.SubnetName != "" || me.SubnetId != "") {
+		return fmt.Errorf("subnet_name and subnet_id can't be combined with fixed_ips, set the subnet of every fixed_ips entry instead")
+	}
+	for _, fixedIp := range me.FixedIPs {
+		if fixedIp.SubnetName != "" && fixedIp.SubnetID != "" {
+			return fmt.Errorf("subnet_name and subnet_id of fixed_ips are mutually exclusive")
+		}
+	}
+	return nil
+}
+
+var ErrMissingPrevResult = fmt.Errorf("missing prevResult")
+
+// NewPrevResult parses the prevResult out of CNI stdin data and converts it to the current result type
+// ErrMissingPrevResult is returned if the configuration doesn't contain a prevResult
+func NewPrevResult(bytes []byte) (*currentcni.Result, error) {
+	var netConf types.NetConf
+	if err := json.Unmarshal(bytes, &netConf); err != nil {
+		return nil, fmt.Errorf("failed to load config data, error = %+v", err)
+	}
+	if err := cniversion.ParsePrevResult(&netConf); err != nil {
+		return nil, err
+	}
+	if netConf.PrevResult == nil {
+		return nil, ErrMissingPrevResult
+	}
+	return currentcni.NewResultFromResult(netConf.PrevResult)
 }
 
 type AddressPair struct {
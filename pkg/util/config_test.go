@@ -35,3 +35,68 @@ func Test_ParsingArgs(t *testing.T) {
 		))
 	})
 }
+
+func Test_FixedIPs(t *testing.T) {
+	newContext := func(t *testing.T, stdin, args string) util.CniContext {
+		context, err := util.NewCniContext(util.CniCommand{StdinData: []byte(stdin), Args: args})
+		Assert(t).That(err, IsNil())
+		return context
+	}
+
+	t.Run("uses the configured fixed ips", func(t *testing.T) {
+		context := newContext(t, `{"fixed_ips":[{"subnet_name":"mysubnet","ip_address":"10.0.0.5"},{"subnet_id":"subnetId"}]}`, "")
+		Assert(t).That(context.FixedIPs(), Equals([]util.FixedIP{
+			{SubnetName: "mysubnet", IpAddress: "10.0.0.5"},
+			{SubnetID: "subnetId"},
+		}))
+	})
+	t.Run("falls back to subnet_name", func(t *testing.T) {
+		context := newContext(t, `{"subnet_name":"mysubnet"}`, "")
+		Assert(t).That(context.FixedIPs(), Equals([]util.FixedIP{{SubnetName: "mysubnet"}}))
+	})
+	t.Run("returns nothing when no subnet or address was requested", func(t *testing.T) {
+		context := newContext(t, `{}`, "")
+		Assert(t).That(context.FixedIPs(), HasLen(0))
+	})
+	t.Run("the IP arg is allocated from subnet_name", func(t *testing.T) {
+		context := newContext(t, `{"subnet_name":"mysubnet"}`, "K8S_POD_NAME=mypod;IP=10.0.0.5,2001:db8::5")
+		Assert(t).That(context.FixedIPs(), Equals([]util.FixedIP{
+			{SubnetName: "mysubnet", IpAddress: "10.0.0.5"},
+			{SubnetName: "mysubnet", IpAddress: "2001:db8::5"},
+		}))
+	})
+	t.Run("the IP arg keeps the subnet of the fixed ip with the same address", func(t *testing.T) {
+		context := newContext(t, `{"fixed_ips":[{"subnet_name":"mysubnet","ip_address":"10.0.0.5"},{"subnet_id":"subnetId","ip_address":"2001:db8::5"}]}`, "IP=10.0.0.6,2001:db8::5")
+		Assert(t).That(context.FixedIPs(), Equals([]util.FixedIP{
+			{IpAddress: "10.0.0.6"},
+			{SubnetID: "subnetId", IpAddress: "2001:db8::5"},
+		}))
+	})
+	t.Run("the runtimeConfig ips take precedence over the IP arg", func(t *testing.T) {
+		context := newContext(t, `{"runtimeConfig":{"ips":["10.0.0.6/24"]}}`, "IP=10.0.0.5")
+		Assert(t).That(context.FixedIPs(), Equals([]util.FixedIP{{IpAddress: "10.0.0.6"}}))
+	})
+}
+
+func Test_CniConfigValidate(t *testing.T) {
+	t.Run("accepts resources referenced by ID", func(t *testing.T) {
+		conf, err := util.NewCniConfig([]byte(`{"network_id":"networkId","subnet_id":"subnetId","project_id":"projectId","security_group_ids":["sgId"]}`))
+		Assert(t).That(err, IsNil())
+		Assert(t).That(conf.NetworkId, Equals("networkId"))
+		Assert(t).That(conf.ProjectName, Equals(""))
+	})
+	t.Run("rejects a resource referenced by name and ID", func(t *testing.T) {
+		for config, expected := range map[string]string{
+			`{"network":"mynetwork","network_id":"networkId"}`:                   "network and network_id",
+			`{"subnet_name":"mysubnet","subnet_id":"subnetId"}`:                  "subnet_name and subnet_id",
+			`{"project_name":"myproject","project_id":"projectId"}`:              "project_name and project_id",
+			`{"security_groups":["default"],"security_group_ids":["sgId"]}`:      "security_groups and security_group_ids",
+			`{"fixed_ips":[{"subnet_name":"mysubnet","subnet_id":"subnetId"}]}`:  "fixed_ips",
+			`{"subnet_name":"mysubnet","fixed_ips":[{"ip_address":"10.0.0.5"}]}`: "fixed_ips",
+			`{"subnet_id":"subnetId","fixed_ips":[{"subnet_name":"mysubnet"}]}`:  "fixed_ips",
+		} {
+			_, err := util.NewCniConfig([]byte(config))
+			Assert(t).That(err.Error(), Contains(expected))
+		}
+	})
+}
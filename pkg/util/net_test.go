@@ -37,4 +37,27 @@ func Test_IpParsing(t *testing.T) {
 		Assert(t).That(err, IsNil())
 		Assert(t).That(ip.String(), Equals(address))
 	})
+
+	t.Run("from a dual-stack port result", func(t *testing.T) {
+		pr := &openstack.SetupPortResult{
+			Subnets: map[string]*subnets.Subnet{
+				"V4ID": {ID: "V4ID", CIDR: "198.18.182.0/24"},
+				"V6ID": {ID: "V6ID", CIDR: "2001:db8::/64"},
+			},
+			Port: &ports.Port{
+				FixedIPs: []ports.IP{
+					{SubnetID: "V4ID", IPAddress: "198.18.182.36"},
+					{SubnetID: "V6ID", IPAddress: "2001:db8::36"},
+				},
+			},
+		}
+
+		addresses, err := pr.GetAddresses()
+		Assert(t).That(err, IsNil())
+		Assert(t).That(addresses, HasLen(2))
+		Assert(t).That(addresses[0].Address.String(), Equals("198.18.182.36/24"))
+		Assert(t).That(addresses[0].Version(), Equals("4"))
+		Assert(t).That(addresses[1].Address.String(), Equals("2001:db8::36/64"))
+		Assert(t).That(addresses[1].Version(), Equals("6"))
+	})
 }
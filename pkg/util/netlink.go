@@ -10,15 +10,21 @@ import (
 // NetlinkWrapper allows us to test without actually using netlink
 type NetlinkWrapper interface {
 	AddrAdd(link netlink.Link, addr *netlink.Addr) error
+	AddrDel(link netlink.Link, addr *netlink.Addr) error
+	AddrList(link netlink.Link, family int) ([]netlink.Addr, error)
 	AddrReplace(link netlink.Link, addr *netlink.Addr) error
 	GetNetNsIdByPath(namespace string) (int, error)
 	GetNetNsIdByPid(pid int) (int, error)
 	LinkByIndex(index int) (netlink.Link, error)
 	LinkByName(ifname string) (netlink.Link, error)
+	LinkList() ([]netlink.Link, error)
 	LinkSetDown(link netlink.Link) error
+	LinkSetMTU(link netlink.Link, mtu int) error
 	LinkSetName(link netlink.Link, name string) error
 	LinkSetNsFd(link netlink.Link, fd int) error
 	LinkSetUp(link netlink.Link) error
+	LinkSubscribe(ch chan<- netlink.LinkUpdate, done <-chan struct{}) error
+	RouteReplace(route *netlink.Route) error
 }
 
 func NewNetlinkWrapper() *netlinkWrapper {
@@ -40,6 +46,14 @@ func (me *netlinkWrapper) AddrAdd(link netlink.Link, addr *netlink.Addr) error {
 	return netlink.AddrAdd(link, addr)
 }
 
+func (me *netlinkWrapper) AddrDel(link netlink.Link, addr *netlink.Addr) error {
+	return netlink.AddrDel(link, addr)
+}
+
+func (me *netlinkWrapper) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	return netlink.AddrList(link, family)
+}
+
 func (me *netlinkWrapper) AddrReplace(link netlink.Link, addr *netlink.Addr) error {
 	return netlink.AddrReplace(link, addr)
 }
@@ -56,10 +70,18 @@ func (me *netlinkWrapper) LinkByName(ifname string) (netlink.Link, error) {
 	return netlink.LinkByName(ifname)
 }
 
+func (me *netlinkWrapper) LinkList() ([]netlink.Link, error) {
+	return netlink.LinkList()
+}
+
 func (me *netlinkWrapper) LinkSetDown(link netlink.Link) error {
 	return netlink.LinkSetDown(link)
 }
 
+func (me *netlinkWrapper) LinkSetMTU(link netlink.Link, mtu int) error {
+	return netlink.LinkSetMTU(link, mtu)
+}
+
 func (me *netlinkWrapper) LinkSetName(link netlink.Link, name string) error {
 	return netlink.LinkSetName(link, name)
 }
@@ -71,3 +93,11 @@ func (me *netlinkWrapper) LinkSetNsFd(link netlink.Link, fd int) error {
 func (me *netlinkWrapper) LinkSetUp(link netlink.Link) error {
 	return netlink.LinkSetUp(link)
 }
+
+func (me *netlinkWrapper) LinkSubscribe(ch chan<- netlink.LinkUpdate, done <-chan struct{}) error {
+	return netlink.LinkSubscribe(ch, done)
+}
+
+func (me *netlinkWrapper) RouteReplace(route *netlink.Route) error {
+	return netlink.RouteReplace(route)
+}
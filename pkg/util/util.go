@@ -1,9 +1,13 @@
 package util
 
 import (
+	"crypto/x509"
 	"encoding/json"
+	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 )
 
 func FromJson(data []byte, i any) error {
@@ -59,3 +63,46 @@ func FileExists(file string) (bool, error) {
 func DirExists(dir string) (bool, error) {
 	return fileEntryExists(dir, true)
 }
+
+// ParseApiUrl returns the network and address used to reach the daemon at apiUrl
+// unix:///run/openstack-cni/daemon.sock yields ("unix", "/run/openstack-cni/daemon.sock")
+// http://127.0.0.1:4242 yields ("tcp", "127.0.0.1:4242")
+func ParseApiUrl(apiUrl string) (network, address string, err error) {
+	u, err := url.Parse(apiUrl)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme == "unix" {
+		if u.Path == "" {
+			return "", "", fmt.Errorf("missing socket path in %s", apiUrl)
+		}
+		return "unix", u.Path, nil
+	}
+	return "tcp", u.Host, nil
+}
+
+// ReadToken reads a token from file, surrounding whitespace is ignored
+func ReadToken(file string) (string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", file)
+	}
+	return token, nil
+}
+
+// LoadCertPool creates a cert pool from a PEM encoded file
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,30 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/jboelensns/openstack-cni/pkg/util"
+
+	. "github.com/pepinns/go-hamcrest"
+)
+
+func Test_ParseApiUrl(t *testing.T) {
+	t.Run("http url listens on tcp", func(t *testing.T) {
+		network, addr, err := util.ParseApiUrl("http://127.0.0.1:4242")
+		Assert(t).That(err, IsNil())
+		Assert(t).That(network, Equals("tcp"))
+		Assert(t).That(addr, Equals("127.0.0.1:4242"))
+	})
+
+	t.Run("unix url listens on the socket path", func(t *testing.T) {
+		network, addr, err := util.ParseApiUrl("unix:///run/openstack-cni/daemon.sock")
+		Assert(t).That(err, IsNil())
+		Assert(t).That(network, Equals("unix"))
+		Assert(t).That(addr, Equals("/run/openstack-cni/daemon.sock"))
+	})
+
+	t.Run("unix url without a path fails", func(t *testing.T) {
+		_, _, err := util.ParseApiUrl("unix://")
+		Assert(t).That(err, Not(IsNil()))
+	})
+}